@@ -9,15 +9,21 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/containers/image/v5/signature"
+
 	"github.com/osbuild/images/internal/buildconfig"
 	"github.com/osbuild/images/internal/cmdutil"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/remotefile"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
 	"github.com/osbuild/images/pkg/dnfjson"
+	"github.com/osbuild/images/pkg/lockfile"
 	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/mirror"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/reporegistry"
@@ -31,10 +37,14 @@ func makeManifest(
 	distribution distro.Distro,
 	repos []rpmmd.RepoConfig,
 	archName string,
-	cacheRoot string,
-) (manifest.OSBuildManifest, error) {
-	cacheDir := filepath.Join(cacheRoot, archName+distribution.Name())
-
+	solver dnfjson.Depsolver,
+	lockfilePath string,
+	lockfileOut string,
+	rewriter *mirror.Rewriter,
+	signaturePolicy *signature.Policy,
+	requireSignatures bool,
+	registriesConf string,
+) (manifest.OSBuildManifest, []string, error) {
 	options := config.Options
 
 	// add RHSM fact to detect changes
@@ -48,50 +58,108 @@ func makeManifest(
 	}
 	seedArg, err := cmdutil.SeedArgFor(config, imgType.Name(), distribution.Name(), archName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	manifest, warnings, err := imgType.Manifest(&bp, options, repos, seedArg)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] manifest generation failed: %w", err)
+		return nil, nil, fmt.Errorf("[ERROR] manifest generation failed: %w", err)
 	}
 	if len(warnings) > 0 {
 		fmt.Fprintf(os.Stderr, "[WARNING]\n%s", strings.Join(warnings, "\n"))
 	}
+	// the sources of the manifest are fetched from the mirrors
+	manifest.Rewriter = rewriter
 
-	packageSpecs, repoConfigs, err := depsolve(cacheDir, manifest.GetPackageSetChains(), distribution, archName)
-	if err != nil {
-		return nil, fmt.Errorf("[ERROR] depsolve failed: %w", err)
-	}
-	if packageSpecs == nil {
-		return nil, fmt.Errorf("[ERROR] depsolve did not return any packages")
-	}
-	_ = repoConfigs
+	var packageSpecs map[string][]rpmmd.PackageSpec
+	var containerSpecs map[string][]container.Spec
+	var commitSpecs map[string][]ostree.CommitSpec
+	var repoConfigs map[string][]rpmmd.RepoConfig
+	if lockfilePath != "" {
+		lf, err := lockfile.Load(lockfilePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] loading lockfile failed: %w", err)
+		}
+		packageSpecs, containerSpecs, commitSpecs, repoConfigs, err = lf.Resolve(manifest, rewriter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] lockfile %q does not match the manifest: %w", lockfilePath, err)
+		}
+	} else {
+		packageSpecs, repoConfigs, err = depsolve(solver, rewriter.RewritePackageSetChains(manifest.GetPackageSetChains()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] depsolve failed: %w", err)
+		}
+		if packageSpecs == nil {
+			return nil, nil, fmt.Errorf("[ERROR] depsolve did not return any packages")
+		}
 
-	if config.Blueprint != nil {
-		bp = blueprint.Blueprint(*config.Blueprint)
+		containerSpecs, err = resolvePipelineContainers(rewriter.RewriteContainerSources(manifest.GetContainerSourceSpecs()), archName, signaturePolicy, requireSignatures, registriesConf)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] container resolution failed: %w", err)
+		}
+
+		commitSpecs, err = resolvePipelineCommits(rewriter.RewriteOSTreeSources(manifest.GetOSTreeSourceSpecs()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] ostree commit resolution failed: %w", err)
+		}
+
+		// record the original URLs of the content resolved from the mirrors
+		packageSpecs = rewriter.RewritePackageSpecs(packageSpecs)
+		containerSpecs = rewriter.RewriteContainerSpecs(containerSpecs)
+		commitSpecs = rewriter.RewriteCommitSpecs(commitSpecs)
+
+		var remoteFiles []*fsnode.File
+		for _, files := range manifest.GetRemoteFiles() {
+			remoteFiles = append(remoteFiles, files...)
+		}
+		remoteFiles, err = rewriter.RewriteRemoteFiles(remoteFiles)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] remote file verification failed: %w", err)
+		}
+		if err := remotefile.VerifyFiles(remoteFiles); err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] remote file verification failed: %w", err)
+		}
 	}
 
-	containerSpecs, err := resolvePipelineContainers(manifest.GetContainerSourceSpecs(), archName)
-	if err != nil {
-		return nil, fmt.Errorf("[ERROR] container resolution failed: %w", err)
+	if lockfileOut != "" {
+		lf := lockfile.New(packageSpecs, containerSpecs, commitSpecs, repoConfigs, manifest.GetPackageSetChains())
+		if err := lf.Save(lockfileOut); err != nil {
+			return nil, nil, fmt.Errorf("[ERROR] saving lockfile failed: %w", err)
+		}
 	}
 
-	commitSpecs, err := resolvePipelineCommits(manifest.GetOSTreeSourceSpecs())
+	mf, err := manifest.Serialize(packageSpecs, containerSpecs, commitSpecs, nil)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] ostree commit resolution failed: %w", err)
+		return nil, nil, fmt.Errorf("[ERROR] manifest serialization failed: %w", err)
 	}
 
-	mf, err := manifest.Serialize(packageSpecs, containerSpecs, commitSpecs, nil)
+	proxyEnv, err := sourcesProxyEnv(packageSpecs, commitSpecs)
 	if err != nil {
-		return nil, fmt.Errorf("[ERROR] manifest serialization failed: %w", err)
+		return nil, nil, fmt.Errorf("[ERROR] proxy configuration failed: %w", err)
 	}
 
-	return mf, nil
+	return mf, proxyEnv, nil
+}
+
+// sourcesProxyEnv returns the proxy environment of osbuild for the content of
+// all pipelines.
+func sourcesProxyEnv(packageSpecs map[string][]rpmmd.PackageSpec, commitSpecs map[string][]ostree.CommitSpec) ([]string, error) {
+	var packages []rpmmd.PackageSpec
+	for _, specs := range packageSpecs {
+		packages = append(packages, specs...)
+	}
+	var commits []ostree.CommitSpec
+	for _, specs := range commitSpecs {
+		commits = append(commits, specs...)
+	}
+	return osbuild.SourcesProxyEnv(packages, commits)
 }
 
-func resolveContainers(containers []container.SourceSpec, archName string) ([]container.Spec, error) {
+func resolveContainers(containers []container.SourceSpec, archName string, signaturePolicy *signature.Policy, requireSignatures bool, registriesConf string) ([]container.Spec, error) {
 	resolver := container.NewResolver(archName)
+	resolver.SignaturePolicy = signaturePolicy
+	resolver.RequireSignatures = requireSignatures
+	resolver.RegistriesConfPath = registriesConf
 
 	for _, c := range containers {
 		resolver.Add(c)
@@ -100,10 +168,10 @@ func resolveContainers(containers []container.SourceSpec, archName string) ([]co
 	return resolver.Finish()
 }
 
-func resolvePipelineContainers(containerSources map[string][]container.SourceSpec, archName string) (map[string][]container.Spec, error) {
+func resolvePipelineContainers(containerSources map[string][]container.SourceSpec, archName string, signaturePolicy *signature.Policy, requireSignatures bool, registriesConf string) (map[string][]container.Spec, error) {
 	containerSpecs := make(map[string][]container.Spec, len(containerSources))
 	for plName, sourceSpecs := range containerSources {
-		specs, err := resolveContainers(sourceSpecs, archName)
+		specs, err := resolveContainers(sourceSpecs, archName, signaturePolicy, requireSignatures, registriesConf)
 		if err != nil {
 			return nil, err
 		}
@@ -128,8 +196,7 @@ func resolvePipelineCommits(commitSources map[string][]ostree.SourceSpec) (map[s
 	return commits, nil
 }
 
-func depsolve(cacheDir string, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string][]rpmmd.PackageSpec, map[string][]rpmmd.RepoConfig, error) {
-	solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir)
+func depsolve(solver dnfjson.Depsolver, packageSets map[string][]rpmmd.PackageSet) (map[string][]rpmmd.PackageSpec, map[string][]rpmmd.RepoConfig, error) {
 	depsolvedSets := make(map[string][]rpmmd.PackageSpec)
 	repoSets := make(map[string][]rpmmd.RepoConfig)
 	for name, pkgSet := range packageSets {
@@ -144,7 +211,11 @@ func depsolve(cacheDir string, packageSets map[string][]rpmmd.PackageSet, d dist
 }
 
 func save(ms manifest.OSBuildManifest, fpath string) error {
-	b, err := json.MarshalIndent(ms, "", "  ")
+	return saveJSON(ms, fpath)
+}
+
+func saveJSON(data interface{}, fpath string) error {
+	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal data for %q: %w", fpath, err)
 	}
@@ -198,6 +269,27 @@ func run() error {
 	flag.StringVar(&imgTypeName, "type", "", "image type name (required)")
 	flag.StringVar(&configFile, "config", "", "build config file (required)")
 
+	// repository args
+	var repoFiles cmdutil.MultiValue
+	flag.Var(&repoFiles, "repo-files", "comma-separated list of .repo files or directories of .repo files to use instead of the tested repositories")
+	var repoVarsDirs cmdutil.MultiValue
+	flag.Var(&repoVarsDirs, "repo-vars", "comma-separated list of dnf vars directories (e.g. /etc/dnf/vars) with the variables of the -repo-files")
+	var mirrorsPath string
+	flag.StringVar(&mirrorsPath, "mirrors", "", "JSON file with rules to rewrite the URLs of repositories, containers and ostree remotes to mirrors")
+	var registriesConf string
+	flag.StringVar(&registriesConf, "registries-conf", "", "containers-registries.conf(5) file with the registry mirrors to resolve containers with, instead of the one of the system")
+
+	// container signature args
+	var signaturePolicyPath string
+	var requireSignatures bool
+	flag.StringVar(&signaturePolicyPath, "signature-policy", "", "containers-policy.json(5) file to verify the signatures of all containers with")
+	flag.BoolVar(&requireSignatures, "require-signatures", false, "fail if the signature policy does not require a signature for a container")
+
+	// lockfile args
+	var lockfilePath, lockfileOut string
+	flag.StringVar(&lockfilePath, "lockfile", "", "generate the manifest from the packages, containers and commits of a lockfile instead of resolving them")
+	flag.StringVar(&lockfileOut, "write-lockfile", "", "write the resolved packages, containers and commits to a lockfile")
+
 	flag.Parse()
 
 	if distroName == "" || imgTypeName == "" || configFile == "" {
@@ -243,17 +335,44 @@ func run() error {
 	}
 
 	// get repositories
-	repos, err := testedRepoRegistry.ReposByArchName(distroName, archName, true)
-	if err != nil {
-		return fmt.Errorf("failed to get repositories for %s/%s: %w", distroName, archName, err)
+	var repos []rpmmd.RepoConfig
+	if len(repoFiles) > 0 {
+		repoConfigs, err := reporegistry.LoadRepoFiles(repoFiles, repoVarsDirs, archName, distribution.Releasever())
+		if err != nil {
+			return fmt.Errorf("failed to load repositories from %v: %w", repoFiles, err)
+		}
+		repos = repoConfigs[archName]
+	} else {
+		repos, err = testedRepoRegistry.ReposByArchName(distroName, archName, true)
+		if err != nil {
+			return fmt.Errorf("failed to get repositories for %s/%s: %w", distroName, archName, err)
+		}
+		repos = filterRepos(repos, imgTypeName)
 	}
-	repos = filterRepos(repos, imgTypeName)
 	if len(repos) == 0 {
 		return fmt.Errorf("no repositories defined for %s/%s", distroName, archName)
 	}
 
+	var rewriter *mirror.Rewriter
+	if mirrorsPath != "" {
+		rewriter, err = mirror.LoadRewriter(mirrorsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var signaturePolicy *signature.Policy
+	if signaturePolicyPath != "" {
+		signaturePolicy, err = signature.NewPolicyFromFile(signaturePolicyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load signature policy: %w", err)
+		}
+	}
+
 	fmt.Printf("Generating manifest for %s: ", config.Name)
-	mf, err := makeManifest(config, imgType, distribution, repos, archName, rpmCacheRoot)
+	cacheDir := filepath.Join(rpmCacheRoot, archName+distribution.Name())
+	solver := dnfjson.NewSolver(distribution.ModulePlatformID(), distribution.Releasever(), archName, distribution.Name(), cacheDir)
+	mf, proxyEnv, err := makeManifest(config, imgType, distribution, repos, archName, solver, lockfilePath, lockfileOut, rewriter, signaturePolicy, requireSignatures, registriesConf)
 	if err != nil {
 		return err
 	}
@@ -263,11 +382,17 @@ func run() error {
 	if err := save(mf, manifestPath); err != nil {
 		return err
 	}
+	if rewriter != nil {
+		// record the original URLs of the mirrored content
+		if err := saveJSON(rewriter.Rewrites(), filepath.Join(buildDir, "url-rewrites.json")); err != nil {
+			return err
+		}
+	}
 
 	fmt.Printf("Building manifest: %s\n", manifestPath)
 
 	jobOutput := filepath.Join(outputDir, buildName)
-	_, err = osbuild.RunOSBuild(mf, osbuildStore, jobOutput, imgType.Exports(), checkpoints, nil, false, os.Stderr)
+	_, err = osbuild.RunOSBuild(mf, osbuildStore, jobOutput, imgType.Exports(), checkpoints, proxyEnv, false, os.Stderr)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/buildconfig"
+	"github.com/osbuild/images/internal/cmdutil"
+	"github.com/osbuild/images/internal/mocks/rpmrepo"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/mirror"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// curlURLs returns the URLs of the curl sources of a manifest by checksum.
+func curlURLs(t *testing.T, mf []byte) map[string]string {
+	var m struct {
+		Sources struct {
+			Curl struct {
+				Items map[string]json.RawMessage `json:"items"`
+			} `json:"org.osbuild.curl"`
+		} `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(mf, &m))
+	urls := make(map[string]string)
+	for checksum, item := range m.Sources.Curl.Items {
+		var options struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(item, &options); err != nil {
+			require.NoError(t, json.Unmarshal(item, &options.URL))
+		}
+		urls[checksum] = options.URL
+	}
+	return urls
+}
+
+func TestMakeManifest(t *testing.T) {
+	t.Setenv(cmdutil.RNG_SEED_ENV_KEY, "0")
+
+	distribution := distrofactory.NewDefault().GetDistro("centos-9")
+	require.NotNil(t, distribution)
+	archi, err := distribution.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := archi.GetImageType("tar")
+	require.NoError(t, err)
+
+	config := &buildconfig.BuildConfig{
+		Name:      "tmux",
+		Blueprint: &blueprint.Blueprint{Packages: []blueprint.Package{{Name: "tmux"}}},
+	}
+	repos := []rpmmd.RepoConfig{{Name: "baseos", BaseURLs: []string{"https://example.com/baseos"}}}
+	solver := rpmrepo.NewDepsolver(rpmrepo.NewTestUniverse(), "x86_64")
+
+	lockfilePath := filepath.Join(t.TempDir(), "lockfile.json")
+	mf, _, err := makeManifest(config, imgType, distribution, repos, "x86_64", solver, "", lockfilePath, nil, nil, false, "")
+	require.NoError(t, err)
+
+	urls := curlURLs(t, mf)
+	var tmux string
+	for _, url := range urls {
+		assert.True(t, strings.HasPrefix(url, "https://example.com/baseos/Packages/"), url)
+		if strings.HasPrefix(url, "https://example.com/baseos/Packages/tmux-") {
+			tmux = url
+		}
+	}
+	assert.NotEmpty(t, tmux)
+
+	// the content of the lockfile is used instead of depsolving
+	locked, _, err := makeManifest(config, imgType, distribution, repos, "x86_64", nil, lockfilePath, "", nil, nil, false, "")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(mf), string(locked))
+
+	// the rewritten repositories are depsolved, and the content of the
+	// lockfile is rewritten with the same rules
+	rewriter, err := mirror.NewRewriter([]mirror.Rule{{Prefix: "https://example.com/", Replace: "https://mirror.lab/"}})
+	require.NoError(t, err)
+	for _, lockfile := range []string{"", lockfilePath} {
+		mirrored, _, err := makeManifest(config, imgType, distribution, repos, "x86_64", solver, lockfile, "", rewriter, nil, false, "")
+		require.NoError(t, err)
+		mirroredURLs := curlURLs(t, mirrored)
+		assert.Len(t, mirroredURLs, len(urls))
+		for checksum, url := range urls {
+			assert.Equal(t, strings.Replace(url, "https://example.com/", "https://mirror.lab/", 1), mirroredURLs[checksum])
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Standalone executable that checks that the repositories of a distribution
+// and architecture can be used for a build, before starting one. The
+// repomd.xml of every repository is fetched, its GPG keys and, with
+// check_repo_gpg, the signature of the metadata are verified with gpgv, and
+// the age of the metadata and problems with SSL client certificates are
+// reported.
+//
+// The command exits with status 2 if any of the repositories is unusable.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/osbuild/images/internal/cmdutil"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/reporegistry"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func printReport(report *reporegistry.HealthReport) {
+	fmt.Printf("Repositories of %s/%s:\n", report.Distro, report.Arch)
+	for _, repo := range report.Repos {
+		status := "OK"
+		if !repo.Healthy() {
+			status = "FAILED"
+		}
+		fmt.Printf("\n%s: %s\n", repo.Name, status)
+		if repo.BaseURL != "" {
+			fmt.Printf("  URL: %s\n", repo.BaseURL)
+		}
+		if repo.MetadataTimestamp != nil {
+			fmt.Printf("  metadata: %s (%s old)\n", repo.MetadataTimestamp.Format(time.RFC3339), repo.MetadataAge.Round(time.Second))
+		}
+		if repo.SignatureVerified {
+			fmt.Printf("  signature: verified\n")
+		}
+		for _, msg := range repo.Errors {
+			fmt.Printf("  error: %s\n", msg)
+		}
+		for _, msg := range repo.Warnings {
+			fmt.Printf("  warning: %s\n", msg)
+		}
+	}
+}
+
+func run() (bool, error) {
+	var distroName, archName, snapshot string
+	flag.StringVar(&distroName, "distro", "", "distribution (required)")
+	flag.StringVar(&archName, "arch", arch.Current().String(), "architecture")
+	flag.StringVar(&snapshot, "snapshot", "", "check the repositories pinned to the snapshot with the given ID")
+
+	var repoFiles cmdutil.MultiValue
+	flag.Var(&repoFiles, "repo-files", "comma-separated list of .repo files or directories of .repo files to check instead of the tested repositories")
+	var repoVarsDirs cmdutil.MultiValue
+	flag.Var(&repoVarsDirs, "repo-vars", "comma-separated list of dnf vars directories (e.g. /etc/dnf/vars) with the variables of the -repo-files")
+
+	var maxAge time.Duration
+	var jsonOutput bool
+	flag.DurationVar(&maxAge, "max-age", 0, "warn about metadata older than this (e.g. 72h), not checked if zero")
+	flag.BoolVar(&jsonOutput, "json", false, "print the report as JSON")
+
+	flag.Parse()
+
+	if distroName == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var registry *reporegistry.RepoRegistry
+	if len(repoFiles) > 0 {
+		distribution := distrofactory.NewDefault().GetDistro(distroName)
+		if distribution == nil {
+			return false, fmt.Errorf("invalid or unsupported distribution: %q", distroName)
+		}
+		repoConfigs, err := reporegistry.LoadRepoFiles(repoFiles, repoVarsDirs, archName, distribution.Releasever())
+		if err != nil {
+			return false, fmt.Errorf("failed to load repositories from %v: %w", repoFiles, err)
+		}
+		registry = reporegistry.NewFromDistrosRepoConfigs(rpmmd.DistrosRepoConfigs{
+			distribution.Name(): repoConfigs,
+		})
+	} else {
+		var err error
+		registry, err = reporegistry.NewTestedDefault()
+		if err != nil {
+			return false, fmt.Errorf("failed to create repo registry with tested distros: %v", err)
+		}
+	}
+	if snapshot != "" {
+		var err error
+		registry, err = registry.AtSnapshot(snapshot)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	report, err := registry.CheckHealth(distroName, archName, reporegistry.HealthCheckOptions{
+		MaxMetadataAge: maxAge,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return false, err
+		}
+	} else {
+		printReport(report)
+	}
+	return report.Healthy(), nil
+}
+
+func main() {
+	healthy, err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	if !healthy {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,46 @@
+// Standalone executable that exports the sources of a resolved manifest into
+// a self-contained bundle directory, which can be carried into an air-gapped
+// environment. The manifest of the bundle has its sources rewritten to the
+// content of the bundle, so it can be built there without network access:
+//
+//	osbuild --store <store> --export <pipeline> <bundle>/manifest.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/osbuild/images/pkg/bundle"
+	"github.com/osbuild/images/pkg/manifest"
+)
+
+func run() error {
+	var manifestPath, outputDir, root string
+	flag.StringVar(&manifestPath, "manifest", "", "resolved osbuild manifest to export (required)")
+	flag.StringVar(&outputDir, "output", "", "bundle directory (required)")
+	flag.StringVar(&root, "root", "", "path of the bundle directory on the build host (defaults to the absolute path of the bundle directory)")
+	flag.Parse()
+
+	if manifestPath == "" || outputDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	if err := bundle.Export(manifest.OSBuildManifest(data), outputDir, bundle.Options{Root: root}); err != nil {
+		return err
+	}
+	fmt.Printf("Bundle written to %s\n", outputDir)
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
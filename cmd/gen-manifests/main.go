@@ -14,6 +14,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gobwas/glob"
 
@@ -161,6 +162,13 @@ func loadImgConfig(configPath string) BuildConfigs {
 
 type manifestJob func(chan string) error
 
+// depsolveCacheConfig enables the persistent depsolve cache of the solver
+type depsolveCacheConfig struct {
+	dir     string
+	timeout time.Duration
+	maxSize uint64
+}
+
 func makeManifestJob(
 	bc *buildconfig.BuildConfig,
 	imgType distro.ImageType,
@@ -168,6 +176,7 @@ func makeManifestJob(
 	repos []rpmmd.RepoConfig,
 	archName string,
 	cacheRoot string,
+	depsolveCache *depsolveCacheConfig,
 	path string,
 	content map[string]bool,
 	metadata bool,
@@ -214,7 +223,11 @@ func makeManifestJob(
 		var packageSpecs map[string][]rpmmd.PackageSpec
 		var repoConfigs map[string][]rpmmd.RepoConfig
 		if content["packages"] {
-			packageSpecs, repoConfigs, err = depsolve(cacheDir, manifest.GetPackageSetChains(), distribution, archName)
+			solver := dnfjson.NewSolver(distribution.ModulePlatformID(), distribution.Releasever(), archName, distribution.Name(), cacheDir)
+			if depsolveCache != nil {
+				solver.SetDepsolveCache(depsolveCache.dir, depsolveCache.timeout, depsolveCache.maxSize)
+			}
+			packageSpecs, repoConfigs, err = depsolve(solver, manifest.GetPackageSetChains())
 			if err != nil {
 				err = fmt.Errorf("[%s] depsolve failed: %s", filename, err.Error())
 				return
@@ -352,8 +365,7 @@ func mockResolveCommits(commitSources map[string][]ostree.SourceSpec) map[string
 	return commits
 }
 
-func depsolve(cacheDir string, packageSets map[string][]rpmmd.PackageSet, d distro.Distro, arch string) (map[string][]rpmmd.PackageSpec, map[string][]rpmmd.RepoConfig, error) {
-	solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), arch, d.Name(), cacheDir)
+func depsolve(solver dnfjson.Depsolver, packageSets map[string][]rpmmd.PackageSet) (map[string][]rpmmd.PackageSpec, map[string][]rpmmd.RepoConfig, error) {
 	depsolvedSets := make(map[string][]rpmmd.PackageSpec)
 	repoSets := make(map[string][]rpmmd.RepoConfig)
 	for name, pkgSet := range packageSets {
@@ -462,6 +474,14 @@ func main() {
 	flag.BoolVar(&skipNoconfig, "skip-noconfig", false, "skip distro-arch-image configurations that have no config (otherwise fail)")
 	flag.BoolVar(&skipNorepos, "skip-norepos", false, "skip distro-arch-image configurations that have no repositories (otherwise fail)")
 
+	// depsolve cache args
+	var depsolveCacheDir string
+	var depsolveCacheTimeout time.Duration
+	var depsolveCacheMaxSize uint64
+	flag.StringVar(&depsolveCacheDir, "depsolve-cache", "", "persistent depsolve result cache directory (disabled if empty)")
+	flag.DurationVar(&depsolveCacheTimeout, "depsolve-cache-ttl", 24*time.Hour, "maximum age of the depsolve cache entries")
+	flag.Uint64Var(&depsolveCacheMaxSize, "depsolve-cache-size", 1024*1024*1024, "maximum size of the depsolve cache in bytes")
+
 	// content args
 	var packages, containers, commits bool
 	flag.BoolVar(&packages, "packages", true, "depsolve package sets")
@@ -476,6 +496,15 @@ func main() {
 
 	flag.Parse()
 
+	var depsolveCache *depsolveCacheConfig
+	if depsolveCacheDir != "" {
+		depsolveCache = &depsolveCacheConfig{
+			dir:     depsolveCacheDir,
+			timeout: depsolveCacheTimeout,
+			maxSize: depsolveCacheMaxSize,
+		}
+	}
+
 	testedRepoRegistry, err := reporegistry.NewTestedDefault()
 	if err != nil {
 		panic(fmt.Sprintf("failed to create repo registry with tested distros: %v", err))
@@ -561,7 +590,7 @@ func main() {
 				}
 
 				for _, itConfig := range imgTypeConfigs {
-					job := makeManifestJob(itConfig, imgType, distribution, repos, archName, cacheRoot, outputDir, contentResolve, metadata)
+					job := makeManifestJob(itConfig, imgType, distribution, repos, archName, cacheRoot, depsolveCache, outputDir, contentResolve, metadata)
 					jobs = append(jobs, job)
 				}
 			}
@@ -1,5 +1,11 @@
 // Simple tool to dump a JSON object containing all package sets for a specific
 // distro x arch x image type.
+//
+// With -why, the package sets are depsolved with the tested repositories of
+// the distro instead, and the chain of dependencies that pulled the given
+// package into each pipeline is printed. The chain is derived from the
+// metadata of the repositories, so it is a plausible explanation of the
+// transaction rather than the decisions of dnf.
 package main
 
 import (
@@ -7,21 +13,60 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distrofactory"
+	"github.com/osbuild/images/pkg/dnfjson"
 	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/reporegistry"
+	"github.com/osbuild/images/pkg/rpmmd"
 )
 
+// why prints the dependency chain of the package for every pipeline that
+// installs it.
+func why(solver *dnfjson.Solver, packageSets map[string][]rpmmd.PackageSet, pkgName string) error {
+	pipelines := make([]string, 0, len(packageSets))
+	for name := range packageSets {
+		pipelines = append(pipelines, name)
+	}
+	sort.Strings(pipelines)
+
+	found := false
+	for _, pipeline := range pipelines {
+		_, _, deps, err := solver.DepsolveWithDependencies(packageSets[pipeline])
+		if err != nil {
+			return fmt.Errorf("depsolving pipeline %q failed: %w", pipeline, err)
+		}
+		if _, err := deps.Why(pkgName); err != nil {
+			continue
+		}
+		found = true
+		fmt.Printf("%s:\n", pipeline)
+		if err := deps.PrintWhy(os.Stdout, pkgName); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("package %q is not installed by any pipeline", pkgName)
+	}
+	return nil
+}
+
 func main() {
 	var distroName string
 	var archName string
 	var imageName string
+	var whyPackage string
+	var cacheRoot string
 
 	flag.StringVar(&distroName, "distro", "", "Distribution name")
 	flag.StringVar(&archName, "arch", "", "Architecture name")
 	flag.StringVar(&imageName, "image", "", "Image name")
+	flag.StringVar(&whyPackage, "why", "", "Depsolve the package sets and explain why the package is installed")
+	flag.StringVar(&cacheRoot, "rpmmd", "/tmp/rpmmd", "RPM metadata cache directory (with -why)")
 	flag.Parse()
 
 	if distroName == "" || archName == "" || imageName == "" {
@@ -53,9 +98,30 @@ func main() {
 			URL: "https://example.com", // required by some image types
 		},
 	}
-	manifest, _, err := image.Manifest(&blueprint.Blueprint{}, options, nil, 0)
+	if whyPackage == "" {
+		manifest, _, err := image.Manifest(&blueprint.Blueprint{}, options, nil, 0)
+		if err != nil {
+			panic(err)
+		}
+		_ = encoder.Encode(manifest.GetPackageSetChains())
+		return
+	}
+
+	testedRepoRegistry, err := reporegistry.NewTestedDefault()
+	if err != nil {
+		panic(err)
+	}
+	repos, err := testedRepoRegistry.ReposByArchName(distroName, archName, true)
+	if err != nil {
+		panic(err)
+	}
+	manifest, _, err := image.Manifest(&blueprint.Blueprint{}, options, repos, 0)
 	if err != nil {
 		panic(err)
 	}
-	_ = encoder.Encode(manifest.GetPackageSetChains())
+	solver := dnfjson.NewSolver(d.ModulePlatformID(), d.Releasever(), archName, d.Name(), filepath.Join(cacheRoot, archName+d.Name()))
+	if err := why(solver, manifest.GetPackageSetChains(), whyPackage); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,339 @@
+package rpmrepo
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Depsolver is a deterministic, in-memory fake of dnfjson.Solver that resolves
+// packages from a Universe. It follows the requirements of the packages by
+// name and ignores versioned and rich dependencies, weak dependencies and
+// conflicts, which is good enough to generate manifests in tests without DNF,
+// see cmd/build.
+type Depsolver struct {
+	universe *Universe
+	arch     string
+
+	// packages by provided capability or file
+	providers map[string][]*Package
+}
+
+// NewDepsolver returns a fake depsolver for the packages of the universe
+// that can be installed on the given architecture.
+func NewDepsolver(universe *Universe, arch string) *Depsolver {
+	d := &Depsolver{
+		universe:  universe,
+		arch:      arch,
+		providers: make(map[string][]*Package),
+	}
+	for _, pkg := range universe.Packages {
+		if !d.installable(pkg) {
+			continue
+		}
+		d.providers[pkg.Name] = append(d.providers[pkg.Name], pkg)
+		for _, p := range pkg.Provides {
+			if p != pkg.Name {
+				d.providers[p] = append(d.providers[p], pkg)
+			}
+		}
+	}
+	return d
+}
+
+func (d *Depsolver) installable(pkg *Package) bool {
+	return pkg.Arch == d.arch || pkg.Arch == "noarch"
+}
+
+// specForms returns the forms of a package that can be matched by a package
+// spec, in the same way as dnf does.
+func specForms(pkg *Package) []string {
+	vr := pkg.Version + "-" + pkg.Release
+	evr := fmt.Sprintf("%d:%s", pkg.Epoch, vr)
+	forms := []string{pkg.Name, pkg.Name + "." + pkg.Arch}
+	for _, v := range []string{pkg.Version, vr, evr} {
+		forms = append(forms, pkg.Name+"-"+v, pkg.Name+"-"+v+"."+pkg.Arch)
+	}
+	return forms
+}
+
+func matchSpec(spec string, pkg *Package) bool {
+	for _, form := range specForms(pkg) {
+		if ok, _ := path.Match(spec, form); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the newest of the packages, preferring the first package
+// name in alphabetical order for different packages with the same version.
+func best(pkgs []*Package) *Package {
+	var result *Package
+	for _, pkg := range pkgs {
+		if result == nil {
+			result = pkg
+			continue
+		}
+		if pkg.Name != result.Name {
+			if pkg.Name < result.Name {
+				result = pkg
+			}
+			continue
+		}
+		if rpmmd.CompareEVR(pkg.Epoch, pkg.Version, pkg.Release, result.Epoch, result.Version, result.Release) > 0 {
+			result = pkg
+		}
+	}
+	return result
+}
+
+// transaction is the state of a chain of package sets being depsolved
+type transaction struct {
+	d *Depsolver
+
+	installed map[string]*Package
+	order     []*Package
+	provided  map[string]bool
+
+	excludes []string
+	// locked [epoch:]version-release by package name, without a zero epoch
+	locks map[string]string
+}
+
+func (t *transaction) excluded(pkg *Package) bool {
+	if evr, ok := t.locks[pkg.Name]; ok {
+		pkgEVR := pkg.Version + "-" + pkg.Release
+		if pkg.Epoch != 0 {
+			pkgEVR = fmt.Sprintf("%d:%s", pkg.Epoch, pkgEVR)
+		}
+		if pkgEVR != evr {
+			return true
+		}
+	}
+	for _, exclude := range t.excludes {
+		if matchSpec(exclude, pkg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *transaction) install(pkg *Package) error {
+	if _, ok := t.installed[pkg.Name]; ok {
+		return nil
+	}
+	t.installed[pkg.Name] = pkg
+	t.order = append(t.order, pkg)
+	t.provided[pkg.Name] = true
+	for _, p := range pkg.Provides {
+		t.provided[p] = true
+	}
+
+	for _, req := range pkg.Requires {
+		if t.provided[req] || strings.HasPrefix(req, "rpmlib(") || strings.HasPrefix(req, "(") {
+			continue
+		}
+		var candidates []*Package
+		for _, p := range t.d.providers[req] {
+			if !t.excluded(p) {
+				candidates = append(candidates, p)
+			}
+		}
+		// prefer the package with the name of the requirement
+		var named []*Package
+		for _, p := range candidates {
+			if p.Name == req {
+				named = append(named, p)
+			}
+		}
+		if len(named) > 0 {
+			candidates = named
+		}
+		provider := best(candidates)
+		if provider == nil {
+			return fmt.Errorf("nothing provides %s needed by %s", req, pkg.NEVRA())
+		}
+		if err := t.install(provider); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *transaction) installSpec(spec string) error {
+	if strings.HasPrefix(spec, "@") {
+		group, ok := t.d.universe.Groups[spec[1:]]
+		if !ok {
+			return fmt.Errorf("no match for group %q", spec[1:])
+		}
+		for _, name := range group {
+			// like dnf, skip the packages of the group that are not available
+			if err := t.installSpec(name); err != nil && !strings.HasPrefix(err.Error(), "no match") {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var matches []*Package
+	for _, pkg := range t.d.universe.Packages {
+		if t.d.installable(pkg) && !t.excluded(pkg) && matchSpec(spec, pkg) {
+			matches = append(matches, pkg)
+		}
+	}
+	if len(matches) == 0 {
+		// like dnf, install the provider of a capability or file if no
+		// package matches
+		var providers []*Package
+		for _, pkg := range t.d.providers[spec] {
+			if !t.excluded(pkg) {
+				providers = append(providers, pkg)
+			}
+		}
+		if len(providers) == 0 {
+			return fmt.Errorf("no match for package %q", spec)
+		}
+		return t.install(best(providers))
+	}
+	// globs can match many different packages, all of them are installed
+	byName := make(map[string][]*Package)
+	var names []string
+	for _, pkg := range matches {
+		if _, ok := byName[pkg.Name]; !ok {
+			names = append(names, pkg.Name)
+		}
+		byName[pkg.Name] = append(byName[pkg.Name], pkg)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := t.install(best(byName[name])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Depsolve resolves the chain of package sets like dnfjson.Solver.Depsolve().
+// The packages are located in the first repository of the package set that
+// installs them.
+func (d *Depsolver) Depsolve(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig, error) {
+	t := &transaction{
+		d:         d,
+		installed: make(map[string]*Package),
+		provided:  make(map[string]bool),
+		locks:     make(map[string]string),
+	}
+
+	// like dnfjson.Solver, locks only restrict the versions of the packages
+	// and apply to the whole chain
+	for _, ps := range pkgSets {
+		for _, lock := range ps.Locks {
+			t.locks[lock.Name] = strings.TrimPrefix(lock.EVR, "0:")
+		}
+	}
+
+	var specs []rpmmd.PackageSpec
+	var repos []rpmmd.RepoConfig
+	repoIDs := make(map[string]bool)
+	for idx, ps := range pkgSets {
+		if len(ps.LocalPackages) > 0 {
+			return nil, nil, fmt.Errorf("package set %d: local packages are not supported by the fake depsolver", idx)
+		}
+		if len(ps.EnabledModules) > 0 {
+			return nil, nil, fmt.Errorf("package set %d: module streams are not supported by the fake depsolver", idx)
+		}
+		if len(ps.Repositories) == 0 {
+			return nil, nil, fmt.Errorf("package set %d has no repositories", idx)
+		}
+		for _, repo := range ps.Repositories {
+			id := repo.Hash()
+			if !repoIDs[id] {
+				repoIDs[id] = true
+				repo.Id = id
+				repos = append(repos, repo)
+			}
+		}
+
+		t.excludes = ps.Exclude
+		start := len(t.order)
+		for _, spec := range ps.Include {
+			if err := t.installSpec(spec); err != nil {
+				return nil, nil, fmt.Errorf("package set %d: %w", idx, err)
+			}
+		}
+
+		repo := ps.Repositories[0]
+		for _, pkg := range t.order[start:] {
+			spec := rpmmd.PackageSpec{
+				Name:           pkg.Name,
+				Epoch:          pkg.Epoch,
+				Version:        pkg.Version,
+				Release:        pkg.Release,
+				Arch:           pkg.Arch,
+				RemoteLocation: strings.TrimSuffix(repo.BaseURLs[0], "/") + "/" + pkg.Location,
+				Checksum:       "sha256:" + pkg.Checksum,
+			}
+			if repo.CheckGPG != nil {
+				spec.CheckGPG = *repo.CheckGPG
+			}
+			if repo.IgnoreSSL != nil {
+				spec.IgnoreSSL = *repo.IgnoreSSL
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs, repos, nil
+}
+
+func toPackageList(pkgs []*Package) rpmmd.PackageList {
+	list := make(rpmmd.PackageList, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		list = append(list, rpmmd.Package{
+			Name:        pkg.Name,
+			Summary:     pkg.Summary,
+			Description: pkg.Description,
+			URL:         pkg.URL,
+			Epoch:       pkg.Epoch,
+			Version:     pkg.Version,
+			Release:     pkg.Release,
+			Arch:        pkg.Arch,
+			BuildTime:   pkg.BuildTime,
+			License:     pkg.License,
+		})
+	}
+	sortID := func(pkg rpmmd.Package) string {
+		return fmt.Sprintf("%s-%s-%s", pkg.Name, pkg.Version, pkg.Release)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return sortID(list[i]) < sortID(list[j])
+	})
+	return list
+}
+
+// FetchMetadata returns all the packages of the universe.
+func (d *Depsolver) FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error) {
+	return toPackageList(d.universe.Packages), nil
+}
+
+// SearchMetadata returns the packages of the universe with names matching one
+// of the given names or globs.
+func (d *Depsolver) SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error) {
+	var found []*Package
+	for _, pkg := range d.universe.Packages {
+		for _, name := range packages {
+			if ok, _ := path.Match(name, pkg.Name); ok {
+				found = append(found, pkg)
+				break
+			}
+		}
+	}
+	return toPackageList(found), nil
+}
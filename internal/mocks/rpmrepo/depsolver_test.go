@@ -0,0 +1,103 @@
+package rpmrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/dnfjson"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+var _ dnfjson.Depsolver = &Depsolver{}
+
+func TestDepsolverDepsolve(t *testing.T) {
+	d := NewDepsolver(NewTestUniverse(), "x86_64")
+	repos := []rpmmd.RepoConfig{{Name: "baseos", BaseURLs: []string{"http://example.com/baseos/"}}}
+
+	pkgSets := []rpmmd.PackageSet{
+		{Include: []string{"filesystem"}, Repositories: repos},
+		{Include: []string{"tmux"}, Exclude: []string{"nano"}, Repositories: repos},
+	}
+	specs, resRepos, err := d.Depsolve(pkgSets)
+	require.NoError(t, err)
+	require.Len(t, resRepos, 1)
+	assert.Equal(t, repos[0].Hash(), resRepos[0].Id)
+
+	names := make(map[string]rpmmd.PackageSpec)
+	for _, spec := range specs {
+		names[spec.Name] = spec
+	}
+	assert.Contains(t, names, "filesystem")
+	assert.Contains(t, names, "tmux")
+	assert.Contains(t, names, "glibc")
+	assert.NotContains(t, names, "nano")
+	assert.Regexp(t, `^http://example\.com/baseos/Packages/tmux-.*\.x86_64\.rpm$`, names["tmux"].RemoteLocation)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, names["tmux"].Checksum)
+
+	// the result is deterministic
+	specs2, _, err := d.Depsolve(pkgSets)
+	require.NoError(t, err)
+	assert.Equal(t, specs, specs2)
+
+	// like dnf, the provider of a capability is installed if no package
+	// matches
+	specs, _, err = d.Depsolve([]rpmmd.PackageSet{{Include: []string{"/usr/bin/tmux"}, Repositories: repos}})
+	require.NoError(t, err)
+	assert.Contains(t, specs, names["tmux"])
+
+	_, _, err = d.Depsolve([]rpmmd.PackageSet{{Include: []string{"does-not-exist"}, Repositories: repos}})
+	assert.EqualError(t, err, `package set 0: no match for package "does-not-exist"`)
+
+	_, _, err = d.Depsolve([]rpmmd.PackageSet{{Include: []string{"tmux"}, Exclude: []string{"tmux"}, Repositories: repos}})
+	assert.EqualError(t, err, `package set 0: no match for package "tmux"`)
+}
+
+func TestDepsolverDepsolveLocks(t *testing.T) {
+	d := NewDepsolver(NewTestUniverse(), "x86_64")
+	repos := []rpmmd.RepoConfig{{Name: "baseos", BaseURLs: []string{"http://example.com/baseos/"}}}
+
+	// locks do not add packages
+	specs, _, err := d.Depsolve([]rpmmd.PackageSet{
+		{Include: []string{"filesystem"}, Repositories: repos, Locks: []rpmmd.PackageLock{{Name: "tmux", EVR: "0:3.2a-4.el9"}}},
+	})
+	require.NoError(t, err)
+	for _, spec := range specs {
+		assert.NotEqual(t, "tmux", spec.Name)
+	}
+
+	specs, _, err = d.Depsolve([]rpmmd.PackageSet{
+		{Include: []string{"filesystem"}, Repositories: repos, Locks: []rpmmd.PackageLock{{Name: "tmux", EVR: "0:3.2a-4.el9"}}},
+		{Include: []string{"tmux"}, Repositories: repos},
+	})
+	require.NoError(t, err)
+	evrs := make(map[string]string)
+	for _, spec := range specs {
+		evrs[spec.Name] = spec.Version + "-" + spec.Release
+	}
+	assert.Equal(t, "3.2a-4.el9", evrs["tmux"])
+
+	_, _, err = d.Depsolve([]rpmmd.PackageSet{
+		{Include: []string{"tmux"}, Repositories: repos, Locks: []rpmmd.PackageLock{{Name: "tmux", EVR: "3.2a-5.el9"}}},
+	})
+	assert.EqualError(t, err, `package set 0: no match for package "tmux"`)
+}
+
+func TestDepsolverSearchMetadata(t *testing.T) {
+	d := NewDepsolver(NewTestUniverse(), "x86_64")
+
+	pkgs, err := d.SearchMetadata(nil, []string{"tmux", "kernel-tools*"})
+	require.NoError(t, err)
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	assert.Contains(t, names, "tmux")
+	assert.Contains(t, names, "kernel-tools")
+	assert.NotContains(t, names, "kernel")
+
+	all, err := d.FetchMetadata(nil)
+	require.NoError(t, err)
+	assert.Greater(t, len(all), len(pkgs))
+}
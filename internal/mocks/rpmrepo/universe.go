@@ -0,0 +1,203 @@
+package rpmrepo
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Package is a package of a Universe with the metadata needed to depsolve it.
+type Package struct {
+	Name        string
+	Epoch       uint
+	Version     string
+	Release     string
+	Arch        string
+	Summary     string
+	Description string
+	URL         string
+	License     string
+	BuildTime   time.Time
+
+	// hex encoded SHA-256 checksum of the RPM file
+	Checksum string
+	// location of the RPM file relative to the repository root
+	Location string
+
+	// names of the capabilities and files provided and required by the
+	// package, without versions
+	Provides []string
+	Requires []string
+}
+
+// NEVRA returns the name-[epoch:]version-release.arch of the package.
+func (p *Package) NEVRA() string {
+	if p.Epoch == 0 {
+		return fmt.Sprintf("%s-%s-%s.%s", p.Name, p.Version, p.Release, p.Arch)
+	}
+	return fmt.Sprintf("%s-%d:%s-%s.%s", p.Name, p.Epoch, p.Version, p.Release, p.Arch)
+}
+
+// Universe is a fixed set of packages and package groups, e.g. the content of
+// a repository.
+type Universe struct {
+	Packages []*Package
+
+	// package names of the groups, keyed by group ID
+	Groups map[string][]string
+}
+
+type primaryEntry struct {
+	Name string `xml:"name,attr"`
+}
+
+type primaryPackage struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Checksum    string `xml:"checksum"`
+	Summary     string `xml:"summary"`
+	Description string `xml:"description"`
+	URL         string `xml:"url"`
+	Time        struct {
+		Build int64 `xml:"build,attr"`
+	} `xml:"time"`
+	Location struct {
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	Format struct {
+		License  string         `xml:"license"`
+		Provides []primaryEntry `xml:"provides>entry"`
+		Requires []primaryEntry `xml:"requires>entry"`
+		Files    []string       `xml:"file"`
+	} `xml:"format"`
+}
+
+type compsGroup struct {
+	ID       string `xml:"id"`
+	Packages []struct {
+		Type string `xml:"type,attr"`
+		Name string `xml:",chardata"`
+	} `xml:"packagelist>packagereq"`
+}
+
+func readGzipXML(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("cannot decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadUniverse reads the packages and groups from the primary and comps
+// metadata in the repodata directory of a repository.
+func LoadUniverse(repodataDir string) (*Universe, error) {
+	primaryFiles, err := filepath.Glob(filepath.Join(repodataDir, "*primary.xml*"))
+	if err != nil || len(primaryFiles) != 1 {
+		return nil, fmt.Errorf("cannot find the primary metadata in %s", repodataDir)
+	}
+	var primary struct {
+		Packages []primaryPackage `xml:"package"`
+	}
+	if err := readGzipXML(primaryFiles[0], &primary); err != nil {
+		return nil, err
+	}
+
+	u := &Universe{Groups: make(map[string][]string)}
+	for _, pp := range primary.Packages {
+		epoch, err := strconv.ParseUint(pp.Version.Epoch, 10, 32)
+		if err != nil && pp.Version.Epoch != "" {
+			return nil, fmt.Errorf("package %s has an invalid epoch %q", pp.Name, pp.Version.Epoch)
+		}
+		pkg := &Package{
+			Name:        pp.Name,
+			Epoch:       uint(epoch),
+			Version:     pp.Version.Ver,
+			Release:     pp.Version.Rel,
+			Arch:        pp.Arch,
+			Summary:     pp.Summary,
+			Description: pp.Description,
+			URL:         pp.URL,
+			License:     pp.Format.License,
+			BuildTime:   time.Unix(pp.Time.Build, 0).UTC(),
+			Checksum:    pp.Checksum,
+			Location:    pp.Location.Href,
+		}
+		for _, e := range pp.Format.Provides {
+			pkg.Provides = append(pkg.Provides, e.Name)
+		}
+		pkg.Provides = append(pkg.Provides, pp.Format.Files...)
+		for _, e := range pp.Format.Requires {
+			pkg.Requires = append(pkg.Requires, e.Name)
+		}
+		u.Packages = append(u.Packages, pkg)
+	}
+
+	compsFiles, err := filepath.Glob(filepath.Join(repodataDir, "*comps*.xml*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range compsFiles {
+		var comps struct {
+			Groups []compsGroup `xml:"group"`
+		}
+		if err := readGzipXML(path, &comps); err != nil {
+			return nil, err
+		}
+		for _, g := range comps.Groups {
+			for _, p := range g.Packages {
+				// optional packages are not installed by default
+				if p.Type == "optional" {
+					continue
+				}
+				u.Groups[g.ID] = append(u.Groups[g.ID], p.Name)
+			}
+		}
+	}
+
+	return u, nil
+}
+
+// testRepoDir returns the path of the test repository, independent of the
+// working directory of the caller.
+func testRepoDir() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		panic("cannot determine the location of the test repository")
+	}
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "test", "data", "testrepo")
+}
+
+// NewTestUniverse returns the universe of the CentOS Stream 9 BaseOS test
+// repository served by NewTestServer().
+func NewTestUniverse() *Universe {
+	u, err := LoadUniverse(filepath.Join(testRepoDir(), "repodata"))
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
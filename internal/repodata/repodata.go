@@ -0,0 +1,398 @@
+// Package repodata fetches the metadata of RPM repositories, i.e. the
+// repomd.xml and the files it lists, with the TLS and proxy configuration of
+// the repository. It is used where the metadata is needed without running
+// dnf, e.g. to check repositories and to read their updateinfo.
+package repodata
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	"github.com/osbuild/images/pkg/proxy"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Timeout of the HTTP requests of a Client
+const Timeout = 30 * time.Second
+
+// Repository is the location of a repository and the configuration needed
+// to access it.
+type Repository struct {
+	Name       string
+	BaseURLs   []string
+	Metalink   string
+	MirrorList string
+
+	IgnoreSSL     bool
+	SSLCACert     string
+	SSLClientCert string
+	SSLClientKey  string
+
+	// Proxy of the repository, nil to use the proxy of the environment
+	Proxy *proxy.Config
+}
+
+// FromRepoConfig returns the Repository of a repository configuration.
+func FromRepoConfig(repo rpmmd.RepoConfig) Repository {
+	return Repository{
+		Name:          repo.Name,
+		BaseURLs:      repo.BaseURLs,
+		Metalink:      repo.Metalink,
+		MirrorList:    repo.MirrorList,
+		IgnoreSSL:     repo.IgnoreSSL != nil && *repo.IgnoreSSL,
+		SSLCACert:     repo.SSLCACert,
+		SSLClientCert: repo.SSLClientCert,
+		SSLClientKey:  repo.SSLClientKey,
+		Proxy:         repo.Proxy,
+	}
+}
+
+// NewHTTPClient returns an HTTP client that uses the TLS and proxy
+// configuration of the repository.
+func NewHTTPClient(repo Repository) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if repo.IgnoreSSL {
+		tlsConfig.InsecureSkipVerify = true // #nosec G402
+	}
+	if repo.SSLCACert != "" {
+		caCert, err := os.ReadFile(repo.SSLCACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", repo.SSLCACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if (repo.SSLClientCert == "") != (repo.SSLClientKey == "") {
+		return nil, fmt.Errorf("both an SSL client certificate and key are required")
+	}
+	if repo.SSLClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(repo.SSLClientCert, repo.SSLClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if err := repo.Proxy.Apply(transport); err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: Timeout}, nil
+}
+
+// Client fetches the metadata of a repository. The metadata is fetched from
+// the first base URL of the repository or, if it has none, from the mirror
+// its metalink or mirrorlist lists first.
+type Client struct {
+	repo   Repository
+	client *http.Client
+
+	// resolved base URL, empty until BaseURL() is called
+	baseURL string
+}
+
+// NewClient returns a Client for the repository.
+func NewClient(repo Repository) (*Client, error) {
+	client, err := NewHTTPClient(repo)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		repo:   repo,
+		client: client,
+	}, nil
+}
+
+// HTTPClient returns the HTTP client used to fetch the metadata.
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
+// BaseURL returns the base URL the metadata is fetched from. For
+// repositories without a base URL, the metalink or mirrorlist is fetched to
+// find a mirror.
+func (c *Client) BaseURL() (string, error) {
+	if c.baseURL != "" {
+		return c.baseURL, nil
+	}
+	switch {
+	case len(c.repo.BaseURLs) > 0:
+		c.baseURL = c.repo.BaseURLs[0]
+	case c.repo.Metalink != "":
+		data, err := c.FetchURL(c.repo.Metalink)
+		if err != nil {
+			return "", fmt.Errorf("cannot fetch metalink: %w", err)
+		}
+		baseURL, err := parseMetalink(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid metalink %s: %w", c.repo.Metalink, err)
+		}
+		c.baseURL = baseURL
+	case c.repo.MirrorList != "":
+		data, err := c.FetchURL(c.repo.MirrorList)
+		if err != nil {
+			return "", fmt.Errorf("cannot fetch mirrorlist: %w", err)
+		}
+		baseURL, err := parseMirrorList(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid mirrorlist %s: %w", c.repo.MirrorList, err)
+		}
+		c.baseURL = baseURL
+	default:
+		return "", fmt.Errorf("repository %q has no base URL, metalink or mirrorlist", c.repo.Name)
+	}
+	return c.baseURL, nil
+}
+
+// Open opens the file at the path relative to the base URL.
+func (c *Client) Open(path string) (io.ReadCloser, error) {
+	baseURL, err := c.BaseURL()
+	if err != nil {
+		return nil, err
+	}
+	return c.OpenURL(strings.TrimSuffix(baseURL, "/") + "/" + path)
+}
+
+// Fetch returns the content of the file at the path relative to the base
+// URL.
+func (c *Client) Fetch(path string) ([]byte, error) {
+	f, err := c.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// OpenURL opens a file, e.g. a GPG key, with the configuration of the
+// repository. Only file, http and https URLs are supported.
+func (c *Client) OpenURL(fileURL string) (io.ReadCloser, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return os.Open(u.Path)
+	case "http", "https":
+		resp, err := c.client.Get(fileURL)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %q from %s", resp.Status, fileURL)
+		}
+		return resp.Body, nil
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+// FetchURL returns the content of a file, see OpenURL().
+func (c *Client) FetchURL(fileURL string) ([]byte, error) {
+	f, err := c.OpenURL(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Repomd fetches and parses the repomd.xml of the repository.
+func (c *Client) Repomd() (*Repomd, error) {
+	data, err := c.Fetch("repodata/repomd.xml")
+	if err != nil {
+		return nil, err
+	}
+	repomd, err := ParseRepomd(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse repomd.xml: %w", err)
+	}
+	return repomd, nil
+}
+
+// OpenData opens the uncompressed content of a metadata file listed in the
+// repomd.xml.
+func (c *Client) OpenData(data Data) (io.ReadCloser, error) {
+	f, err := c.Open(data.Location.Href)
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(f, data.Location.Href)
+}
+
+// Repomd is the content of a repomd.xml file, which lists the metadata files
+// of a repository.
+type Repomd struct {
+	Revision string `xml:"revision"`
+	Data     []Data `xml:"data"`
+
+	// Raw content of the file
+	Raw []byte `xml:"-"`
+}
+
+// Data is a metadata file listed in a repomd.xml.
+type Data struct {
+	// Type of the metadata, e.g. primary or updateinfo
+	Type     string `xml:"type,attr"`
+	Location struct {
+		// Path of the file relative to the base URL of the repository
+		Href string `xml:"href,attr"`
+	} `xml:"location"`
+	// Unix timestamp of the file, which may be fractional
+	Timestamp string `xml:"timestamp"`
+}
+
+// ParseRepomd parses the content of a repomd.xml file.
+func ParseRepomd(raw []byte) (*Repomd, error) {
+	var repomd Repomd
+	if err := xml.Unmarshal(raw, &repomd); err != nil {
+		return nil, err
+	}
+	repomd.Raw = raw
+	return &repomd, nil
+}
+
+// Find returns the metadata file of the given type and true, or false if the
+// repomd.xml does not list one.
+func (r *Repomd) Find(dataType string) (Data, bool) {
+	for _, data := range r.Data {
+		if data.Type == dataType {
+			return data, true
+		}
+	}
+	return Data{}, false
+}
+
+// Decompress returns a reader of the uncompressed content of a metadata file
+// based on the extension of its name. Closing the reader closes f.
+func Decompress(f io.ReadCloser, name string) (io.ReadCloser, error) {
+	switch path.Ext(name) {
+	case ".gz":
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &readCloser{Reader: r, closers: []func() error{r.Close, f.Close}}, nil
+	case ".xz":
+		r, err := xz.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &readCloser{Reader: r, closers: []func() error{f.Close}}, nil
+	case ".zst":
+		dec, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		// the decoder runs goroutines that are only stopped by Close()
+		return &readCloser{Reader: dec, closers: []func() error{func() error { dec.Close(); return nil }, f.Close}}, nil
+	case ".bz2":
+		return &readCloser{Reader: bzip2.NewReader(f), closers: []func() error{f.Close}}, nil
+	default:
+		return f, nil
+	}
+}
+
+// readCloser is a reader that closes the decompressor and the underlying
+// file.
+type readCloser struct {
+	io.Reader
+	closers []func() error
+}
+
+func (r *readCloser) Close() error {
+	var firstErr error
+	for _, closer := range r.closers {
+		if err := closer(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type metalinkXML struct {
+	Files []struct {
+		Name string `xml:"name,attr"`
+		URLs []struct {
+			Protocol   string `xml:"protocol,attr"`
+			Preference int    `xml:"preference,attr"`
+			URL        string `xml:",chardata"`
+		} `xml:"resources>url"`
+	} `xml:"files>file"`
+}
+
+// parseMetalink returns the base URL of the preferred mirror of the
+// repomd.xml listed in a metalink.
+func parseMetalink(data []byte) (string, error) {
+	var metalink metalinkXML
+	if err := xml.Unmarshal(data, &metalink); err != nil {
+		return "", err
+	}
+	for _, file := range metalink.Files {
+		if file.Name != "repomd.xml" {
+			continue
+		}
+		urls := file.URLs
+		sort.SliceStable(urls, func(i, j int) bool {
+			return urls[i].Preference > urls[j].Preference
+		})
+		for _, u := range urls {
+			switch u.Protocol {
+			case "http", "https", "file":
+				mirror := strings.TrimSpace(u.URL)
+				if baseURL, ok := strings.CutSuffix(mirror, "repodata/repomd.xml"); ok {
+					return baseURL, nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("no http, https or file mirror of repomd.xml found")
+}
+
+// parseMirrorList returns the first URL of a mirrorlist, which is either a
+// list of base URLs, one per line, or a metalink.
+func parseMirrorList(data []byte) (string, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return parseMetalink(data)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no mirrors found")
+}
@@ -0,0 +1,224 @@
+package repodata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRepomd = `<?xml version="1.0" encoding="UTF-8"?>
+<repomd xmlns="http://linux.duke.edu/metadata/repo" xmlns:rpm="http://linux.duke.edu/metadata/rpm">
+  <revision>1714564800</revision>
+  <data type="primary">
+    <location href="repodata/primary.xml.zst"/>
+    <timestamp>1714564800</timestamp>
+  </data>
+  <data type="updateinfo">
+    <location href="repodata/updateinfo.xml.gz"/>
+    <timestamp>1714564000</timestamp>
+  </data>
+</repomd>
+`
+
+func compress(t *testing.T, ext string, content string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch ext {
+	case ".gz":
+		w = gzip.NewWriter(&buf)
+	case ".zst":
+		var err error
+		w, err = zstd.NewWriter(&buf)
+		require.NoError(t, err)
+	default:
+		t.Fatalf("unsupported extension %s", ext)
+	}
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestClient(t *testing.T) {
+	var srvURL string
+	files := map[string][]byte{
+		"/repo/repodata/repomd.xml":          []byte(testRepomd),
+		"/repo/repodata/primary.xml.zst":     compress(t, ".zst", "<metadata/>"),
+		"/repo/repodata/updateinfo.xml.gz":   compress(t, ".gz", "<updates/>"),
+		"/metalink":                          nil,
+		"/mirrorlist":                        nil,
+		"/mirrorlist-metalink":               nil,
+		"/empty-mirrorlist":                  []byte("# no mirrors\n"),
+		"/metalink-without-http-mirrors.xml": []byte(`<metalink><files><file name="repomd.xml"><resources><url protocol="rsync">rsync://example.com/repo/repodata/repomd.xml</url></resources></file></files></metalink>`),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var content []byte
+		switch r.URL.Path {
+		case "/metalink", "/mirrorlist-metalink":
+			content = []byte(`<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/" xmlns:mm0="http://fedorahosted.org/mirrormanager">
+  <files>
+    <file name="repomd.xml">
+      <mm0:timestamp>1714564800</mm0:timestamp>
+      <resources maxconnections="1">
+        <url protocol="https" type="https" location="US" preference="50">https://slow.example.com/repo/repodata/repomd.xml</url>
+        <url protocol="http" type="http" location="US" preference="100">` + srvURL + `/repo/repodata/repomd.xml</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`)
+		case "/mirrorlist":
+			content = []byte("# comment\n\n" + srvURL + "/repo/\nhttps://other.example.com/repo/\n")
+		default:
+			var ok bool
+			content, ok = files[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		w.Write(content) //nolint:errcheck
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	testCases := []struct {
+		name    string
+		repo    Repository
+		baseURL string
+		err     string
+	}{
+		{
+			name:    "baseurl",
+			repo:    Repository{Name: "baseurl", BaseURLs: []string{srv.URL + "/repo", "https://other.example.com/repo"}},
+			baseURL: srv.URL + "/repo",
+		},
+		{
+			name:    "metalink",
+			repo:    Repository{Name: "metalink", Metalink: srv.URL + "/metalink"},
+			baseURL: srv.URL + "/repo/",
+		},
+		{
+			name:    "mirrorlist",
+			repo:    Repository{Name: "mirrorlist", MirrorList: srv.URL + "/mirrorlist"},
+			baseURL: srv.URL + "/repo/",
+		},
+		{
+			name:    "mirrorlist-metalink",
+			repo:    Repository{Name: "mirrorlist", MirrorList: srv.URL + "/mirrorlist-metalink"},
+			baseURL: srv.URL + "/repo/",
+		},
+		{
+			name: "empty-mirrorlist",
+			repo: Repository{Name: "mirrorlist", MirrorList: srv.URL + "/empty-mirrorlist"},
+			err:  "invalid mirrorlist " + srv.URL + "/empty-mirrorlist: no mirrors found",
+		},
+		{
+			name: "metalink-without-http-mirrors",
+			repo: Repository{Name: "metalink", Metalink: srv.URL + "/metalink-without-http-mirrors.xml"},
+			err:  "invalid metalink " + srv.URL + "/metalink-without-http-mirrors.xml: no http, https or file mirror of repomd.xml found",
+		},
+		{
+			name: "missing-metalink",
+			repo: Repository{Name: "metalink", Metalink: srv.URL + "/missing"},
+			err:  `cannot fetch metalink: unexpected status "404 Not Found" from ` + srv.URL + "/missing",
+		},
+		{
+			name: "no-url",
+			repo: Repository{Name: "nothing"},
+			err:  `repository "nothing" has no base URL, metalink or mirrorlist`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClient(tc.repo)
+			require.NoError(t, err)
+			baseURL, err := client.BaseURL()
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.baseURL, baseURL)
+
+			repomd, err := client.Repomd()
+			require.NoError(t, err)
+			assert.Equal(t, "1714564800", repomd.Revision)
+			assert.Equal(t, []byte(testRepomd), repomd.Raw)
+
+			for dataType, expected := range map[string]string{"primary": "<metadata/>", "updateinfo": "<updates/>"} {
+				data, ok := repomd.Find(dataType)
+				require.True(t, ok)
+				f, err := client.OpenData(data)
+				require.NoError(t, err)
+				content, err := io.ReadAll(f)
+				require.NoError(t, err)
+				assert.NoError(t, f.Close())
+				assert.Equal(t, expected, string(content))
+			}
+			_, ok := repomd.Find("group")
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestClientFileURL(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repodata"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repodata", "repomd.xml"), []byte(testRepomd), 0644))
+
+	client, err := NewClient(Repository{Name: "local", BaseURLs: []string{"file://" + dir}})
+	require.NoError(t, err)
+	repomd, err := client.Repomd()
+	require.NoError(t, err)
+	assert.Len(t, repomd.Data, 2)
+
+	_, err = client.FetchURL("ftp://example.com/key.asc")
+	assert.EqualError(t, err, `unsupported URL scheme "ftp"`)
+}
+
+func TestNewHTTPClientErrors(t *testing.T) {
+	_, err := NewHTTPClient(Repository{SSLClientCert: "/etc/pki/client.pem"})
+	assert.EqualError(t, err, "both an SSL client certificate and key are required")
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, []byte("not a certificate"), 0644))
+	_, err = NewHTTPClient(Repository{SSLCACert: caCert})
+	assert.EqualError(t, err, "no certificates found in "+caCert)
+}
+
+func TestDecompressCloseStopsDecoder(t *testing.T) {
+	// the decoder only runs goroutines if it can decode concurrently
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	data := compress(t, ".zst", strings.Repeat("<package/>", 100000))
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		r, err := Decompress(io.NopCloser(bytes.NewReader(data)), "primary.xml.zst")
+		require.NoError(t, err)
+		// the goroutines are left running if the content is not read to
+		// the end, e.g. on a parse error, unless the decoder is closed
+		_, err = io.ReadFull(r, make([]byte, 10))
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	}
+	// the goroutines exit asynchronously after Close(), the count is polled
+	// here as assert.Eventually() runs a goroutine itself
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
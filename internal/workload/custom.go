@@ -1,8 +1,14 @@
 package workload
 
+import "github.com/osbuild/images/pkg/rpmmd"
+
 type Custom struct {
 	BaseWorkload
 	Packages         []string
+	LocalPackages    []rpmmd.LocalPackage
+	ExcludePackages  []string
+	PackageLocks     []rpmmd.PackageLock
+	EnabledModules   []rpmmd.ModuleStream
 	Services         []string
 	DisabledServices []string
 }
@@ -11,6 +17,22 @@ func (p *Custom) GetPackages() []string {
 	return p.Packages
 }
 
+func (p *Custom) GetLocalPackages() []rpmmd.LocalPackage {
+	return p.LocalPackages
+}
+
+func (p *Custom) GetExcludePackages() []string {
+	return p.ExcludePackages
+}
+
+func (p *Custom) GetPackageLocks() []rpmmd.PackageLock {
+	return p.PackageLocks
+}
+
+func (p *Custom) GetEnabledModules() []rpmmd.ModuleStream {
+	return p.EnabledModules
+}
+
 func (p *Custom) GetServices() []string {
 	return p.Services
 }
@@ -4,6 +4,10 @@ import "github.com/osbuild/images/pkg/rpmmd"
 
 type Workload interface {
 	GetPackages() []string
+	GetLocalPackages() []rpmmd.LocalPackage
+	GetExcludePackages() []string
+	GetPackageLocks() []rpmmd.PackageLock
+	GetEnabledModules() []rpmmd.ModuleStream
 	GetRepos() []rpmmd.RepoConfig
 	GetServices() []string
 	GetDisabledServices() []string
@@ -17,6 +21,22 @@ func (p BaseWorkload) GetPackages() []string {
 	return []string{}
 }
 
+func (p BaseWorkload) GetLocalPackages() []rpmmd.LocalPackage {
+	return nil
+}
+
+func (p BaseWorkload) GetExcludePackages() []string {
+	return nil
+}
+
+func (p BaseWorkload) GetPackageLocks() []rpmmd.PackageLock {
+	return nil
+}
+
+func (p BaseWorkload) GetEnabledModules() []rpmmd.ModuleStream {
+	return nil
+}
+
 func (p BaseWorkload) GetRepos() []rpmmd.RepoConfig {
 	return p.Repos
 }
@@ -0,0 +1,133 @@
+package blueprint
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type AuditCustomization struct {
+	Rules []AuditRulesCustomization `json:"rules,omitempty" toml:"rules,omitempty"`
+}
+
+// AuditRulesCustomization is a rules file in /etc/audit/rules.d. Each rule is
+// a single line in auditctl(8) syntax.
+type AuditRulesCustomization struct {
+	Filename string   `json:"filename" toml:"filename"`
+	Rules    []string `json:"rules" toml:"rules"`
+}
+
+const auditRulesFilenameRegex = "^[\\w.-]{1,250}\\.rules$"
+
+var (
+	auditRuleActions     = []string{"always", "never"}
+	auditRuleLists       = []string{"task", "exit", "user", "exclude", "filesystem", "io_uring"}
+	auditRuleFieldRegex  = regexp.MustCompile(`^[a-z0-9_]+(=|!=|<|>|<=|>=|&|&=)\S+$`)
+	auditRuleNoArgFlags  = []string{"-D", "-c", "-i", "--loginuid-immutable"}
+	auditRuleNumberFlags = []string{"-b", "-r", "--backlog_wait_time"}
+)
+
+// validateAuditRule checks that rule is a syntactically valid audit rule.
+// Empty lines and comments are accepted.
+func validateAuditRule(rule string) error {
+	fields := strings.Fields(rule)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+
+	invalid := func(reason string, args ...interface{}) error {
+		return fmt.Errorf("audit rule %q is invalid: %s", rule, fmt.Sprintf(reason, args...))
+	}
+
+	flag, args := fields[0], fields[1:]
+	switch {
+	case slices.Contains(auditRuleNoArgFlags, flag):
+		if len(args) != 0 {
+			return invalid("%s takes no arguments", flag)
+		}
+		return nil
+	case slices.Contains(auditRuleNumberFlags, flag):
+		if len(args) != 1 {
+			return invalid("%s requires a single argument", flag)
+		}
+		if _, err := strconv.ParseUint(args[0], 10, 32); err != nil {
+			return invalid("%s requires a non-negative number", flag)
+		}
+		return nil
+	case flag == "-e" || flag == "-f":
+		if len(args) != 1 || !slices.Contains([]string{"0", "1", "2"}, args[0]) {
+			return invalid("%s requires one of 0, 1 or 2", flag)
+		}
+		return nil
+	case flag == "-w" || flag == "-W":
+		if len(args) == 0 || !filepath.IsAbs(args[0]) {
+			return invalid("%s requires an absolute path", flag)
+		}
+		return validateAuditRuleOptions(args[1:], []string{"-p", "-k"}, invalid)
+	case flag == "-a" || flag == "-A":
+		if len(args) == 0 {
+			return invalid("%s requires a list and an action", flag)
+		}
+		listAction := strings.Split(args[0], ",")
+		if len(listAction) != 2 {
+			return invalid("%s requires a list and an action separated by a comma", flag)
+		}
+		if !(slices.Contains(auditRuleLists, listAction[0]) && slices.Contains(auditRuleActions, listAction[1])) &&
+			!(slices.Contains(auditRuleActions, listAction[0]) && slices.Contains(auditRuleLists, listAction[1])) {
+			return invalid("unknown list or action %q", args[0])
+		}
+		return validateAuditRuleOptions(args[1:], []string{"-F", "-S", "-C", "-k"}, invalid)
+	}
+
+	return invalid("unknown option %q", flag)
+}
+
+func validateAuditRuleOptions(args []string, allowed []string, invalid func(string, ...interface{}) error) error {
+	if len(args)%2 != 0 {
+		return invalid("option %q requires an argument", args[len(args)-1])
+	}
+	for idx := 0; idx < len(args); idx += 2 {
+		opt, value := args[idx], args[idx+1]
+		if !slices.Contains(allowed, opt) {
+			return invalid("unexpected option %q", opt)
+		}
+		switch opt {
+		case "-p":
+			if strings.Trim(value, "rwxa") != "" {
+				return invalid("permissions %q must only contain r, w, x and a", value)
+			}
+		case "-F", "-C":
+			if !auditRuleFieldRegex.MatchString(value) {
+				return invalid("field comparison %q is malformed", value)
+			}
+		}
+	}
+	return nil
+}
+
+func validateAuditCustomization(ac *AuditCustomization) error {
+	filenameRegex := regexp.MustCompile(auditRulesFilenameRegex)
+	filenames := make(map[string]bool)
+	for _, rf := range ac.Rules {
+		if !filenameRegex.MatchString(rf.Filename) {
+			return fmt.Errorf("audit rules filename %q is invalid", rf.Filename)
+		}
+		if filenames[rf.Filename] {
+			return fmt.Errorf("audit rules filename %q is used more than once", rf.Filename)
+		}
+		filenames[rf.Filename] = true
+
+		if len(rf.Rules) == 0 {
+			return fmt.Errorf("audit rules file %q contains no rules", rf.Filename)
+		}
+		for _, rule := range rf.Rules {
+			if err := validateAuditRule(rule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
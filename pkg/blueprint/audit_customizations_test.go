@@ -0,0 +1,82 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAuditRule(t *testing.T) {
+	valid := []string{
+		"",
+		"# a comment",
+		"-D",
+		"-b 8192",
+		"--backlog_wait_time 60000",
+		"-f 1",
+		"-e 2",
+		"--loginuid-immutable",
+		"-w /etc/passwd -p wa -k identity",
+		"-w /var/log/audit/",
+		"-a always,exit -F arch=b64 -S adjtimex,settimeofday -k time-change",
+		"-a exit,always -F arch=b32 -S open -F exit=-EACCES -F auid>=1000 -F auid!=unset -k access",
+		"-A exclude,never -F msgtype=CWD",
+	}
+	for _, rule := range valid {
+		assert.NoError(t, validateAuditRule(rule), rule)
+	}
+
+	invalid := map[string]string{
+		"-x":                          `audit rule "-x" is invalid: unknown option "-x"`,
+		"-D now":                      `audit rule "-D now" is invalid: -D takes no arguments`,
+		"-b":                          `audit rule "-b" is invalid: -b requires a single argument`,
+		"-b -1":                       `audit rule "-b -1" is invalid: -b requires a non-negative number`,
+		"-e 3":                        `audit rule "-e 3" is invalid: -e requires one of 0, 1 or 2`,
+		"-w etc/passwd":               `audit rule "-w etc/passwd" is invalid: -w requires an absolute path`,
+		"-w /etc/passwd -p wz":        `audit rule "-w /etc/passwd -p wz" is invalid: permissions "wz" must only contain r, w, x and a`,
+		"-w /etc/passwd -k":           `audit rule "-w /etc/passwd -k" is invalid: option "-k" requires an argument`,
+		"-w /etc/passwd -S open":      `audit rule "-w /etc/passwd -S open" is invalid: unexpected option "-S"`,
+		"-a always":                   `audit rule "-a always" is invalid: -a requires a list and an action separated by a comma`,
+		"-a sometimes,exit":           `audit rule "-a sometimes,exit" is invalid: unknown list or action "sometimes,exit"`,
+		"-a always,exit -F arch":      `audit rule "-a always,exit -F arch" is invalid: field comparison "arch" is malformed`,
+		"-a always,exit -p wa -k key": `audit rule "-a always,exit -p wa -k key" is invalid: unexpected option "-p"`,
+	}
+	for rule, expErr := range invalid {
+		assert.EqualError(t, validateAuditRule(rule), expErr)
+	}
+}
+
+func TestGetAuditErrors(t *testing.T) {
+	tests := map[string]struct {
+		ac     AuditCustomization
+		expErr string
+	}{
+		"bad-filename": {
+			ac:     AuditCustomization{Rules: []AuditRulesCustomization{{Filename: "identity.conf", Rules: []string{"-D"}}}},
+			expErr: `audit rules filename "identity.conf" is invalid`,
+		},
+		"duplicate-filename": {
+			ac: AuditCustomization{Rules: []AuditRulesCustomization{
+				{Filename: "10-base.rules", Rules: []string{"-D"}},
+				{Filename: "10-base.rules", Rules: []string{"-b 8192"}},
+			}},
+			expErr: `audit rules filename "10-base.rules" is used more than once`,
+		},
+		"no-rules": {
+			ac:     AuditCustomization{Rules: []AuditRulesCustomization{{Filename: "10-base.rules"}}},
+			expErr: `audit rules file "10-base.rules" contains no rules`,
+		},
+		"bad-rule": {
+			ac:     AuditCustomization{Rules: []AuditRulesCustomization{{Filename: "10-base.rules", Rules: []string{"-e 5"}}}},
+			expErr: `audit rule "-e 5" is invalid: -e requires one of 0, 1 or 2`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Customizations{Audit: &tc.ac}
+			_, err := c.GetAudit()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
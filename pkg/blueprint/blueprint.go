@@ -1,6 +1,13 @@
 // Package blueprint contains primitives for representing weldr blueprints
 package blueprint
 
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
 // A Blueprint is a high-level description of an image.
 type Blueprint struct {
 	Name           string          `json:"name" toml:"name"`
@@ -10,6 +17,10 @@ type Blueprint struct {
 	Modules        []Package       `json:"modules" toml:"modules"`
 	Groups         []Group         `json:"groups" toml:"groups"`
 	Containers     []Container     `json:"containers,omitempty" toml:"containers,omitempty"`
+	LocalPackages  []LocalPackage  `json:"local_packages,omitempty" toml:"local_packages,omitempty"`
+	Exclude        []string        `json:"exclude,omitempty" toml:"exclude,omitempty"`
+	Locks          []PackageLock   `json:"locks,omitempty" toml:"locks,omitempty"`
+	EnabledModules []EnabledModule `json:"enabled_modules,omitempty" toml:"enabled_modules,omitempty"`
 	Customizations *Customizations `json:"customizations,omitempty" toml:"customizations"`
 	Distro         string          `json:"distro" toml:"distro"`
 
@@ -23,17 +34,50 @@ type Package struct {
 	Version string `json:"version,omitempty" toml:"version,omitempty"`
 }
 
+// A LocalPackage specifies an RPM file on the host that is installed in the
+// image together with the packages from the repositories. The file name must
+// be the NEVRA of the package, as produced by rpmbuild, e.g.
+// "hello-1.0-1.fc40.x86_64.rpm".
+type LocalPackage struct {
+	Path string `json:"path" toml:"path"`
+	// Checksum of the file in the form "sha256:<hex>"
+	Checksum string `json:"checksum" toml:"checksum"`
+}
+
+// A PackageLock pins a package to an exact version. The lock only applies if
+// the package is installed, it does not add the package to the image.
+type PackageLock struct {
+	Name string `json:"name" toml:"name"`
+	// Exact [epoch:]version-release of the package, e.g. "1:3.0.7-27.el9"
+	EVR string `json:"evr" toml:"evr"`
+}
+
+// An EnabledModule is a DNF module stream that is enabled in the image. The
+// packages of the module are installed from the stream, and the packages of
+// the profile, if any, are installed as well.
+type EnabledModule struct {
+	Name    string `json:"name" toml:"name"`
+	Stream  string `json:"stream" toml:"stream"`
+	Profile string `json:"profile,omitempty" toml:"profile,omitempty"`
+}
+
 // A group specifies an package group.
 type Group struct {
 	Name string `json:"name" toml:"name"`
 }
 
 type Container struct {
+	// Reference of the container in a registry, or an OCI archive or layout
+	// directory on the build host, "oci-archive:<path>" or "oci:<path>[:<ref>]"
 	Source string `json:"source" toml:"source"`
 	Name   string `json:"name,omitempty" toml:"name,omitempty"`
 
 	TLSVerify    *bool `json:"tls-verify,omitempty" toml:"tls-verify,omitempty"`
 	LocalStorage bool  `json:"local-storage,omitempty" toml:"local-storage,omitempty"`
+
+	// Require a signature of the container that is verified with the
+	// signature policy of the host
+	RequireSignature bool `json:"require-signature,omitempty" toml:"require-signature,omitempty"`
 }
 
 // packages, modules, and groups all resolve to rpm packages right now. This
@@ -63,6 +107,118 @@ func (b *Blueprint) GetPackagesEx(bootable bool) []string {
 	return packages
 }
 
+var localPackageChecksumRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// GetLocalPackages returns the local RPM files of the blueprint, or an error
+// if one of them is invalid.
+func (b *Blueprint) GetLocalPackages() ([]LocalPackage, error) {
+	if b == nil {
+		return nil, nil
+	}
+	names := make(map[string]bool)
+	for _, pkg := range b.LocalPackages {
+		if !filepath.IsAbs(pkg.Path) || filepath.Clean(pkg.Path) != pkg.Path {
+			return nil, fmt.Errorf("local package path %q must be absolute and canonical", pkg.Path)
+		}
+		name := filepath.Base(pkg.Path)
+		if !strings.HasSuffix(name, ".rpm") || name == ".rpm" {
+			return nil, fmt.Errorf("local package %q must be an RPM file with the .rpm extension", pkg.Path)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("local package file name %q is used more than once", name)
+		}
+		names[name] = true
+		if !localPackageChecksumRegex.MatchString(pkg.Checksum) {
+			return nil, fmt.Errorf("checksum %q of local package %q is invalid: must be \"sha256:\" followed by 64 lowercase hexadecimal characters", pkg.Checksum, pkg.Path)
+		}
+	}
+	return b.LocalPackages, nil
+}
+
+var (
+	excludeSpecRegex = regexp.MustCompile(`^[^\s,]+$`)
+	evrRegex         = regexp.MustCompile(`^([0-9]+:)?[^\s:-]+-[^\s:-]+$`)
+)
+
+// GetExcludes returns the packages that must not be installed, or an error
+// if one of them is invalid or also requested by the blueprint.
+func (b *Blueprint) GetExcludes() ([]string, error) {
+	if b == nil {
+		return nil, nil
+	}
+	for _, exclude := range b.Exclude {
+		if !excludeSpecRegex.MatchString(exclude) {
+			return nil, fmt.Errorf("excluded package %q is invalid", exclude)
+		}
+		for _, pkg := range b.Packages {
+			if pkg.Name == exclude {
+				return nil, fmt.Errorf("package %q is both requested and excluded", exclude)
+			}
+		}
+	}
+	return b.Exclude, nil
+}
+
+// GetPackageLocks returns the package version locks of the blueprint, or an
+// error if one of them is invalid or conflicts with the other packages of the
+// blueprint.
+func (b *Blueprint) GetPackageLocks() ([]PackageLock, error) {
+	if b == nil {
+		return nil, nil
+	}
+	names := make(map[string]bool)
+	for _, lock := range b.Locks {
+		if lock.Name == "" || !excludeSpecRegex.MatchString(lock.Name) || strings.ContainsAny(lock.Name, "*?[") {
+			return nil, fmt.Errorf("locked package name %q is invalid", lock.Name)
+		}
+		if names[lock.Name] {
+			return nil, fmt.Errorf("package %q is locked more than once", lock.Name)
+		}
+		names[lock.Name] = true
+		if !evrRegex.MatchString(lock.EVR) {
+			return nil, fmt.Errorf("lock of package %q is invalid: %q is not an [epoch:]version-release", lock.Name, lock.EVR)
+		}
+		for _, exclude := range b.Exclude {
+			if exclude == lock.Name {
+				return nil, fmt.Errorf("package %q is both locked and excluded", lock.Name)
+			}
+		}
+		for _, pkg := range b.Packages {
+			if pkg.Name == lock.Name && pkg.Version != "" && pkg.Version != "*" {
+				return nil, fmt.Errorf("package %q is locked to %s but requested with version %q", lock.Name, lock.EVR, pkg.Version)
+			}
+		}
+	}
+	return b.Locks, nil
+}
+
+var moduleNameRegex = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+
+// GetEnabledModules returns the module streams to enable, or an error if one
+// of them is invalid. Only one stream of each module can be enabled.
+func (b *Blueprint) GetEnabledModules() ([]EnabledModule, error) {
+	if b == nil {
+		return nil, nil
+	}
+	names := make(map[string]bool)
+	for _, module := range b.EnabledModules {
+		if !moduleNameRegex.MatchString(module.Name) {
+			return nil, fmt.Errorf("module name %q is invalid", module.Name)
+		}
+		if names[module.Name] {
+			return nil, fmt.Errorf("module %q is enabled more than once", module.Name)
+		}
+		names[module.Name] = true
+		if !moduleNameRegex.MatchString(module.Stream) {
+			return nil, fmt.Errorf("stream %q of module %q is invalid", module.Stream, module.Name)
+		}
+		if module.Profile != "" && !moduleNameRegex.MatchString(module.Profile) {
+			return nil, fmt.Errorf("profile %q of module %q is invalid", module.Profile, module.Name)
+		}
+	}
+	return b.EnabledModules, nil
+}
+
 func (p Package) ToNameVersion() string {
 	// Omit version to prevent all packages with prefix of name to be installed
 	if p.Version == "*" || p.Version == "" {
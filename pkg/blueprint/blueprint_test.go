@@ -2,6 +2,7 @@ package blueprint
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/BurntSushi/toml"
@@ -87,6 +88,168 @@ func TestGetPackages(t *testing.T) {
 	assert.ElementsMatch(t, []string{"tmux-1.2", "openssh-server", "@anaconda-tools", "kernel"}, Received_packages)
 }
 
+func TestGetLocalPackages(t *testing.T) {
+	checksum := "sha256:" + strings.Repeat("ab", 32)
+
+	bp := Blueprint{
+		LocalPackages: []LocalPackage{
+			{Path: "/srv/rpms/hello-1.0-1.fc40.x86_64.rpm", Checksum: checksum},
+		},
+	}
+	pkgs, err := bp.GetLocalPackages()
+	assert.NoError(t, err)
+	assert.Equal(t, bp.LocalPackages, pkgs)
+
+	var nilbp *Blueprint
+	pkgs, err = nilbp.GetLocalPackages()
+	assert.NoError(t, err)
+	assert.Nil(t, pkgs)
+
+	tests := map[string]struct {
+		pkgs   []LocalPackage
+		expErr string
+	}{
+		"relative-path": {
+			pkgs:   []LocalPackage{{Path: "hello-1.0-1.fc40.x86_64.rpm", Checksum: checksum}},
+			expErr: `local package path "hello-1.0-1.fc40.x86_64.rpm" must be absolute and canonical`,
+		},
+		"not-canonical": {
+			pkgs:   []LocalPackage{{Path: "/srv/../hello-1.0-1.fc40.x86_64.rpm", Checksum: checksum}},
+			expErr: `local package path "/srv/../hello-1.0-1.fc40.x86_64.rpm" must be absolute and canonical`,
+		},
+		"not-rpm": {
+			pkgs:   []LocalPackage{{Path: "/srv/hello.tar.gz", Checksum: checksum}},
+			expErr: `local package "/srv/hello.tar.gz" must be an RPM file with the .rpm extension`,
+		},
+		"duplicate-name": {
+			pkgs: []LocalPackage{
+				{Path: "/srv/a/hello-1.0-1.fc40.x86_64.rpm", Checksum: checksum},
+				{Path: "/srv/b/hello-1.0-1.fc40.x86_64.rpm", Checksum: checksum},
+			},
+			expErr: `local package file name "hello-1.0-1.fc40.x86_64.rpm" is used more than once`,
+		},
+		"bad-checksum": {
+			pkgs:   []LocalPackage{{Path: "/srv/hello-1.0-1.fc40.x86_64.rpm", Checksum: "md5:abcd"}},
+			expErr: `checksum "md5:abcd" of local package "/srv/hello-1.0-1.fc40.x86_64.rpm" is invalid: must be "sha256:" followed by 64 lowercase hexadecimal characters`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bp := Blueprint{LocalPackages: tc.pkgs}
+			_, err := bp.GetLocalPackages()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
+
+func TestGetExcludesAndLocks(t *testing.T) {
+	bp := Blueprint{
+		Packages: []Package{{Name: "httpd"}, {Name: "openssl", Version: "*"}},
+		Exclude:  []string{"PackageKit*", "abrt"},
+		Locks:    []PackageLock{{Name: "openssl", EVR: "1:3.0.7-27.el9"}, {Name: "httpd", EVR: "2.4.57-5.el9"}},
+	}
+	excludes, err := bp.GetExcludes()
+	assert.NoError(t, err)
+	assert.Equal(t, bp.Exclude, excludes)
+	locks, err := bp.GetPackageLocks()
+	assert.NoError(t, err)
+	assert.Equal(t, bp.Locks, locks)
+
+	var nilbp *Blueprint
+	excludes, err = nilbp.GetExcludes()
+	assert.NoError(t, err)
+	assert.Nil(t, excludes)
+	locks, err = nilbp.GetPackageLocks()
+	assert.NoError(t, err)
+	assert.Nil(t, locks)
+
+	tests := map[string]struct {
+		bp     Blueprint
+		expErr string
+	}{
+		"bad-exclude": {
+			bp:     Blueprint{Exclude: []string{"abrt, PackageKit"}},
+			expErr: `excluded package "abrt, PackageKit" is invalid`,
+		},
+		"requested-and-excluded": {
+			bp:     Blueprint{Packages: []Package{{Name: "abrt"}}, Exclude: []string{"abrt"}},
+			expErr: `package "abrt" is both requested and excluded`,
+		},
+		"glob-lock": {
+			bp:     Blueprint{Locks: []PackageLock{{Name: "openssl*", EVR: "3.0.7-27.el9"}}},
+			expErr: `locked package name "openssl*" is invalid`,
+		},
+		"duplicate-lock": {
+			bp:     Blueprint{Locks: []PackageLock{{Name: "openssl", EVR: "3.0.7-27.el9"}, {Name: "openssl", EVR: "3.0.7-28.el9"}}},
+			expErr: `package "openssl" is locked more than once`,
+		},
+		"bad-evr": {
+			bp:     Blueprint{Locks: []PackageLock{{Name: "openssl", EVR: "3.0.7"}}},
+			expErr: `lock of package "openssl" is invalid: "3.0.7" is not an [epoch:]version-release`,
+		},
+		"locked-and-excluded": {
+			bp:     Blueprint{Exclude: []string{"openssl"}, Locks: []PackageLock{{Name: "openssl", EVR: "3.0.7-27.el9"}}},
+			expErr: `package "openssl" is both locked and excluded`,
+		},
+		"locked-and-versioned": {
+			bp:     Blueprint{Packages: []Package{{Name: "openssl", Version: "3.1*"}}, Locks: []PackageLock{{Name: "openssl", EVR: "3.0.7-27.el9"}}},
+			expErr: `package "openssl" is locked to 3.0.7-27.el9 but requested with version "3.1*"`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.bp.GetExcludes()
+			if err == nil {
+				_, err = tc.bp.GetPackageLocks()
+			}
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
+
+func TestGetEnabledModules(t *testing.T) {
+	bp := Blueprint{
+		EnabledModules: []EnabledModule{{Name: "nodejs", Stream: "18"}, {Name: "postgresql", Stream: "15", Profile: "server"}},
+	}
+	modules, err := bp.GetEnabledModules()
+	assert.NoError(t, err)
+	assert.Equal(t, bp.EnabledModules, modules)
+
+	var nilbp *Blueprint
+	modules, err = nilbp.GetEnabledModules()
+	assert.NoError(t, err)
+	assert.Nil(t, modules)
+
+	tests := map[string]struct {
+		modules []EnabledModule
+		expErr  string
+	}{
+		"bad-name": {
+			modules: []EnabledModule{{Name: "nodejs:18", Stream: "18"}},
+			expErr:  `module name "nodejs:18" is invalid`,
+		},
+		"duplicate": {
+			modules: []EnabledModule{{Name: "nodejs", Stream: "18"}, {Name: "nodejs", Stream: "20"}},
+			expErr:  `module "nodejs" is enabled more than once`,
+		},
+		"no-stream": {
+			modules: []EnabledModule{{Name: "nodejs"}},
+			expErr:  `stream "" of module "nodejs" is invalid`,
+		},
+		"bad-profile": {
+			modules: []EnabledModule{{Name: "nodejs", Stream: "18", Profile: "common/dev"}},
+			expErr:  `profile "common/dev" of module "nodejs" is invalid`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			bp := Blueprint{EnabledModules: tc.modules}
+			_, err := bp.GetEnabledModules()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
+
 func TestKernelNameCustomization(t *testing.T) {
 	kernels := []string{"kernel", "kernel-debug", "kernel-rt"}
 
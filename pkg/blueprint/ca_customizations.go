@@ -0,0 +1,79 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/osbuild/images/pkg/cert"
+)
+
+// CACustomization adds certificate authorities to the system trust store
+type CACustomization struct {
+	// PEM encoded certificate bundles, each containing one or more
+	// certificates
+	PEMCerts []string `json:"pem_certs,omitempty" toml:"pem_certs,omitempty"`
+}
+
+func validateCACustomization(ca *CACustomization) error {
+	if len(ca.PEMCerts) == 0 {
+		return fmt.Errorf("cacerts customization requires at least one certificate")
+	}
+	for idx, bundle := range ca.PEMCerts {
+		certs, err := cert.ParseCerts(bundle)
+		if err != nil {
+			return fmt.Errorf("invalid certificate bundle #%d in cacerts customization: %w", idx+1, err)
+		}
+		// only certificate authorities can be trust anchors
+		for _, c := range certs {
+			if !c.BasicConstraintsValid || !c.IsCA {
+				return fmt.Errorf("invalid certificate bundle #%d in cacerts customization: certificate %q is not a CA certificate", idx+1, c.Subject.CommonName)
+			}
+		}
+	}
+	return nil
+}
+
+// Custom TOML unmarshalling for CACustomization with validation
+func (ca *CACustomization) UnmarshalTOML(data interface{}) error {
+	var caCustomization CACustomization
+
+	dataMap, _ := data.(map[string]interface{})
+
+	switch pemCerts := dataMap["pem_certs"].(type) {
+	case []interface{}:
+		for _, pemCert := range pemCerts {
+			pemCertStr, ok := pemCert.(string)
+			if !ok {
+				return fmt.Errorf("UnmarshalTOML: pem_certs must be a list of strings")
+			}
+			caCustomization.PEMCerts = append(caCustomization.PEMCerts, pemCertStr)
+		}
+	default:
+		return fmt.Errorf("UnmarshalTOML: pem_certs must be a list of strings")
+	}
+
+	if err := validateCACustomization(&caCustomization); err != nil {
+		return err
+	}
+
+	*ca = caCustomization
+	return nil
+}
+
+// Custom JSON unmarshalling for CACustomization with validation
+func (ca *CACustomization) UnmarshalJSON(data []byte) error {
+	type caCustomization CACustomization
+
+	var caPrivate caCustomization
+	if err := json.Unmarshal(data, &caPrivate); err != nil {
+		return err
+	}
+
+	caCust := CACustomization(caPrivate)
+	if err := validateCACustomization(&caCust); err != nil {
+		return err
+	}
+
+	*ca = caCust
+	return nil
+}
@@ -0,0 +1,81 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIBgzCCASmgAwIBAgIUSDm4/9TNoy/pAInNMj4klsS/TWYwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLVGVzdCBDQSBUd28wIBcNMjYxMDE4MjE1NjMwWhgPMjEyNjA5
+MjQyMTU2MzBaMBYxFDASBgNVBAMMC1Rlc3QgQ0EgVHdvMFkwEwYHKoZIzj0CAQYI
+KoZIzj0DAQcDQgAEun2KePq54xx1tbj30AU1v1u1UVqV1UT330hcxGwzZ81mhv8a
+gD0OFuaYVt+WTp0DBKn7h/Cpp2BkT700NRAQfaNTMFEwHQYDVR0OBBYEFIto22Qt
+NHw2Aa6IGLfow5bQvYDmMB8GA1UdIwQYMBaAFIto22QtNHw2Aa6IGLfow5bQvYDm
+MA8GA1UdEwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSAAwRQIgaJCpDX515kf76Tzi
+fZhwB4hRdpGswI2Q7eRQ9ogMVU0CIQC92RWghBhpcWQUidbHoy1BDIal/EFTcK3y
+OHFwwvTqTg==
+-----END CERTIFICATE-----
+`
+
+// a server certificate that is not a certificate authority
+const testLeafCert = `-----BEGIN CERTIFICATE-----
+MIIBgDCCASagAwIBAgIUFuZ4i6uZ6iRAZbbZpmd6EEVnveswCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLVGVzdCBTZXJ2ZXIwIBcNMjYxMDE5MDE1OTA5WhgPMjEyNjA5
+MjUwMTU5MDlaMBYxFDASBgNVBAMMC1Rlc3QgU2VydmVyMFkwEwYHKoZIzj0CAQYI
+KoZIzj0DAQcDQgAEmh/tVHUGDNROnSm5BbNChiA9rfK7ZYUjeo40i/8B3Wl9gehy
+eY+8Do5jhTAQoFYTdTQ4o/q5w/860USOCdlz9qNQME4wHQYDVR0OBBYEFASfIZyC
+g/I7du/ZbreCoRY1TT6IMB8GA1UdIwQYMBaAFASfIZyCg/I7du/ZbreCoRY1TT6I
+MAwGA1UdEwEB/wQCMAAwCgYIKoZIzj0EAwIDSAAwRQIgah9OFSxLB3Q1mmvPcVFr
+lapZSn8cROjIX9b0+b5bL6UCIQDk7BPTkFrNNnF0M1Qj5YGfcPeHV2whAaNY6tvG
+M8yViQ==
+-----END CERTIFICATE-----
+`
+
+func TestCACustomizationUnmarshalTOML(t *testing.T) {
+	var bp Blueprint
+	bpToml := "name = \"test\"\n[customizations.cacerts]\npem_certs = [\"\"\"\n" + testCACert + "\"\"\"]\n"
+	err := toml.Unmarshal([]byte(bpToml), &bp)
+	require.NoError(t, err)
+	require.NotNil(t, bp.Customizations.CACerts)
+	assert.Equal(t, []string{testCACert}, bp.Customizations.CACerts.PEMCerts)
+
+	err = toml.Unmarshal([]byte("[customizations.cacerts]\npem_certs = [\"garbage\"]\n"), &bp)
+	assert.ErrorContains(t, err, "invalid certificate bundle #1 in cacerts customization: unexpected data after the last certificate")
+
+	err = toml.Unmarshal([]byte("[customizations.cacerts]\npem_certs = \"garbage\"\n"), &bp)
+	assert.ErrorContains(t, err, "UnmarshalTOML: pem_certs must be a list of strings")
+}
+
+func TestCACustomizationUnmarshalJSON(t *testing.T) {
+	data, err := json.Marshal(map[string]interface{}{
+		"cacerts": map[string]interface{}{"pem_certs": []string{testCACert}},
+	})
+	require.NoError(t, err)
+
+	var c Customizations
+	require.NoError(t, json.Unmarshal(data, &c))
+	assert.Equal(t, []string{testCACert}, c.CACerts.PEMCerts)
+
+	err = json.Unmarshal([]byte(`{"cacerts": {"pem_certs": []}}`), &c)
+	assert.EqualError(t, err, "cacerts customization requires at least one certificate")
+}
+
+func TestGetCACerts(t *testing.T) {
+	c := &Customizations{CACerts: &CACustomization{PEMCerts: []string{testCACert}}}
+	ca, err := c.GetCACerts()
+	require.NoError(t, err)
+	assert.Equal(t, c.CACerts, ca)
+
+	c = &Customizations{CACerts: &CACustomization{PEMCerts: []string{"garbage"}}}
+	_, err = c.GetCACerts()
+	assert.EqualError(t, err, "invalid certificate bundle #1 in cacerts customization: unexpected data after the last certificate")
+
+	c = &Customizations{CACerts: &CACustomization{PEMCerts: []string{testCACert, testCACert + testLeafCert}}}
+	_, err = c.GetCACerts()
+	assert.EqualError(t, err, `invalid certificate bundle #2 in cacerts customization: certificate "Test Server" is not a CA certificate`)
+}
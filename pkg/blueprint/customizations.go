@@ -32,6 +32,12 @@ type Customizations struct {
 	Installer          *InstallerCustomization        `json:"installer,omitempty" toml:"installer,omitempty"`
 	RPM                *RPMCustomization              `json:"rpm,omitempty" toml:"rpm,omitempty"`
 	RHSM               *RHSMCustomization             `json:"rhsm,omitempty" toml:"rhsm,omitempty"`
+	SELinux            *SELinuxCustomization          `json:"selinux,omitempty" toml:"selinux,omitempty"`
+	Journald           *JournaldCustomization         `json:"journald,omitempty" toml:"journald,omitempty"`
+	Audit              *AuditCustomization            `json:"audit,omitempty" toml:"audit,omitempty"`
+	CryptoPolicy       *string                        `json:"crypto_policy,omitempty" toml:"crypto_policy,omitempty"`
+	CACerts            *CACustomization               `json:"cacerts,omitempty" toml:"cacerts,omitempty"`
+	Sudoers            []SudoersCustomization         `json:"sudoers,omitempty" toml:"sudoers,omitempty"`
 }
 
 type IgnitionCustomization struct {
@@ -79,6 +85,14 @@ type UserCustomization struct {
 	GID                *int     `json:"gid,omitempty" toml:"gid,omitempty"`
 	ExpireDate         *int     `json:"expiredate,omitempty" toml:"expiredate,omitempty"`
 	ForcePasswordReset *bool    `json:"force_password_reset,omitempty" toml:"force_password_reset,omitempty"`
+
+	// Authorized SSH keys, in addition to Key
+	Keys          []AuthorizedKeyCustomization `json:"keys,omitempty" toml:"keys,omitempty"`
+	PasswordAging *PasswordAgingCustomization  `json:"password_aging,omitempty" toml:"password_aging,omitempty"`
+	// Lock the password of the account, like passwd --lock
+	Locked *bool                    `json:"locked,omitempty" toml:"locked,omitempty"`
+	SubUID *SubIDRangeCustomization `json:"subuid,omitempty" toml:"subuid,omitempty"`
+	SubGID *SubIDRangeCustomization `json:"subgid,omitempty" toml:"subgid,omitempty"`
 }
 
 type GroupCustomization struct {
@@ -260,6 +274,13 @@ func (c *Customizations) GetUsers() []UserCustomization {
 	return users
 }
 
+// CheckUsers checks the users returned by GetUsers, including the users of
+// the legacy sshkey customization, for invalid SSH keys, password aging
+// policies and subordinate ID ranges.
+func (c *Customizations) CheckUsers() error {
+	return validateUserCustomizations(c.GetUsers())
+}
+
 func (c *Customizations) GetGroups() []GroupCustomization {
 	if c == nil {
 		return nil
@@ -389,6 +410,15 @@ func (c *Customizations) GetFIPS() bool {
 	return *c.FIPS
 }
 
+// GetCryptoPolicy returns the system-wide crypto policy with optional
+// subpolicies, e.g. "DEFAULT:NO-SHA1", or an empty string if not set.
+func (c *Customizations) GetCryptoPolicy() string {
+	if c == nil || c.CryptoPolicy == nil {
+		return ""
+	}
+	return *c.CryptoPolicy
+}
+
 func (c *Customizations) GetContainerStorage() *ContainerStorageCustomization {
 	if c == nil || c.ContainersStorage == nil {
 		return nil
@@ -439,3 +469,63 @@ func (c *Customizations) GetRHSM() *RHSMCustomization {
 	}
 	return c.RHSM
 }
+
+func (c *Customizations) GetSELinux() (*SELinuxCustomization, error) {
+	if c == nil || c.SELinux == nil {
+		return nil, nil
+	}
+
+	if err := validateSELinuxCustomization(c.SELinux, c.Files); err != nil {
+		return nil, err
+	}
+
+	return c.SELinux, nil
+}
+
+func (c *Customizations) GetJournald() (*JournaldCustomization, error) {
+	if c == nil || c.Journald == nil {
+		return nil, nil
+	}
+
+	if err := validateJournaldCustomization(c.Journald); err != nil {
+		return nil, err
+	}
+
+	return c.Journald, nil
+}
+
+func (c *Customizations) GetAudit() (*AuditCustomization, error) {
+	if c == nil || c.Audit == nil {
+		return nil, nil
+	}
+
+	if err := validateAuditCustomization(c.Audit); err != nil {
+		return nil, err
+	}
+
+	return c.Audit, nil
+}
+
+func (c *Customizations) GetCACerts() (*CACustomization, error) {
+	if c == nil || c.CACerts == nil {
+		return nil, nil
+	}
+
+	if err := validateCACustomization(c.CACerts); err != nil {
+		return nil, err
+	}
+
+	return c.CACerts, nil
+}
+
+func (c *Customizations) GetSudoers() ([]SudoersCustomization, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	if err := validateSudoersCustomizations(c.Sudoers, c.GetUsers(), c.GetGroups()); err != nil {
+		return nil, err
+	}
+
+	return c.Sudoers, nil
+}
@@ -190,6 +190,12 @@ type FileCustomization struct {
 	Mode string `json:"mode,omitempty" toml:"mode,omitempty"`
 	// Data is the file content in plain text
 	Data string `json:"data,omitempty" toml:"data,omitempty"`
+	// URL is the http(s) location of the file content, as an alternative to
+	// Data. The content is downloaded when building the image.
+	URL string `json:"url,omitempty" toml:"url,omitempty"`
+	// SHA256 is the hex encoded checksum of the content at URL. It is
+	// required when URL is set.
+	SHA256 string `json:"sha256,omitempty" toml:"sha256,omitempty"`
 }
 
 // Custom TOML unmarshalling for FileCustomization with validation
@@ -245,6 +251,24 @@ func (f *FileCustomization) UnmarshalTOML(data interface{}) error {
 		return fmt.Errorf("UnmarshalTOML: data must be a string")
 	}
 
+	switch url := dataMap["url"].(type) {
+	case string:
+		file.URL = url
+	case nil:
+		break
+	default:
+		return fmt.Errorf("UnmarshalTOML: url must be a string")
+	}
+
+	switch sha256 := dataMap["sha256"].(type) {
+	case string:
+		file.SHA256 = sha256
+	case nil:
+		break
+	default:
+		return fmt.Errorf("UnmarshalTOML: sha256 must be a string")
+	}
+
 	// try converting to fsnode.File to validate all values
 	_, err := file.ToFsNodeFile()
 	if err != nil {
@@ -309,6 +333,19 @@ func (f FileCustomization) ToFsNodeFile() (*fsnode.File, error) {
 		mode = common.ToPtr(os.FileMode(modeNum))
 	}
 
+	if f.URL != "" {
+		if f.Data != "" {
+			return nil, fmt.Errorf("file %q cannot have both data and url", f.Path)
+		}
+		if f.SHA256 == "" {
+			return nil, fmt.Errorf("file %q with url %q requires a sha256 checksum", f.Path, f.URL)
+		}
+		return fsnode.NewRemoteFile(f.Path, mode, f.User, f.Group, f.URL, f.SHA256)
+	}
+	if f.SHA256 != "" {
+		return nil, fmt.Errorf("file %q has a sha256 checksum but no url", f.Path)
+	}
+
 	return fsnode.NewFile(f.Path, mode, f.User, f.Group, data)
 }
 
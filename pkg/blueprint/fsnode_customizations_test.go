@@ -10,6 +10,7 @@ import (
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/pathpolicy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDirectoryCustomizationToFsNodeDirectory(t *testing.T) {
@@ -1252,3 +1253,60 @@ func TestCheckDirectoryCustomizationsPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestFileCustomizationRemote(t *testing.T) {
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+
+	var bp Blueprint
+	err := toml.Unmarshal([]byte(`
+name = "test"
+
+[[customizations.files]]
+path = "/etc/bundle.tar"
+mode = "0600"
+url = "https://example.com/bundle.tar"
+sha256 = "`+checksum+`"
+`), &bp)
+	require.NoError(t, err)
+	assert.Equal(t, []FileCustomization{
+		{Path: "/etc/bundle.tar", Mode: "0600", URL: "https://example.com/bundle.tar", SHA256: checksum},
+	}, bp.Customizations.Files)
+
+	file, err := bp.Customizations.Files[0].ToFsNodeFile()
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/bundle.tar", file.URL())
+	assert.Equal(t, checksum, file.SHA256())
+
+	testCases := []struct {
+		name   string
+		file   FileCustomization
+		expErr string
+	}{
+		{
+			name:   "data-and-url",
+			file:   FileCustomization{Path: "/etc/file", Data: "data", URL: "https://example.com/file", SHA256: checksum},
+			expErr: `file "/etc/file" cannot have both data and url`,
+		},
+		{
+			name:   "url-without-checksum",
+			file:   FileCustomization{Path: "/etc/file", URL: "https://example.com/file"},
+			expErr: `file "/etc/file" with url "https://example.com/file" requires a sha256 checksum`,
+		},
+		{
+			name:   "checksum-without-url",
+			file:   FileCustomization{Path: "/etc/file", SHA256: checksum},
+			expErr: `file "/etc/file" has a sha256 checksum but no url`,
+		},
+		{
+			name:   "bad-checksum",
+			file:   FileCustomization{Path: "/etc/file", URL: "https://example.com/file", SHA256: "1234"},
+			expErr: `sha256 checksum "1234" of file "/etc/file" must be 64 lowercase hexadecimal characters`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.file.ToFsNodeFile()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
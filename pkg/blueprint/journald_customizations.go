@@ -0,0 +1,64 @@
+package blueprint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+type JournaldCustomization struct {
+	// Where to store journal data: volatile, persistent, auto or none
+	Storage string `json:"storage,omitempty" toml:"storage,omitempty"`
+
+	// Size limits for the persistent (system) and volatile (runtime)
+	// journal, e.g. "500M" or "2G"
+	SystemMaxUse       string `json:"system_max_use,omitempty" toml:"system_max_use,omitempty"`
+	SystemMaxFileSize  string `json:"system_max_file_size,omitempty" toml:"system_max_file_size,omitempty"`
+	RuntimeMaxUse      string `json:"runtime_max_use,omitempty" toml:"runtime_max_use,omitempty"`
+	RuntimeMaxFileSize string `json:"runtime_max_file_size,omitempty" toml:"runtime_max_file_size,omitempty"`
+
+	// Maximum time to store journal entries, e.g. "30day" or "1year"
+	MaxRetentionSec string `json:"max_retention_sec,omitempty" toml:"max_retention_sec,omitempty"`
+
+	ForwardToSyslog  *bool `json:"forward_to_syslog,omitempty" toml:"forward_to_syslog,omitempty"`
+	ForwardToKMsg    *bool `json:"forward_to_kmsg,omitempty" toml:"forward_to_kmsg,omitempty"`
+	ForwardToConsole *bool `json:"forward_to_console,omitempty" toml:"forward_to_console,omitempty"`
+	ForwardToWall    *bool `json:"forward_to_wall,omitempty" toml:"forward_to_wall,omitempty"`
+}
+
+var (
+	journaldStorageValues = []string{"volatile", "persistent", "auto", "none"}
+	journaldSizeRegex     = regexp.MustCompile(`^[0-9]+[KMGTPE]?$`)
+	journaldTimespanRegex = regexp.MustCompile(`^[0-9]+(us|ms|s|sec|m|min|h|hr|d|day|w|week|month|y|year)?$`)
+)
+
+func validateJournaldCustomization(jc *JournaldCustomization) error {
+	if *jc == (JournaldCustomization{}) {
+		return fmt.Errorf("journald customization requires at least one option")
+	}
+
+	if jc.Storage != "" && !slices.Contains(journaldStorageValues, jc.Storage) {
+		return fmt.Errorf("journald storage %q is invalid: must be one of %v", jc.Storage, journaldStorageValues)
+	}
+
+	sizes := []struct {
+		name  string
+		value string
+	}{
+		{"system_max_use", jc.SystemMaxUse},
+		{"system_max_file_size", jc.SystemMaxFileSize},
+		{"runtime_max_use", jc.RuntimeMaxUse},
+		{"runtime_max_file_size", jc.RuntimeMaxFileSize},
+	}
+	for _, size := range sizes {
+		if size.value != "" && !journaldSizeRegex.MatchString(size.value) {
+			return fmt.Errorf("journald %s %q is not a valid size", size.name, size.value)
+		}
+	}
+
+	if jc.MaxRetentionSec != "" && !journaldTimespanRegex.MatchString(jc.MaxRetentionSec) {
+		return fmt.Errorf("journald max_retention_sec %q is not a valid time span", jc.MaxRetentionSec)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+func TestGetJournald(t *testing.T) {
+	expected := &JournaldCustomization{
+		Storage:         "persistent",
+		SystemMaxUse:    "2G",
+		RuntimeMaxUse:   "100M",
+		MaxRetentionSec: "30day",
+		ForwardToSyslog: common.ToPtr(true),
+	}
+	c := &Customizations{Journald: expected}
+	jc, err := c.GetJournald()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, jc)
+}
+
+func TestGetJournaldErrors(t *testing.T) {
+	tests := map[string]struct {
+		jc     JournaldCustomization
+		expErr string
+	}{
+		"empty": {
+			jc:     JournaldCustomization{},
+			expErr: "journald customization requires at least one option",
+		},
+		"bad-storage": {
+			jc:     JournaldCustomization{Storage: "disk"},
+			expErr: `journald storage "disk" is invalid: must be one of [volatile persistent auto none]`,
+		},
+		"bad-size": {
+			jc:     JournaldCustomization{SystemMaxFileSize: "10 MB"},
+			expErr: `journald system_max_file_size "10 MB" is not a valid size`,
+		},
+		"bad-timespan": {
+			jc:     JournaldCustomization{MaxRetentionSec: "forever"},
+			expErr: `journald max_retention_sec "forever" is not a valid time span`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Customizations{Journald: &tc.jc}
+			_, err := c.GetJournald()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package blueprint
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type SELinuxCustomization struct {
+	// Persistent SELinux booleans to set in the image policy
+	Booleans []SELinuxBooleanCustomization `json:"booleans,omitempty" toml:"booleans,omitempty"`
+	// Network port type definitions
+	Ports []SELinuxPortCustomization `json:"ports,omitempty" toml:"ports,omitempty"`
+	// File context definitions, applied when the image is labeled
+	FileContexts []SELinuxFileContextCustomization `json:"file_contexts,omitempty" toml:"file_contexts,omitempty"`
+	// Paths in the image of custom policy modules (.pp or .cil) to install.
+	// The modules must be added to the image with file customizations.
+	Modules []string `json:"modules,omitempty" toml:"modules,omitempty"`
+}
+
+type SELinuxBooleanCustomization struct {
+	Name  string `json:"name" toml:"name"`
+	Value bool   `json:"value" toml:"value"`
+}
+
+type SELinuxPortCustomization struct {
+	Type     string `json:"type" toml:"type"`
+	Protocol string `json:"protocol" toml:"protocol"`
+	// A single port or a range of ports in the form "low-high"
+	Port string `json:"port" toml:"port"`
+}
+
+type SELinuxFileContextCustomization struct {
+	// Regular expression matching the paths to label
+	Target string `json:"target" toml:"target"`
+	Type   string `json:"type" toml:"type"`
+}
+
+var (
+	selinuxIdentifierRegex  = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+	selinuxModuleExtensions = []string{".pp", ".cil"}
+	selinuxPortProtocols    = []string{"tcp", "udp", "sctp", "dccp"}
+)
+
+func validateSELinuxPort(port string) error {
+	parts := strings.SplitN(port, "-", 2)
+	var bounds []int
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("SELinux port %q is invalid: must be a port number or range between 1 and 65535", port)
+		}
+		bounds = append(bounds, n)
+	}
+	if len(bounds) == 2 && bounds[0] > bounds[1] {
+		return fmt.Errorf("SELinux port range %q is invalid: lower bound is greater than upper bound", port)
+	}
+	return nil
+}
+
+// validateSELinuxCustomization checks the SELinux customization for invalid
+// values. Custom policy modules must be provided by one of the given file
+// customizations.
+func validateSELinuxCustomization(sc *SELinuxCustomization, files []FileCustomization) error {
+	for _, b := range sc.Booleans {
+		if !selinuxIdentifierRegex.MatchString(b.Name) {
+			return fmt.Errorf("SELinux boolean name %q is invalid", b.Name)
+		}
+	}
+
+	for _, p := range sc.Ports {
+		if !selinuxIdentifierRegex.MatchString(p.Type) {
+			return fmt.Errorf("SELinux port type %q is invalid", p.Type)
+		}
+		if !slices.Contains(selinuxPortProtocols, p.Protocol) {
+			return fmt.Errorf("SELinux port protocol %q is invalid: must be one of %v", p.Protocol, selinuxPortProtocols)
+		}
+		if err := validateSELinuxPort(p.Port); err != nil {
+			return err
+		}
+	}
+
+	for _, fc := range sc.FileContexts {
+		if !strings.HasPrefix(fc.Target, "/") {
+			return fmt.Errorf("SELinux file context target %q must be an absolute path expression", fc.Target)
+		}
+		if _, err := regexp.Compile(fc.Target); err != nil {
+			return fmt.Errorf("SELinux file context target %q is not a valid regular expression: %v", fc.Target, err)
+		}
+		if !selinuxIdentifierRegex.MatchString(fc.Type) {
+			return fmt.Errorf("SELinux file context type %q is invalid", fc.Type)
+		}
+	}
+
+	for _, module := range sc.Modules {
+		if !filepath.IsAbs(module) || filepath.Clean(module) != module {
+			return fmt.Errorf("SELinux module path %q must be absolute and canonical", module)
+		}
+		if !slices.Contains(selinuxModuleExtensions, filepath.Ext(module)) {
+			return fmt.Errorf("SELinux module %q must have one of the extensions %v", module, selinuxModuleExtensions)
+		}
+		found := false
+		for _, f := range files {
+			if f.Path == module {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("SELinux module %q is not provided by any file customization", module)
+		}
+	}
+
+	return nil
+}
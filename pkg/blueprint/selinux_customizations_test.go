@@ -0,0 +1,99 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSELinux(t *testing.T) {
+	expected := &SELinuxCustomization{
+		Booleans: []SELinuxBooleanCustomization{
+			{Name: "httpd_can_network_connect", Value: true},
+		},
+		Ports: []SELinuxPortCustomization{
+			{Type: "http_port_t", Protocol: "tcp", Port: "8443"},
+			{Type: "http_port_t", Protocol: "udp", Port: "9000-9010"},
+		},
+		FileContexts: []SELinuxFileContextCustomization{
+			{Target: "/srv/www(/.*)?", Type: "httpd_sys_content_t"},
+		},
+		Modules: []string{"/etc/selinux/modules/myapp.pp"},
+	}
+
+	c := &Customizations{
+		SELinux: expected,
+		Files: []FileCustomization{
+			{Path: "/etc/selinux/modules/myapp.pp", Data: "module"},
+		},
+	}
+	sc, err := c.GetSELinux()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, sc)
+
+	var nilc *Customizations
+	sc, err = nilc.GetSELinux()
+	assert.NoError(t, err)
+	assert.Nil(t, sc)
+}
+
+func TestGetSELinuxErrors(t *testing.T) {
+	files := []FileCustomization{
+		{Path: "/etc/selinux/modules/myapp.pp", Data: "module"},
+		{Path: "/etc/selinux/modules/myapp.te", Data: "module"},
+	}
+
+	tests := map[string]struct {
+		sc     SELinuxCustomization
+		expErr string
+	}{
+		"bad-boolean": {
+			sc:     SELinuxCustomization{Booleans: []SELinuxBooleanCustomization{{Name: "httpd can"}}},
+			expErr: `SELinux boolean name "httpd can" is invalid`,
+		},
+		"bad-port-type": {
+			sc:     SELinuxCustomization{Ports: []SELinuxPortCustomization{{Type: "", Protocol: "tcp", Port: "80"}}},
+			expErr: `SELinux port type "" is invalid`,
+		},
+		"bad-protocol": {
+			sc:     SELinuxCustomization{Ports: []SELinuxPortCustomization{{Type: "http_port_t", Protocol: "icmp", Port: "80"}}},
+			expErr: `SELinux port protocol "icmp" is invalid: must be one of [tcp udp sctp dccp]`,
+		},
+		"bad-port": {
+			sc:     SELinuxCustomization{Ports: []SELinuxPortCustomization{{Type: "http_port_t", Protocol: "tcp", Port: "70000"}}},
+			expErr: `SELinux port "70000" is invalid: must be a port number or range between 1 and 65535`,
+		},
+		"bad-port-range": {
+			sc:     SELinuxCustomization{Ports: []SELinuxPortCustomization{{Type: "http_port_t", Protocol: "tcp", Port: "90-80"}}},
+			expErr: `SELinux port range "90-80" is invalid: lower bound is greater than upper bound`,
+		},
+		"relative-fcontext": {
+			sc:     SELinuxCustomization{FileContexts: []SELinuxFileContextCustomization{{Target: "srv", Type: "httpd_sys_content_t"}}},
+			expErr: `SELinux file context target "srv" must be an absolute path expression`,
+		},
+		"bad-fcontext-regex": {
+			sc:     SELinuxCustomization{FileContexts: []SELinuxFileContextCustomization{{Target: "/srv(", Type: "httpd_sys_content_t"}}},
+			expErr: "SELinux file context target \"/srv(\" is not a valid regular expression: error parsing regexp: missing closing ): `/srv(`",
+		},
+		"bad-module-ext": {
+			sc:     SELinuxCustomization{Modules: []string{"/etc/selinux/modules/myapp.te"}},
+			expErr: `SELinux module "/etc/selinux/modules/myapp.te" must have one of the extensions [.pp .cil]`,
+		},
+		"module-not-provided": {
+			sc:     SELinuxCustomization{Modules: []string{"/etc/selinux/modules/other.cil"}},
+			expErr: `SELinux module "/etc/selinux/modules/other.cil" is not provided by any file customization`,
+		},
+		"module-relative": {
+			sc:     SELinuxCustomization{Modules: []string{"myapp.pp"}},
+			expErr: `SELinux module path "myapp.pp" must be absolute and canonical`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Customizations{SELinux: &tc.sc, Files: files}
+			_, err := c.GetSELinux()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
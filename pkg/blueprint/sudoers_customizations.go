@@ -0,0 +1,107 @@
+package blueprint
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SudoersCustomization is a drop-in file in /etc/sudoers.d
+type SudoersCustomization struct {
+	// Name of the drop-in file. sudo ignores files with a '.' in their name,
+	// so only letters, digits, '-' and '_' are allowed.
+	Filename string                     `json:"filename" toml:"filename"`
+	Rules    []SudoersRuleCustomization `json:"rules" toml:"rules"`
+}
+
+// SudoersRuleCustomization allows a user or the members of a group to run
+// commands as another user.
+type SudoersRuleCustomization struct {
+	// Exactly one of User and Group must be set
+	User  string `json:"user,omitempty" toml:"user,omitempty"`
+	Group string `json:"group,omitempty" toml:"group,omitempty"`
+	// Users (and optionally group, separated by ':') the commands may be run
+	// as. Defaults to ALL.
+	RunAs string `json:"runas,omitempty" toml:"runas,omitempty"`
+	// Commands with absolute paths and optional arguments. Defaults to ALL.
+	Commands []string `json:"commands,omitempty" toml:"commands,omitempty"`
+	NoPasswd bool     `json:"nopasswd,omitempty" toml:"nopasswd,omitempty"`
+}
+
+var (
+	sudoersFilenameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,250}$`)
+	sudoersRunAsRegex    = regexp.MustCompile(`^(ALL|[a-z_][a-z0-9_-]*)(:(ALL|[a-z_][a-z0-9_-]*))?$`)
+
+	// users and groups that exist in every image, created by the setup
+	// package
+	sudoersBaseUsers  = []string{"root"}
+	sudoersBaseGroups = []string{"root", "wheel", "adm"}
+)
+
+func validateSudoersCustomizations(sudoers []SudoersCustomization, users []UserCustomization, groups []GroupCustomization) error {
+	knownUsers := slices.Clone(sudoersBaseUsers)
+	knownGroups := slices.Clone(sudoersBaseGroups)
+	for _, user := range users {
+		knownUsers = append(knownUsers, user.Name)
+		// supplementary groups of blueprint users must exist in the image
+		knownGroups = append(knownGroups, user.Groups...)
+	}
+	for _, group := range groups {
+		knownGroups = append(knownGroups, group.Name)
+	}
+
+	filenames := make(map[string]bool)
+	for _, dropin := range sudoers {
+		if !sudoersFilenameRegex.MatchString(dropin.Filename) {
+			return fmt.Errorf("sudoers filename %q is invalid: only letters, digits, '-' and '_' are allowed", dropin.Filename)
+		}
+		if filenames[dropin.Filename] {
+			return fmt.Errorf("sudoers filename %q is used more than once", dropin.Filename)
+		}
+		filenames[dropin.Filename] = true
+
+		if len(dropin.Rules) == 0 {
+			return fmt.Errorf("sudoers file %q contains no rules", dropin.Filename)
+		}
+
+		for _, rule := range dropin.Rules {
+			switch {
+			case rule.User != "" && rule.Group != "":
+				return fmt.Errorf("sudoers rule in %q must specify either a user or a group, not both", dropin.Filename)
+			case rule.User != "":
+				if !slices.Contains(knownUsers, rule.User) {
+					return fmt.Errorf("sudoers rule in %q references user %q which is not defined in the blueprint", dropin.Filename, rule.User)
+				}
+			case rule.Group != "":
+				if !slices.Contains(knownGroups, rule.Group) {
+					return fmt.Errorf("sudoers rule in %q references group %q which is not defined in the blueprint", dropin.Filename, rule.Group)
+				}
+			default:
+				return fmt.Errorf("sudoers rule in %q must specify a user or a group", dropin.Filename)
+			}
+
+			if rule.RunAs != "" && !sudoersRunAsRegex.MatchString(rule.RunAs) {
+				return fmt.Errorf("sudoers runas %q in %q is invalid", rule.RunAs, dropin.Filename)
+			}
+
+			for _, cmd := range rule.Commands {
+				if cmd == "ALL" {
+					continue
+				}
+				// these characters separate commands, start comments or
+				// have a meaning in the command specification of sudoers(5)
+				if strings.ContainsAny(cmd, "#,:=\n\\") {
+					return fmt.Errorf("sudoers command %q in %q must not contain '#', ',', ':', '=', '\\' or newlines", cmd, dropin.Filename)
+				}
+				fields := strings.Fields(cmd)
+				if len(fields) == 0 || !filepath.IsAbs(fields[0]) {
+					return fmt.Errorf("sudoers command %q in %q must be ALL or start with an absolute path", cmd, dropin.Filename)
+				}
+			}
+		}
+	}
+
+	return nil
+}
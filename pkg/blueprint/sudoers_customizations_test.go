@@ -0,0 +1,108 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSudoers(t *testing.T) {
+	expected := []SudoersCustomization{
+		{
+			Filename: "admins",
+			Rules: []SudoersRuleCustomization{
+				{Group: "wheel", NoPasswd: true},
+				{Group: "ops"},
+				{User: "alice", RunAs: "postgres:postgres", Commands: []string{"/usr/bin/psql", "/usr/bin/systemctl restart postgresql"}},
+				{User: "root", Commands: []string{"ALL"}},
+			},
+		},
+	}
+
+	c := &Customizations{
+		Sudoers: expected,
+		User:    []UserCustomization{{Name: "alice", Groups: []string{"ops"}}},
+	}
+	sudoers, err := c.GetSudoers()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, sudoers)
+
+	var nilc *Customizations
+	sudoers, err = nilc.GetSudoers()
+	assert.NoError(t, err)
+	assert.Nil(t, sudoers)
+}
+
+func TestGetSudoersErrors(t *testing.T) {
+	tests := map[string]struct {
+		sudoers []SudoersCustomization
+		expErr  string
+	}{
+		"bad-filename": {
+			sudoers: []SudoersCustomization{{Filename: "admins.conf", Rules: []SudoersRuleCustomization{{Group: "wheel"}}}},
+			expErr:  `sudoers filename "admins.conf" is invalid: only letters, digits, '-' and '_' are allowed`,
+		},
+		"duplicate-filename": {
+			sudoers: []SudoersCustomization{
+				{Filename: "admins", Rules: []SudoersRuleCustomization{{Group: "wheel"}}},
+				{Filename: "admins", Rules: []SudoersRuleCustomization{{Group: "adm"}}},
+			},
+			expErr: `sudoers filename "admins" is used more than once`,
+		},
+		"no-rules": {
+			sudoers: []SudoersCustomization{{Filename: "admins"}},
+			expErr:  `sudoers file "admins" contains no rules`,
+		},
+		"user-and-group": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Group: "wheel"}}}},
+			expErr:  `sudoers rule in "admins" must specify either a user or a group, not both`,
+		},
+		"no-user-or-group": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{NoPasswd: true}}}},
+			expErr:  `sudoers rule in "admins" must specify a user or a group`,
+		},
+		"unknown-user": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "bob"}}}},
+			expErr:  `sudoers rule in "admins" references user "bob" which is not defined in the blueprint`,
+		},
+		"unknown-group": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{Group: "devs"}}}},
+			expErr:  `sudoers rule in "admins" references group "devs" which is not defined in the blueprint`,
+		},
+		"bad-runas": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", RunAs: "root, alice"}}}},
+			expErr:  `sudoers runas "root, alice" in "admins" is invalid`,
+		},
+		"relative-command": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Commands: []string{"systemctl"}}}}},
+			expErr:  `sudoers command "systemctl" in "admins" must be ALL or start with an absolute path`,
+		},
+		"command-with-comma": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Commands: []string{"/usr/bin/ls, /usr/bin/cat"}}}}},
+			expErr:  `sudoers command "/usr/bin/ls, /usr/bin/cat" in "admins" must not contain '#', ',', ':', '=', '\' or newlines`,
+		},
+		"command-with-comment": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Commands: []string{"/usr/bin/ls # list"}}}}},
+			expErr:  `sudoers command "/usr/bin/ls # list" in "admins" must not contain '#', ',', ':', '=', '\' or newlines`,
+		},
+		"command-with-colon": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Commands: []string{"/usr/bin/ls:/usr/bin/cat"}}}}},
+			expErr:  `sudoers command "/usr/bin/ls:/usr/bin/cat" in "admins" must not contain '#', ',', ':', '=', '\' or newlines`,
+		},
+		"command-with-equals": {
+			sudoers: []SudoersCustomization{{Filename: "admins", Rules: []SudoersRuleCustomization{{User: "alice", Commands: []string{"/usr/bin/env FOO=bar"}}}}},
+			expErr:  `sudoers command "/usr/bin/env FOO=bar" in "admins" must not contain '#', ',', ':', '=', '\' or newlines`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Customizations{
+				Sudoers: tc.sudoers,
+				User:    []UserCustomization{{Name: "alice"}},
+			}
+			_, err := c.GetSudoers()
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
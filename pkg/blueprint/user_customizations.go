@@ -0,0 +1,213 @@
+package blueprint
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AuthorizedKeyCustomization is a single line in the authorized_keys file of a user
+type AuthorizedKeyCustomization struct {
+	// Public key in the OpenSSH format, e.g. "ssh-ed25519 AAAA... comment"
+	Key string `json:"key" toml:"key"`
+	// authorized_keys options for the key, e.g. `from="10.0.0.0/8"` or
+	// "no-port-forwarding"
+	Options []string `json:"options,omitempty" toml:"options,omitempty"`
+}
+
+// PasswordAgingCustomization sets the password aging policy of a user, with
+// the same semantics as the corresponding chage(1) options.
+type PasswordAgingCustomization struct {
+	// Minimum number of days between password changes (chage --mindays)
+	MinDays *int `json:"min_days,omitempty" toml:"min_days,omitempty"`
+	// Maximum number of days a password is valid (chage --maxdays)
+	MaxDays *int `json:"max_days,omitempty" toml:"max_days,omitempty"`
+	// Number of days of warning before a password expires (chage --warndays)
+	WarnDays *int `json:"warn_days,omitempty" toml:"warn_days,omitempty"`
+	// Number of days after password expiry until the account is locked
+	// (chage --inactive)
+	InactiveDays *int `json:"inactive_days,omitempty" toml:"inactive_days,omitempty"`
+}
+
+// SubIDRangeCustomization is a range of subordinate user or group IDs, as
+// used by rootless containers.
+type SubIDRangeCustomization struct {
+	// First ID of the range. If unset, a free range starting at
+	// SubIDMin or above is allocated.
+	Start *int `json:"start,omitempty" toml:"start,omitempty"`
+	// Number of IDs in the range. Defaults to SubIDDefaultCount.
+	Count int `json:"count,omitempty" toml:"count,omitempty"`
+}
+
+const (
+	// SubIDMin is the lowest subordinate ID that is allocated automatically,
+	// matching the SUB_UID_MIN and SUB_GID_MIN defaults of shadow-utils.
+	SubIDMin = 100000
+	// SubIDDefaultCount is the number of subordinate IDs allocated when no
+	// count is given, matching SUB_UID_COUNT and SUB_GID_COUNT.
+	SubIDDefaultCount = 65536
+	// SubIDMax is the highest valid subordinate ID. (uid_t)-1 is reserved.
+	SubIDMax = 4294967294
+)
+
+// GetCount returns the number of IDs in the range, applying the default.
+func (r *SubIDRangeCustomization) GetCount() int {
+	if r.Count == 0 {
+		return SubIDDefaultCount
+	}
+	return r.Count
+}
+
+var (
+	sshKeyTypeRegex   = regexp.MustCompile(`^(ssh-(rsa|dss|ed25519)|ecdsa-sha2-nistp(256|384|521)|sk-(ssh-ed25519|ecdsa-sha2-nistp256)@openssh\.com)$`)
+	sshKeyOptionRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9-]*(="([^"\\\n]|\\.)*")?$`)
+)
+
+func validateSSHKey(user string, key AuthorizedKeyCustomization) error {
+	if strings.ContainsAny(key.Key, "\r\n") {
+		return fmt.Errorf("SSH key of user %q must be a single line", user)
+	}
+	fields := strings.Fields(key.Key)
+	if len(fields) < 2 || !sshKeyTypeRegex.MatchString(fields[0]) {
+		return fmt.Errorf("SSH key of user %q is invalid: must be in the OpenSSH format \"<type> <base64 key> [comment]\"", user)
+	}
+	if _, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+		return fmt.Errorf("SSH key of user %q is invalid: key data is not base64 encoded", user)
+	}
+	for _, opt := range key.Options {
+		if !sshKeyOptionRegex.MatchString(opt) {
+			return fmt.Errorf("SSH key option %q of user %q is invalid", opt, user)
+		}
+	}
+	return nil
+}
+
+func validatePasswordAging(user string, aging *PasswordAgingCustomization) error {
+	values := []struct {
+		name  string
+		value *int
+	}{
+		{"min_days", aging.MinDays},
+		{"max_days", aging.MaxDays},
+		{"warn_days", aging.WarnDays},
+		{"inactive_days", aging.InactiveDays},
+	}
+	for _, v := range values {
+		if v.value != nil && *v.value < 0 {
+			return fmt.Errorf("password aging %s of user %q must not be negative", v.name, user)
+		}
+	}
+	if aging.MinDays != nil && aging.MaxDays != nil && *aging.MinDays > *aging.MaxDays {
+		return fmt.Errorf("password aging min_days of user %q must not be greater than max_days", user)
+	}
+	return nil
+}
+
+type subIDRange struct {
+	user  string
+	start int
+	count int
+}
+
+func (r subIDRange) overlaps(other subIDRange) bool {
+	return r.start < other.start+other.count && other.start < r.start+r.count
+}
+
+// validateSubIDRanges checks a single kind ("subuid" or "subgid") of
+// subordinate ID ranges. Explicit ranges must not overlap each other.
+func validateSubIDRanges(kind string, users []UserCustomization, get func(UserCustomization) *SubIDRangeCustomization) error {
+	var ranges []subIDRange
+	for _, user := range users {
+		r := get(user)
+		if r == nil {
+			continue
+		}
+		if r.Count < 0 {
+			return fmt.Errorf("%s count of user %q must not be negative", kind, user.Name)
+		}
+		if r.Start == nil {
+			continue
+		}
+		cur := subIDRange{user: user.Name, start: *r.Start, count: r.GetCount()}
+		if cur.start < 1 || cur.start+cur.count-1 > SubIDMax {
+			return fmt.Errorf("%s range of user %q must be between 1 and %d", kind, user.Name, SubIDMax)
+		}
+		for _, other := range ranges {
+			if cur.overlaps(other) {
+				return fmt.Errorf("%s range of user %q overlaps with the range of user %q", kind, cur.user, other.user)
+			}
+		}
+		ranges = append(ranges, cur)
+	}
+	_, err := AllocateSubIDRanges(kind, users, get)
+	return err
+}
+
+// AllocateSubIDRanges resolves a single kind ("subuid" or "subgid") of
+// subordinate ID ranges of the users. Ranges with an explicit start are used
+// as is, the others get the lowest free range at or above SubIDMin, in the
+// order of the users. The returned slice has an entry with the start and
+// count set for every user, nil for users without a range. It is an error if
+// there is no free range up to SubIDMax left for a user.
+func AllocateSubIDRanges(kind string, users []UserCustomization, get func(UserCustomization) *SubIDRangeCustomization) ([]*SubIDRangeCustomization, error) {
+	ranges := make([]*SubIDRangeCustomization, len(users))
+
+	var used []subIDRange
+	for idx, user := range users {
+		if r := get(user); r != nil && r.Start != nil {
+			ranges[idx] = &SubIDRangeCustomization{Start: r.Start, Count: r.GetCount()}
+			used = append(used, subIDRange{user: user.Name, start: *r.Start, count: r.GetCount()})
+		}
+	}
+
+	for idx, user := range users {
+		r := get(user)
+		if r == nil || r.Start != nil {
+			continue
+		}
+		sort.Slice(used, func(i, j int) bool { return used[i].start < used[j].start })
+		start := SubIDMin
+		for _, u := range used {
+			if start+r.GetCount() <= u.start {
+				break
+			}
+			if u.start+u.count > start {
+				start = u.start + u.count
+			}
+		}
+		if start+r.GetCount()-1 > SubIDMax {
+			return nil, fmt.Errorf("no free %s range of %d IDs left for user %q", kind, r.GetCount(), user.Name)
+		}
+		ranges[idx] = &SubIDRangeCustomization{Start: &start, Count: r.GetCount()}
+		used = append(used, subIDRange{user: user.Name, start: start, count: r.GetCount()})
+	}
+
+	return ranges, nil
+}
+
+func validateUserCustomizations(users []UserCustomization) error {
+	for _, user := range users {
+		if user.Key != nil {
+			if err := validateSSHKey(user.Name, AuthorizedKeyCustomization{Key: *user.Key}); err != nil {
+				return err
+			}
+		}
+		for _, key := range user.Keys {
+			if err := validateSSHKey(user.Name, key); err != nil {
+				return err
+			}
+		}
+		if user.PasswordAging != nil {
+			if err := validatePasswordAging(user.Name, user.PasswordAging); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := validateSubIDRanges("subuid", users, func(u UserCustomization) *SubIDRangeCustomization { return u.SubUID }); err != nil {
+		return err
+	}
+	return validateSubIDRanges("subgid", users, func(u UserCustomization) *SubIDRangeCustomization { return u.SubGID })
+}
@@ -0,0 +1,135 @@
+package blueprint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+)
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAABAgMEBQYHCAkKCwwNDg8QERITFBUWFxgZGhscHR4f user@host"
+
+func TestCheckUsers(t *testing.T) {
+	c := &Customizations{
+		SSHKey: []SSHKeyCustomization{{User: "root", Key: testSSHKey}},
+		User: []UserCustomization{
+			{
+				Name: "builder",
+				Keys: []AuthorizedKeyCustomization{
+					{Key: testSSHKey},
+					{Key: testSSHKey, Options: []string{`from="10.0.0.0/8"`, "no-pty", `command="/usr/bin/backup --dry-run"`}},
+				},
+				PasswordAging: &PasswordAgingCustomization{
+					MinDays:      common.ToPtr(1),
+					MaxDays:      common.ToPtr(90),
+					WarnDays:     common.ToPtr(7),
+					InactiveDays: common.ToPtr(30),
+				},
+				Locked: common.ToPtr(true),
+				SubUID: &SubIDRangeCustomization{Start: common.ToPtr(100000), Count: 65536},
+				SubGID: &SubIDRangeCustomization{},
+			},
+			{
+				Name:   "ci",
+				SubUID: &SubIDRangeCustomization{Start: common.ToPtr(165536)},
+				SubGID: &SubIDRangeCustomization{Count: 1000},
+			},
+		},
+	}
+	assert.NoError(t, c.CheckUsers())
+
+	var nilc *Customizations
+	assert.NoError(t, nilc.CheckUsers())
+}
+
+func TestCheckUsersErrors(t *testing.T) {
+	tests := map[string]struct {
+		users  []UserCustomization
+		expErr string
+	}{
+		"multiline-key": {
+			users:  []UserCustomization{{Name: "alice", Keys: []AuthorizedKeyCustomization{{Key: testSSHKey + "\n" + testSSHKey}}}},
+			expErr: `SSH key of user "alice" must be a single line`,
+		},
+		"bad-key-type": {
+			users:  []UserCustomization{{Name: "alice", Keys: []AuthorizedKeyCustomization{{Key: "ssh-foo AAAA"}}}},
+			expErr: `SSH key of user "alice" is invalid: must be in the OpenSSH format "<type> <base64 key> [comment]"`,
+		},
+		"key-without-data": {
+			users:  []UserCustomization{{Name: "alice", Keys: []AuthorizedKeyCustomization{{Key: "ssh-rsa"}}}},
+			expErr: `SSH key of user "alice" is invalid: must be in the OpenSSH format "<type> <base64 key> [comment]"`,
+		},
+		"bad-key-data": {
+			users:  []UserCustomization{{Name: "alice", Keys: []AuthorizedKeyCustomization{{Key: "ssh-rsa not-base64!"}}}},
+			expErr: `SSH key of user "alice" is invalid: key data is not base64 encoded`,
+		},
+		"bad-key-option": {
+			users:  []UserCustomization{{Name: "alice", Keys: []AuthorizedKeyCustomization{{Key: testSSHKey, Options: []string{`from="10.0.0.0/8" ssh-rsa`}}}}},
+			expErr: `SSH key option "from=\"10.0.0.0/8\" ssh-rsa" of user "alice" is invalid`,
+		},
+		"negative-aging": {
+			users:  []UserCustomization{{Name: "alice", PasswordAging: &PasswordAgingCustomization{WarnDays: common.ToPtr(-1)}}},
+			expErr: `password aging warn_days of user "alice" must not be negative`,
+		},
+		"min-greater-than-max": {
+			users:  []UserCustomization{{Name: "alice", PasswordAging: &PasswordAgingCustomization{MinDays: common.ToPtr(10), MaxDays: common.ToPtr(5)}}},
+			expErr: `password aging min_days of user "alice" must not be greater than max_days`,
+		},
+		"negative-subid-count": {
+			users:  []UserCustomization{{Name: "alice", SubGID: &SubIDRangeCustomization{Count: -1}}},
+			expErr: `subgid count of user "alice" must not be negative`,
+		},
+		"subid-out-of-range": {
+			users:  []UserCustomization{{Name: "alice", SubUID: &SubIDRangeCustomization{Start: common.ToPtr(4294967000)}}},
+			expErr: `subuid range of user "alice" must be between 1 and 4294967294`,
+		},
+		"subid-overlap": {
+			users: []UserCustomization{
+				{Name: "alice", SubUID: &SubIDRangeCustomization{Start: common.ToPtr(100000)}},
+				{Name: "bob", SubUID: &SubIDRangeCustomization{Start: common.ToPtr(165535), Count: 10}},
+			},
+			expErr: `subuid range of user "bob" overlaps with the range of user "alice"`,
+		},
+		"subid-exhausted": {
+			// alice's range ends right below the maximum, leaving no room for bob
+			users: []UserCustomization{
+				{Name: "alice", SubGID: &SubIDRangeCustomization{Start: common.ToPtr(SubIDMin), Count: SubIDMax - SubIDMin}},
+				{Name: "bob", SubGID: &SubIDRangeCustomization{Count: 2}},
+			},
+			expErr: `no free subgid range of 2 IDs left for user "bob"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := &Customizations{User: tc.users}
+			assert.EqualError(t, c.CheckUsers(), tc.expErr)
+		})
+	}
+}
+
+func TestCheckUsersLegacyKey(t *testing.T) {
+	c := &Customizations{User: []UserCustomization{{Name: "alice", Key: common.ToPtr("not a key")}}}
+	assert.EqualError(t, c.CheckUsers(), `SSH key of user "alice" is invalid: must be in the OpenSSH format "<type> <base64 key> [comment]"`)
+
+	c = &Customizations{SSHKey: []SSHKeyCustomization{{User: "root", Key: "ssh-rsa not-base64!"}}}
+	assert.EqualError(t, c.CheckUsers(), `SSH key of user "root" is invalid: key data is not base64 encoded`)
+}
+
+func TestAllocateSubIDRanges(t *testing.T) {
+	users := []UserCustomization{
+		{Name: "alice", SubUID: &SubIDRangeCustomization{}},
+		{Name: "bob", SubUID: &SubIDRangeCustomization{Start: common.ToPtr(100000), Count: 1000}},
+		{Name: "carol", SubUID: &SubIDRangeCustomization{Count: 500}},
+		{Name: "dave"},
+	}
+	ranges, err := AllocateSubIDRanges("subuid", users, func(u UserCustomization) *SubIDRangeCustomization { return u.SubUID })
+	assert.NoError(t, err)
+	assert.Equal(t, []*SubIDRangeCustomization{
+		{Start: common.ToPtr(101000), Count: 65536},
+		{Start: common.ToPtr(100000), Count: 1000},
+		{Start: common.ToPtr(166536), Count: 500},
+		nil,
+	}, ranges)
+}
@@ -0,0 +1,536 @@
+// Package bundle exports the sources of a resolved manifest into a
+// self-contained directory, which can be carried into an air-gapped
+// environment to build the manifest without network access.
+//
+// A bundle directory contains:
+//   - manifest.json: the manifest with its sources rewritten to the content
+//     of the bundle,
+//   - sources/org.osbuild.curl/: the files of the curl sources, e.g. RPMs and
+//     remote files, and the container manifest lists, named by checksum,
+//     downloaded with the secrets of the sources,
+//   - containers/storage/: a containers storage with the container images,
+//   - ostree/repo/: an ostree repository with the commits.
+//
+// Inline data is part of the manifest itself and needs no export.
+package bundle
+
+import (
+	"crypto/md5"  // #nosec G501
+	"crypto/sha1" // #nosec G505
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/rhsm"
+)
+
+const (
+	ManifestFile = "manifest.json"
+
+	curlDir              = "sources/org.osbuild.curl"
+	containersStorageDir = "containers/storage"
+	ostreeRepoDir        = "ostree/repo"
+)
+
+// runCommand runs an external command and returns its standard output. It is
+// replaced in tests.
+var runCommand = func(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w\n%s", name, err, stderr.String())
+	}
+	return output, nil
+}
+
+// secretsProvider finds the RHSM secrets of a URL, see
+// rhsm.Subscriptions.GetSecretsForURL().
+type secretsProvider interface {
+	GetSecretsForURL(url string) (*rhsm.RHSMSecrets, error)
+}
+
+// loadSubscriptions loads the subscriptions of the host. It is replaced in
+// tests.
+var loadSubscriptions = func() (secretsProvider, error) {
+	subscriptions, err := rhsm.LoadSystemSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+type Options struct {
+	// Path of the bundle directory on the host that builds the manifest,
+	// used in the rewritten sources. Defaults to the absolute path of the
+	// bundle directory.
+	Root string
+
+	// HTTP client to download the curl sources without secrets with. A
+	// default client is used if nil. Sources with secrets or that skip the
+	// TLS verification are downloaded with clients built from their
+	// configuration: the secrets of org.osbuild.rhsm sources are the ones of
+	// the subscriptions of the host, the ones of org.osbuild.mtls sources
+	// are read from the environment variables osbuild uses,
+	// OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY, OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT
+	// and, optionally, OSBUILD_SOURCES_CURL_SSL_CA_CERT.
+	Client *http.Client
+}
+
+// A resolved osbuild manifest with the pipelines kept as they are
+type osbuildManifest struct {
+	Version   string          `json:"version"`
+	Pipelines json.RawMessage `json:"pipelines"`
+	Sources   osbuild.Sources `json:"sources"`
+}
+
+type exporter struct {
+	dir     string
+	root    string
+	client  *http.Client
+	sources osbuild.Sources
+
+	// clients of the curl sources with secrets by configuration
+	clients map[string]*http.Client
+	// subscriptions of the host, loaded for the first org.osbuild.rhsm
+	// source
+	subscriptions secretsProvider
+}
+
+// Export exports the sources of the resolved manifest into the directory and
+// writes the manifest with its sources rewritten to the content of the
+// directory to ManifestFile in it. Content that already exists in the
+// directory is not exported again, so an interrupted export can be resumed.
+//
+// Container images and ostree commits are exported with the skopeo and
+// ostree tools, which must be installed. Manifests with
+// org.osbuild.containers-storage sources, i.e. containers from the local
+// storage of the host, cannot be exported.
+func Export(mf manifest.OSBuildManifest, dir string, options Options) error {
+	var m osbuildManifest
+	if err := json.Unmarshal(mf, &m); err != nil {
+		return fmt.Errorf("cannot parse manifest: %w", err)
+	}
+
+	e := &exporter{
+		dir:     dir,
+		root:    options.Root,
+		client:  options.Client,
+		sources: osbuild.Sources{},
+		clients: make(map[string]*http.Client),
+	}
+	if e.root == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		e.root = abs
+	}
+	if e.client == nil {
+		e.client = &http.Client{}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// the secrets of the curl sources are checked before anything is
+	// exported
+	curlSource, _ := m.Sources["org.osbuild.curl"].(*osbuild.CurlSource)
+	var downloads []download
+	if curlSource != nil {
+		var err error
+		downloads, err = e.curlDownloads(curlSource)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the manifest lists of the skopeo-index sources are added to the curl
+	// source, so the curl source is exported last
+	names := make([]string, 0, len(m.Sources))
+	for name := range m.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var err error
+		switch source := m.Sources[name].(type) {
+		case *osbuild.CurlSource:
+			continue
+		case *osbuild.InlineSource:
+			e.sources[name] = source
+		case *osbuild.SkopeoSource:
+			err = e.exportSkopeo(source)
+		case *osbuild.SkopeoIndexSource:
+			err = e.exportSkopeoIndex(source)
+		case *osbuild.OSTreeSource:
+			err = e.exportOSTree(source)
+		default:
+			err = fmt.Errorf("%s sources cannot be exported", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if curlSource != nil {
+		if err := e.exportCurl(curlSource, downloads); err != nil {
+			return err
+		}
+	}
+
+	m.Sources = e.sources
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644) // #nosec G306
+}
+
+// fileURL returns the file:// URL of the path relative to the bundle
+// directory on the build host.
+func (e *exporter) fileURL(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.Join(e.root, path)}).String()
+}
+
+// addCurlItem adds the file with the checksum in the curl directory of the
+// bundle to the rewritten curl source.
+func (e *exporter) addCurlItem(checksum string) {
+	source, ok := e.sources["org.osbuild.curl"].(*osbuild.CurlSource)
+	if !ok {
+		source = osbuild.NewCurlSource()
+		e.sources["org.osbuild.curl"] = source
+	}
+	source.Items[checksum] = osbuild.URL(e.fileURL(filepath.Join(curlDir, checksum)))
+}
+
+func newHash(checksum string) (hash.Hash, string, error) {
+	algorithm, digest, found := strings.Cut(checksum, ":")
+	if !found {
+		return nil, "", fmt.Errorf("invalid checksum %q", checksum)
+	}
+	switch algorithm {
+	case "md5":
+		return md5.New(), digest, nil // #nosec G401
+	case "sha1":
+		return sha1.New(), digest, nil // #nosec G401
+	case "sha256":
+		return sha256.New(), digest, nil
+	case "sha384":
+		return sha512.New384(), digest, nil
+	case "sha512":
+		return sha512.New(), digest, nil
+	}
+	return nil, "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
+
+// verifyFile returns nil if the file exists and has the checksum.
+func verifyFile(path, checksum string) error {
+	h, digest, err := newHash(checksum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		return fmt.Errorf("checksum mismatch of %s: expected %s, got %s", path, digest, actual)
+	}
+	return nil
+}
+
+// writeFile writes the content to the file with the checksum in the curl
+// directory, after verifying the checksum.
+func (e *exporter) writeFile(checksum string, content io.Reader) error {
+	dir := filepath.Join(e.dir, curlDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	h, digest, err := newHash(checksum)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	_, err = io.Copy(io.MultiWriter(tmp, h), content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", digest, actual)
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, checksum))
+}
+
+// download is a file of a curl source that is not in the bundle yet.
+type download struct {
+	checksum string
+	url      string
+	client   *http.Client
+}
+
+func (e *exporter) download(d download) error {
+	u, err := url.Parse(d.url)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "file":
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return e.writeFile(d.checksum, f)
+	case "http", "https":
+		resp, err := d.client.Get(d.url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %q from %s", resp.Status, d.url)
+		}
+		return e.writeFile(d.checksum, resp.Body)
+	default:
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+}
+
+// curlClient returns the HTTP client to download a curl source item with.
+func (e *exporter) curlClient(item *osbuild.CurlSourceOptions) (*http.Client, error) {
+	if item.Secrets == nil && !item.Insecure {
+		return e.client, nil
+	}
+
+	repo := repodata.Repository{IgnoreSSL: item.Insecure}
+	if item.Secrets != nil {
+		switch item.Secrets.Name {
+		case "org.osbuild.rhsm":
+			if e.subscriptions == nil {
+				subscriptions, err := loadSubscriptions()
+				if err != nil {
+					return nil, fmt.Errorf("cannot load the subscriptions of the host for org.osbuild.rhsm secrets: %w", err)
+				}
+				e.subscriptions = subscriptions
+			}
+			secrets, err := e.subscriptions.GetSecretsForURL(item.URL)
+			if err != nil {
+				return nil, err
+			}
+			repo.SSLCACert = secrets.SSLCACert
+			repo.SSLClientKey = secrets.SSLClientKey
+			repo.SSLClientCert = secrets.SSLClientCert
+		case "org.osbuild.mtls":
+			repo.SSLCACert = os.Getenv("OSBUILD_SOURCES_CURL_SSL_CA_CERT")
+			repo.SSLClientKey = os.Getenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY")
+			repo.SSLClientCert = os.Getenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT")
+			if repo.SSLClientKey == "" || repo.SSLClientCert == "" {
+				return nil, fmt.Errorf("org.osbuild.mtls secrets require OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY and OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT to be set")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported secrets %q", item.Secrets.Name)
+		}
+	}
+
+	key := strings.Join([]string{repo.SSLCACert, repo.SSLClientKey, repo.SSLClientCert, fmt.Sprint(repo.IgnoreSSL)}, "\x00")
+	if client, ok := e.clients[key]; ok {
+		return client, nil
+	}
+	client, err := repodata.NewHTTPClient(repo)
+	if err != nil {
+		return nil, err
+	}
+	// packages can take longer to download than the metadata the client is
+	// meant for
+	client.Timeout = 0
+	e.clients[key] = client
+	return client, nil
+}
+
+// curlDownloads returns the files of the curl source that are not in the
+// bundle yet, with the clients to download them with.
+func (e *exporter) curlDownloads(source *osbuild.CurlSource) ([]download, error) {
+	checksums := make([]string, 0, len(source.Items))
+	for checksum := range source.Items {
+		checksums = append(checksums, checksum)
+	}
+	sort.Strings(checksums)
+
+	var downloads []download
+	for _, checksum := range checksums {
+		var item *osbuild.CurlSourceOptions
+		switch i := source.Items[checksum].(type) {
+		case osbuild.URL:
+			item = &osbuild.CurlSourceOptions{URL: string(i)}
+		case osbuild.CurlSourceOptions:
+			item = &i
+		case *osbuild.CurlSourceOptions:
+			item = i
+		}
+		if verifyFile(filepath.Join(e.dir, curlDir, checksum), checksum) == nil {
+			continue
+		}
+		client, err := e.curlClient(item)
+		if err != nil {
+			return nil, fmt.Errorf("cannot export %s: %w", item.URL, err)
+		}
+		downloads = append(downloads, download{checksum: checksum, url: item.URL, client: client})
+	}
+	return downloads, nil
+}
+
+func (e *exporter) exportCurl(source *osbuild.CurlSource, downloads []download) error {
+	for _, d := range downloads {
+		if err := e.download(d); err != nil {
+			return fmt.Errorf("cannot export %s: %w", d.url, err)
+		}
+	}
+	for checksum := range source.Items {
+		e.addCurlItem(checksum)
+	}
+	return nil
+}
+
+// storageReference returns the containers-storage transport reference of the
+// image in the containers storage of the bundle.
+func (e *exporter) storageReference(image string) string {
+	storage := filepath.Join(e.dir, containersStorageDir)
+	runRoot := filepath.Join(e.dir, "containers/run")
+	return fmt.Sprintf("containers-storage:[overlay@%s+%s]%s", storage, runRoot, image)
+}
+
+func tlsVerifyArgs(flag string, tlsVerify *bool) []string {
+	if tlsVerify != nil && !*tlsVerify {
+		return []string{flag + "=false"}
+	}
+	return nil
+}
+
+func (e *exporter) exportSkopeo(source *osbuild.SkopeoSource) error {
+	rewritten := osbuild.NewSkopeoSource()
+	for imageID, item := range source.Items {
+		image := item.Image
+		if image.ContainersTransport != "" && image.ContainersTransport != osbuild.DockerTransport {
+			return fmt.Errorf("cannot export container %s from transport %q", image.Name, image.ContainersTransport)
+		}
+		ref := image.Name + "@" + image.Digest
+		args := append([]string{"copy", "--quiet"}, tlsVerifyArgs("--src-tls-verify", image.TLSVerify)...)
+		args = append(args, "docker://"+ref, e.storageReference(ref))
+		if _, err := runCommand("skopeo", args...); err != nil {
+			return fmt.Errorf("cannot export container %s: %w", ref, err)
+		}
+		rewritten.Items[imageID] = osbuild.SkopeoSourceItem{
+			Image: osbuild.SkopeopSourceImage{
+				Name:                image.Name,
+				Digest:              image.Digest,
+				ContainersTransport: osbuild.ContainersStorageTransport,
+				StorageLocation:     filepath.Join(e.root, containersStorageDir),
+			},
+		}
+	}
+	e.sources["org.osbuild.skopeo"] = rewritten
+	return nil
+}
+
+// exportSkopeoIndex exports the manifest lists of the skopeo-index source to
+// the curl directory. osbuild stores the content of both sources by
+// checksum, so the manifest lists are made available as curl items with the
+// list digests as checksums.
+func (e *exporter) exportSkopeoIndex(source *osbuild.SkopeoIndexSource) error {
+	for listDigest, item := range source.Items {
+		path := filepath.Join(e.dir, curlDir, listDigest)
+		if verifyFile(path, listDigest) != nil {
+			args := append([]string{"inspect", "--raw"}, tlsVerifyArgs("--tls-verify", item.Image.TLSVerify)...)
+			args = append(args, "docker://"+item.Image.Name+"@"+listDigest)
+			raw, err := runCommand("skopeo", args...)
+			if err != nil {
+				return fmt.Errorf("cannot export manifest list %s@%s: %w", item.Image.Name, listDigest, err)
+			}
+			if err := e.writeFile(listDigest, strings.NewReader(string(raw))); err != nil {
+				return fmt.Errorf("cannot export manifest list %s@%s: %w", item.Image.Name, listDigest, err)
+			}
+		}
+		e.addCurlItem(listDigest)
+	}
+	return nil
+}
+
+func (e *exporter) exportOSTree(source *osbuild.OSTreeSource) error {
+	repo := filepath.Join(e.dir, ostreeRepoDir)
+	if _, err := os.Stat(filepath.Join(repo, "config")); os.IsNotExist(err) {
+		if err := os.MkdirAll(repo, 0755); err != nil {
+			return err
+		}
+		if _, err := runCommand("ostree", "init", "--repo="+repo, "--mode=archive"); err != nil {
+			return err
+		}
+	}
+
+	checksums := make([]string, 0, len(source.Items))
+	for checksum := range source.Items {
+		checksums = append(checksums, checksum)
+	}
+	sort.Strings(checksums)
+
+	rewritten := osbuild.NewOSTreeSource()
+	for idx, checksum := range checksums {
+		remote := source.Items[checksum].Remote
+		if remote.Secrets != nil {
+			return fmt.Errorf("cannot export ostree commit %s: remotes with %s secrets are not supported", checksum, remote.Secrets.Name)
+		}
+		remoteName := fmt.Sprintf("bundle-%d", idx)
+		args := []string{"remote", "add", "--repo=" + repo, "--force", "--no-gpg-verify"}
+		if remote.ContentURL != "" {
+			args = append(args, "--set=contenturl="+remote.ContentURL)
+		}
+		args = append(args, remoteName, remote.URL)
+		if _, err := runCommand("ostree", args...); err != nil {
+			return fmt.Errorf("cannot export ostree commit %s: %w", checksum, err)
+		}
+		if _, err := runCommand("ostree", "pull", "--repo="+repo, remoteName, checksum); err != nil {
+			return fmt.Errorf("cannot export ostree commit %s: %w", checksum, err)
+		}
+		rewritten.Items[checksum] = osbuild.OSTreeSourceItem{
+			Remote: osbuild.OSTreeSourceRemote{
+				URL:     e.fileURL(ostreeRepoDir),
+				GPGKeys: remote.GPGKeys,
+			},
+		}
+	}
+	// the remotes are only needed for pulling
+	for idx := range checksums {
+		if _, err := runCommand("ostree", "remote", "delete", "--repo="+repo, "--if-exists", fmt.Sprintf("bundle-%d", idx)); err != nil {
+			return err
+		}
+	}
+	e.sources["org.osbuild.ostree"] = rewritten
+	return nil
+}
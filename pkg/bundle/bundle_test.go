@@ -0,0 +1,314 @@
+package bundle
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/osbuild"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rhsm"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func sha256sum(data string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(data)))
+}
+
+func makeManifest(t *testing.T, packages []rpmmd.PackageSpec, commits []ostree.CommitSpec, inline []string, containers []container.Spec, files []*fsnode.File) manifest.OSBuildManifest {
+	sources, err := osbuild.GenSources(packages, commits, inline, containers, files)
+	require.NoError(t, err)
+	data, err := json.Marshal(osbuild.Manifest{
+		Version:   "2",
+		Pipelines: []osbuild.Pipeline{{Name: "os"}},
+		Sources:   sources,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func TestExport(t *testing.T) {
+	rpm := "tmux rpm"
+	rpmPath := filepath.Join(t.TempDir(), "tmux.rpm")
+	require.NoError(t, os.WriteFile(rpmPath, []byte(rpm), 0600))
+
+	remoteFile := "bundle content"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bundle.tar" {
+			w.Write([]byte(remoteFile)) //nolint:errcheck
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	file, err := fsnode.NewRemoteFile("/etc/bundle.tar", nil, nil, nil, srv.URL+"/bundle.tar", strings.TrimPrefix(sha256sum(remoteFile), "sha256:"))
+	require.NoError(t, err)
+
+	manifestList := `{"manifests": []}`
+	listDigest := sha256sum(manifestList)
+	containerSpec := container.Spec{
+		Source:     "quay.io/centos/centos",
+		Digest:     "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		ImageID:    "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		ListDigest: listDigest,
+	}
+	commit := ostree.CommitSpec{
+		Ref:      "test/x86_64/edge",
+		URL:      "https://ostree.example.com/repo",
+		Checksum: "02604b2da6e954bd34b8b82a835e5a77d2b60ffa",
+	}
+
+	mf := makeManifest(t,
+		[]rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: "file://" + rpmPath, Checksum: sha256sum(rpm), IgnoreSSL: true}},
+		[]ostree.CommitSpec{commit},
+		[]string{"inline data"},
+		[]container.Spec{containerSpec},
+		[]*fsnode.File{file},
+	)
+
+	dir := t.TempDir()
+	var commands []string
+	origRunCommand := runCommand
+	defer func() { runCommand = origRunCommand }()
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if name == "skopeo" && args[0] == "inspect" {
+			return []byte(manifestList), nil
+		}
+		return nil, nil
+	}
+
+	require.NoError(t, Export(mf, dir, Options{Root: "/mnt/bundle"}))
+
+	storage := fmt.Sprintf("[overlay@%s/containers/storage+%s/containers/run]", dir, dir)
+	repo := filepath.Join(dir, "ostree/repo")
+	assert.Equal(t, []string{
+		"ostree init --repo=" + repo + " --mode=archive",
+		"ostree remote add --repo=" + repo + " --force --no-gpg-verify bundle-0 https://ostree.example.com/repo",
+		"ostree pull --repo=" + repo + " bundle-0 " + commit.Checksum,
+		"ostree remote delete --repo=" + repo + " --if-exists bundle-0",
+		"skopeo copy --quiet docker://quay.io/centos/centos@" + containerSpec.Digest + " containers-storage:" + storage + "quay.io/centos/centos@" + containerSpec.Digest,
+		"skopeo inspect --raw docker://quay.io/centos/centos@" + listDigest,
+	}, commands)
+
+	for checksum, content := range map[string]string{
+		sha256sum(rpm):        rpm,
+		sha256sum(remoteFile): remoteFile,
+		listDigest:            manifestList,
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, "sources/org.osbuild.curl", checksum))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	require.NoError(t, err)
+	var exported osbuild.Manifest
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "2", exported.Version)
+	assert.Equal(t, []osbuild.Pipeline{{Name: "os"}}, exported.Pipelines)
+
+	curlURL := func(checksum string) osbuild.CurlSourceItem {
+		return osbuild.URL("file:///mnt/bundle/sources/org.osbuild.curl/" + checksum)
+	}
+	assert.Equal(t, &osbuild.CurlSource{Items: map[string]osbuild.CurlSourceItem{
+		sha256sum(rpm):        curlURL(sha256sum(rpm)),
+		sha256sum(remoteFile): curlURL(sha256sum(remoteFile)),
+		listDigest:            curlURL(listDigest),
+	}}, exported.Sources["org.osbuild.curl"])
+	assert.Equal(t, &osbuild.SkopeoSource{Items: map[string]osbuild.SkopeoSourceItem{
+		containerSpec.ImageID: {
+			Image: osbuild.SkopeopSourceImage{
+				Name:                "quay.io/centos/centos",
+				Digest:              containerSpec.Digest,
+				ContainersTransport: "containers-storage",
+				StorageLocation:     "/mnt/bundle/containers/storage",
+			},
+		},
+	}}, exported.Sources["org.osbuild.skopeo"])
+	assert.Equal(t, &osbuild.OSTreeSource{Items: map[string]osbuild.OSTreeSourceItem{
+		commit.Checksum: {Remote: osbuild.OSTreeSourceRemote{URL: "file:///mnt/bundle/ostree/repo"}},
+	}}, exported.Sources["org.osbuild.ostree"])
+	assert.Len(t, exported.Sources["org.osbuild.inline"].(*osbuild.InlineSource).Items, 1)
+	assert.NotContains(t, exported.Sources, "org.osbuild.skopeo-index")
+
+	// exported files are not downloaded again
+	srv.Close()
+	require.NoError(t, os.Remove(rpmPath))
+	commands = nil
+	require.NoError(t, Export(makeManifest(t, []rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: "file://" + rpmPath, Checksum: sha256sum(rpm)}}, nil, nil, nil, []*fsnode.File{file}), dir, Options{}))
+	assert.Empty(t, commands)
+	data, err = os.ReadFile(filepath.Join(dir, ManifestFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "file://"+dir+"/sources/org.osbuild.curl/"+sha256sum(rpm))
+}
+
+func TestExportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("corrupted")) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	checksum := sha256sum("tmux rpm")
+	mf := makeManifest(t, []rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: srv.URL + "/tmux.rpm", Checksum: checksum}}, nil, nil, nil, nil)
+	dir := t.TempDir()
+	err := Export(mf, dir, Options{})
+	assert.EqualError(t, err, fmt.Sprintf("cannot export %s/tmux.rpm: checksum mismatch: expected %s, got %s",
+		srv.URL, strings.TrimPrefix(checksum, "sha256:"), strings.TrimPrefix(sha256sum("corrupted"), "sha256:")))
+	_, err = os.Stat(filepath.Join(dir, "sources/org.osbuild.curl", checksum))
+	assert.True(t, os.IsNotExist(err))
+
+	mf = makeManifest(t, nil, nil, nil, []container.Spec{{
+		ImageID:      "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		LocalStorage: true,
+	}}, nil)
+	assert.EqualError(t, Export(mf, dir, Options{}), "org.osbuild.containers-storage sources cannot be exported")
+}
+
+// writeClientCert writes a self-signed client certificate and its key to dir
+// and returns their paths and the certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certPath, keyPath, cert
+}
+
+type fakeSubscriptions struct {
+	secrets *rhsm.RHSMSecrets
+}
+
+func (s fakeSubscriptions) GetSecretsForURL(url string) (*rhsm.RHSMSecrets, error) {
+	if s.secrets == nil {
+		return nil, fmt.Errorf("no subscription for %s in the available subscriptions", url)
+	}
+	return s.secrets, nil
+}
+
+func TestExportSecrets(t *testing.T) {
+	certDir := t.TempDir()
+	clientCert, clientKey, cert := writeClientCert(t, certDir)
+
+	rpm := "tmux rpm"
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(rpm)) //nolint:errcheck
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	caCert := filepath.Join(certDir, "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+
+	origLoadSubscriptions := loadSubscriptions
+	defer func() { loadSubscriptions = origLoadSubscriptions }()
+	var commands []string
+	origRunCommand := runCommand
+	defer func() { runCommand = origRunCommand }()
+	runCommand = func(name string, args ...string) ([]byte, error) {
+		commands = append(commands, name)
+		return nil, nil
+	}
+
+	containers := []container.Spec{{
+		Source:  "quay.io/centos/centos",
+		Digest:  "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		ImageID: "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+	}}
+	rhsmManifest := makeManifest(t, []rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: srv.URL + "/tmux.rpm", Checksum: sha256sum(rpm), Secrets: "org.osbuild.rhsm"}}, nil, nil, containers, nil)
+	mtlsManifest := makeManifest(t, []rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: srv.URL + "/tmux.rpm", Checksum: sha256sum(rpm), Secrets: "org.osbuild.mtls"}}, nil, nil, containers, nil)
+
+	t.Run("rhsm", func(t *testing.T) {
+		loadSubscriptions = func() (secretsProvider, error) {
+			return fakeSubscriptions{&rhsm.RHSMSecrets{SSLCACert: caCert, SSLClientKey: clientKey, SSLClientCert: clientCert}}, nil
+		}
+		dir := t.TempDir()
+		require.NoError(t, Export(rhsmManifest, dir, Options{}))
+		data, err := os.ReadFile(filepath.Join(dir, "sources/org.osbuild.curl", sha256sum(rpm)))
+		require.NoError(t, err)
+		assert.Equal(t, rpm, string(data))
+	})
+
+	t.Run("mtls", func(t *testing.T) {
+		t.Setenv("OSBUILD_SOURCES_CURL_SSL_CA_CERT", caCert)
+		t.Setenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY", clientKey)
+		t.Setenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT", clientCert)
+		dir := t.TempDir()
+		require.NoError(t, Export(mtlsManifest, dir, Options{}))
+		data, err := os.ReadFile(filepath.Join(dir, "sources/org.osbuild.curl", sha256sum(rpm)))
+		require.NoError(t, err)
+		assert.Equal(t, rpm, string(data))
+	})
+
+	// nothing is exported if the secrets are missing
+	t.Run("missing", func(t *testing.T) {
+		commands = nil
+		loadSubscriptions = func() (secretsProvider, error) {
+			return fakeSubscriptions{}, nil
+		}
+		err := Export(rhsmManifest, t.TempDir(), Options{})
+		assert.EqualError(t, err, fmt.Sprintf("cannot export %[1]s/tmux.rpm: no subscription for %[1]s/tmux.rpm in the available subscriptions", srv.URL))
+
+		loadSubscriptions = func() (secretsProvider, error) {
+			return nil, fmt.Errorf("no matching key and certificate pair")
+		}
+		err = Export(rhsmManifest, t.TempDir(), Options{})
+		assert.EqualError(t, err, fmt.Sprintf("cannot export %s/tmux.rpm: cannot load the subscriptions of the host for org.osbuild.rhsm secrets: no matching key and certificate pair", srv.URL))
+
+		t.Setenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY", "")
+		t.Setenv("OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT", "")
+		err = Export(mtlsManifest, t.TempDir(), Options{})
+		assert.EqualError(t, err, fmt.Sprintf("cannot export %s/tmux.rpm: org.osbuild.mtls secrets require OSBUILD_SOURCES_CURL_SSL_CLIENT_KEY and OSBUILD_SOURCES_CURL_SSL_CLIENT_CERT to be set", srv.URL))
+		assert.Empty(t, commands)
+	})
+
+	// the client without secrets is not accepted by the server
+	t.Run("without-secrets", func(t *testing.T) {
+		mf := makeManifest(t, []rpmmd.PackageSpec{{Name: "tmux", RemoteLocation: srv.URL + "/tmux.rpm", Checksum: sha256sum(rpm), IgnoreSSL: true}}, nil, nil, nil, nil)
+		err := Export(mf, t.TempDir(), Options{})
+		assert.ErrorContains(t, err, "cannot export "+srv.URL+"/tmux.rpm")
+	})
+}
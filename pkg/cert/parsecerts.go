@@ -0,0 +1,43 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNoCerts = errors.New("no certificates found")
+
+// ParseCerts parses all PEM encoded certificates in the bundle. Any content
+// that is not a PEM encoded certificate is an error.
+func ParseCerts(bundle string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block type %q, only certificates are supported", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("unexpected data after the last certificate")
+	}
+	if len(certs) == 0 {
+		return nil, ErrNoCerts
+	}
+
+	return certs, nil
+}
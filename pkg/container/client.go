@@ -4,6 +4,7 @@ package container
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -21,7 +22,10 @@ import (
 	"github.com/containers/image/v5/copy"
 	"github.com/containers/image/v5/docker"
 	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
 	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/pkg/blobinfocache/none"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/transports"
 	"github.com/containers/image/v5/transports/alltransports"
@@ -39,6 +43,57 @@ const (
 	DefaultPolicyPath = "/etc/containers/policy.json"
 )
 
+// Transports of container sources that are not fetched from a registry
+const (
+	OCIArchiveTransport = "oci-archive"
+	OCILayoutTransport  = "oci"
+)
+
+// SplitOCISource splits a source of the form "oci-archive:<path>[:<ref>]"
+// or "oci:<path>[:<ref>]", i.e. an OCI archive or an OCI layout directory,
+// into its transport and the reference within the transport. ok is false
+// for other sources.
+func SplitOCISource(source string) (transport, ref string, ok bool) {
+	for _, t := range []string{OCIArchiveTransport, OCILayoutTransport} {
+		if strings.HasPrefix(source, t+":") {
+			return t, strings.TrimPrefix(source, t+":"), true
+		}
+	}
+	return "", "", false
+}
+
+// extensions of OCI archives and layout directories that are not part of the
+// name of their image
+var ociArchiveExtensions = []string{".tar", ".tgz", ".gz", ".oci"}
+
+// targetName returns the name of the image of a source: the source itself
+// for registry sources, or a "localhost/" name derived from the path of OCI
+// sources without the extensions of archives, e.g. "localhost/image" for
+// "oci-archive:/srv/image.oci.tar.gz".
+func targetName(source string) (string, error) {
+	transport, path, ok := SplitOCISource(source)
+	if !ok {
+		return source, nil
+	}
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") {
+		path = path[:i]
+	}
+	name := filepath.Base(path)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, ext := range ociArchiveExtensions {
+			if base, ok := strings.CutSuffix(name, ext); ok && base != "" {
+				name, trimmed = base, true
+			}
+		}
+	}
+	target := "localhost/" + strings.ToLower(name)
+	if _, err := reference.ParseNormalizedNamed(target); err != nil {
+		return "", fmt.Errorf("cannot derive an image name from %s source %q: %w", transport, path, err)
+	}
+	return target, nil
+}
+
 // GetDefaultAuthFile returns the authentication file to use for the
 // current environment.
 //
@@ -112,13 +167,42 @@ type Client struct {
 	policy *signature.Policy
 	sysCtx *types.SystemContext
 
+	// OCI archive or layout source and its reference, unset for registries
+	ociSource string
+	ociRef    types.ImageReference
+
+	// signature verification when resolving the Target
+	signaturePolicy  *signature.Policy
+	verifySignature  bool
+	requireSignature bool
+
 	store string // another store location other than the main one, useful for testing
 }
 
 // NewClient constructs a new Client for target with default options.
 // It will add the "latest" tag if target does not contain it.
+//
+// The target can also be an OCI archive or layout directory, see
+// SplitOCISource(). The Target of the client is then "localhost/" followed
+// by the base name of the archive or directory, which is the default name
+// of the image when it is resolved.
 func NewClient(target string) (*Client, error) {
 
+	var ociSource string
+	var ociRef types.ImageReference
+	if _, _, ok := SplitOCISource(target); ok {
+		var err error
+		ociSource = target
+		ociRef, err = alltransports.ParseImageName(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse '%s': %w", target, err)
+		}
+		target, err = targetName(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	ref, err := reference.ParseNormalizedNamed(target)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse '%s': %w", target, err)
@@ -157,16 +241,52 @@ func NewClient(target string) (*Client, error) {
 		},
 		policy: policy,
 		store:  "/var/lib/containers/storage",
+
+		ociSource: ociSource,
+		ociRef:    ociRef,
 	}
 
 	return &client, nil
 }
 
+// SetSignaturePolicy makes Resolve verify the signatures of the Target with
+// the policy. If policy is nil, the policy of the system is used, see
+// DefaultPolicyPath.
+func (cl *Client) SetSignaturePolicy(policy *signature.Policy) {
+	cl.signaturePolicy = policy
+	cl.verifySignature = true
+}
+
+// SetRequireSignature makes Resolve fail if the signature policy does not
+// require a signature of the Target, e.g. if the policy accepts any image.
+// It implies the verification of the signatures with the policy of the
+// system if no policy is set with SetSignaturePolicy.
+func (cl *Client) SetRequireSignature(require bool) {
+	cl.requireSignature = require
+	if require {
+		cl.verifySignature = true
+	}
+}
+
 // SetAuthFilePath sets the location of the `containers-auth.json(5)` file.
 func (cl *Client) SetAuthFilePath(path string) {
 	cl.sysCtx.AuthFilePath = path
 }
 
+// SetRegistriesConfPath sets the `containers-registries.conf(5)` file with
+// the mirror, blocked and insecure registry rules to resolve the Target
+// with, instead of the one of the system. The drop-in files of
+// registries.conf.d still apply.
+func (cl *Client) SetRegistriesConfPath(path string) {
+	cl.sysCtx.SystemRegistriesConfPath = path
+}
+
+// GetRegistriesConfPath gets the location of the
+// `containers-registries.conf(5)` file, empty for the one of the system.
+func (cl *Client) GetRegistriesConfPath() string {
+	return cl.sysCtx.SystemRegistriesConfPath
+}
+
 // GetAuthFilePath gets the location of the `containers-auth.json(5)` file.
 func (cl *Client) GetAuthFilePath() string {
 	return cl.sysCtx.AuthFilePath
@@ -340,6 +460,11 @@ type RawManifest struct {
 	Data     []byte
 	MimeType string
 	Arch     arch.Arch
+
+	// Location is the repository a registry image was fetched from,
+	// i.e. the Target or the mirror of its registry that the docker
+	// transport selected with the registries configuration
+	Location string
 }
 
 // Digest computes the digest from the raw manifest data
@@ -348,6 +473,9 @@ func (m RawManifest) Digest() (digest.Digest, error) {
 }
 
 func (cl *Client) getImageRef(id string, local bool) (types.ImageReference, error) {
+	if cl.ociRef != nil {
+		return cl.ociRef, nil
+	}
 	if local {
 		imageName := cl.Target.String()
 		if id != "" {
@@ -360,18 +488,70 @@ func (cl *Client) getImageRef(id string, local bool) (types.ImageReference, erro
 	return docker.NewReference(cl.Target)
 }
 
-func (cl *Client) resolveContainerImageArch(ctx context.Context, ref types.ImageReference) (*arch.Arch, error) {
-	img, err := ref.NewImage(ctx, cl.sysCtx)
+// pullLocation is a blob info cache that records the repository the docker
+// transport fetched a blob from. The transport selects the location to pull
+// from, the registry or one of its mirrors, with the registries
+// configuration, and the blob info cache is the only place where it reveals
+// its selection.
+type pullLocation struct {
+	types.BlobInfoCache
+	location string
+}
+
+func (l *pullLocation) RecordKnownLocation(transport types.ImageTransport, scope types.BICTransportScope, blobDigest digest.Digest, location types.BICLocationReference) {
+	l.location = location.Opaque
+}
+
+// inspectImage returns the architecture of the image of the source, or of
+// the instance of the manifest list for the architecture choice, and the
+// repository the source fetched the image from, if it is a registry.
+func (cl *Client) inspectImage(ctx context.Context, src types.ImageSource, instanceDigest *digest.Digest) (*arch.Arch, string, error) {
+	img, err := image.FromUnparsedImage(ctx, cl.sysCtx, image.UnparsedInstance(src, instanceDigest))
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	configInfo := img.ConfigInfo()
+	if configInfo.Digest == "" {
+		// schema 1 images have no configuration blob
+		info, err := img.Inspect(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		a := arch.FromString(info.Architecture)
+		return &a, "", nil
 	}
-	defer img.Close()
-	info, err := img.Inspect(ctx)
+
+	location := &pullLocation{BlobInfoCache: none.NoCache}
+	blob, _, err := src.GetBlob(ctx, configInfo, location)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	defer blob.Close()
+	var config imgspecv1.Image
+	if err := json.NewDecoder(blob).Decode(&config); err != nil {
+		return nil, "", fmt.Errorf("error parsing the image configuration: %w", err)
 	}
-	a := arch.FromString(info.Architecture)
-	return &a, nil
+	a := arch.FromString(config.Architecture)
+	return &a, location.location, nil
+}
+
+// insecureMirror returns true if the mirror is marked insecure in the
+// registries configuration of the Target.
+func (cl *Client) insecureMirror(mirror string) (bool, error) {
+	registry, err := sysregistriesv2.FindRegistry(cl.sysCtx, cl.Target.Name())
+	if err != nil || registry == nil {
+		return false, err
+	}
+	sources, err := registry.PullSourcesFromReference(cl.Target)
+	if err != nil {
+		return false, err
+	}
+	for _, source := range sources {
+		if source.Reference.Name() == mirror {
+			return source.Endpoint.Insecure, nil
+		}
+	}
+	return false, nil
 }
 
 func (cl *Client) getLocalImageIDFromDigest(instance digest.Digest) (string, error) {
@@ -446,11 +626,14 @@ func (cl *Client) GetManifest(ctx context.Context, instanceDigest digest.Digest,
 			return nil
 		}
 
-		imageArch, err := cl.resolveContainerImageArch(ctx, ref)
+		imageArch, location, err := cl.inspectImage(ctx, src, overrideDigest)
 		if err != nil {
 			return err
 		}
 		r.Arch = *imageArch
+		if !local && cl.ociRef == nil {
+			r.Location = location
+		}
 
 		return nil
 	}, &retryOpts); err != nil {
@@ -561,7 +744,9 @@ func (cl *Client) resolveRawManifest(ctx context.Context, rm RawManifest, local
 // variant specified via SetArchitectureChoice or the corresponding defaults for
 // the host.
 func (cl *Client) Resolve(ctx context.Context, name string, local bool) (Spec, error) {
-
+	if local && cl.ociRef != nil {
+		return Spec{}, fmt.Errorf("%s cannot be resolved from the local containers storage", cl.ociSource)
+	}
 	raw, err := cl.GetManifest(ctx, "", local)
 	if err != nil {
 		return Spec{}, fmt.Errorf("error getting manifest: %w", err)
@@ -587,6 +772,37 @@ func (cl *Client) Resolve(ctx context.Context, name string, local bool) (Spec, e
 	} else {
 		spec.Arch = raw.Arch
 	}
+	if cl.ociRef != nil {
+		// the image is copied from the archive or layout directory without
+		// its manifest list, which osbuild can only fetch from registries
+		spec.Source = cl.ociSource
+		spec.ListDigest = ""
+	}
+	if raw.Location != "" && raw.Location != cl.Target.Name() {
+		// the mirror of the registry the docker transport resolved the
+		// image from, which osbuild fetches it from as well
+		spec.Mirror = raw.Location
+		insecure, err := cl.insecureMirror(spec.Mirror)
+		if err != nil {
+			return Spec{}, err
+		}
+		if insecure && spec.TLSVerify == nil {
+			spec.TLSVerify = common.ToPtr(false)
+		}
+	}
+
+	if cl.verifySignature {
+		// signatures are verified for the top-level manifest, i.e. the
+		// manifest list if there is one
+		dgst := ids.Manifest
+		if ids.ListManifest != "" {
+			dgst = ids.ListManifest
+		}
+		spec.PolicyRequirements, err = cl.verifySignatures(ctx, dgst, local)
+		if err != nil {
+			return Spec{}, err
+		}
+	}
 
 	return spec, nil
 }
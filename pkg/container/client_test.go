@@ -2,12 +2,21 @@ package container_test
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/container"
 )
@@ -71,6 +80,165 @@ func TestClientResolve(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestClientResolveOCI(t *testing.T) {
+	registry := NewTestRegistry()
+	defer registry.Close()
+
+	repo := registry.AddRepo("library/osbuild")
+	repo.AddImage(
+		[]Blob{NewDataBlobFromBase64(rootLayer)},
+		[]string{"amd64", "ppc64le"},
+		"cool container",
+		time.Time{})
+	ref := registry.GetRef("library/osbuild")
+
+	tmpdir := t.TempDir()
+	for target, name := range map[string]string{
+		"oci:" + filepath.Join(tmpdir, "osbuild-layout") + ":ci":      "localhost/osbuild-layout:latest",
+		"oci-archive:" + filepath.Join(tmpdir, "osbuild-archive.tar"): "localhost/osbuild-archive:latest",
+	} {
+		copyImage(t, "docker://"+ref, target)
+
+		client, err := container.NewClient(target)
+		require.NoError(t, err)
+		assert.Equal(t, name, client.Target.String())
+
+		client.SetArchitectureChoice("ppc64le")
+		spec, err := client.Resolve(context.Background(), "", false)
+		require.NoError(t, err)
+		assert.Equal(t, target, spec.Source)
+		assert.Regexp(t, "^sha256:[0-9a-f]{64}$", spec.ImageID)
+		assert.Equal(t, client.Target.String(), spec.LocalName)
+		assert.Equal(t, arch.ARCH_PPC64LE, spec.Arch)
+		// the manifest list is not copied into the image
+		assert.Empty(t, spec.ListDigest)
+
+		_, err = client.Resolve(context.Background(), "", true)
+		assert.EqualError(t, err, target+" cannot be resolved from the local containers storage")
+	}
+}
+
+func TestNewClientOCIName(t *testing.T) {
+	tmpdir := t.TempDir()
+	for source, name := range map[string]string{
+		"oci-archive:" + filepath.Join(tmpdir, "image.tar"):        "localhost/image:latest",
+		"oci-archive:" + filepath.Join(tmpdir, "image.tar.gz"):     "localhost/image:latest",
+		"oci-archive:" + filepath.Join(tmpdir, "image.oci.tar"):    "localhost/image:latest",
+		"oci-archive:" + filepath.Join(tmpdir, "Image-v2.tgz"):     "localhost/image-v2:latest",
+		"oci:" + filepath.Join(tmpdir, "layout.oci") + ":ci":       "localhost/layout:latest",
+		"oci:" + filepath.Join(tmpdir, "fedora-bootc"):             "localhost/fedora-bootc:latest",
+		"oci-archive:" + filepath.Join(tmpdir, "v1.2.release.tar"): "localhost/v1.2.release:latest",
+	} {
+		client, err := container.NewClient(source)
+		require.NoError(t, err, source)
+		assert.Equal(t, name, client.Target.String(), source)
+	}
+
+	_, err := container.NewClient("oci-archive:" + filepath.Join(tmpdir, "Image_.tar"))
+	assert.ErrorContains(t, err, "cannot derive an image name from oci-archive source")
+}
+
+func TestClientResolveMirrors(t *testing.T) {
+	registry := NewTestRegistry()
+	defer registry.Close()
+
+	repo := registry.AddRepo("mirror/library/osbuild")
+	repo.AddImage(
+		[]Blob{NewDataBlobFromBase64(rootLayer)},
+		[]string{"amd64"},
+		"mirrored container",
+		time.Time{})
+	mirror := registry.GetRef("mirror/library/osbuild")
+
+	// the registry stands in for the second mirror of an unreachable
+	// registry; the first mirror does not have the image
+	confPath := filepath.Join(t.TempDir(), "registries.conf")
+	require.NoError(t, os.WriteFile(confPath, []byte(fmt.Sprintf(`
+[[registry]]
+location = "registry.invalid/library"
+
+[[registry.mirror]]
+location = "%[1]s/empty"
+insecure = true
+
+[[registry.mirror]]
+location = "%[1]s/mirror/library"
+insecure = true
+
+[[registry]]
+location = "%[1]s/direct"
+
+[[registry.mirror]]
+location = "%[1]s/empty"
+insecure = true
+
+[[registry]]
+location = "blocked.invalid"
+blocked = true
+`, strings.TrimSuffix(registry.GetRef(""), "/"))), 0600))
+
+	client, err := container.NewClient("registry.invalid/library/osbuild")
+	require.NoError(t, err)
+	client.SetRegistriesConfPath(confPath)
+	assert.Equal(t, confPath, client.GetRegistriesConfPath())
+	client.SetArchitectureChoice("amd64")
+
+	spec, err := client.Resolve(context.Background(), "", false)
+	require.NoError(t, err)
+	expected, err := registry.Resolve(mirror, arch.ARCH_X86_64)
+	require.NoError(t, err)
+	assert.Equal(t, container.Spec{
+		Source:     "registry.invalid/library/osbuild",
+		Mirror:     mirror,
+		Digest:     expected.Digest,
+		ImageID:    expected.ImageID,
+		TLSVerify:  common.ToPtr(false),
+		LocalName:  "registry.invalid/library/osbuild:latest",
+		ListDigest: expected.ListDigest,
+		Arch:       arch.ARCH_X86_64,
+	}, spec)
+
+	// the registry itself is used if none of its mirrors has the image
+	direct := registry.AddRepo("direct/osbuild")
+	direct.AddImage(
+		[]Blob{NewDataBlobFromBase64(rootLayer)},
+		[]string{"amd64"},
+		"direct container",
+		time.Time{})
+	client, err = container.NewClient(registry.GetRef("direct/osbuild"))
+	require.NoError(t, err)
+	client.SetRegistriesConfPath(confPath)
+	client.SkipTLSVerify()
+	client.SetArchitectureChoice("amd64")
+	spec, err = client.Resolve(context.Background(), "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "", spec.Mirror)
+	assert.Equal(t, registry.GetRef("direct/osbuild"), spec.Source)
+
+	client, err = container.NewClient("blocked.invalid/osbuild")
+	require.NoError(t, err)
+	client.SetRegistriesConfPath(confPath)
+	_, err = client.Resolve(context.Background(), "", false)
+	assert.ErrorContains(t, err, "registry blocked.invalid is blocked in "+confPath)
+}
+
+// copyImage copies all the images of src to dest
+func copyImage(t *testing.T, src, dest string) {
+	srcRef, err := alltransports.ParseImageName(src)
+	require.NoError(t, err)
+	destRef, err := alltransports.ParseImageName(dest)
+	require.NoError(t, err)
+	policyContext, err := signature.NewPolicyContext(&signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}})
+	require.NoError(t, err)
+	defer policyContext.Destroy() //nolint:errcheck
+
+	_, err = copy.Image(context.Background(), policyContext, destRef, srcRef, &copy.Options{
+		SourceCtx:          &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue},
+		ImageListSelection: copy.CopyAllImages,
+	})
+	require.NoError(t, err)
+}
+
 func TestClientAuthFilePath(t *testing.T) {
 
 	client, err := container.NewClient("quay.io/osbuild/osbuild")
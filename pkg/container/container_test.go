@@ -88,18 +88,20 @@ func MakeDescriptorForBlob(b Blob) manifest.Schema2Descriptor {
 
 // Repo //
 type Repo struct {
-	blobs     map[string]Blob
-	manifests map[string]*manifest.Schema2
-	images    map[string]*manifest.Schema2List
-	tags      map[string]string
+	blobs      map[string]Blob
+	manifests  map[string]*manifest.Schema2
+	images     map[string]*manifest.Schema2List
+	tags       map[string]string
+	signatures map[string][][]byte
 }
 
 func NewRepo() *Repo {
 	return &Repo{
-		blobs:     make(map[string]Blob),
-		manifests: make(map[string]*manifest.Schema2),
-		tags:      make(map[string]string),
-		images:    make(map[string]*manifest.Schema2List),
+		blobs:      make(map[string]Blob),
+		manifests:  make(map[string]*manifest.Schema2),
+		tags:       make(map[string]string),
+		images:     make(map[string]*manifest.Schema2List),
+		signatures: make(map[string][][]byte),
 	}
 }
 
@@ -187,6 +189,39 @@ func (r *Repo) AddTag(checksum, tag string) {
 	r.tags[tag] = checksum
 }
 
+// AddSignature adds a simple signing signature of the manifest with the
+// given checksum, which is served with the signature API extension of the
+// OpenShift registry.
+func (r *Repo) AddSignature(checksum string, sig []byte) {
+	r.signatures[checksum] = append(r.signatures[checksum], sig)
+}
+
+func (r *Repo) ServeSignatures(checksum string, w http.ResponseWriter) {
+	type extensionSignature struct {
+		Version int    `json:"schemaVersion"`
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		Content []byte `json:"content"`
+	}
+	list := struct {
+		Signatures []extensionSignature `json:"signatures"`
+	}{
+		Signatures: []extensionSignature{},
+	}
+	for idx, sig := range r.signatures[checksum] {
+		list.Signatures = append(list.Signatures, extensionSignature{
+			Version: 2,
+			Name:    fmt.Sprintf("%s@%d", checksum, idx),
+			Type:    "atomic",
+			Content: sig,
+		})
+	}
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing signatures: %v", err)
+	}
+}
+
 func WriteBlob(blob Blob, w http.ResponseWriter) {
 	w.Header().Add("Content-Type", blob.GetMediaType())
 	w.Header().Add("Content-Length", fmt.Sprintf("%d", blob.GetSize()))
@@ -250,9 +285,20 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// [1] version-check:  /v2/
 	// [2] blobs:          /v2/<repo_name>/blobs/<digest>
 	// [3] manifest:       /v2/<repo_name>/manifests/<ref>
+	// [4] signatures:     /extensions/v2/<repo_name>/signatures/<digest>
 	//
 	// we need at least 4 path components and path has to start with "/v2"
 
+	if len(paths) > 4 && paths[0] == "extensions" && paths[1] == "v2" && paths[len(paths)-2] == "signatures" {
+		repo, ok := reg.repos[strings.Join(paths[2:len(paths)-2], "/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		repo.ServeSignatures(paths[len(paths)-1], w)
+		return
+	}
+
 	if len(paths) < 1 || paths[0] != "v2" {
 		http.NotFound(w, req)
 		return
@@ -260,6 +306,7 @@ func (reg *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	// [1] version check
 	if len(paths) == 1 {
+		w.Header().Add("X-Registry-Supports-Signatures", "1")
 		w.WriteHeader(200)
 		return
 	} else if len(paths) < 4 {
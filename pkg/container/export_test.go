@@ -1,5 +1,11 @@
 package container
 
+import (
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/signature"
+)
+
 func NewResolverWithTestClient(arch string, f func(string) (*Client, error)) *Resolver {
 	resolver := NewResolver(arch)
 	resolver.newClient = f
@@ -11,3 +17,15 @@ func NewClientWithTestStorage(target, storage string) (*Client, error) {
 	client.store = storage
 	return client, err
 }
+
+func SignatureRequirementsFor(policy *signature.Policy, target string) ([]SignatureRequirement, error) {
+	named, err := reference.ParseNormalizedNamed(target)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := docker.NewReference(named)
+	if err != nil {
+		return nil, err
+	}
+	return signatureRequirements(policyRequirementsFor(policy, ref))
+}
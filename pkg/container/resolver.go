@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/signature"
 )
 
 type resolveResult struct {
@@ -21,6 +24,17 @@ type Resolver struct {
 	Arch         string
 	AuthFilePath string
 
+	// registries.conf file to resolve all containers with, see
+	// Client.SetRegistriesConfPath()
+	RegistriesConfPath string
+
+	// Signature policy to verify all containers with, see
+	// Client.SetSignaturePolicy()
+	SignaturePolicy *signature.Policy
+	// Require a verified signature for all containers, see
+	// Client.SetRequireSignature()
+	RequireSignatures bool
+
 	newClient func(string) (*Client, error)
 }
 
@@ -30,6 +44,32 @@ type SourceSpec struct {
 	Digest    *string
 	TLSVerify *bool
 	Local     bool
+
+	// Require a verified signature for the container, see
+	// Client.SetRequireSignature()
+	RequireSignature bool
+}
+
+// SpecNames returns the Source and LocalName of the Spec that the source
+// resolves to, which identify the container independently of its digest.
+func (src SourceSpec) SpecNames() (source string, localName string, err error) {
+	target, err := targetName(src.Source)
+	if err != nil {
+		return "", "", err
+	}
+	ref, err := reference.ParseNormalizedNamed(target)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse '%s': %w", target, err)
+	}
+	source = ref.Name()
+	if _, _, ok := SplitOCISource(src.Source); ok {
+		source = src.Source
+	}
+	localName = src.Name
+	if localName == "" {
+		localName = reference.TagNameOnly(ref).String()
+	}
+	return source, localName, nil
 }
 
 // XXX: use arch.Arch here?
@@ -43,8 +83,8 @@ func NewResolver(arch string) *Resolver {
 	}
 }
 
-func (r *Resolver) Add(spec SourceSpec) {
-	client, err := r.newClient(spec.Source)
+func (r *Resolver) Add(src SourceSpec) {
+	client, err := r.newClient(src.Source)
 	r.jobs += 1
 
 	if err != nil {
@@ -52,16 +92,23 @@ func (r *Resolver) Add(spec SourceSpec) {
 		return
 	}
 
-	client.SetTLSVerify(spec.TLSVerify)
+	client.SetTLSVerify(src.TLSVerify)
 	client.SetArchitectureChoice(r.Arch)
 	if r.AuthFilePath != "" {
 		client.SetAuthFilePath(r.AuthFilePath)
 	}
+	if r.RegistriesConfPath != "" {
+		client.SetRegistriesConfPath(r.RegistriesConfPath)
+	}
+	if r.SignaturePolicy != nil {
+		client.SetSignaturePolicy(r.SignaturePolicy)
+	}
+	client.SetRequireSignature(r.RequireSignatures || src.RequireSignature)
 
 	go func() {
-		spec, err := client.Resolve(r.ctx, spec.Name, spec.Local)
+		spec, err := client.Resolve(r.ctx, src.Name, src.Local)
 		if err != nil {
-			err = fmt.Errorf("'%s': %w", spec.Source, err)
+			err = fmt.Errorf("'%s': %w", src.Source, err)
 		}
 		r.queue <- resolveResult{spec: spec, err: err}
 	}()
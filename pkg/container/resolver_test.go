@@ -68,6 +68,53 @@ func TestResolver(t *testing.T) {
 	}
 
 	assert.ElementsMatch(t, have, want)
+
+	// the names of the sources identify the resolved specs
+	for _, spec := range have {
+		source, localName, err := container.SourceSpec{Source: spec.LocalName}.SpecNames()
+		assert.NoError(t, err)
+		assert.Equal(t, spec.Source, source)
+		assert.Equal(t, spec.LocalName, localName)
+	}
+}
+
+func TestSourceSpecNames(t *testing.T) {
+	testCases := []struct {
+		src       container.SourceSpec
+		source    string
+		localName string
+		err       string
+	}{
+		{
+			src:       container.SourceSpec{Source: "fedora:40"},
+			source:    "docker.io/library/fedora",
+			localName: "docker.io/library/fedora:40",
+		},
+		{
+			src:       container.SourceSpec{Source: "registry.example.com/app", Name: "app:stable"},
+			source:    "registry.example.com/app",
+			localName: "app:stable",
+		},
+		{
+			src:       container.SourceSpec{Source: "oci-archive:/srv/images/App.tar"},
+			source:    "oci-archive:/srv/images/App.tar",
+			localName: "localhost/app:latest",
+		},
+		{
+			src: container.SourceSpec{Source: "Invalid Name"},
+			err: "failed to parse 'Invalid Name': invalid reference format: repository name must be lowercase",
+		},
+	}
+	for _, tc := range testCases {
+		source, localName, err := tc.src.SpecNames()
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, tc.source, source)
+		assert.Equal(t, tc.localName, localName)
+	}
 }
 
 func TestResolverFail(t *testing.T) {
@@ -0,0 +1,155 @@
+package container
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// A SignatureRequirement is a signature requirement of the signature policy
+// that an image satisfied when it was resolved. It records the keys or the
+// signer the policy accepts, e.g. all the keys of a keyring, not the key that
+// made the signature.
+type SignatureRequirement struct {
+	// Type of the policy requirement, "signedBy" or "sigstoreSigned"
+	Type string `json:"type"`
+
+	// Paths of the public keys or keyrings the policy accepts
+	KeyPaths []string `json:"key_paths,omitempty"`
+
+	// Digests of the public keys or keyrings inlined in the policy
+	KeyDataDigests []string `json:"key_data_digests,omitempty"`
+
+	// Fulcio identity the policy accepts for keyless sigstore signatures
+	OIDCIssuer   string `json:"oidc_issuer,omitempty"`
+	SubjectEmail string `json:"subject_email,omitempty"`
+}
+
+// policyRequirement is the union of the serialized signature requirements of
+// a policy, which do not expose their fields.
+type policyRequirement struct {
+	Type     string   `json:"type"`
+	KeyPath  string   `json:"keyPath"`
+	KeyPaths []string `json:"keyPaths"`
+	KeyData  []byte   `json:"keyData"`
+	KeyDatas [][]byte `json:"keyDatas"`
+	Fulcio   *struct {
+		OIDCIssuer   string `json:"oidcIssuer"`
+		SubjectEmail string `json:"subjectEmail"`
+	} `json:"fulcio"`
+}
+
+// policyRequirementsFor returns the requirements of the policy for the image
+// reference: the requirements of the most specific scope of its transport
+// or the default requirements.
+func policyRequirementsFor(policy *signature.Policy, ref types.ImageReference) signature.PolicyRequirements {
+	if scopes, ok := policy.Transports[ref.Transport().Name()]; ok {
+		if reqs, ok := scopes[ref.PolicyConfigurationIdentity()]; ok {
+			return reqs
+		}
+		for _, namespace := range ref.PolicyConfigurationNamespaces() {
+			if reqs, ok := scopes[namespace]; ok {
+				return reqs
+			}
+		}
+		if reqs, ok := scopes[""]; ok {
+			return reqs
+		}
+	}
+	return policy.Default
+}
+
+// signatureRequirements returns the signature requirements of the policy
+// requirements, skipping the ones that do not require a signature.
+func signatureRequirements(reqs signature.PolicyRequirements) ([]SignatureRequirement, error) {
+	var sigReqs []SignatureRequirement
+	for _, req := range reqs {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		var pr policyRequirement
+		if err := json.Unmarshal(data, &pr); err != nil {
+			return nil, err
+		}
+		if pr.Type != "signedBy" && pr.Type != "sigstoreSigned" {
+			continue
+		}
+
+		sigReq := SignatureRequirement{Type: pr.Type}
+		if pr.KeyPath != "" {
+			sigReq.KeyPaths = append(sigReq.KeyPaths, pr.KeyPath)
+		}
+		sigReq.KeyPaths = append(sigReq.KeyPaths, pr.KeyPaths...)
+		if pr.KeyData != nil {
+			pr.KeyDatas = append([][]byte{pr.KeyData}, pr.KeyDatas...)
+		}
+		for _, keyData := range pr.KeyDatas {
+			sigReq.KeyDataDigests = append(sigReq.KeyDataDigests, fmt.Sprintf("sha256:%x", sha256.Sum256(keyData)))
+		}
+		if pr.Fulcio != nil {
+			sigReq.OIDCIssuer = pr.Fulcio.OIDCIssuer
+			sigReq.SubjectEmail = pr.Fulcio.SubjectEmail
+		}
+		sigReqs = append(sigReqs, sigReq)
+	}
+	return sigReqs, nil
+}
+
+// verifySignatures checks the image with the given top-level manifest digest
+// against the signature policy of the client and returns the signature
+// requirements of the policy the image satisfied.
+func (cl *Client) verifySignatures(ctx context.Context, dgst digest.Digest, local bool) ([]SignatureRequirement, error) {
+	policy := cl.signaturePolicy
+	if policy == nil {
+		policy = cl.policy
+	}
+
+	var ref types.ImageReference
+	var err error
+	if local || cl.ociRef != nil {
+		ref, err = cl.getImageRef("", local)
+	} else {
+		var named reference.Canonical
+		named, err = reference.WithDigest(reference.TrimNamed(cl.Target), dgst)
+		if err == nil {
+			ref, err = docker.NewReference(named)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sigReqs, err := signatureRequirements(policyRequirementsFor(policy, ref))
+	if err != nil {
+		return nil, err
+	}
+	if cl.requireSignature && len(sigReqs) == 0 {
+		return nil, fmt.Errorf("the signature policy does not require a signature for %s", cl.Target)
+	}
+
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		return nil, err
+	}
+	defer policyContext.Destroy() //nolint:errcheck
+
+	src, err := ref.NewImageSource(ctx, cl.sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	if allowed, err := policyContext.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, nil)); !allowed {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	return sigReqs, nil
+}
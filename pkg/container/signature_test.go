@@ -0,0 +1,203 @@
+package container_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/container"
+)
+
+func TestSignatureRequirements(t *testing.T) {
+	policy, err := signature.NewPolicyFromBytes([]byte(`{
+	"default": [{"type": "reject"}],
+	"transports": {
+		"docker": {
+			"quay.io/centos": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": "/etc/pki/rpm-gpg/RPM-GPG-KEY-centosofficial"}],
+			"quay.io/centos/centos-bootc": [
+				{"type": "sigstoreSigned", "keyData": "a2V5", "signedIdentity": {"type": "matchRepository"}},
+				{"type": "sigstoreSigned", "fulcio": {"caPath": "/etc/pki/fulcio.pem", "oidcIssuer": "https://oauth2.sigstore.dev/auth", "subjectEmail": "release@centos.org"}, "rekorPublicKeyPath": "/etc/pki/rekor.pub"}
+			],
+			"docker.io": [{"type": "insecureAcceptAnything"}]
+		}
+	}
+}`))
+	require.NoError(t, err)
+
+	sigReqs, err := container.SignatureRequirementsFor(policy, "quay.io/centos/centos:stream9")
+	require.NoError(t, err)
+	assert.Equal(t, []container.SignatureRequirement{
+		{Type: "signedBy", KeyPaths: []string{"/etc/pki/rpm-gpg/RPM-GPG-KEY-centosofficial"}},
+	}, sigReqs)
+
+	sigReqs, err = container.SignatureRequirementsFor(policy, "quay.io/centos/centos-bootc:stream9")
+	require.NoError(t, err)
+	assert.Equal(t, []container.SignatureRequirement{
+		{Type: "sigstoreSigned", KeyDataDigests: []string{"sha256:2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683"}},
+		{Type: "sigstoreSigned", OIDCIssuer: "https://oauth2.sigstore.dev/auth", SubjectEmail: "release@centos.org"},
+	}, sigReqs)
+
+	sigReqs, err = container.SignatureRequirementsFor(policy, "fedora:40")
+	require.NoError(t, err)
+	assert.Empty(t, sigReqs)
+
+	sigReqs, err = container.SignatureRequirementsFor(policy, "registry.example.com/image:latest")
+	require.NoError(t, err)
+	assert.Empty(t, sigReqs)
+}
+
+func TestClientResolveSignatures(t *testing.T) {
+	registry := NewTestRegistry()
+	defer registry.Close()
+
+	repo := registry.AddRepo("library/osbuild")
+	listDigest := repo.AddImage(
+		[]Blob{NewDataBlobFromBase64(rootLayer)},
+		[]string{"amd64"},
+		"signed container",
+		time.Time{})
+	ref := registry.GetRef("library/osbuild")
+
+	resolve := func(policyJSON string, requireSignature bool) (container.Spec, error) {
+		client, err := container.NewClient(ref)
+		require.NoError(t, err)
+		client.SkipTLSVerify()
+		client.SetArchitectureChoice("amd64")
+		policy, err := signature.NewPolicyFromBytes([]byte(policyJSON))
+		require.NoError(t, err)
+		client.SetSignaturePolicy(policy)
+		client.SetRequireSignature(requireSignature)
+		return client.Resolve(context.Background(), "", false)
+	}
+
+	acceptAnything := `{"default": [{"type": "insecureAcceptAnything"}]}`
+	spec, err := resolve(acceptAnything, false)
+	require.NoError(t, err)
+	assert.Equal(t, listDigest, spec.ListDigest)
+	assert.Nil(t, spec.PolicyRequirements)
+
+	_, err = resolve(acceptAnything, true)
+	assert.EqualError(t, err, fmt.Sprintf("the signature policy does not require a signature for %s:latest", ref))
+
+	_, err = resolve(`{"default": [{"type": "reject"}]}`, false)
+	assert.EqualError(t, err, fmt.Sprintf("signature verification failed: Running image docker://%s@%s is rejected by policy.", ref, listDigest))
+
+	_, err = resolve(`{"default": [{"type": "signedBy", "keyType": "GPGKeys", "keyData": "a2V5"}]}`, true)
+	assert.EqualError(t, err, "signature verification failed: A signature was required, but no signature exists")
+}
+
+// gpgSign returns the public key of a new GPG key and a simple signing
+// signature of the manifest digest for the docker reference, made with the
+// key.
+func gpgSign(t *testing.T, dockerReference, manifestDigest string) ([]byte, []byte) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg is not installed")
+	}
+	// the socket of the agent must fit in a unix socket path, which the
+	// directories of t.TempDir() may not
+	home, err := os.MkdirTemp("", "gpg")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = exec.Command("gpgconf", "--homedir", home, "--kill", "gpg-agent").Run()
+		os.RemoveAll(home)
+	})
+	gpg := func(stdin []byte, args ...string) []byte {
+		cmd := exec.Command("gpg", append([]string{"--homedir", home, "--batch", "--pinentry-mode", "loopback", "--passphrase", ""}, args...)...)
+		cmd.Stdin = bytes.NewReader(stdin)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		require.NoError(t, err, stderr.String())
+		return out
+	}
+
+	// the openpgp signature mechanism does not support ed25519 keys
+	gpg(nil, "--quick-generate-key", "osbuild test <test@osbuild.org>", "rsa2048", "sign", "never")
+	payload := fmt.Sprintf(`{"critical": {"type": "atomic container signature", "image": {"docker-manifest-digest": %q}, "identity": {"docker-reference": %q}}, "optional": {"creator": "osbuild test"}}`, manifestDigest, dockerReference)
+	return gpg(nil, "--export", "test@osbuild.org"), gpg([]byte(payload), "--sign", "--local-user", "test@osbuild.org", "--output", "-")
+}
+
+func TestClientResolvePolicyRequirements(t *testing.T) {
+	registry := NewTestRegistry()
+	defer registry.Close()
+
+	repo := registry.AddRepo("library/osbuild")
+	listDigest := repo.AddImage(
+		[]Blob{NewDataBlobFromBase64(rootLayer)},
+		[]string{"amd64"},
+		"signed container",
+		time.Time{})
+	ref := registry.GetRef("library/osbuild")
+
+	publicKey, sig := gpgSign(t, ref+":latest", listDigest)
+	repo.AddSignature(listDigest, sig)
+	keyPath := filepath.Join(t.TempDir(), "key.gpg")
+	require.NoError(t, os.WriteFile(keyPath, publicKey, 0644))
+
+	resolve := func(policyJSON string) (container.Spec, error) {
+		client, err := container.NewClient(ref)
+		require.NoError(t, err)
+		client.SkipTLSVerify()
+		client.SetArchitectureChoice("amd64")
+		policy, err := signature.NewPolicyFromBytes([]byte(policyJSON))
+		require.NoError(t, err)
+		client.SetSignaturePolicy(policy)
+		client.SetRequireSignature(true)
+		return client.Resolve(context.Background(), "", false)
+	}
+
+	// the signature requirements of the policy are recorded
+	spec, err := resolve(fmt.Sprintf(`{"default": [{"type": "signedBy", "keyType": "GPGKeys", "keyData": %q}]}`, base64.StdEncoding.EncodeToString(publicKey)))
+	require.NoError(t, err)
+	assert.Equal(t, listDigest, spec.ListDigest)
+	assert.Equal(t, []container.SignatureRequirement{
+		{Type: "signedBy", KeyDataDigests: []string{fmt.Sprintf("sha256:%x", sha256.Sum256(publicKey))}},
+	}, spec.PolicyRequirements)
+
+	spec, err = resolve(fmt.Sprintf(`{"default": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": %q}]}`, keyPath))
+	require.NoError(t, err)
+	assert.Equal(t, []container.SignatureRequirement{{Type: "signedBy", KeyPaths: []string{keyPath}}}, spec.PolicyRequirements)
+
+	// the signature is made for the tag rather than the digest the image is
+	// resolved to
+	_, err = resolve(fmt.Sprintf(`{"default": [{"type": "signedBy", "keyType": "GPGKeys", "keyPath": %q, "signedIdentity": {"type": "matchExact"}}]}`, keyPath))
+	assert.ErrorContains(t, err, "signature verification failed")
+	assert.ErrorContains(t, err, "not accepted")
+}
+
+func TestResolverRequireSignatures(t *testing.T) {
+	registry := NewTestRegistry()
+	defer registry.Close()
+
+	repo := registry.AddRepo("library/osbuild")
+	repo.AddImage([]Blob{NewDataBlobFromBase64(rootLayer)}, []string{"amd64"}, "unsigned container", time.Time{})
+	ref := registry.GetRef("library/osbuild")
+
+	policy, err := signature.NewPolicyFromBytes([]byte(`{"default": [{"type": "insecureAcceptAnything"}]}`))
+	require.NoError(t, err)
+
+	resolver := container.NewResolver("amd64")
+	resolver.SignaturePolicy = policy
+	resolver.Add(container.SourceSpec{Source: ref, TLSVerify: common.ToPtr(false)})
+	specs, err := resolver.Finish()
+	require.NoError(t, err)
+	assert.Len(t, specs, 1)
+
+	resolver = container.NewResolver("amd64")
+	resolver.SignaturePolicy = policy
+	resolver.Add(container.SourceSpec{Source: ref, TLSVerify: common.ToPtr(false), RequireSignature: true})
+	_, err = resolver.Finish()
+	assert.EqualError(t, err, fmt.Sprintf("failed to resolve container: '%s': the signature policy does not require a signature for %s:latest", ref, ref))
+}
@@ -22,6 +22,19 @@ type Spec struct {
 	LocalStorage bool
 
 	Arch arch.Arch // the architecture of the image
+
+	// mirror of the Source, from the registries configuration, that the
+	// container was resolved from and is fetched from (optional)
+	Mirror string
+
+	// source the Source was rewritten from by the mirror rewrite rules, if
+	// any (optional)
+	OriginalSource string
+
+	// signature requirements of the policy the image satisfied, if its
+	// signatures were verified when it was resolved. They are the keys and
+	// identities the policy accepts, not the ones that signed the image.
+	PolicyRequirements []SignatureRequirement
 }
 
 // NewSpec creates a new Spec from the essential information.
@@ -2,23 +2,117 @@ package crypt
 
 import (
 	"crypto/rand"
+	"fmt"
 	"math/big"
 	"strings"
 )
 
+// Algorithm is a password hashing method supported by crypt(3)
+type Algorithm string
+
+const (
+	AlgorithmYescrypt Algorithm = "yescrypt"
+	AlgorithmSHA512   Algorithm = "sha512"
+	AlgorithmSHA256   Algorithm = "sha256"
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+	AlgorithmScrypt   Algorithm = "scrypt"
+	AlgorithmMD5      Algorithm = "md5"
+)
+
+// prefixes of the hashes of each algorithm, as described in crypt(5)
+var algorithmPrefixes = []struct {
+	prefix    string
+	algorithm Algorithm
+}{
+	{"$y$", AlgorithmYescrypt},
+	{"$6$", AlgorithmSHA512},
+	{"$5$", AlgorithmSHA256},
+	{"$2b$", AlgorithmBcrypt},
+	{"$2a$", AlgorithmBcrypt},
+	{"$2y$", AlgorithmBcrypt},
+	{"$7$", AlgorithmScrypt},
+	{"$1$", AlgorithmMD5},
+}
+
+const (
+	// sha512crypt and sha256crypt use 5000 rounds when none are given
+	shaMinRounds = 1000
+	shaMaxRounds = 999999999
+
+	// yescrypt cost parameter, as accepted by crypt_gensalt(3). The default
+	// used when none is given is 5.
+	yescryptMinCost = 1
+	yescryptMaxCost = 11
+)
+
+// HashOptions selects the algorithm and the number of rounds used to hash
+// passwords.
+type HashOptions struct {
+	Algorithm Algorithm
+	// Number of rounds for SHA-512, or the cost parameter for yescrypt.
+	// 0 selects the default of the algorithm.
+	Rounds int
+}
+
+// Validate checks that passwords can be hashed with the options.
+func (o HashOptions) Validate() error {
+	switch o.Algorithm {
+	case AlgorithmSHA512:
+		if o.Rounds != 0 && (o.Rounds < shaMinRounds || o.Rounds > shaMaxRounds) {
+			return fmt.Errorf("%s rounds must be between %d and %d, got %d", o.Algorithm, shaMinRounds, shaMaxRounds, o.Rounds)
+		}
+	case AlgorithmYescrypt:
+		if o.Rounds != 0 && (o.Rounds < yescryptMinCost || o.Rounds > yescryptMaxCost) {
+			return fmt.Errorf("%s cost must be between %d and %d, got %d", o.Algorithm, yescryptMinCost, yescryptMaxCost, o.Rounds)
+		}
+	default:
+		return fmt.Errorf("hashing passwords with %q is not supported", o.Algorithm)
+	}
+	return nil
+}
+
+// Crypt hashes the given password with the given options and a random salt.
+//
+// Note that this function is not deterministic.
+func Crypt(phrase string, options HashOptions) (string, error) {
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+
+	switch options.Algorithm {
+	case AlgorithmSHA512:
+		return cryptSHA512(phrase, options.Rounds)
+	case AlgorithmYescrypt:
+		hashSettings, err := gensalt("$y$", uint64(options.Rounds))
+		if err != nil {
+			return "", err
+		}
+		return crypt(phrase, hashSettings)
+	}
+
+	panic(fmt.Sprintf("unhandled password hash algorithm %q", options.Algorithm))
+}
+
 // CryptSHA512 encrypts the given password with SHA512 and a random salt.
 //
 // Note that this function is not deterministic.
 func CryptSHA512(phrase string) (string, error) {
+	return cryptSHA512(phrase, 0)
+}
+
+func cryptSHA512(phrase string, rounds int) (string, error) {
 	const SHA512SaltLength = 16
 
 	salt, err := genSalt(SHA512SaltLength)
 
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	hashSettings := "$6$" + salt
+	if rounds != 0 {
+		hashSettings = fmt.Sprintf("$6$rounds=%d$%s", rounds, salt)
+	}
 	return crypt(phrase, hashSettings)
 }
 
@@ -38,22 +132,43 @@ func genSalt(length int) (string, error) {
 	return string(b), nil
 }
 
+// DetectAlgorithm returns the algorithm of the given password hash, based on
+// its prefix. The second return value is false if the string does not look
+// like a hash of any known algorithm.
+func DetectAlgorithm(hash string) (Algorithm, bool) {
+	for _, ap := range algorithmPrefixes {
+		if strings.HasPrefix(hash, ap.prefix) {
+			return ap.algorithm, true
+		}
+	}
+	return "", false
+}
+
+// ValidateHash returns an error if the algorithm of the given password hash
+// is not one of the supported ones. Strings that do not look like a hash are
+// accepted, they are considered to be plain text passwords.
+func ValidateHash(hash string, supported []Algorithm) error {
+	algorithm, ok := DetectAlgorithm(hash)
+	if !ok {
+		return nil
+	}
+	for _, s := range supported {
+		if s == algorithm {
+			return nil
+		}
+	}
+	return fmt.Errorf("password hash algorithm %q is not supported, must be one of %v", algorithm, supported)
+}
+
 // PasswordIsCrypted returns true if the password appears to be an encrypted
 // one, according to a very simple heuristic.
 //
-// Any string starting with one of $2$, $6$ or $5$ is considered to be
-// encrypted. Any other string is consdirede to be unencrypted.
+// Any string starting with the prefix of one of the algorithms known to
+// DetectAlgorithm, e.g. $y$, $6$ or $2b$, is considered to be encrypted. Any
+// other string is considered to be unencrypted.
 //
 // This functionality is taken from pylorax.
 func PasswordIsCrypted(s string) bool {
-	// taken from lorax src: src/pylorax/api/compose.py:533
-	prefixes := [...]string{"$2b$", "$6$", "$5$"}
-
-	for _, prefix := range prefixes {
-		if strings.HasPrefix(s, prefix) {
-			return true
-		}
-	}
-
-	return false
+	_, ok := DetectAlgorithm(s)
+	return ok
 }
@@ -62,6 +62,19 @@ import (
 
 	 return ret;
 	}
+
+	char *gnu_ext_crypt_gensalt(char *prefix, unsigned long count) {
+		char out[CRYPT_GENSALT_OUTPUT_SIZE];
+		char *enc = NULL;
+
+		// a NULL rbytes makes libxcrypt read random bytes from the OS
+		enc = crypt_gensalt_rn(prefix, count, NULL, 0, out, sizeof(out));
+		if(enc == NULL) {
+			return NULL;
+		}
+
+		return strdup(enc);
+	}
 */
 import "C"
 
@@ -85,3 +98,19 @@ func crypt(pass, salt string) (string, error) {
 	// on success. Caller should ignore errno on success.
 	return C.GoString(c_enc), nil
 }
+
+// gensalt provides a wrapper around the libxcrypt crypt_gensalt_rn()
+// function. It returns the hash settings for the given prefix and cost, with
+// a random salt.
+func gensalt(prefix string, count uint64) (string, error) {
+	c_prefix := C.CString(prefix)
+	defer C.free(unsafe.Pointer(c_prefix))
+
+	c_settings, err := C.gnu_ext_crypt_gensalt(c_prefix, C.ulong(count))
+	if c_settings == nil {
+		return "", err
+	}
+	defer C.free(unsafe.Pointer(c_settings))
+
+	return C.GoString(c_settings), nil
+}
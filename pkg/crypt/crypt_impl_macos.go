@@ -5,3 +5,7 @@ package crypt
 func crypt(pass, salt string) (string, error) {
 	panic("You must not run osbuild-composer on macOS!")
 }
+
+func gensalt(prefix string, count uint64) (string, error) {
+	panic("You must not run osbuild-composer on macOS!")
+}
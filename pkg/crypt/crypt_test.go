@@ -3,6 +3,7 @@
 package crypt
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,6 +20,18 @@ func Test_crypt_PasswordIsCrypted(t *testing.T) {
 			name:     "bcrypt",
 			password: "$2b$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy",
 			want:     true,
+		}, {
+			name:     "bcrypt-2a",
+			password: "$2a$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy",
+			want:     true,
+		}, {
+			name:     "bcrypt-2y",
+			password: "$2y$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy",
+			want:     true,
+		}, {
+			name:     "yescrypt",
+			password: "$y$j9T$Ed4aKnE1z7KB/AlZsSauA/$ezVO5sgMqYTrywv1Ya21wF178EWoJn5ZQky09zKAdr6",
+			want:     true,
 		}, {
 			name:     "sha256",
 			password: "$5$1234567890123456$v.2bOKKLlpmUSKn0rxJmgnh.e3wOKivAVNZmNrOsoA3",
@@ -30,7 +43,11 @@ func Test_crypt_PasswordIsCrypted(t *testing.T) {
 		}, {
 			name:     "scrypt",
 			password: "$7$123456789012345", //not actual hash output from scrypt
-			want:     false,
+			want:     true,
+		}, {
+			name:     "md5",
+			password: "$1$12345678$abcdefghijklmnopqrstuv",
+			want:     true,
 		}, {
 			name:     "plain",
 			password: "password",
@@ -62,3 +79,74 @@ func TestGenSalt(t *testing.T) {
 	retSaltSecond, _ := genSalt(length)
 	assert.NotEqual(t, retSaltFirst, retSaltSecond)
 }
+
+func TestCryptYescrypt(t *testing.T) {
+	hash, err := Crypt("testPass", HashOptions{Algorithm: AlgorithmYescrypt})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$y$j9T$"), hash)
+	assert.True(t, PasswordIsCrypted(hash))
+
+	// the hash verifies against the password it was created from
+	again, err := crypt("testPass", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	// a higher cost is encoded in the parameters
+	hash, err = Crypt("testPass", HashOptions{Algorithm: AlgorithmYescrypt, Rounds: 7})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$y$jBT$"), hash)
+}
+
+func TestCryptSHA512Rounds(t *testing.T) {
+	hash, err := Crypt("testPass", HashOptions{Algorithm: AlgorithmSHA512, Rounds: 10000})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$6$rounds=10000$"), hash)
+
+	hash, err = Crypt("testPass", HashOptions{Algorithm: AlgorithmSHA512})
+	assert.NoError(t, err)
+	assert.NotContains(t, hash, "rounds=")
+}
+
+func TestCryptInvalidOptions(t *testing.T) {
+	tests := []struct {
+		options HashOptions
+		expErr  string
+	}{
+		{HashOptions{Algorithm: AlgorithmMD5}, `hashing passwords with "md5" is not supported`},
+		{HashOptions{Algorithm: AlgorithmSHA512, Rounds: 10}, "sha512 rounds must be between 1000 and 999999999, got 10"},
+		{HashOptions{Algorithm: AlgorithmYescrypt, Rounds: 12}, "yescrypt cost must be between 1 and 11, got 12"},
+	}
+	for _, test := range tests {
+		_, err := Crypt("testPass", test.options)
+		assert.EqualError(t, err, test.expErr)
+	}
+}
+
+func TestDetectAlgorithm(t *testing.T) {
+	tests := []struct {
+		hash      string
+		algorithm Algorithm
+		ok        bool
+	}{
+		{"$y$j9T$Ed4aKnE1z7KB/AlZsSauA/$ezVO5sgMqYTrywv1Ya21wF178EWoJn5ZQky09zKAdr6", AlgorithmYescrypt, true},
+		{"$6$1234567890123456$d.pgKQFaiD8bRiExg5NesbGR/3u51YvxeYaQXPzx4C6oSYREw8VoReiuYZjx0V9OhGVTZFqhc6emAxT1RC5BV.", AlgorithmSHA512, true},
+		{"$5$1234567890123456$v.2bOKKLlpmUSKn0rxJmgnh.e3wOKivAVNZmNrOsoA3", AlgorithmSHA256, true},
+		{"$2b$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy", AlgorithmBcrypt, true},
+		{"$1$12345678$abcdefghijklmnopqrstuv", AlgorithmMD5, true},
+		{"password", "", false},
+	}
+	for _, test := range tests {
+		algorithm, ok := DetectAlgorithm(test.hash)
+		assert.Equal(t, test.algorithm, algorithm)
+		assert.Equal(t, test.ok, ok)
+	}
+}
+
+func TestValidateHash(t *testing.T) {
+	supported := []Algorithm{AlgorithmYescrypt, AlgorithmSHA512}
+
+	assert.NoError(t, ValidateHash("$y$j9T$Ed4aKnE1z7KB/AlZsSauA/$ezVO5sgMqYTrywv1Ya21wF178EWoJn5ZQky09zKAdr6", supported))
+	assert.NoError(t, ValidateHash("plain text password", supported))
+	assert.EqualError(t, ValidateHash("$1$12345678$abcdefghijklmnopqrstuv", supported),
+		`password hash algorithm "md5" is not supported, must be one of [yescrypt sha512]`)
+}
@@ -0,0 +1,35 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// RulesDir is the directory from which augenrules(8) loads rules files.
+const RulesDir = "/etc/audit/rules.d"
+
+// RulesFile is a set of audit rules written to a file in RulesDir.
+type RulesFile struct {
+	Filename string
+	Rules    []string
+}
+
+func FromBP(bpAudit blueprint.AuditCustomization) []RulesFile {
+	files := make([]RulesFile, len(bpAudit.Rules))
+	for idx := range bpAudit.Rules {
+		files[idx] = RulesFile(bpAudit.Rules[idx])
+	}
+	return files
+}
+
+// File returns the fsnode.File for the rules file. Rules files are only
+// readable by root, like the ones shipped by the audit package.
+func (rf RulesFile) File() (*fsnode.File, error) {
+	data := strings.Join(rf.Rules, "\n") + "\n"
+	return fsnode.NewFile(filepath.Join(RulesDir, rf.Filename), common.ToPtr(os.FileMode(0600)), "root", "root", []byte(data))
+}
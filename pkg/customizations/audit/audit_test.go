@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestFromBP(t *testing.T) {
+	bpAudit := blueprint.AuditCustomization{
+		Rules: []blueprint.AuditRulesCustomization{
+			{
+				Filename: "50-identity.rules",
+				Rules: []string{
+					"-w /etc/passwd -p wa -k identity",
+					"-w /etc/group -p wa -k identity",
+				},
+			},
+		},
+	}
+	files := FromBP(bpAudit)
+	require.Len(t, files, 1)
+
+	file, err := files[0].File()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/audit/rules.d/50-identity.rules", file.Path())
+	assert.Equal(t, os.FileMode(0600), *file.Mode())
+	assert.Equal(t, "-w /etc/passwd -p wa -k identity\n-w /etc/group -p wa -k identity\n", string(file.Data()))
+}
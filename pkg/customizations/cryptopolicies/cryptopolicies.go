@@ -0,0 +1,138 @@
+package cryptopolicies
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+type Policy string
+
+const (
+	Default Policy = "DEFAULT"
+	Legacy  Policy = "LEGACY"
+	Future  Policy = "FUTURE"
+	FIPS    Policy = "FIPS"
+)
+
+type Subpolicy string
+
+const (
+	ADSupport       Subpolicy = "AD-SUPPORT"
+	ADSupportLegacy Subpolicy = "AD-SUPPORT-LEGACY"
+	ECDHEOnly       Subpolicy = "ECDHE-ONLY"
+	NoCamellia      Subpolicy = "NO-CAMELLIA"
+	NoEnforceEMS    Subpolicy = "NO-ENFORCE-EMS"
+	NoSHA1          Subpolicy = "NO-SHA1"
+	OSPP            Subpolicy = "OSPP"
+	SHA1            Subpolicy = "SHA1"
+)
+
+// ModulesDir is the directory for custom policy modules. A custom subpolicy
+// NAME is defined by the file NAME.pmod in this directory.
+const ModulesDir = "/etc/crypto-policies/policies/modules"
+
+// AllowList contains the policies and subpolicies shipped by the
+// crypto-policies package of a distribution.
+type AllowList struct {
+	Policies    []Policy
+	Subpolicies []Subpolicy
+}
+
+var (
+	// DefaultAllowList contains the policies and subpolicies shipped by the
+	// crypto-policies package of Fedora and RHEL 9 and later.
+	DefaultAllowList = AllowList{
+		Policies:    []Policy{Default, Legacy, Future, FIPS},
+		Subpolicies: []Subpolicy{ADSupport, ADSupportLegacy, ECDHEOnly, NoCamellia, NoEnforceEMS, NoSHA1, OSPP, SHA1},
+	}
+
+	// RHEL8AllowList contains the policies and subpolicies shipped by the
+	// crypto-policies package of RHEL 8.
+	RHEL8AllowList = AllowList{
+		Policies:    []Policy{Default, Legacy, Future, FIPS},
+		Subpolicies: []Subpolicy{ADSupport, ECDHEOnly, NoCamellia, NoSHA1, OSPP},
+	}
+)
+
+// Config is a system-wide crypto policy with optional subpolicies, as
+// accepted by update-crypto-policies --set, e.g. "DEFAULT:NO-SHA1".
+type Config struct {
+	Policy      Policy
+	Subpolicies []Subpolicy
+}
+
+func Parse(policy string) (*Config, error) {
+	parts := strings.Split(policy, ":")
+	if parts[0] == "" {
+		return nil, fmt.Errorf("crypto policy %q is invalid: missing policy name", policy)
+	}
+	config := &Config{
+		Policy: Policy(parts[0]),
+	}
+	for _, sp := range parts[1:] {
+		if sp == "" {
+			return nil, fmt.Errorf("crypto policy %q is invalid: empty subpolicy name", policy)
+		}
+		config.Subpolicies = append(config.Subpolicies, Subpolicy(sp))
+	}
+	return config, nil
+}
+
+func (c Config) String() string {
+	parts := []string{string(c.Policy)}
+	for _, sp := range c.Subpolicies {
+		parts = append(parts, string(sp))
+	}
+	return strings.Join(parts, ":")
+}
+
+// Validate checks the policy against the allow list of the distribution and
+// the FIPS mode of the image. Subpolicies that are not in the allow list must
+// be provided as custom modules by one of the files.
+func (c Config) Validate(allowList AllowList, fips bool, files []string) error {
+	if !slices.Contains(allowList.Policies, c.Policy) {
+		return fmt.Errorf("crypto policy %q is not supported, supported policies are: %v", c.Policy, allowList.Policies)
+	}
+
+	for _, sp := range c.Subpolicies {
+		if slices.Contains(allowList.Subpolicies, sp) {
+			continue
+		}
+		module := filepath.Join(ModulesDir, string(sp)+".pmod")
+		if !slices.Contains(files, module) {
+			return fmt.Errorf("crypto subpolicy %q is not supported and no custom module %q is provided", sp, module)
+		}
+	}
+
+	if fips && c.Policy != FIPS {
+		return fmt.Errorf("crypto policy %q conflicts with FIPS mode, only the %q policy can be used with FIPS enabled", c.String(), FIPS)
+	}
+	if !fips && c.Policy == FIPS {
+		return fmt.Errorf("crypto policy %q requires enabling the fips customization", c.String())
+	}
+
+	return nil
+}
+
+// ValidateBP checks the crypto policy of the blueprint customizations, if
+// any, against the allow list of the distribution. Custom subpolicy modules
+// are looked up in the files of the customizations.
+func ValidateBP(c *blueprint.Customizations, allowList AllowList) error {
+	policy := c.GetCryptoPolicy()
+	if policy == "" {
+		return nil
+	}
+	config, err := Parse(policy)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, file := range c.GetFiles() {
+		files = append(files, file.Path)
+	}
+	return config.Validate(allowList, c.GetFIPS(), files)
+}
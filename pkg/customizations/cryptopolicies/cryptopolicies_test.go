@@ -0,0 +1,91 @@
+package cryptopolicies
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+var testAllowList = AllowList{
+	Policies:    []Policy{Default, Future, FIPS},
+	Subpolicies: []Subpolicy{NoSHA1, OSPP},
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse("DEFAULT:NO-SHA1:OSPP")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Policy: Default, Subpolicies: []Subpolicy{NoSHA1, OSPP}}, config)
+	assert.Equal(t, "DEFAULT:NO-SHA1:OSPP", config.String())
+
+	config, err = Parse("FUTURE")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Policy: Future}, config)
+	assert.Equal(t, "FUTURE", config.String())
+
+	_, err = Parse(":NO-SHA1")
+	assert.EqualError(t, err, `crypto policy ":NO-SHA1" is invalid: missing policy name`)
+	_, err = Parse("DEFAULT::NO-SHA1")
+	assert.EqualError(t, err, `crypto policy "DEFAULT::NO-SHA1" is invalid: empty subpolicy name`)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		policy string
+		fips   bool
+		files  []string
+		expErr string
+	}{
+		{policy: "FUTURE"},
+		{policy: "DEFAULT:NO-SHA1"},
+		{policy: "FIPS:OSPP", fips: true},
+		{policy: "DEFAULT:CORP", files: []string{"/etc/crypto-policies/policies/modules/CORP.pmod"}},
+		{
+			policy: "LEGACY",
+			expErr: `crypto policy "LEGACY" is not supported, supported policies are: [DEFAULT FUTURE FIPS]`,
+		},
+		{
+			policy: "DEFAULT:CORP",
+			expErr: `crypto subpolicy "CORP" is not supported and no custom module "/etc/crypto-policies/policies/modules/CORP.pmod" is provided`,
+		},
+		{
+			policy: "FUTURE",
+			fips:   true,
+			expErr: `crypto policy "FUTURE" conflicts with FIPS mode, only the "FIPS" policy can be used with FIPS enabled`,
+		},
+		{
+			policy: "FIPS",
+			expErr: `crypto policy "FIPS" requires enabling the fips customization`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			config, err := Parse(tc.policy)
+			require.NoError(t, err)
+			err = config.Validate(testAllowList, tc.fips, tc.files)
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestValidateBP(t *testing.T) {
+	assert.NoError(t, ValidateBP(nil, DefaultAllowList))
+	assert.NoError(t, ValidateBP(&blueprint.Customizations{CryptoPolicy: common.ToPtr("DEFAULT:SHA1")}, DefaultAllowList))
+	assert.NoError(t, ValidateBP(&blueprint.Customizations{
+		CryptoPolicy: common.ToPtr("DEFAULT:SHA1"),
+		Files:        []blueprint.FileCustomization{{Path: "/etc/crypto-policies/policies/modules/SHA1.pmod"}},
+	}, RHEL8AllowList))
+
+	err := ValidateBP(&blueprint.Customizations{CryptoPolicy: common.ToPtr("DEFAULT:SHA1")}, RHEL8AllowList)
+	assert.EqualError(t, err, `crypto subpolicy "SHA1" is not supported and no custom module "/etc/crypto-policies/policies/modules/SHA1.pmod" is provided`)
+	err = ValidateBP(&blueprint.Customizations{CryptoPolicy: common.ToPtr("FIPS")}, DefaultAllowList)
+	assert.EqualError(t, err, `crypto policy "FIPS" requires enabling the fips customization`)
+}
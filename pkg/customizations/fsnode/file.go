@@ -1,12 +1,23 @@
 package fsnode
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 )
 
+var sha256Regex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
 type File struct {
 	baseFsNode
 	data []byte
+
+	// remote files are downloaded from url when building the image and
+	// their content is verified against the sha256 checksum
+	url    string
+	sha256 string
 }
 
 func (f *File) IsDir() bool {
@@ -20,6 +31,26 @@ func (f *File) Data() []byte {
 	return f.data
 }
 
+// URL returns the location of the content of a remote file, or an empty
+// string if the content of the file is inline.
+func (f *File) URL() string {
+	if f == nil {
+		return ""
+	}
+	return f.url
+}
+
+// SHA256 returns the hex encoded SHA-256 checksum of the content of the file.
+func (f *File) SHA256() string {
+	if f == nil {
+		return ""
+	}
+	if f.url != "" {
+		return f.sha256
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(f.data))
+}
+
 // NewFile creates a new file with the given path, data, mode, user and group.
 // user and group can be either a string (user name/group name), an int64 (UID/GID) or nil.
 func NewFile(path string, mode *os.FileMode, user interface{}, group interface{}, data []byte) (*File, error) {
@@ -34,3 +65,28 @@ func NewFile(path string, mode *os.FileMode, user interface{}, group interface{}
 		data:       data,
 	}, nil
 }
+
+// NewRemoteFile creates a new file with the given path, mode, user and group,
+// with its content downloaded from the given http(s) URL. The content must
+// match the given hex encoded SHA-256 checksum.
+func NewRemoteFile(path string, mode *os.FileMode, user interface{}, group interface{}, fileURL string, checksum string) (*File, error) {
+	baseNode, err := newBaseFsNode(path, mode, user, group)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(fileURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url %q of file %q must be an absolute http or https URL", fileURL, path)
+	}
+
+	if !sha256Regex.MatchString(checksum) {
+		return nil, fmt.Errorf("sha256 checksum %q of file %q must be 64 lowercase hexadecimal characters", checksum, path)
+	}
+
+	return &File{
+		baseFsNode: *baseNode,
+		url:        fileURL,
+		sha256:     checksum,
+	}, nil
+}
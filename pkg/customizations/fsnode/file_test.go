@@ -79,3 +79,29 @@ func TestNewFile(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRemoteFile(t *testing.T) {
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+
+	file, err := NewRemoteFile("/etc/bundle.tar", common.ToPtr(os.FileMode(0600)), "root", nil, "https://example.com/bundle.tar", checksum)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/bundle.tar", file.URL())
+	assert.Equal(t, checksum, file.SHA256())
+	assert.Nil(t, file.Data())
+
+	_, err = NewRemoteFile("/etc/bundle.tar", nil, nil, nil, "file:///tmp/bundle.tar", checksum)
+	assert.EqualError(t, err, `url "file:///tmp/bundle.tar" of file "/etc/bundle.tar" must be an absolute http or https URL`)
+
+	_, err = NewRemoteFile("/etc/bundle.tar", nil, nil, nil, "https://example.com/bundle.tar", "ABC")
+	assert.EqualError(t, err, `sha256 checksum "ABC" of file "/etc/bundle.tar" must be 64 lowercase hexadecimal characters`)
+
+	_, err = NewRemoteFile("etc/bundle.tar", nil, nil, nil, "https://example.com/bundle.tar", checksum)
+	assert.Error(t, err)
+}
+
+func TestFileSHA256Inline(t *testing.T) {
+	file, err := NewFile("/etc/file", nil, nil, nil, []byte("test\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "", file.URL())
+	assert.Equal(t, "f2ca1bb6c7e907d06dafe4687e579fce76b37e4e93b7605022da52e6ccc26fd2", file.SHA256())
+}
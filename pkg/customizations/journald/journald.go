@@ -0,0 +1,95 @@
+package journald
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// DropInDir is the directory journald reads drop-in configuration files from.
+const DropInDir = "/etc/systemd/journald.conf.d"
+
+// DropInFilename is the name of the drop-in file in DropInDir the options
+// are written to.
+const DropInFilename = "50-blueprint.conf"
+
+// Options are the settings of the [Journal] section of journald.conf(5)
+// that can be customized.
+type Options struct {
+	Storage            string
+	SystemMaxUse       string
+	SystemMaxFileSize  string
+	RuntimeMaxUse      string
+	RuntimeMaxFileSize string
+	MaxRetentionSec    string
+	ForwardToSyslog    *bool
+	ForwardToKMsg      *bool
+	ForwardToConsole   *bool
+	ForwardToWall      *bool
+}
+
+func FromBP(bpJournald blueprint.JournaldCustomization) *Options {
+	return &Options{
+		Storage:            bpJournald.Storage,
+		SystemMaxUse:       bpJournald.SystemMaxUse,
+		SystemMaxFileSize:  bpJournald.SystemMaxFileSize,
+		RuntimeMaxUse:      bpJournald.RuntimeMaxUse,
+		RuntimeMaxFileSize: bpJournald.RuntimeMaxFileSize,
+		MaxRetentionSec:    bpJournald.MaxRetentionSec,
+		ForwardToSyslog:    bpJournald.ForwardToSyslog,
+		ForwardToKMsg:      bpJournald.ForwardToKMsg,
+		ForwardToConsole:   bpJournald.ForwardToConsole,
+		ForwardToWall:      bpJournald.ForwardToWall,
+	}
+}
+
+// String returns the options as a journald.conf(5) drop-in. Unset options
+// are omitted, so journald uses its defaults for them.
+func (o Options) String() string {
+	var data strings.Builder
+	data.WriteString("[Journal]\n")
+	set := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&data, "%s=%s\n", key, value)
+		}
+	}
+	setBool := func(key string, value *bool) {
+		if value == nil {
+			return
+		}
+		if *value {
+			set(key, "yes")
+		} else {
+			set(key, "no")
+		}
+	}
+	set("Storage", o.Storage)
+	set("SystemMaxUse", o.SystemMaxUse)
+	set("SystemMaxFileSize", o.SystemMaxFileSize)
+	set("RuntimeMaxUse", o.RuntimeMaxUse)
+	set("RuntimeMaxFileSize", o.RuntimeMaxFileSize)
+	set("MaxRetentionSec", o.MaxRetentionSec)
+	setBool("ForwardToSyslog", o.ForwardToSyslog)
+	setBool("ForwardToKMsg", o.ForwardToKMsg)
+	setBool("ForwardToConsole", o.ForwardToConsole)
+	setBool("ForwardToWall", o.ForwardToWall)
+	return data.String()
+}
+
+// Nodes returns the fsnode.Directory and fsnode.File of the drop-in. The
+// org.osbuild.systemd-journald stage only supports a subset of the options,
+// so the drop-in is written as a file instead.
+func (o Options) Nodes() (*fsnode.Directory, *fsnode.File, error) {
+	dir, err := fsnode.NewDirectory(DropInDir, nil, nil, nil, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := fsnode.NewFile(filepath.Join(DropInDir, DropInFilename), nil, "root", "root", []byte(o.String()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return dir, file, nil
+}
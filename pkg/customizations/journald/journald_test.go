@@ -0,0 +1,53 @@
+package journald
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestFromBP(t *testing.T) {
+	opts := FromBP(blueprint.JournaldCustomization{
+		Storage:         "persistent",
+		SystemMaxUse:    "2G",
+		MaxRetentionSec: "30day",
+		ForwardToSyslog: common.ToPtr(true),
+	})
+	assert.Equal(t, &Options{
+		Storage:         "persistent",
+		SystemMaxUse:    "2G",
+		MaxRetentionSec: "30day",
+		ForwardToSyslog: common.ToPtr(true),
+	}, opts)
+}
+
+func TestNodes(t *testing.T) {
+	opts := Options{
+		Storage:            "persistent",
+		SystemMaxUse:       "2G",
+		SystemMaxFileSize:  "100M",
+		RuntimeMaxUse:      "200M",
+		RuntimeMaxFileSize: "10M",
+		MaxRetentionSec:    "30day",
+		ForwardToSyslog:    common.ToPtr(true),
+		ForwardToWall:      common.ToPtr(false),
+	}
+	dir, file, err := opts.Nodes()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/systemd/journald.conf.d", dir.Path())
+	assert.Equal(t, "/etc/systemd/journald.conf.d/50-blueprint.conf", file.Path())
+	assert.Equal(t, `[Journal]
+Storage=persistent
+SystemMaxUse=2G
+SystemMaxFileSize=100M
+RuntimeMaxUse=200M
+RuntimeMaxFileSize=10M
+MaxRetentionSec=30day
+ForwardToSyslog=yes
+ForwardToWall=no
+`, string(file.Data()))
+}
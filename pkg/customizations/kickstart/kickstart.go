@@ -2,6 +2,7 @@ package kickstart
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/customizations/users"
@@ -76,6 +77,11 @@ func New(customizations *blueprint.Customizations) (*Options, error) {
 }
 
 func (options Options) Validate() error {
+	for _, user := range options.Users {
+		if err := validateUser(user); err != nil {
+			return err
+		}
+	}
 	if options.UserFile != nil {
 		// users, groups, and other kickstart options are not allowed when
 		// users add their own kickstarts
@@ -91,3 +97,28 @@ func (options Options) Validate() error {
 	}
 	return nil
 }
+
+// validateUser checks that the user only uses customizations that the
+// org.osbuild.kickstart stage can write to the kickstart user command.
+func validateUser(user users.User) error {
+	var unsupported []string
+	if len(user.Keys) > 0 {
+		unsupported = append(unsupported, "keys")
+	}
+	if user.PasswordAging != nil {
+		unsupported = append(unsupported, "password_aging")
+	}
+	if user.Locked != nil {
+		unsupported = append(unsupported, "locked")
+	}
+	if user.SubUID != nil {
+		unsupported = append(unsupported, "subuid")
+	}
+	if user.SubGID != nil {
+		unsupported = append(unsupported, "subgid")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("user %q: %s not supported for users created by the installer kickstart", user.Name, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package kickstart
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestNewUsers(t *testing.T) {
+	options, err := New(&blueprint.Customizations{
+		User: []blueprint.UserCustomization{{Name: "alice", Key: common.ToPtr("ssh-ed25519 AAAA alice")}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, options.Users, 1)
+	assert.Equal(t, "alice", options.Users[0].Name)
+}
+
+func TestNewUsersUnsupported(t *testing.T) {
+	tests := map[string]struct {
+		user   blueprint.UserCustomization
+		expErr string
+	}{
+		"keys": {
+			user:   blueprint.UserCustomization{Name: "alice", Keys: []blueprint.AuthorizedKeyCustomization{{Key: "ssh-ed25519 AAAA alice"}}},
+			expErr: `user "alice": keys not supported for users created by the installer kickstart`,
+		},
+		"aging-and-locked": {
+			user:   blueprint.UserCustomization{Name: "alice", PasswordAging: &blueprint.PasswordAgingCustomization{MaxDays: common.ToPtr(90)}, Locked: common.ToPtr(true)},
+			expErr: `user "alice": password_aging, locked not supported for users created by the installer kickstart`,
+		},
+		"subids": {
+			user:   blueprint.UserCustomization{Name: "alice", SubUID: &blueprint.SubIDRangeCustomization{}, SubGID: &blueprint.SubIDRangeCustomization{}},
+			expErr: `user "alice": subuid, subgid not supported for users created by the installer kickstart`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(&blueprint.Customizations{User: []blueprint.UserCustomization{tc.user}})
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
@@ -29,6 +29,10 @@ func (c *Client) makeRequest(u *url.URL) ([]byte, error) {
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("File resolver: unexpected status %q from %s", resp.Status, u)
+	}
+
 	output, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
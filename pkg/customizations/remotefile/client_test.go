@@ -71,3 +71,16 @@ func TestInputSpecValidation(t *testing.T) {
 	}
 
 }
+
+func TestClientResolveHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	url := server.URL + "/missing"
+
+	client := NewClient()
+
+	output, err := client.Resolve(url)
+	assert.EqualError(t, err, fmt.Sprintf("File resolver: unexpected status \"404 Not Found\" from %s", url))
+	assert.Nil(t, output)
+}
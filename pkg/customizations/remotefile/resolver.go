@@ -2,14 +2,20 @@ package remotefile
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/osbuild/images/internal/worker/clienterrors"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
 )
 
 type resolveResult struct {
-	url     string
-	content []byte
-	err     error
+	url      string
+	checksum string
+	content  []byte
+	err      error
 }
 
 // TODO: could make this more generic
@@ -30,12 +36,19 @@ func NewResolver() *Resolver {
 }
 
 func (r *Resolver) Add(url string) {
+	r.AddWithChecksum(url, "")
+}
+
+// AddWithChecksum adds a remote file whose content must match the given hex
+// encoded SHA-256 checksum. A mismatch is reported as a resolution error by
+// Finish.
+func (r *Resolver) AddWithChecksum(url, checksum string) {
 	client := NewClient()
 	r.jobs += 1
 
 	go func() {
 		content, err := client.Resolve(url)
-		r.queue <- resolveResult{url: url, content: content, err: err}
+		r.queue <- resolveResult{url: url, checksum: checksum, content: content, err: err}
 	}()
 }
 
@@ -46,6 +59,13 @@ func (r *Resolver) Finish() []Spec {
 		result := <-r.queue
 		r.jobs -= 1
 
+		if result.err == nil && result.checksum != "" {
+			if actual := fmt.Sprintf("%x", sha256.Sum256(result.content)); actual != result.checksum {
+				result.err = fmt.Errorf("File resolver: checksum mismatch for %s: expected sha256:%s, got sha256:%s", result.url, result.checksum, actual)
+				result.content = nil
+			}
+		}
+
 		var resultError *clienterrors.Error
 		if result.err != nil {
 			resultError = clienterrors.WorkerClientError(
@@ -57,6 +77,7 @@ func (r *Resolver) Finish() []Spec {
 
 		resultItems = append(resultItems, Spec{
 			URL:             result.url,
+			Checksum:        result.checksum,
 			Content:         result.content,
 			ResolutionError: resultError,
 		})
@@ -64,3 +85,24 @@ func (r *Resolver) Finish() []Spec {
 
 	return resultItems
 }
+
+// VerifyFiles downloads the content of remote files and checks it against
+// their checksums, so that a wrong URL or checksum is reported before the
+// image is built. It returns the errors of all the files that failed.
+func VerifyFiles(files []*fsnode.File) error {
+	resolver := NewResolver()
+	for _, file := range files {
+		resolver.AddWithChecksum(file.URL(), file.SHA256())
+	}
+	var errs []string
+	for _, spec := range resolver.Finish() {
+		if spec.ResolutionError != nil {
+			errs = append(errs, spec.ResolutionError.Reason)
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("cannot verify remote files: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -1,10 +1,14 @@
 package remotefile
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
 )
 
 func TestSingleInputResolver(t *testing.T) {
@@ -100,3 +104,69 @@ func TestMultiInvalidInputResolver(t *testing.T) {
 	assert.Contains(t, errs, expectedErrMessageOne)
 	assert.Contains(t, errs, expectedErrMessageTwo)
 }
+
+func TestChecksumResolver(t *testing.T) {
+	server := makeTestServer()
+	url := server.URL + "/key1"
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("key1\n")))
+
+	resolver := NewResolver()
+	resolver.AddWithChecksum(url, checksum)
+
+	resultItems := resolver.Finish()
+	assert.Equal(t, []Spec{
+		{
+			URL:      url,
+			Checksum: checksum,
+			Content:  []byte("key1\n"),
+		},
+	}, resultItems)
+}
+
+func TestChecksumMismatchResolver(t *testing.T) {
+	server := makeTestServer()
+	url := server.URL + "/key2"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("key1\n")))
+	actual := fmt.Sprintf("%x", sha256.Sum256([]byte("key2\n")))
+
+	resolver := NewResolver()
+	resolver.AddWithChecksum(url, checksum)
+
+	resultItems := resolver.Finish()
+	require.Len(t, resultItems, 1)
+	assert.Nil(t, resultItems[0].Content)
+	require.NotNil(t, resultItems[0].ResolutionError)
+	assert.Equal(t,
+		fmt.Sprintf("File resolver: checksum mismatch for %s: expected sha256:%s, got sha256:%s", url, checksum, actual),
+		resultItems[0].ResolutionError.Reason)
+}
+
+func TestVerifyFiles(t *testing.T) {
+	server := makeTestServer()
+	defer server.Close()
+
+	files, err := blueprint.FileCustomizationsToFsNodeFiles([]blueprint.FileCustomization{
+		{
+			Path:   "/etc/key1",
+			URL:    server.URL + "/key1",
+			SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("key1\n"))),
+		},
+	})
+	require.NoError(t, err)
+	assert.NoError(t, VerifyFiles(files))
+
+	// the checksum of the blueprint does not match the content
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte("key1\n")))
+	actual := fmt.Sprintf("%x", sha256.Sum256([]byte("key2\n")))
+	files, err = blueprint.FileCustomizationsToFsNodeFiles([]blueprint.FileCustomization{
+		{
+			Path:   "/etc/key2",
+			URL:    server.URL + "/key2",
+			SHA256: checksum,
+		},
+	})
+	require.NoError(t, err)
+	err = VerifyFiles(files)
+	assert.EqualError(t, err, fmt.Sprintf("cannot verify remote files: File resolver: checksum mismatch for %s/key2: expected sha256:%s, got sha256:%s", server.URL, checksum, actual))
+}
@@ -3,7 +3,10 @@ package remotefile
 import "github.com/osbuild/images/internal/worker/clienterrors"
 
 type Spec struct {
-	URL             string
+	URL string
+	// Checksum is the expected hex encoded SHA-256 checksum of Content, if
+	// one was given when adding the URL to the resolver
+	Checksum        string
 	Content         []byte
 	ResolutionError *clienterrors.Error
 }
@@ -0,0 +1,48 @@
+package selinux
+
+import "github.com/osbuild/images/pkg/blueprint"
+
+// Boolean sets the persistent value of an SELinux policy boolean.
+type Boolean struct {
+	Name  string
+	Value bool
+}
+
+// Port assigns an SELinux type to a network port or range of ports.
+type Port struct {
+	Type     string
+	Protocol string
+	Port     string
+}
+
+// FileContext assigns an SELinux type to the paths matching Target.
+type FileContext struct {
+	Target string
+	Type   string
+}
+
+// Options are local modifications to the SELinux policy of an image.
+type Options struct {
+	Booleans     []Boolean
+	Ports        []Port
+	FileContexts []FileContext
+
+	// Paths of custom policy modules in the image tree
+	Modules []string
+}
+
+func FromBP(bpSELinux blueprint.SELinuxCustomization) *Options {
+	options := &Options{
+		Modules: bpSELinux.Modules,
+	}
+	for _, b := range bpSELinux.Booleans {
+		options.Booleans = append(options.Booleans, Boolean(b))
+	}
+	for _, p := range bpSELinux.Ports {
+		options.Ports = append(options.Ports, Port(p))
+	}
+	for _, fc := range bpSELinux.FileContexts {
+		options.FileContexts = append(options.FileContexts, FileContext(fc))
+	}
+	return options
+}
@@ -0,0 +1,78 @@
+package sudoers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// DropInDir is the directory sudo reads additional configuration from.
+const DropInDir = "/etc/sudoers.d"
+
+// Rule allows a user or the members of a group to run commands as another
+// user.
+type Rule struct {
+	User     string
+	Group    string
+	RunAs    string
+	Commands []string
+	NoPasswd bool
+}
+
+// DropIn is a sudoers file in DropInDir.
+type DropIn struct {
+	Filename string
+	Rules    []Rule
+}
+
+func FromBP(bpSudoers []blueprint.SudoersCustomization) []DropIn {
+	dropins := make([]DropIn, len(bpSudoers))
+	for idx, bpDropIn := range bpSudoers {
+		dropins[idx].Filename = bpDropIn.Filename
+		for _, bpRule := range bpDropIn.Rules {
+			dropins[idx].Rules = append(dropins[idx].Rules, Rule(bpRule))
+		}
+	}
+	return dropins
+}
+
+// String returns the rule as a line in sudoers(5) format, e.g.
+//
+//	%wheel	ALL=(ALL)	NOPASSWD: ALL
+func (r Rule) String() string {
+	who := r.User
+	if r.Group != "" {
+		who = "%" + r.Group
+	}
+
+	runAs := r.RunAs
+	if runAs == "" {
+		runAs = "ALL"
+	}
+
+	commands := "ALL"
+	if len(r.Commands) > 0 {
+		commands = strings.Join(r.Commands, ", ")
+	}
+	if r.NoPasswd {
+		commands = "NOPASSWD: " + commands
+	}
+
+	return fmt.Sprintf("%s\tALL=(%s)\t%s", who, runAs, commands)
+}
+
+// File returns the fsnode.File for the drop-in. sudo requires drop-ins to
+// not be writable, so they are created with mode 0440 like visudo does.
+func (d DropIn) File() (*fsnode.File, error) {
+	var data strings.Builder
+	for _, rule := range d.Rules {
+		data.WriteString(rule.String())
+		data.WriteString("\n")
+	}
+	return fsnode.NewFile(filepath.Join(DropInDir, d.Filename), common.ToPtr(os.FileMode(0440)), "root", "root", []byte(data.String()))
+}
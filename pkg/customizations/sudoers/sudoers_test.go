@@ -0,0 +1,58 @@
+package sudoers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/blueprint"
+)
+
+func TestRuleString(t *testing.T) {
+	tests := []struct {
+		rule     Rule
+		expected string
+	}{
+		{
+			rule:     Rule{User: "alice"},
+			expected: "alice\tALL=(ALL)\tALL",
+		},
+		{
+			rule:     Rule{Group: "wheel", NoPasswd: true},
+			expected: "%wheel\tALL=(ALL)\tNOPASSWD: ALL",
+		},
+		{
+			rule: Rule{
+				User:     "deploy",
+				RunAs:    "root",
+				Commands: []string{"/usr/bin/systemctl restart httpd", "/usr/bin/journalctl"},
+				NoPasswd: true,
+			},
+			expected: "deploy\tALL=(root)\tNOPASSWD: /usr/bin/systemctl restart httpd, /usr/bin/journalctl",
+		},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, tc.rule.String())
+	}
+}
+
+func TestFromBP(t *testing.T) {
+	dropins := FromBP([]blueprint.SudoersCustomization{
+		{
+			Filename: "admins",
+			Rules: []blueprint.SudoersRuleCustomization{
+				{Group: "admins", NoPasswd: true},
+				{User: "alice"},
+			},
+		},
+	})
+	require.Len(t, dropins, 1)
+
+	file, err := dropins[0].File()
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/sudoers.d/admins", file.Path())
+	assert.Equal(t, os.FileMode(0440), *file.Mode())
+	assert.Equal(t, "%admins\tALL=(ALL)\tNOPASSWD: ALL\nalice\tALL=(ALL)\tALL\n", string(file.Data()))
+}
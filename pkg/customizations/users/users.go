@@ -1,6 +1,12 @@
 package users
 
-import "github.com/osbuild/images/pkg/blueprint"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+)
 
 type User struct {
 	Name               string
@@ -14,6 +20,50 @@ type User struct {
 	GID                *int
 	ExpireDate         *int
 	ForcePasswordReset *bool
+	Keys               []SSHKey
+	PasswordAging      *PasswordAging
+	Locked             *bool
+	SubUID             *SubIDRange
+	SubGID             *SubIDRange
+}
+
+// SSHKey is an authorized SSH key with optional authorized_keys options
+type SSHKey struct {
+	Key     string
+	Options []string
+}
+
+// String returns the key as a line for the authorized_keys file.
+func (k SSHKey) String() string {
+	if len(k.Options) == 0 {
+		return k.Key
+	}
+	return strings.Join(k.Options, ",") + " " + k.Key
+}
+
+type PasswordAging struct {
+	MinDays      *int
+	MaxDays      *int
+	WarnDays     *int
+	InactiveDays *int
+}
+
+// SubIDRange is a range of subordinate user or group IDs
+type SubIDRange struct {
+	Start int
+	Count int
+}
+
+// AuthorizedKeys returns all the authorized_keys lines of the user.
+func (u *User) AuthorizedKeys() []string {
+	var keys []string
+	if u.Key != nil {
+		keys = append(keys, *u.Key)
+	}
+	for _, key := range u.Keys {
+		keys = append(keys, key.String())
+	}
+	return keys
 }
 
 type Group struct {
@@ -23,14 +73,98 @@ type Group struct {
 
 func UsersFromBP(userCustomizations []blueprint.UserCustomization) []User {
 	users := make([]User, len(userCustomizations))
-	for idx := range userCustomizations {
-		// currently, they have the same structure, so we convert directly
-		// this will fail to compile as soon as one of the two changes
-		users[idx] = User(userCustomizations[idx])
+	for idx, uc := range userCustomizations {
+		user := User{
+			Name:               uc.Name,
+			Description:        uc.Description,
+			Password:           uc.Password,
+			Key:                uc.Key,
+			Home:               uc.Home,
+			Shell:              uc.Shell,
+			Groups:             uc.Groups,
+			UID:                uc.UID,
+			GID:                uc.GID,
+			ExpireDate:         uc.ExpireDate,
+			ForcePasswordReset: uc.ForcePasswordReset,
+			Locked:             uc.Locked,
+		}
+		for _, key := range uc.Keys {
+			user.Keys = append(user.Keys, SSHKey{Key: key.Key, Options: key.Options})
+		}
+		if pa := uc.PasswordAging; pa != nil {
+			user.PasswordAging = &PasswordAging{
+				MinDays:      pa.MinDays,
+				MaxDays:      pa.MaxDays,
+				WarnDays:     pa.WarnDays,
+				InactiveDays: pa.InactiveDays,
+			}
+		}
+		users[idx] = user
+	}
+
+	subUIDs := allocateSubIDs("subuid", userCustomizations, func(u blueprint.UserCustomization) *blueprint.SubIDRangeCustomization { return u.SubUID })
+	subGIDs := allocateSubIDs("subgid", userCustomizations, func(u blueprint.UserCustomization) *blueprint.SubIDRangeCustomization { return u.SubGID })
+	for idx := range users {
+		users[idx].SubUID = subUIDs[idx]
+		users[idx].SubGID = subGIDs[idx]
 	}
+
 	return users
 }
 
+// allocateSubIDs resolves the subordinate ID ranges of the given users with
+// blueprint.AllocateSubIDRanges. The users must have been checked with
+// blueprint.Customizations.CheckUsers before, so allocating never fails.
+func allocateSubIDs(kind string, userCustomizations []blueprint.UserCustomization, get func(blueprint.UserCustomization) *blueprint.SubIDRangeCustomization) []*SubIDRange {
+	bpRanges, err := blueprint.AllocateSubIDRanges(kind, userCustomizations, get)
+	if err != nil {
+		panic(fmt.Sprintf("allocating %s ranges of unchecked users: %v", kind, err))
+	}
+	ranges := make([]*SubIDRange, len(bpRanges))
+	for idx, r := range bpRanges {
+		if r != nil {
+			ranges[idx] = &SubIDRange{Start: *r.Start, Count: r.Count}
+		}
+	}
+	return ranges
+}
+
+// HashPasswords replaces the plain text passwords of the users with hashes
+// created with the given options. Empty and already hashed passwords are left
+// untouched. If options is nil, the passwords are hashed with the default
+// algorithm when the users stage is created.
+func HashPasswords(users []User, options *crypt.HashOptions) error {
+	if options == nil {
+		return nil
+	}
+	for idx := range users {
+		password := users[idx].Password
+		if password == nil || *password == "" || crypt.PasswordIsCrypted(*password) {
+			continue
+		}
+		hashed, err := crypt.Crypt(*password, *options)
+		if err != nil {
+			return fmt.Errorf("cannot hash password of user %q: %w", users[idx].Name, err)
+		}
+		users[idx].Password = &hashed
+	}
+	return nil
+}
+
+// ValidatePasswordHashes returns an error if the password of one of the
+// users is a hash created with an algorithm that is not supported.
+func ValidatePasswordHashes(users []blueprint.UserCustomization, supported []crypt.Algorithm) error {
+	for _, user := range users {
+		if user.Password == nil {
+			continue
+		}
+		if err := crypt.ValidateHash(*user.Password, supported); err != nil {
+			return fmt.Errorf("invalid password of user %q: %w", user.Name, err)
+		}
+	}
+	return nil
+}
+
 func GroupsFromBP(groupCustomizations []blueprint.GroupCustomization) []Group {
 	groups := make([]Group, len(groupCustomizations))
 	for idx := range groupCustomizations {
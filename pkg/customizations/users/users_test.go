@@ -0,0 +1,95 @@
+package users
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+)
+
+func TestUsersFromBPSubIDAllocation(t *testing.T) {
+	users := UsersFromBP([]blueprint.UserCustomization{
+		{Name: "alice", SubUID: &blueprint.SubIDRangeCustomization{}},
+		{Name: "bob", SubUID: &blueprint.SubIDRangeCustomization{Start: common.ToPtr(100000), Count: 1000}},
+		{Name: "carol", SubUID: &blueprint.SubIDRangeCustomization{Count: 500}, SubGID: &blueprint.SubIDRangeCustomization{Count: 500}},
+		{Name: "dave"},
+	})
+
+	// bob's explicit range is reserved first, alice and carol are allocated
+	// after it in the order they are defined
+	assert.Equal(t, &SubIDRange{Start: 101000, Count: 65536}, users[0].SubUID)
+	assert.Equal(t, &SubIDRange{Start: 100000, Count: 1000}, users[1].SubUID)
+	assert.Equal(t, &SubIDRange{Start: 166536, Count: 500}, users[2].SubUID)
+	assert.Equal(t, &SubIDRange{Start: 100000, Count: 500}, users[2].SubGID)
+	assert.Nil(t, users[3].SubUID)
+	assert.Nil(t, users[3].SubGID)
+}
+
+func TestUsersFromBPSubIDAllocationFillsGaps(t *testing.T) {
+	users := UsersFromBP([]blueprint.UserCustomization{
+		{Name: "alice", SubUID: &blueprint.SubIDRangeCustomization{Start: common.ToPtr(100100), Count: 100}},
+		{Name: "bob", SubUID: &blueprint.SubIDRangeCustomization{Count: 100}},
+		{Name: "carol", SubUID: &blueprint.SubIDRangeCustomization{Count: 100}},
+	})
+
+	assert.Equal(t, &SubIDRange{Start: 100000, Count: 100}, users[1].SubUID)
+	assert.Equal(t, &SubIDRange{Start: 100200, Count: 100}, users[2].SubUID)
+}
+
+func TestAuthorizedKeys(t *testing.T) {
+	user := User{
+		Name: "alice",
+		Key:  common.ToPtr("ssh-rsa AAAA legacy"),
+		Keys: []SSHKey{
+			{Key: "ssh-ed25519 AAAA one"},
+			{Key: "ssh-ed25519 AAAA two", Options: []string{`from="10.0.0.0/8"`, "no-pty"}},
+		},
+	}
+	assert.Equal(t, []string{
+		"ssh-rsa AAAA legacy",
+		"ssh-ed25519 AAAA one",
+		`from="10.0.0.0/8",no-pty ssh-ed25519 AAAA two`,
+	}, user.AuthorizedKeys())
+}
+
+func TestHashPasswords(t *testing.T) {
+	sha512Hash := "$6$1234567890123456$d.pgKQFaiD8bRiExg5NesbGR/3u51YvxeYaQXPzx4C6oSYREw8VoReiuYZjx0V9OhGVTZFqhc6emAxT1RC5BV."
+	bcryptHash := "$2y$04$123465789012345678901uac5A8egfBuZVHMrDZsQzR96IqNBivCy"
+	users := []User{
+		{Name: "alice", Password: common.ToPtr("secret")},
+		{Name: "bob", Password: common.ToPtr(sha512Hash)},
+		{Name: "carol", Password: common.ToPtr("")},
+		{Name: "dave"},
+		{Name: "erin", Password: common.ToPtr(bcryptHash)},
+	}
+
+	// without options the passwords are left for the users stage to hash
+	assert.NoError(t, HashPasswords(users, nil))
+	assert.Equal(t, "secret", *users[0].Password)
+
+	assert.NoError(t, HashPasswords(users, &crypt.HashOptions{Algorithm: crypt.AlgorithmYescrypt}))
+	assert.True(t, strings.HasPrefix(*users[0].Password, "$y$"))
+	assert.Equal(t, sha512Hash, *users[1].Password)
+	assert.Equal(t, "", *users[2].Password)
+	assert.Nil(t, users[3].Password)
+	assert.Equal(t, bcryptHash, *users[4].Password)
+
+	users = []User{{Name: "alice", Password: common.ToPtr("secret")}}
+	assert.EqualError(t, HashPasswords(users, &crypt.HashOptions{Algorithm: crypt.AlgorithmMD5}),
+		`cannot hash password of user "alice": hashing passwords with "md5" is not supported`)
+}
+
+func TestValidatePasswordHashes(t *testing.T) {
+	supported := []crypt.Algorithm{crypt.AlgorithmSHA512}
+	assert.NoError(t, ValidatePasswordHashes([]blueprint.UserCustomization{
+		{Name: "alice", Password: common.ToPtr("secret")},
+		{Name: "bob"},
+	}, supported))
+	assert.EqualError(t, ValidatePasswordHashes([]blueprint.UserCustomization{
+		{Name: "alice", Password: common.ToPtr("$y$j9T$Ed4aKnE1z7KB/AlZsSauA/$ezVO5sgMqYTrywv1Ya21wF178EWoJn5ZQky09zKAdr6")},
+	}, supported), `invalid password of user "alice": password hash algorithm "yescrypt" is not supported, must be one of [sha512]`)
+}
@@ -0,0 +1,47 @@
+package distro
+
+import (
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+	"github.com/osbuild/images/pkg/customizations/cryptopolicies"
+	"github.com/osbuild/images/pkg/customizations/users"
+)
+
+// CheckCustomizations checks the customizations that are validated the same
+// way on all distributions: SELinux, journald, audit, CA certificates,
+// sudoers, users and, if cryptoPolicies is not nil, the crypto policy.
+// Password hashes must use one of the passwordHashAlgorithms.
+func CheckCustomizations(c *blueprint.Customizations, passwordHashAlgorithms []crypt.Algorithm, cryptoPolicies *cryptopolicies.AllowList) error {
+	if _, err := c.GetSELinux(); err != nil {
+		return err
+	}
+
+	if _, err := c.GetJournald(); err != nil {
+		return err
+	}
+
+	if _, err := c.GetAudit(); err != nil {
+		return err
+	}
+
+	if _, err := c.GetCACerts(); err != nil {
+		return err
+	}
+
+	if _, err := c.GetSudoers(); err != nil {
+		return err
+	}
+
+	if err := c.CheckUsers(); err != nil {
+		return err
+	}
+
+	if err := users.ValidatePasswordHashes(c.GetUsers(), passwordHashAlgorithms); err != nil {
+		return err
+	}
+
+	if cryptoPolicies != nil {
+		return cryptopolicies.ValidateBP(c, *cryptoPolicies)
+	}
+	return nil
+}
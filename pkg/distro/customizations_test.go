@@ -0,0 +1,61 @@
+package distro
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/blueprint"
+	"github.com/osbuild/images/pkg/crypt"
+	"github.com/osbuild/images/pkg/customizations/cryptopolicies"
+)
+
+func TestCheckCustomizations(t *testing.T) {
+	algorithms := []crypt.Algorithm{crypt.AlgorithmSHA512}
+
+	tests := map[string]struct {
+		customizations *blueprint.Customizations
+		cryptoPolicies *cryptopolicies.AllowList
+		expErr         string
+	}{
+		"empty": {
+			customizations: &blueprint.Customizations{},
+			cryptoPolicies: &cryptopolicies.DefaultAllowList,
+		},
+		"nil": {
+			cryptoPolicies: &cryptopolicies.DefaultAllowList,
+		},
+		"invalid-legacy-key": {
+			customizations: &blueprint.Customizations{
+				SSHKey: []blueprint.SSHKeyCustomization{{User: "root", Key: "not a key"}},
+			},
+			expErr: `SSH key of user "root" is invalid: must be in the OpenSSH format "<type> <base64 key> [comment]"`,
+		},
+		"invalid-password-aging": {
+			customizations: &blueprint.Customizations{
+				User: []blueprint.UserCustomization{{Name: "alice", PasswordAging: &blueprint.PasswordAgingCustomization{MinDays: common.ToPtr(-1)}}},
+			},
+			expErr: `password aging min_days of user "alice" must not be negative`,
+		},
+		"unsupported-crypto-policy": {
+			customizations: &blueprint.Customizations{CryptoPolicy: common.ToPtr("NOPE")},
+			cryptoPolicies: &cryptopolicies.DefaultAllowList,
+			expErr:         `crypto policy "NOPE" is not supported, supported policies are: [DEFAULT LEGACY FUTURE FIPS]`,
+		},
+		"crypto-policy-not-checked-without-allow-list": {
+			customizations: &blueprint.Customizations{CryptoPolicy: common.ToPtr("NOPE")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckCustomizations(tc.customizations, algorithms, tc.cryptoPolicies)
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expErr)
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/proxy"
 	"github.com/osbuild/images/pkg/reporegistry"
 )
 
@@ -302,6 +303,7 @@ func TestDistro_OSTreeOptions(t *testing.T, d distro.Distro) {
 				ostreeOptions := ostree.ImageOptions{
 					ImageRef: "test/x86_64/01",
 					URL:      "https://example.com/repo",
+					Proxy:    &proxy.Config{URL: "http://proxy.example.com:3128"},
 				}
 				options := distro.ImageOptions{OSTree: &ostreeOptions}
 				m, _, err := imgType.Manifest(bp, options, nil, 0)
@@ -312,6 +314,7 @@ func TestDistro_OSTreeOptions(t *testing.T, d distro.Distro) {
 					for _, commit := range commits {
 						assert.Equal(options.OSTree.URL, commit.URL, "url does not match expected for image type %q\n", typeName)
 						assert.Equal(options.OSTree.ImageRef, commit.Ref, "ref does not match expected for image type %q\n", typeName)
+						assert.Equal(options.OSTree.Proxy, commit.Proxy, "proxy does not match expected for image type %q\n", typeName)
 						nrefs++
 					}
 				}
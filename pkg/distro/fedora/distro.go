@@ -9,6 +9,7 @@ import (
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/internal/environment"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/distro"
@@ -419,6 +420,15 @@ var (
 		exports:             []string{"xz"},
 		basePartitionTables: minimalrawPartitionTables,
 	}
+
+	// password hash algorithms supported by the crypt(3) implementation of
+	// the distribution
+	passwordHashAlgorithms = []crypt.Algorithm{
+		crypt.AlgorithmYescrypt,
+		crypt.AlgorithmSHA512,
+		crypt.AlgorithmSHA256,
+		crypt.AlgorithmBcrypt,
+	}
 )
 
 type distribution struct {
@@ -438,6 +448,7 @@ var defaultDistroImageConfig = &distro.ImageConfig{
 	Timezone:               common.ToPtr("UTC"),
 	Locale:                 common.ToPtr("en_US"),
 	DefaultOSCAPDatastream: common.ToPtr(oscap.DefaultFedoraDatastream()),
+	PasswordHash:           &crypt.HashOptions{Algorithm: crypt.AlgorithmYescrypt},
 }
 
 func getISOLabelFunc(variant string) isoLabelFunc {
@@ -9,12 +9,16 @@ import (
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
 	"github.com/osbuild/images/pkg/customizations/anaconda"
+	"github.com/osbuild/images/pkg/customizations/audit"
 	"github.com/osbuild/images/pkg/customizations/bootc"
 	"github.com/osbuild/images/pkg/customizations/fdo"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/ignition"
+	"github.com/osbuild/images/pkg/customizations/journald"
 	"github.com/osbuild/images/pkg/customizations/kickstart"
 	"github.com/osbuild/images/pkg/customizations/oscap"
+	"github.com/osbuild/images/pkg/customizations/selinux"
+	"github.com/osbuild/images/pkg/customizations/sudoers"
 	"github.com/osbuild/images/pkg/customizations/users"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/image"
@@ -50,6 +54,7 @@ func osCustomizations(
 	}
 
 	osc.FIPS = c.GetFIPS()
+	osc.CryptoPolicy = c.GetCryptoPolicy()
 
 	osc.ExtraBasePackages = osPackageSet.Include
 	osc.ExcludeBasePackages = osPackageSet.Exclude
@@ -71,6 +76,9 @@ func osCustomizations(
 		// add them via kickstart instead
 		osc.Groups = users.GroupsFromBP(c.GetGroups())
 		osc.Users = users.UsersFromBP(c.GetUsers())
+		if err := users.HashPasswords(osc.Users, imageConfig.PasswordHash); err != nil {
+			return manifest.OSCustomizations{}, err
+		}
 	}
 
 	osc.EnabledServices = imageConfig.EnabledServices
@@ -130,7 +138,17 @@ func osCustomizations(
 		osc.SElinux = "targeted"
 	}
 
-	var err error
+	selinuxConfig, err := c.GetSELinux()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if selinuxConfig != nil {
+		if osc.SElinux == "" {
+			return manifest.OSCustomizations{}, fmt.Errorf("SELinux customizations are not supported for image types without SELinux")
+		}
+		osc.SELinuxPolicy = selinux.FromBP(*selinuxConfig)
+	}
+
 	osc.Directories, err = blueprint.DirectoryCustomizationsToFsNodeDirectories(c.GetDirectories())
 	if err != nil {
 		// In theory this should never happen, because the blueprint directory customizations
@@ -183,6 +201,36 @@ func osCustomizations(
 		osc.YUMRepos = append(osc.YUMRepos, osbuild.NewYumReposStageOptions(filename, repos))
 	}
 
+	journaldConfig, err := c.GetJournald()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if journaldConfig != nil {
+		osc.Journald = journald.FromBP(*journaldConfig)
+	}
+
+	auditConfig, err := c.GetAudit()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if auditConfig != nil {
+		osc.AuditRules = audit.FromBP(*auditConfig)
+	}
+
+	sudoersConfig, err := c.GetSudoers()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	osc.Sudoers = sudoers.FromBP(sudoersConfig)
+
+	caConfig, err := c.GetCACerts()
+	if err != nil {
+		return manifest.OSCustomizations{}, err
+	}
+	if caConfig != nil {
+		osc.CACerts = caConfig.PEMCerts
+	}
+
 	if oscapConfig := c.GetOpenSCAP(); oscapConfig != nil {
 		if t.rpmOstree {
 			panic("unexpected oscap options for ostree image type")
@@ -263,6 +311,9 @@ func ostreeDeploymentCustomizations(
 	deploymentConf.FIPS = c.GetFIPS()
 
 	deploymentConf.Users = users.UsersFromBP(c.GetUsers())
+	if err := users.HashPasswords(deploymentConf.Users, imageConfig.PasswordHash); err != nil {
+		return manifest.OSTreeDeploymentCustomizations{}, err
+	}
 	deploymentConf.Groups = users.GroupsFromBP(c.GetGroups())
 
 	var err error
@@ -415,6 +466,9 @@ func imageInstallerImage(workload workload.Workload,
 	if err != nil {
 		return nil, err
 	}
+	if err := users.HashPasswords(img.Kickstart.Users, t.getDefaultImageConfig().PasswordHash); err != nil {
+		return nil, err
+	}
 	img.Kickstart.Language = &img.OSCustomizations.Language
 	img.Kickstart.Keyboard = img.OSCustomizations.Keyboard
 	img.Kickstart.Timezone = &img.OSCustomizations.Timezone
@@ -627,6 +681,9 @@ func iotInstallerImage(workload workload.Workload,
 	if err != nil {
 		return nil, err
 	}
+	if err := users.HashPasswords(img.Kickstart.Users, t.getDefaultImageConfig().PasswordHash); err != nil {
+		return nil, err
+	}
 	img.Kickstart.OSTree = &kickstart.OSTree{
 		OSName: "fedora-iot",
 		Remote: "fedora-iot",
@@ -812,9 +869,10 @@ func makeOSTreeParentCommit(options *ostree.ImageOptions, defaultRef string) (*o
 
 	}
 	parentCommit = &ostree.SourceSpec{
-		URL:  options.URL,
-		Ref:  parentRef,
-		RHSM: options.RHSM,
+		URL:   options.URL,
+		Ref:   parentRef,
+		RHSM:  options.RHSM,
+		Proxy: options.Proxy,
 	}
 	return parentCommit, commitRef
 }
@@ -834,9 +892,10 @@ func makeOSTreePayloadCommit(options *ostree.ImageOptions, defaultRef string) (o
 	}
 
 	return ostree.SourceSpec{
-		URL:  options.URL,
-		Ref:  commitRef,
-		RHSM: options.RHSM,
+		URL:   options.URL,
+		Ref:   commitRef,
+		RHSM:  options.RHSM,
+		Proxy: options.Proxy,
 	}, nil
 }
 
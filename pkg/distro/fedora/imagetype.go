@@ -12,6 +12,7 @@ import (
 	"github.com/osbuild/images/internal/workload"
 	"github.com/osbuild/images/pkg/blueprint"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/cryptopolicies"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/distro"
@@ -195,6 +196,25 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 		return nil, nil, err
 	}
 
+	localPackages, err := bp.GetLocalPackages()
+	if err != nil {
+		return nil, nil, err
+	}
+	excludes, err := bp.GetExcludes()
+	if err != nil {
+		return nil, nil, err
+	}
+	locks, err := bp.GetPackageLocks()
+	if err != nil {
+		return nil, nil, err
+	}
+	// modularity was retired in Fedora 39
+	if modules, err := bp.GetEnabledModules(); err != nil {
+		return nil, nil, err
+	} else if len(modules) > 0 {
+		return nil, nil, fmt.Errorf("DNF module streams are not supported on %s", t.Arch().Distro().Name())
+	}
+
 	// merge package sets that appear in the image type with the package sets
 	// of the same name from the distro and arch
 	staticPackageSets := make(map[string]rpmmd.PackageSet)
@@ -228,7 +248,14 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 			BaseWorkload: workload.BaseWorkload{
 				Repos: payloadRepos,
 			},
-			Packages: bp.GetPackagesEx(false),
+			Packages:        bp.GetPackagesEx(false),
+			ExcludePackages: excludes,
+		}
+		for _, lock := range locks {
+			cw.PackageLocks = append(cw.PackageLocks, rpmmd.PackageLock{Name: lock.Name, EVR: lock.EVR})
+		}
+		for _, pkg := range localPackages {
+			cw.LocalPackages = append(cw.LocalPackages, rpmmd.LocalPackage{Path: pkg.Path, Checksum: pkg.Checksum})
 		}
 		if services := bp.Customizations.GetServices(); services != nil {
 			cw.Services = services.Enabled
@@ -240,10 +267,11 @@ func (t *imageType) Manifest(bp *blueprint.Blueprint,
 	containerSources := make([]container.SourceSpec, len(bp.Containers))
 	for idx, cont := range bp.Containers {
 		containerSources[idx] = container.SourceSpec{
-			Source:    cont.Source,
-			Name:      cont.Name,
-			TLSVerify: cont.TLSVerify,
-			Local:     cont.LocalStorage,
+			Source:           cont.Source,
+			Name:             cont.Name,
+			TLSVerify:        cont.TLSVerify,
+			Local:            cont.LocalStorage,
+			RequireSignature: cont.RequireSignature,
 		}
 	}
 
@@ -414,6 +442,10 @@ func (t *imageType) checkOptions(bp *blueprint.Blueprint, options distro.ImageOp
 		return nil, err
 	}
 
+	if err := distro.CheckCustomizations(customizations, passwordHashAlgorithms, &cryptopolicies.DefaultAllowList); err != nil {
+		return nil, err
+	}
+
 	if customizations.GetFIPS() && !common.IsBuildHostFIPSEnabled() {
 		w := fmt.Sprintln(common.FIPSEnabledImageWarning)
 		return []string{w}, nil
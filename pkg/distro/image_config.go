@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/customizations/shell"
 	"github.com/osbuild/images/pkg/customizations/subscription"
@@ -35,6 +36,9 @@ type ImageConfig struct {
 	// Disable documentation
 	ExcludeDocs *bool
 
+	// Algorithm and rounds used to hash plain text user passwords
+	PasswordHash *crypt.HashOptions
+
 	ShellInit []shell.InitFile
 
 	// for RHSM configuration, we need to potentially distinguish the case
@@ -249,6 +249,27 @@ func (t *ImageType) Manifest(bp *blueprint.Blueprint,
 		return nil, nil, err
 	}
 
+	localPackages, err := bp.GetLocalPackages()
+	if err != nil {
+		return nil, nil, err
+	}
+	excludes, err := bp.GetExcludes()
+	if err != nil {
+		return nil, nil, err
+	}
+	locks, err := bp.GetPackageLocks()
+	if err != nil {
+		return nil, nil, err
+	}
+	modules, err := bp.GetEnabledModules()
+	if err != nil {
+		return nil, nil, err
+	}
+	// modularity only exists in RHEL 8 and 9
+	if len(modules) > 0 && !slices.Contains([]string{"8", "9"}, t.Arch().Distro().Releasever()) {
+		return nil, nil, fmt.Errorf("DNF module streams are not supported on %s", t.Arch().Distro().Name())
+	}
+
 	// merge package sets that appear in the image type with the package sets
 	// of the same name from the distro and arch
 	staticPackageSets := make(map[string]rpmmd.PackageSet)
@@ -279,7 +300,17 @@ func (t *ImageType) Manifest(bp *blueprint.Blueprint,
 			BaseWorkload: workload.BaseWorkload{
 				Repos: payloadRepos,
 			},
-			Packages: bp.GetPackagesEx(false),
+			Packages:        bp.GetPackagesEx(false),
+			ExcludePackages: excludes,
+		}
+		for _, lock := range locks {
+			cw.PackageLocks = append(cw.PackageLocks, rpmmd.PackageLock{Name: lock.Name, EVR: lock.EVR})
+		}
+		for _, pkg := range localPackages {
+			cw.LocalPackages = append(cw.LocalPackages, rpmmd.LocalPackage{Path: pkg.Path, Checksum: pkg.Checksum})
+		}
+		for _, module := range modules {
+			cw.EnabledModules = append(cw.EnabledModules, rpmmd.ModuleStream{Name: module.Name, Stream: module.Stream, Profile: module.Profile})
 		}
 		if services := bp.Customizations.GetServices(); services != nil {
 			cw.Services = services.Enabled
@@ -291,10 +322,11 @@ func (t *ImageType) Manifest(bp *blueprint.Blueprint,
 	containerSources := make([]container.SourceSpec, len(bp.Containers))
 	for idx, cont := range bp.Containers {
 		containerSources[idx] = container.SourceSpec{
-			Source:    cont.Source,
-			Name:      cont.Name,
-			TLSVerify: cont.TLSVerify,
-			Local:     cont.LocalStorage,
+			Source:           cont.Source,
+			Name:             cont.Name,
+			TLSVerify:        cont.TLSVerify,
+			Local:            cont.LocalStorage,
+			RequireSignature: cont.RequireSignature,
 		}
 	}
 
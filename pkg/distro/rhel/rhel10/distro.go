@@ -6,6 +6,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/rhel"
@@ -35,6 +36,15 @@ var (
 		oscap.Stig,
 		oscap.StigGui,
 	}
+
+	// password hash algorithms supported by the crypt(3) implementation of
+	// the distribution
+	passwordHashAlgorithms = []crypt.Algorithm{
+		crypt.AlgorithmYescrypt,
+		crypt.AlgorithmSHA512,
+		crypt.AlgorithmSHA256,
+		crypt.AlgorithmBcrypt,
+	}
 )
 
 func distroISOLabelFunc(t *rhel.ImageType) string {
@@ -65,6 +75,7 @@ func defaultDistroImageConfig(d *rhel.Distribution) *distro.ImageConfig {
 				},
 			},
 		},
+		PasswordHash: &crypt.HashOptions{Algorithm: crypt.AlgorithmYescrypt},
 	}
 }
 
@@ -5,12 +5,20 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/rhel"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
 )
 
+// password hash algorithms supported by the crypt(3) implementation of the
+// distribution
+var passwordHashAlgorithms = []crypt.Algorithm{
+	crypt.AlgorithmSHA512,
+	crypt.AlgorithmSHA256,
+}
+
 // RHEL-based OS image configuration defaults
 func defaultDistroImageConfig(d *rhel.Distribution) *distro.ImageConfig {
 	return &distro.ImageConfig{
@@ -33,6 +41,7 @@ func defaultDistroImageConfig(d *rhel.Distribution) *distro.ImageConfig {
 		},
 		KernelOptionsBootloader: common.ToPtr(true),
 		NoBLS:                   common.ToPtr(true), // RHEL 7 grub does not support BLS
+		PasswordHash:            &crypt.HashOptions{Algorithm: crypt.AlgorithmSHA512},
 	}
 }
 
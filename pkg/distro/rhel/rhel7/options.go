@@ -59,5 +59,13 @@ func checkOptions(t *rhel.ImageType, bp *blueprint.Blueprint, options distro.Ima
 		return warnings, err
 	}
 
+	if err := distro.CheckCustomizations(customizations, passwordHashAlgorithms, nil); err != nil {
+		return warnings, err
+	}
+
+	if customizations.GetCryptoPolicy() != "" {
+		return warnings, fmt.Errorf("crypto policy customizations are not supported on %s", t.Arch().Distro().Name())
+	}
+
 	return warnings, nil
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/rhel"
@@ -33,6 +34,14 @@ var (
 		oscap.Stig,
 		oscap.StigGui,
 	}
+
+	// password hash algorithms supported by the crypt(3) implementation of
+	// the distribution
+	passwordHashAlgorithms = []crypt.Algorithm{
+		crypt.AlgorithmSHA512,
+		crypt.AlgorithmSHA256,
+		crypt.AlgorithmBcrypt,
+	}
 )
 
 // RHEL-based OS image configuration defaults
@@ -54,6 +63,7 @@ func defaultDistroImageConfig(d *rhel.Distribution) *distro.ImageConfig {
 		},
 		KernelOptionsBootloader: common.ToPtr(true),
 		DefaultOSCAPDatastream:  common.ToPtr(oscap.DefaultRHEL8Datastream(d.IsRHEL())),
+		PasswordHash:            &crypt.HashOptions{Algorithm: crypt.AlgorithmSHA512},
 	}
 }
 
@@ -6,6 +6,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/crypt"
 	"github.com/osbuild/images/pkg/customizations/oscap"
 	"github.com/osbuild/images/pkg/distro"
 	"github.com/osbuild/images/pkg/distro/rhel"
@@ -38,6 +39,15 @@ var (
 		oscap.Stig,
 		oscap.StigGui,
 	}
+
+	// password hash algorithms supported by the crypt(3) implementation of
+	// the distribution
+	passwordHashAlgorithms = []crypt.Algorithm{
+		crypt.AlgorithmYescrypt,
+		crypt.AlgorithmSHA512,
+		crypt.AlgorithmSHA256,
+		crypt.AlgorithmBcrypt,
+	}
 )
 
 func distroISOLabelFunc(t *rhel.ImageType) string {
@@ -69,6 +79,7 @@ func defaultDistroImageConfig(d *rhel.Distribution) *distro.ImageConfig {
 			},
 		},
 		DefaultOSCAPDatastream: common.ToPtr(oscap.DefaultRHEL9Datastream(d.IsRHEL())),
+		PasswordHash:           &crypt.HashOptions{Algorithm: crypt.AlgorithmYescrypt},
 	}
 }
 
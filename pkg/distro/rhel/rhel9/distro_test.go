@@ -839,3 +839,90 @@ func TestDistro_CustomUsrPartitionNotLargeEnough(t *testing.T) {
 		}
 	}
 }
+
+func TestDistro_CryptoPolicy(t *testing.T) {
+	r9distro := rhelFamilyDistros[0].distro
+	arch, err := r9distro.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		policy string
+		fips   bool
+		expErr string
+	}{
+		{policy: "FUTURE"},
+		{policy: "DEFAULT:NO-SHA1"},
+		{policy: "FIPS:OSPP", fips: true},
+		{
+			policy: "NEXT",
+			expErr: `crypto policy "NEXT" is not supported, supported policies are: [DEFAULT LEGACY FUTURE FIPS]`,
+		},
+		{
+			policy: "DEFAULT:FOO",
+			expErr: `crypto subpolicy "FOO" is not supported and no custom module "/etc/crypto-policies/policies/modules/FOO.pmod" is provided`,
+		},
+		{
+			policy: "FUTURE",
+			fips:   true,
+			expErr: `crypto policy "FUTURE" conflicts with FIPS mode, only the "FIPS" policy can be used with FIPS enabled`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.policy, func(t *testing.T) {
+			bp := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					CryptoPolicy: &tc.policy,
+					FIPS:         &tc.fips,
+				},
+			}
+			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestDistro_PasswordHash(t *testing.T) {
+	r9distro := rhelFamilyDistros[0].distro
+	arch, err := r9distro.GetArch("x86_64")
+	require.NoError(t, err)
+	imgType, err := arch.GetImageType("qcow2")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		expErr   string
+	}{
+		{name: "plain", password: "secret"},
+		{name: "yescrypt", password: "$y$j9T$Ed4aKnE1z7KB/AlZsSauA/$ezVO5sgMqYTrywv1Ya21wF178EWoJn5ZQky09zKAdr6"},
+		{name: "sha512", password: "$6$1234567890123456$d.pgKQFaiD8bRiExg5NesbGR/3u51YvxeYaQXPzx4C6oSYREw8VoReiuYZjx0V9OhGVTZFqhc6emAxT1RC5BV."},
+		{
+			name:     "md5",
+			password: "$1$12345678$abcdefghijklmnopqrstuv",
+			expErr:   `invalid password of user "alice": password hash algorithm "md5" is not supported, must be one of [yescrypt sha512 sha256 bcrypt]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			bp := blueprint.Blueprint{
+				Customizations: &blueprint.Customizations{
+					User: []blueprint.UserCustomization{{Name: "alice", Password: &tc.password}},
+				},
+			}
+			_, _, err := imgType.Manifest(&bp, distro.ImageOptions{}, nil, 0)
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expErr)
+			}
+		})
+	}
+}
@@ -270,6 +270,7 @@ func (t *TestImageType) Manifest(b *blueprint.Blueprint, options distro.ImageOpt
 			// copy any other options that might be specified
 			ostreeSource.URL = options.OSTree.URL
 			ostreeSource.RHSM = options.OSTree.RHSM
+			ostreeSource.Proxy = options.OSTree.Proxy
 		}
 		ostreeSources = []ostree.SourceSpec{ostreeSource}
 	}
@@ -0,0 +1,368 @@
+package dnfjson
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// an entry of the provides, requires, recommends or supplements of a package
+// in the primary metadata of a repository
+type primaryEntry struct {
+	Name string `xml:"name,attr"`
+}
+
+type primaryPackage struct {
+	Name    string `xml:"name"`
+	Arch    string `xml:"arch"`
+	Version struct {
+		Epoch string `xml:"epoch,attr"`
+		Ver   string `xml:"ver,attr"`
+		Rel   string `xml:"rel,attr"`
+	} `xml:"version"`
+	Provides    []primaryEntry `xml:"format>provides>entry"`
+	Requires    []primaryEntry `xml:"format>requires>entry"`
+	Recommends  []primaryEntry `xml:"format>recommends>entry"`
+	Supplements []primaryEntry `xml:"format>supplements>entry"`
+	// only the files in bin directories and /etc are listed in the primary
+	// metadata, which are the ones packages can depend on
+	Files []string `xml:"format>file"`
+}
+
+func (p *primaryPackage) nevra() string {
+	epoch := p.Version.Epoch
+	if epoch == "" {
+		epoch = "0"
+	}
+	return fmt.Sprintf("%s-%s:%s-%s.%s", p.Name, epoch, p.Version.Ver, p.Version.Rel, p.Arch)
+}
+
+func (p PackageSpec) nevra() string {
+	return fmt.Sprintf("%s-%d:%s-%s.%s", p.Name, p.Epoch, p.Version, p.Release, p.Arch)
+}
+
+type compsXML struct {
+	Groups []struct {
+		ID       string   `xml:"id"`
+		Packages []string `xml:"packagelist>packagereq"`
+	} `xml:"group"`
+	Environments []struct {
+		ID     string   `xml:"id"`
+		Groups []string `xml:"grouplist>groupid"`
+	} `xml:"environment"`
+}
+
+// parsePrimary returns the packages of the primary metadata of a repository
+// that are selected by the given NEVRAs.
+func parsePrimary(r io.Reader, nevras map[string]bool) ([]primaryPackage, error) {
+	var pkgs []primaryPackage
+	dec := xml.NewDecoder(r)
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return pkgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "package" {
+			continue
+		}
+		var pkg primaryPackage
+		if err := dec.DecodeElement(&pkg, &start); err != nil {
+			return nil, err
+		}
+		if nevras[pkg.nevra()] {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+}
+
+// richDependencyNames returns the names of the packages, provides or files a
+// dependency consists of. Rich dependencies, e.g. "(foo >= 1.0 if bar)", are
+// split into the names that are required, skipping versions, operators and
+// conditions.
+func richDependencyNames(dep string) []string {
+	if !strings.HasPrefix(dep, "(") {
+		return []string{dep}
+	}
+
+	var names []string
+	// whether the terms at each level of nesting are conditions
+	condition := []bool{false}
+	skipVersion := false
+	for _, field := range strings.Fields(dep) {
+		for strings.HasPrefix(field, "(") {
+			condition = append(condition, condition[len(condition)-1])
+			field = field[1:]
+		}
+		// closing parentheses that are not part of a name, e.g. of
+		// "foo(x86-64))"
+		closing := 0
+		for strings.HasSuffix(field, ")") && strings.Count(field, "(") < strings.Count(field, ")") {
+			field = field[:len(field)-1]
+			closing++
+		}
+
+		top := len(condition) - 1
+		switch field {
+		case "":
+		case "=", "==", "<", ">", "<=", ">=":
+			skipVersion = true
+		case "if", "unless", "without":
+			condition[top] = true
+		case "else":
+			condition[top] = false
+		case "and", "or", "with":
+		default:
+			if skipVersion {
+				skipVersion = false
+			} else if !condition[top] {
+				names = append(names, field)
+			}
+		}
+
+		for ; closing > 0 && len(condition) > 1; closing-- {
+			condition = condition[:len(condition)-1]
+		}
+	}
+	return names
+}
+
+// matchesSpec returns true if the package is selected by a package spec of a
+// package set, i.e. by its name or NEVRA, optionally with a glob pattern.
+func matchesSpec(pkg PackageSpec, spec string) bool {
+	forms := []string{
+		pkg.Name,
+		pkg.Name + "." + pkg.Arch,
+		fmt.Sprintf("%s-%s-%s", pkg.Name, pkg.Version, pkg.Release),
+		fmt.Sprintf("%s-%s-%s.%s", pkg.Name, pkg.Version, pkg.Release, pkg.Arch),
+		fmt.Sprintf("%s-%d:%s-%s.%s", pkg.Name, pkg.Epoch, pkg.Version, pkg.Release, pkg.Arch),
+	}
+	for _, form := range forms {
+		if ok, _ := path.Match(spec, form); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// openRepoData opens the first of the given types of metadata of the
+// repository that it has. The metadata that dnf downloaded to the cache of the
+// solver is used if possible, otherwise it is fetched from the repository.
+// It returns nil if the repository has none of the types of metadata.
+func (s *Solver) openRepoData(repo repoConfig, dataTypes ...string) (io.ReadCloser, error) {
+	if cached := s.cachedRepoDir(repo); cached != "" {
+		if client, err := repodata.NewClient(repodata.Repository{Name: repo.Name, BaseURLs: []string{"file://" + cached}}); err == nil {
+			if f, err := openData(client, dataTypes); err == nil {
+				return f, nil
+			}
+		}
+	}
+
+	client, err := repodata.NewClient(repo.repodata(s.proxy))
+	if err != nil {
+		return nil, err
+	}
+	return openData(client, dataTypes)
+}
+
+func openData(client *repodata.Client, dataTypes []string) (io.ReadCloser, error) {
+	repomd, err := client.Repomd()
+	if err != nil {
+		return nil, err
+	}
+	for _, dataType := range dataTypes {
+		if data, ok := repomd.Find(dataType); ok {
+			return client.OpenData(data)
+		}
+	}
+	return nil, nil
+}
+
+// cachedRepoDir returns the newest directory of the repository in the dnf
+// cache of the solver, or an empty string if there is none. dnf names the
+// directories after the ID of the repository followed by a hash of its URL.
+func (s *Solver) cachedRepoDir(repo repoConfig) string {
+	matches, _ := filepath.Glob(filepath.Join(s.GetCacheDir(), repo.ID+"-*", "repodata", "repomd.xml"))
+	var newest string
+	var newestInfo os.FileInfo
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		if newestInfo == nil || info.ModTime().After(newestInfo.ModTime()) {
+			newest, newestInfo = match, info
+		}
+	}
+	if newest == "" {
+		return ""
+	}
+	return filepath.Dir(filepath.Dir(newest))
+}
+
+// dependencyGraph derives the dependency graph of a depsolved package set
+// chain from the primary and comps metadata of the repositories of the
+// packages. osbuild-depsolve-dnf does not return the edges of the
+// transaction, so the graph is an approximation of the decisions of dnf:
+//   - requirements are matched by the names of the provides and files of the
+//     packages in the transaction, ignoring versions, and a requirement that
+//     more than one installed package provides is an edge to each of them,
+//     even if dnf only needed one
+//   - the conditions of rich dependencies are not evaluated, the packages
+//     they name are dependencies whether the condition holds or not
+//   - packages that dnf installed for reasons the metadata does not show,
+//     e.g. to replace an obsoleted package, have no edges
+//
+// This is enough to explain why a package of a transaction that dnf already
+// resolved is installed, but not to tell which edges dnf followed.
+func (s *Solver) dependencyGraph(pkgSets []rpmmd.PackageSet, result *depsolveResult, repos []repoConfig) (rpmmd.DependencyGraph, error) {
+	nevras := make(map[string]map[string]bool)
+	for _, pkg := range result.Packages {
+		if nevras[pkg.RepoID] == nil {
+			nevras[pkg.RepoID] = make(map[string]bool)
+		}
+		nevras[pkg.RepoID][pkg.nevra()] = true
+	}
+
+	metadata := make(map[string]primaryPackage)
+	groups := make(map[string][]string)
+	for _, repo := range repos {
+		if nevras[repo.ID] == nil {
+			continue
+		}
+		f, err := s.openRepoData(repo, "primary")
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the metadata of repository %q: %w", repo.Name, err)
+		}
+		if f == nil {
+			return nil, fmt.Errorf("repository %q has no primary metadata", repo.Name)
+		}
+		pkgs, err := parsePrimary(f, nevras[repo.ID])
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the primary metadata of repository %q: %w", repo.Name, err)
+		}
+		for _, pkg := range pkgs {
+			metadata[pkg.nevra()] = pkg
+		}
+
+		f, err = s.openRepoData(repo, "group", "group_gz")
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the comps metadata of repository %q: %w", repo.Name, err)
+		}
+		if f == nil {
+			continue
+		}
+		var comps compsXML
+		err = xml.NewDecoder(f).Decode(&comps)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse the comps metadata of repository %q: %w", repo.Name, err)
+		}
+		for _, group := range comps.Groups {
+			groups["@"+group.ID] = append(groups["@"+group.ID], group.Packages...)
+		}
+		for _, env := range comps.Environments {
+			for _, group := range env.Groups {
+				groups["@^"+env.ID] = append(groups["@^"+env.ID], "@"+group)
+			}
+		}
+	}
+
+	installed := make(map[string]bool)
+	providers := make(map[string][]string)
+	for _, pkg := range result.Packages {
+		info, ok := metadata[pkg.nevra()]
+		if !ok {
+			return nil, fmt.Errorf("package %s not found in the metadata of its repository", pkg.nevra())
+		}
+		installed[pkg.Name] = true
+		for _, provide := range info.Provides {
+			providers[provide.Name] = append(providers[provide.Name], pkg.Name)
+		}
+		for _, file := range info.Files {
+			providers[file] = append(providers[file], pkg.Name)
+		}
+	}
+
+	var graph rpmmd.DependencyGraph
+	seen := make(map[rpmmd.PackageDependency]bool)
+	add := func(dep rpmmd.PackageDependency) {
+		if dep.From != dep.To && !seen[dep] {
+			seen[dep] = true
+			graph = append(graph, dep)
+		}
+	}
+
+	// groups are expanded once, members that are groups themselves are
+	// followed as well
+	var addGroup func(group string)
+	addGroup = func(group string) {
+		for _, member := range groups[group] {
+			if strings.HasPrefix(member, "@") || installed[member] {
+				dep := rpmmd.PackageDependency{From: group, To: member, Type: rpmmd.DependencyGroup}
+				if !seen[dep] {
+					add(dep)
+					addGroup(member)
+				}
+			}
+		}
+	}
+	for _, pkgSet := range pkgSets {
+		for _, spec := range pkgSet.Include {
+			if strings.HasPrefix(spec, "@") {
+				add(rpmmd.PackageDependency{To: spec, Type: rpmmd.DependencyRequested})
+				addGroup(spec)
+				continue
+			}
+			for _, pkg := range result.Packages {
+				if matchesSpec(pkg, spec) {
+					add(rpmmd.PackageDependency{To: pkg.Name, Type: rpmmd.DependencyRequested})
+				}
+			}
+			for _, provider := range providers[spec] {
+				add(rpmmd.PackageDependency{To: provider, Type: rpmmd.DependencyRequested})
+			}
+		}
+	}
+
+	for _, pkg := range result.Packages {
+		info := metadata[pkg.nevra()]
+		for _, dep := range info.Requires {
+			if strings.HasPrefix(dep.Name, "rpmlib(") {
+				continue
+			}
+			for _, name := range richDependencyNames(dep.Name) {
+				for _, provider := range providers[name] {
+					add(rpmmd.PackageDependency{From: pkg.Name, To: provider, Type: rpmmd.DependencyRequires, Requirement: dep.Name})
+				}
+			}
+		}
+		for _, dep := range info.Recommends {
+			for _, name := range richDependencyNames(dep.Name) {
+				for _, provider := range providers[name] {
+					add(rpmmd.PackageDependency{From: pkg.Name, To: provider, Type: rpmmd.DependencyWeak, Requirement: dep.Name})
+				}
+			}
+		}
+		// a package that supplements another one is pulled in by it
+		for _, dep := range info.Supplements {
+			for _, name := range richDependencyNames(dep.Name) {
+				for _, provider := range providers[name] {
+					add(rpmmd.PackageDependency{From: provider, To: pkg.Name, Type: rpmmd.DependencyWeak, Requirement: dep.Name})
+				}
+			}
+		}
+	}
+	return graph, nil
+}
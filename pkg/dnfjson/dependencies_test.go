@@ -0,0 +1,282 @@
+package dnfjson
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+const testPrimary = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="9">
+<package type="rpm">
+  <name>tmux</name><arch>x86_64</arch><version epoch="0" ver="3.3a" rel="3.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="tmux" flags="EQ" epoch="0" ver="3.3a" rel="3.fc38"/></rpm:provides>
+    <rpm:requires>
+      <rpm:entry name="libc.so.6()(64bit)"/>
+      <rpm:entry name="/bin/sh" pre="1"/>
+      <rpm:entry name="rpmlib(CompressedFileNames)" flags="LE" epoch="0" ver="3.0.4" rel="1"/>
+    </rpm:requires>
+    <rpm:recommends><rpm:entry name="(bash-completion if bash)"/></rpm:recommends>
+    <file>/usr/bin/tmux</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>tmux</name><arch>x86_64</arch><version epoch="0" ver="3.3a" rel="1.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="tmux" flags="EQ" epoch="0" ver="3.3a" rel="1.fc38"/></rpm:provides>
+    <rpm:requires><rpm:entry name="vim-minimal"/></rpm:requires>
+  </format>
+</package>
+<package type="rpm">
+  <name>glibc</name><arch>x86_64</arch><version epoch="0" ver="2.37" rel="4.fc38"/>
+  <format>
+    <rpm:provides>
+      <rpm:entry name="glibc" flags="EQ" epoch="0" ver="2.37" rel="4.fc38"/>
+      <rpm:entry name="libc.so.6()(64bit)"/>
+    </rpm:provides>
+    <rpm:requires><rpm:entry name="glibc-common" flags="EQ" epoch="0" ver="2.37" rel="4.fc38"/></rpm:requires>
+  </format>
+</package>
+<package type="rpm">
+  <name>glibc-common</name><arch>x86_64</arch><version epoch="0" ver="2.37" rel="4.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="glibc-common"/></rpm:provides>
+    <rpm:requires><rpm:entry name="glibc" flags="EQ" epoch="0" ver="2.37" rel="4.fc38"/></rpm:requires>
+  </format>
+</package>
+<package type="rpm">
+  <name>bash</name><arch>x86_64</arch><version epoch="0" ver="5.2.15" rel="3.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="bash"/></rpm:provides>
+    <file>/bin/sh</file>
+    <file>/usr/bin/bash</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>busybox</name><arch>x86_64</arch><version epoch="1" ver="1.36.1" rel="1.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="busybox"/></rpm:provides>
+    <file>/bin/sh</file>
+  </format>
+</package>
+<package type="rpm">
+  <name>bash-completion</name><arch>noarch</arch><version epoch="1" ver="2.11" rel="9.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="bash-completion"/></rpm:provides>
+  </format>
+</package>
+<package type="rpm">
+  <name>vim-minimal</name><arch>x86_64</arch><version epoch="2" ver="9.0.1677" rel="1.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="vim-minimal"/><rpm:entry name="vi"/></rpm:provides>
+  </format>
+</package>
+<package type="rpm">
+  <name>tmux-powerline</name><arch>noarch</arch><version epoch="0" ver="1.0" rel="1.fc38"/>
+  <format>
+    <rpm:provides><rpm:entry name="tmux-powerline"/></rpm:provides>
+    <rpm:supplements><rpm:entry name="(tmux and vim-minimal)"/></rpm:supplements>
+  </format>
+</package>
+</metadata>
+`
+
+const testComps = `<?xml version="1.0" encoding="UTF-8"?>
+<comps>
+  <group>
+    <id>core</id>
+    <packagelist>
+      <packagereq type="mandatory">vim-minimal</packagereq>
+      <packagereq type="optional">emacs</packagereq>
+    </packagelist>
+  </group>
+</comps>
+`
+
+// makeDependenciesRepo creates the primary (gzip compressed) and comps
+// metadata of a repository in dir.
+func makeDependenciesRepo(t *testing.T, dir string) {
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "repodata"), 0755))
+	f, err := os.Create(filepath.Join(dir, "repodata", "0123-primary.xml.gz"))
+	require.NoError(t, err)
+	w := gzip.NewWriter(f)
+	_, err = w.Write([]byte(testPrimary))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repodata", "4567-comps.xml"), []byte(testComps), 0644))
+
+	repomd := `<repomd>
+  <data type="primary"><location href="repodata/0123-primary.xml.gz"/></data>
+  <data type="group"><location href="repodata/4567-comps.xml"/></data>
+</repomd>`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "repodata", "repomd.xml"), []byte(repomd), 0644))
+}
+
+// fakeDepsolver returns the path of a depsolver that returns the
+// transaction of the test repository.
+func fakeDepsolver(t *testing.T, repoID string) string {
+	fakeSolver := fmt.Sprintf(`#!/bin/sh -e
+cat - > /dev/null
+cat <<'END'
+{
+  "packages": [
+    {"name": "tmux", "version": "3.3a", "release": "3.fc38", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "glibc", "version": "2.37", "release": "4.fc38", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "glibc-common", "version": "2.37", "release": "4.fc38", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "bash", "version": "5.2.15", "release": "3.fc38", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "bash-completion", "epoch": 1, "version": "2.11", "release": "9.fc38", "arch": "noarch", "repo_id": "%[1]s"},
+    {"name": "vim-minimal", "epoch": 2, "version": "9.0.1677", "release": "1.fc38", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "tmux-powerline", "version": "1.0", "release": "1.fc38", "arch": "noarch", "repo_id": "%[1]s"}
+  ],
+  "repos": {"%[1]s": {"id": "%[1]s", "name": "fedora"}}
+}
+END
+`, repoID)
+	fakeSolverPath := filepath.Join(t.TempDir(), "fake-solver")
+	require.NoError(t, os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755)) //nolint:gosec
+	return fakeSolverPath
+}
+
+func TestSolverDepsolveWithDependencies(t *testing.T) {
+	repoDir := t.TempDir()
+	makeDependenciesRepo(t, repoDir)
+
+	testCases := map[string]struct {
+		repo  rpmmd.RepoConfig
+		cache bool
+	}{
+		// the metadata is read from the repository
+		"repository": {
+			repo: rpmmd.RepoConfig{Name: "fedora", BaseURLs: []string{"file://" + repoDir}},
+		},
+		// the metadata is read from the dnf cache instead of the repository,
+		// which cannot be reached
+		"cache": {
+			repo:  rpmmd.RepoConfig{Name: "fedora", BaseURLs: []string{"http://127.0.0.1:0/fedora"}},
+			cache: true,
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", t.TempDir())
+			solver.SetDNFJSONPath(fakeDepsolver(t, tc.repo.Hash()))
+			if tc.cache {
+				makeDependenciesRepo(t, filepath.Join(solver.GetCacheDir(), tc.repo.Hash()+"-8f2a4c1d0e5b7a93"))
+			}
+
+			pkgs, _, deps, err := solver.DepsolveWithDependencies([]rpmmd.PackageSet{
+				{Include: []string{"tmux", "@core"}, Repositories: []rpmmd.RepoConfig{tc.repo}, InstallWeakDeps: true},
+			})
+			require.NoError(t, err)
+			assert.Len(t, pkgs, 7)
+
+			for pkg, expected := range map[string][]rpmmd.PackageDependency{
+				"glibc-common": {
+					{To: "tmux", Type: rpmmd.DependencyRequested},
+					{From: "tmux", To: "glibc", Type: rpmmd.DependencyRequires, Requirement: "libc.so.6()(64bit)"},
+					{From: "glibc", To: "glibc-common", Type: rpmmd.DependencyRequires, Requirement: "glibc-common"},
+				},
+				"bash": {
+					{To: "tmux", Type: rpmmd.DependencyRequested},
+					{From: "tmux", To: "bash", Type: rpmmd.DependencyRequires, Requirement: "/bin/sh"},
+				},
+				"bash-completion": {
+					{To: "tmux", Type: rpmmd.DependencyRequested},
+					{From: "tmux", To: "bash-completion", Type: rpmmd.DependencyWeak, Requirement: "(bash-completion if bash)"},
+				},
+				"vim-minimal": {
+					{To: "@core", Type: rpmmd.DependencyRequested},
+					{From: "@core", To: "vim-minimal", Type: rpmmd.DependencyGroup},
+				},
+				"tmux-powerline": {
+					{To: "tmux", Type: rpmmd.DependencyRequested},
+					{From: "tmux", To: "tmux-powerline", Type: rpmmd.DependencyWeak, Requirement: "(tmux and vim-minimal)"},
+				},
+			} {
+				chain, err := deps.Why(pkg)
+				require.NoError(t, err)
+				assert.Equal(t, expected, chain, pkg)
+			}
+
+			// the condition of a rich dependency is not an edge
+			assert.NotContains(t, deps, rpmmd.PackageDependency{From: "tmux", To: "bash", Type: rpmmd.DependencyWeak, Requirement: "(bash-completion if bash)"})
+		})
+	}
+}
+
+func TestSolverDepsolveWithDependenciesMissingMetadata(t *testing.T) {
+	repoDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "repodata"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "repodata", "repomd.xml"), []byte("<repomd/>"), 0644))
+	repo := rpmmd.RepoConfig{Name: "fedora", BaseURLs: []string{"file://" + repoDir}}
+
+	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", t.TempDir())
+	solver.SetDNFJSONPath(fakeDepsolver(t, repo.Hash()))
+	_, _, _, err := solver.DepsolveWithDependencies([]rpmmd.PackageSet{
+		{Include: []string{"tmux"}, Repositories: []rpmmd.RepoConfig{repo}},
+	})
+	assert.EqualError(t, err, `deriving the dependency graph failed: repository "fedora" has no primary metadata`)
+}
+
+func TestDependencyGraphApproximation(t *testing.T) {
+	repoDir := t.TempDir()
+	makeDependenciesRepo(t, repoDir)
+	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", t.TempDir())
+	repos, err := solver.reposFromRPMMD([]rpmmd.RepoConfig{{Name: "fedora", BaseURLs: []string{"file://" + repoDir}}})
+	require.NoError(t, err)
+	repoID := repos[0].ID
+
+	pkgSets := []rpmmd.PackageSet{{Include: []string{"tmux"}}}
+	result := depsolveResult{
+		Packages: packageSpecs{
+			{Name: "tmux", Version: "3.3a", Release: "3.fc38", Arch: "x86_64", RepoID: repoID},
+			{Name: "glibc", Version: "2.37", Release: "4.fc38", Arch: "x86_64", RepoID: repoID},
+			{Name: "glibc-common", Version: "2.37", Release: "4.fc38", Arch: "x86_64", RepoID: repoID},
+			{Name: "bash", Version: "5.2.15", Release: "3.fc38", Arch: "x86_64", RepoID: repoID},
+			{Name: "busybox", Epoch: 1, Version: "1.36.1", Release: "1.fc38", Arch: "x86_64", RepoID: repoID},
+			// installed for a reason the metadata does not show, e.g. as
+			// the replacement of an obsoleted package
+			{Name: "vim-minimal", Epoch: 2, Version: "9.0.1677", Release: "1.fc38", Arch: "x86_64", RepoID: repoID},
+		},
+	}
+	deps, err := solver.dependencyGraph(pkgSets, &result, repos)
+	require.NoError(t, err)
+
+	// every installed provider of a requirement is a dependency, even if
+	// the solver only needed one of them
+	assert.Contains(t, deps, rpmmd.PackageDependency{From: "tmux", To: "bash", Type: rpmmd.DependencyRequires, Requirement: "/bin/sh"})
+	assert.Contains(t, deps, rpmmd.PackageDependency{From: "tmux", To: "busybox", Type: rpmmd.DependencyRequires, Requirement: "/bin/sh"})
+
+	// packages that are not pulled in by any dependency are not explained
+	_, err = deps.Why("vim-minimal")
+	assert.EqualError(t, err, `package "vim-minimal" is not part of the dependency graph`)
+}
+
+func TestRichDependencyNames(t *testing.T) {
+	testCases := []struct {
+		dep      string
+		expected []string
+	}{
+		{"libc.so.6()(64bit)", []string{"libc.so.6()(64bit)"}},
+		{"(foo or bar)", []string{"foo", "bar"}},
+		{"(foo >= 1.0 and bar(x86-64))", []string{"foo", "bar(x86-64)"}},
+		{"(foo if bar)", []string{"foo"}},
+		{"(foo if bar else baz)", []string{"foo", "baz"}},
+		{"((foo or bar) unless (baz and qux))", []string{"foo", "bar"}},
+		{"(foo with foo-libs = 2.0)", []string{"foo", "foo-libs"}},
+		{"(foo without foo-devel)", []string{"foo"}},
+		{"(python3-foo if (python3 or python3-libs)) ", []string{"python3-foo"}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, richDependencyNames(tc.dep), tc.dep)
+	}
+}
@@ -0,0 +1,260 @@
+package dnfjson
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/osbuild/images/internal/repodata"
+)
+
+// depsolveCache is a persistent cache of depsolve results. Entries are keyed
+// by the hash of the request and the revision of the metadata of all the
+// repositories of the request, so a new revision of a repository invalidates
+// all the results that depend on it.
+type depsolveCache struct {
+	// root path for the cache
+	root string
+
+	// entries older than the timeout are not used and removed
+	timeout time.Duration
+
+	// max cache size
+	maxSize uint64
+
+	locker *sync.Mutex
+}
+
+func newDepsolveCache(path string, timeout time.Duration, maxSize uint64) *depsolveCache {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		panic(err) // can only happen if the CWD does not exist and the path isn't already absolute
+	}
+	return &depsolveCache{
+		root:    absPath,
+		timeout: timeout,
+		maxSize: maxSize,
+		locker:  new(sync.Mutex),
+	}
+}
+
+func (c *depsolveCache) entryPath(key string) string {
+	return filepath.Join(c.root, key+".json")
+}
+
+// Get returns the cached output of osbuild-depsolve-dnf and true, or nil and
+// false if there is no valid entry for the key.
+func (c *depsolveCache) Get(key string) ([]byte, bool) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	path := c.entryPath(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) >= c.timeout {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !json.Valid(data) {
+		return nil, false
+	}
+	return data, true
+}
+
+// Store saves the output of osbuild-depsolve-dnf in the cache and shrinks
+// the cache if it grew above its maximum size.
+func (c *depsolveCache) Store(key string, output []byte) error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	if err := os.MkdirAll(c.root, 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so that concurrent readers never see a
+	// partial entry
+	tmp, err := os.CreateTemp(c.root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(output); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.entryPath(key)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return c.shrink()
+}
+
+// Clean removes the expired entries and shrinks the cache below its maximum
+// size.
+func (c *depsolveCache) Clean() error {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.shrink()
+}
+
+// shrink removes the expired entries, then the oldest entries until the
+// total size of the cache falls below the maximum size. Must be called with
+// the lock held.
+func (c *depsolveCache) shrink() error {
+	entries, err := os.ReadDir(c.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	type entry struct {
+		path  string
+		size  uint64
+		mtime time.Time
+	}
+	var valid []entry
+	var size uint64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(c.root, e.Name())
+		if time.Since(info.ModTime()) >= c.timeout {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		valid = append(valid, entry{path: path, size: uint64(info.Size()), mtime: info.ModTime()})
+		size += uint64(info.Size())
+	}
+
+	// oldest first
+	sort.Slice(valid, func(i, j int) bool {
+		return valid[i].mtime.Before(valid[j].mtime)
+	})
+	for idx := 0; idx < len(valid) && size > c.maxSize; idx++ {
+		if err := os.Remove(valid[idx].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= valid[idx].size
+	}
+	return nil
+}
+
+// defaultMetadataExpire is the default of the metadata_expire option of dnf
+const defaultMetadataExpire = 48 * time.Hour
+
+// metadataExpire parses the metadata_expire option of a repository: a number
+// of seconds, optionally with an s, m, h or d suffix, or "never" or -1 for
+// metadata that never expires. It returns false for metadata that never
+// expires.
+func metadataExpire(value string) (time.Duration, bool, error) {
+	if value == "" {
+		return defaultMetadataExpire, true, nil
+	}
+	if value == "never" || value == "-1" {
+		return 0, false, nil
+	}
+	number, unit := value, time.Second
+	switch value[len(value)-1] {
+	case 's':
+		number = value[:len(value)-1]
+	case 'm':
+		unit = time.Minute
+		number = value[:len(value)-1]
+	case 'h':
+		unit = time.Hour
+		number = value[:len(value)-1]
+	case 'd':
+		unit = 24 * time.Hour
+		number = value[:len(value)-1]
+	}
+	n, err := strconv.ParseUint(number, 10, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid metadata_expire %q", value)
+	}
+	return time.Duration(n) * unit, true, nil
+}
+
+// repoRevision returns a checksum of the repomd.xml of the repository, which
+// changes with every new revision of the repository metadata. The repomd.xml
+// in the dnf cache of the solver is used as long as it has not expired, since
+// dnf depsolves with the cached metadata as well, otherwise the repomd.xml is
+// fetched from the repository.
+func (s *Solver) repoRevision(repo repoConfig) (string, error) {
+	if cached := s.cachedRepoDir(repo); cached != "" {
+		path := filepath.Join(cached, "repodata", "repomd.xml")
+		expire, expires, err := metadataExpire(repo.MetadataExpire)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(path); err == nil && (!expires || time.Since(info.ModTime()) < expire) {
+			data, err := os.ReadFile(path)
+			if err == nil {
+				return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+			}
+		}
+	}
+
+	client, err := repodata.NewClient(repo.repodata(s.proxy))
+	if err != nil {
+		return "", err
+	}
+	data, err := client.Fetch("repodata/repomd.xml")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// depsolveCacheKey returns the cache key of a depsolve request, or false if
+// the result of the request cannot be cached because the revision of one of
+// its repositories is unknown. The revisions of the repositories are looked
+// up concurrently.
+func (s *Solver) depsolveCacheKey(req *Request) (string, bool) {
+	if req.Arguments.RootDir != "" {
+		// repositories from the root dir are not part of the request
+		return "", false
+	}
+	revisions := make([]string, len(req.Arguments.Repos))
+	errs := make([]error, len(req.Arguments.Repos))
+	var wg sync.WaitGroup
+	for idx, repo := range req.Arguments.Repos {
+		wg.Add(1)
+		go func(idx int, repo repoConfig) {
+			defer wg.Done()
+			revisions[idx], errs[idx] = s.repoRevision(repo)
+		}(idx, repo)
+	}
+	wg.Wait()
+
+	h := sha256.New()
+	h.Write([]byte(req.Hash())) //nolint:errcheck
+	for idx, repo := range req.Arguments.Repos {
+		if errs[idx] != nil {
+			return "", false
+		}
+		h.Write([]byte(repo.Hash() + revisions[idx])) //nolint:errcheck
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), true
+}
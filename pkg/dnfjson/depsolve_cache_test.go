@@ -0,0 +1,206 @@
+package dnfjson
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestDepsolveCacheGetStore(t *testing.T) {
+	cache := newDepsolveCache(t.TempDir(), time.Hour, 1024)
+
+	_, ok := cache.Get("key1")
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Store("key1", []byte(`{"packages": []}`)))
+	data, ok := cache.Get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, `{"packages": []}`, string(data))
+
+	// expired entries are removed
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(cache.entryPath("key1"), old, old))
+	_, ok = cache.Get("key1")
+	assert.False(t, ok)
+	assert.NoFileExists(t, cache.entryPath("key1"))
+}
+
+func TestDepsolveCacheShrink(t *testing.T) {
+	cache := newDepsolveCache(t.TempDir(), time.Hour, 25)
+	entry := []byte(`{"packages": [1, 2, 3]}`) // 23 bytes
+
+	require.NoError(t, cache.Store("key1", entry))
+	old := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(cache.entryPath("key1"), old, old))
+
+	// the oldest entry is removed to make room for the new one
+	require.NoError(t, cache.Store("key2", entry))
+	assert.NoFileExists(t, cache.entryPath("key1"))
+	assert.FileExists(t, cache.entryPath("key2"))
+
+	// clean removes expired entries
+	expired := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(cache.entryPath("key2"), expired, expired))
+	require.NoError(t, cache.Clean())
+	assert.NoFileExists(t, cache.entryPath("key2"))
+}
+
+func TestMetadataExpire(t *testing.T) {
+	testCases := []struct {
+		value   string
+		expire  time.Duration
+		expires bool
+		err     string
+	}{
+		{value: "", expire: 48 * time.Hour, expires: true},
+		{value: "300", expire: 5 * time.Minute, expires: true},
+		{value: "30s", expire: 30 * time.Second, expires: true},
+		{value: "90m", expire: 90 * time.Minute, expires: true},
+		{value: "6h", expire: 6 * time.Hour, expires: true},
+		{value: "2d", expire: 48 * time.Hour, expires: true},
+		{value: "never"},
+		{value: "-1"},
+		{value: "soon", err: `invalid metadata_expire "soon"`},
+	}
+	for _, tc := range testCases {
+		expire, expires, err := metadataExpire(tc.value)
+		if tc.err != "" {
+			assert.EqualError(t, err, tc.err)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Equal(t, tc.expire, expire, tc.value)
+		assert.Equal(t, tc.expires, expires, tc.value)
+	}
+}
+
+func TestRepoRevision(t *testing.T) {
+	repomd := "<repomd><revision>1</revision></repomd>"
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/baseos/repodata/repomd.xml":
+			w.Write([]byte(repomd)) //nolint:errcheck
+		case "/metalink":
+			w.Write([]byte(`<metalink><files><file name="repomd.xml"><resources><url protocol="http">` + srvURL + `/baseos/repodata/repomd.xml</url></resources></file></files></metalink>`)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	solver := NewSolver("platform:f40", "40", "x86_64", "fedora-40", t.TempDir())
+
+	rev1, err := solver.repoRevision(repoConfig{BaseURLs: []string{srv.URL + "/baseos/"}})
+	require.NoError(t, err)
+	assert.Len(t, rev1, 64)
+
+	// metalink repositories are resolved to a mirror
+	rev, err := solver.repoRevision(repoConfig{Name: "fedora", Metalink: srv.URL + "/metalink"})
+	require.NoError(t, err)
+	assert.Equal(t, rev1, rev)
+
+	repomd = "<repomd><revision>2</revision></repomd>"
+	rev2, err := solver.repoRevision(repoConfig{BaseURLs: []string{srv.URL + "/baseos"}})
+	require.NoError(t, err)
+	assert.NotEqual(t, rev1, rev2)
+
+	_, err = solver.repoRevision(repoConfig{BaseURLs: []string{srv.URL + "/appstream"}})
+	assert.ErrorContains(t, err, "unexpected status")
+
+	_, err = solver.repoRevision(repoConfig{Name: "fedora", Metalink: srv.URL + "/missing"})
+	assert.ErrorContains(t, err, "cannot fetch metalink")
+}
+
+func TestRepoRevisionDNFCache(t *testing.T) {
+	solver := NewSolver("platform:f40", "40", "x86_64", "fedora-40", t.TempDir())
+	// the repository cannot be reached, only the dnf cache
+	repo := repoConfig{ID: "0123abcd", Name: "fedora", BaseURLs: []string{"http://127.0.0.1:0/fedora"}}
+	repomdPath := filepath.Join(solver.GetCacheDir(), repo.ID+"-8f2a4c1d0e5b7a93", "repodata", "repomd.xml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(repomdPath), 0755))
+	require.NoError(t, os.WriteFile(repomdPath, []byte("<repomd><revision>1</revision></repomd>"), 0644))
+
+	rev, err := solver.repoRevision(repo)
+	require.NoError(t, err)
+	assert.Len(t, rev, 64)
+
+	// expired metadata is fetched from the repository again
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(repomdPath, old, old))
+	repo.MetadataExpire = "30m"
+	_, err = solver.repoRevision(repo)
+	assert.Error(t, err)
+
+	repo.MetadataExpire = "never"
+	rev, err = solver.repoRevision(repo)
+	require.NoError(t, err)
+	assert.Len(t, rev, 64)
+}
+
+func TestDepsolveWithCache(t *testing.T) {
+	tmpdir := t.TempDir()
+	repoDir := filepath.Join(tmpdir, "repo")
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "repodata"), 0755))
+	repomdPath := filepath.Join(repoDir, "repodata", "repomd.xml")
+	require.NoError(t, os.WriteFile(repomdPath, []byte("<repomd><revision>1</revision></repomd>"), 0644))
+
+	// the fake solver counts its invocations
+	fakeSolver := `#!/bin/sh -e
+cat - > /dev/null
+echo x >> "$0".calls
+echo '{"packages": [], "repos": {}}'
+`
+	fakeSolverPath := filepath.Join(tmpdir, "fake-solver")
+	require.NoError(t, os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755)) //nolint:gosec
+	calls := func() int {
+		data, err := os.ReadFile(fakeSolverPath + ".calls")
+		require.NoError(t, err)
+		return strings.Count(string(data), "x")
+	}
+
+	solver := NewSolver("platform:f40", "40", "x86_64", "fedora-40", filepath.Join(tmpdir, "rpmmd"))
+	solver.SetDNFJSONPath(fakeSolverPath)
+	solver.SetDepsolveCache(filepath.Join(tmpdir, "depsolve"), time.Hour, 1024*1024)
+
+	pkgSets := []rpmmd.PackageSet{
+		{Include: []string{"kernel"}, Repositories: []rpmmd.RepoConfig{{Name: "local", BaseURLs: []string{"file://" + repoDir}}}},
+	}
+	_, _, err := solver.Depsolve(pkgSets)
+	require.NoError(t, err)
+
+	// the metadata of the repositories of cached results is kept in the dnf
+	// cache
+	repoCacheDir := filepath.Join(solver.GetCacheDir(), pkgSets[0].Repositories[0].Hash()+"-8f2a4c1d0e5b7a93")
+	require.NoError(t, os.MkdirAll(repoCacheDir, 0755))
+	old := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(repoCacheDir, old, old))
+
+	_, _, err = solver.Depsolve(pkgSets)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls())
+	info, err := os.Stat(repoCacheDir)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), info.ModTime(), time.Hour)
+
+	// different packages are not served from the cache
+	pkgSets[0].Include = []string{"kernel", "tmux"}
+	_, _, err = solver.Depsolve(pkgSets)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls())
+
+	// a new revision of the repository invalidates the cached result
+	require.NoError(t, os.WriteFile(repomdPath, []byte("<repomd><revision>2</revision></repomd>"), 0644))
+	_, _, err = solver.Depsolve(pkgSets)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls())
+}
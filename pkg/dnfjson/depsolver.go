@@ -0,0 +1,22 @@
+package dnfjson
+
+import (
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Depsolver resolves package sets and queries the package metadata of
+// repositories. It is implemented by Solver, which uses DNF, and can be
+// replaced by other implementations, e.g. fakes in tests that cannot run DNF.
+type Depsolver interface {
+	// Depsolve the chain of package sets, see Solver.Depsolve().
+	Depsolve(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig, error)
+
+	// FetchMetadata returns all the available packages in the repositories.
+	FetchMetadata(repos []rpmmd.RepoConfig) (rpmmd.PackageList, error)
+
+	// SearchMetadata returns the packages in the repositories matching the
+	// given names or globs.
+	SearchMetadata(repos []rpmmd.RepoConfig, packages []string) (rpmmd.PackageList, error)
+}
+
+var _ Depsolver = &Solver{}
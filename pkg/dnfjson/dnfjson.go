@@ -27,6 +27,8 @@ import (
 	"time"
 
 	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/proxy"
 	"github.com/osbuild/images/pkg/rhsm"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
@@ -43,6 +45,9 @@ type BaseSolver struct {
 	dnfJsonCmd []string
 
 	resultCache *dnfCache
+
+	// optional persistent cache of depsolve results
+	depsolveCache *depsolveCache
 }
 
 // Find the osbuild-depsolve-dnf script. This checks the default location in
@@ -85,6 +90,16 @@ func (s *BaseSolver) SetMaxCacheSize(size uint64) {
 	s.cache.maxSize = size
 }
 
+// SetDepsolveCache enables a persistent cache of depsolve results in the
+// given directory. Results are reused for identical requests as long as the
+// metadata of the repositories did not change and the result is not older
+// than timeout. The oldest results are removed when the cache grows above
+// maxSize. Requests with repositories whose metadata cannot be fetched are
+// not cached.
+func (s *BaseSolver) SetDepsolveCache(dir string, timeout time.Duration, maxSize uint64) {
+	s.depsolveCache = newDepsolveCache(dir, timeout, maxSize)
+}
+
 // SetDNFJSONPath sets the path to the dnf-json binary and optionally any command line arguments.
 func (s *BaseSolver) SetDNFJSONPath(cmd string, args ...string) {
 	s.dnfJsonCmd = append([]string{cmd}, args...)
@@ -107,9 +122,15 @@ func (bs *BaseSolver) NewWithConfig(modulePlatformID, releaseVer, arch, distro s
 
 // CleanCache deletes the least recently used repository metadata caches until
 // the total size of the cache falls below the configured maximum size (see
-// SetMaxCacheSize()).
+// SetMaxCacheSize()). Expired entries of the depsolve cache are removed as
+// well.
 func (bs *BaseSolver) CleanCache() error {
 	bs.resultCache.CleanCache()
+	if bs.depsolveCache != nil {
+		if err := bs.depsolveCache.Clean(); err != nil {
+			return err
+		}
+	}
 	return bs.cache.shrink()
 }
 
@@ -193,21 +214,132 @@ func (s *Solver) SetProxy(proxy string) error {
 // their associated repositories.  Each package set is depsolved as a separate
 // transactions in a chain.  It returns a list of all packages (with solved
 // dependencies) that will be installed into the system.
+//
+// Locks restrict the packages of their name to the exact version of the lock,
+// they do not add the package to the package sets. A LockConflictError is
+// returned if a lock cannot be satisfied.
+//
+// Local packages of the package sets are verified against their checksums and
+// depsolved from a temporary repository. The resulting package specs point to
+// the original files.
 func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig, error) {
+	packages, repos, _, err := s.depsolve(pkgSets, false)
+	return packages, repos, err
+}
+
+// DepsolveWithDependencies is like Depsolve but also returns the dependency
+// graph of the transactions, which explains why each package was installed
+// (see rpmmd.DependencyGraph.Why()). The graph is not returned by the
+// depsolver but derived from the primary and comps metadata of the
+// repositories, which is read from the dnf cache of the solver or fetched
+// from the repositories, so it is an approximation of the transaction: it
+// can have more edges than dnf followed, and packages that no dependency in
+// the metadata explains are missing from it.
+func (s *Solver) DepsolveWithDependencies(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig, rpmmd.DependencyGraph, error) {
+	return s.depsolve(pkgSets, true)
+}
+
+func (s *Solver) depsolve(pkgSets []rpmmd.PackageSet, dependencies bool) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig, rpmmd.DependencyGraph, error) {
+	localRepo, err := makeLocalRepo(pkgSets)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if localRepo != nil {
+		defer localRepo.cleanup()
+		pkgSets = withLocalRepo(pkgSets, localRepo)
+	}
+
+	locks, err := collectLocks(pkgSets)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	unlockedPkgSets := pkgSets
+	if len(locks) > 0 {
+		excludes, err := s.lockExcludes(pkgSets, locks)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pkgSets = withExcludes(pkgSets, excludes)
+	}
+
 	req, rhsmMap, err := s.makeDepsolveRequest(pkgSets)
 	if err != nil {
-		return nil, nil, fmt.Errorf("makeDepsolveRequest failed: %w", err)
+		return nil, nil, nil, fmt.Errorf("makeDepsolveRequest failed: %w", err)
 	}
 
 	// get non-exclusive read lock
 	s.cache.locker.RLock()
 	defer s.cache.locker.RUnlock()
 
-	output, err := run(s.dnfJsonCmd, req)
+	// the temporary repository of local packages is different for every
+	// request, so there is no point in caching the result
+	cache := s.depsolveCache != nil && localRepo == nil
+	result, err := s.solve(req, cache)
 	if err != nil {
-		return nil, nil, fmt.Errorf("running osbuild-depsolve-dnf failed:\n%w", err)
+		if len(locks) > 0 {
+			return nil, nil, nil, s.lockConflict(unlockedPkgSets, locks, cache, err)
+		}
+		return nil, nil, nil, fmt.Errorf("running osbuild-depsolve-dnf failed:\n%w", err)
 	}
-	// touch repos to now
+
+	if err := result.checkLocks(locks); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var graph rpmmd.DependencyGraph
+	if dependencies {
+		// the graph is derived before the local packages are resolved, which
+		// needs the metadata of the temporary repository
+		graph, err = s.dependencyGraph(pkgSets, result, req.Arguments.Repos)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("deriving the dependency graph failed: %w", err)
+		}
+	}
+
+	if localRepo != nil {
+		if err := result.resolveLocalPackages(localRepo); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	proxies := make(map[string]*proxy.Config)
+	for _, repo := range req.Arguments.Repos {
+		if repo.proxy != nil {
+			proxies[repo.ID] = repo.proxy
+		}
+	}
+	packages, repos := result.toRPMMD(rhsmMap, proxies)
+	if localRepo != nil {
+		repos = withoutLocalRepo(repos, localRepo)
+	}
+	return packages, repos, graph, nil
+}
+
+// solve runs a depsolve request and decodes the result. If cache is true,
+// the result is looked up in and stored to the depsolve cache. The caller
+// must hold the read lock of the dnf cache.
+func (s *Solver) solve(req *Request, cache bool) (*depsolveResult, error) {
+	var cacheKey string
+	if cache {
+		if key, ok := s.depsolveCacheKey(req); ok {
+			cacheKey = key
+		}
+	}
+
+	var output []byte
+	cached := false
+	if cacheKey != "" {
+		output, cached = s.depsolveCache.Get(cacheKey)
+	}
+	if !cached {
+		var err error
+		output, err = run(s.dnfJsonCmd, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// touch repos to now, also for cached results, so that the metadata the
+	// cache keys were derived from is kept
 	now := time.Now().Local()
 	for _, r := range req.Arguments.Repos {
 		// ignore errors
@@ -219,11 +351,14 @@ func (s *Solver) Depsolve(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageSpec, []rp
 	dec := json.NewDecoder(bytes.NewReader(output))
 	dec.DisallowUnknownFields()
 	if err := dec.Decode(&result); err != nil {
-		return nil, nil, fmt.Errorf("decoding depsolve result failed: %w", err)
+		return nil, fmt.Errorf("decoding depsolve result failed: %w", err)
 	}
 
-	packages, repos := result.toRPMMD(rhsmMap)
-	return packages, repos, nil
+	if cacheKey != "" && !cached {
+		// the cache is optional, failing to store a result is not an error
+		_ = s.depsolveCache.Store(cacheKey, output)
+	}
+	return &result, nil
 }
 
 // FetchMetadata returns the list of all the available packages in repos and
@@ -334,6 +469,15 @@ func (s *Solver) reposFromRPMMD(rpmRepos []rpmmd.RepoConfig) ([]repoConfig, erro
 			SSLClientKey:   rr.SSLClientKey,
 			SSLClientCert:  rr.SSLClientCert,
 			repoHash:       rr.Hash(),
+			proxy:          rr.Proxy,
+		}
+		if rr.Proxy != nil {
+			proxyURL, err := repoProxyURL(rr)
+			if err != nil {
+				return nil, fmt.Errorf("repository %q: %w", rr.Name, err)
+			}
+			dr.Proxy = proxyURL
+			dr.ProxySSLCACert = rr.Proxy.CACert
 		}
 		if rr.ModuleHotfixes != nil {
 			val := *rr.ModuleHotfixes
@@ -387,9 +531,37 @@ type repoConfig struct {
 	SSLClientCert  string   `json:"sslclientcert,omitempty"`
 	MetadataExpire string   `json:"metadata_expire,omitempty"`
 	ModuleHotfixes *bool    `json:"module_hotfixes,omitempty"`
+	Proxy          string   `json:"proxy,omitempty"`
+	ProxySSLCACert string   `json:"proxy_sslcacert,omitempty"`
 	// set the repo hass from `rpmmd.RepoConfig.Hash()` function
 	// rather than re-calculating it
 	repoHash string
+	// proxy configuration of the repository, nil for the solver-wide proxy
+	proxy *proxy.Config
+}
+
+// repoProxyURL returns the proxy of the repository in the form of the dnf
+// proxy option: "_none_" if the repository is accessed directly. DNF has no
+// per-repository no-proxy list, so the list is matched against the first
+// URL of the repository.
+func repoProxyURL(repo rpmmd.RepoConfig) (string, error) {
+	var repoURL string
+	switch {
+	case len(repo.BaseURLs) > 0:
+		repoURL = repo.BaseURLs[0]
+	case repo.Metalink != "":
+		repoURL = repo.Metalink
+	default:
+		repoURL = repo.MirrorList
+	}
+	proxyURL, err := repo.Proxy.ForURL(repoURL)
+	if err != nil {
+		return "", err
+	}
+	if proxyURL == "" {
+		return "_none_", nil
+	}
+	return proxyURL, nil
 }
 
 // use the hash calculated by the `rpmmd.RepoConfig.Hash()`
@@ -398,6 +570,26 @@ func (r *repoConfig) Hash() string {
 	return r.repoHash
 }
 
+// repodata returns the repository for fetching its metadata without dnf. The
+// proxy of the repository takes precedence over the solver-wide proxy.
+func (r *repoConfig) repodata(solverProxy string) repodata.Repository {
+	repo := repodata.Repository{
+		Name:          r.Name,
+		BaseURLs:      r.BaseURLs,
+		Metalink:      r.Metalink,
+		MirrorList:    r.MirrorList,
+		IgnoreSSL:     r.SSLVerify != nil && !*r.SSLVerify,
+		SSLCACert:     r.SSLCACert,
+		SSLClientCert: r.SSLClientCert,
+		SSLClientKey:  r.SSLClientKey,
+		Proxy:         r.proxy,
+	}
+	if repo.Proxy == nil && solverProxy != "" {
+		repo.Proxy = &proxy.Config{URL: solverProxy}
+	}
+	return repo
+}
+
 // Helper function for creating a depsolve request payload. The request defines
 // a sequence of transactions, each depsolving one of the elements of `pkgSets`
 // in the order they appear. The repositories are collected in the request
@@ -435,6 +627,9 @@ func (s *Solver) makeDepsolveRequest(pkgSets []rpmmd.PackageSet) (*Request, map[
 			ExcludeSpecs:    pkgSet.Exclude,
 			InstallWeakDeps: pkgSet.InstallWeakDeps,
 		}
+		for _, module := range pkgSet.EnabledModules {
+			transactions[dsIdx].ModuleEnableSpecs = append(transactions[dsIdx].ModuleEnableSpecs, module.Spec())
+		}
 
 		for _, jobRepo := range pkgSet.Repositories {
 			transactions[dsIdx].RepoIDs = append(transactions[dsIdx].RepoIDs, jobRepo.Hash())
@@ -541,7 +736,7 @@ func (s *Solver) makeSearchRequest(repos []rpmmd.RepoConfig, packages []string)
 // convert internal a list of PackageSpecs and map of repoConfig to the rpmmd
 // equivalents and attach key and subscription information based on the
 // repository configs.
-func (result depsolveResult) toRPMMD(rhsm map[string]bool) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig) {
+func (result depsolveResult) toRPMMD(rhsm map[string]bool, proxies map[string]*proxy.Config) ([]rpmmd.PackageSpec, []rpmmd.RepoConfig) {
 	pkgs := result.Packages
 	repos := result.Repos
 	rpmDependencies := make([]rpmmd.PackageSpec, len(pkgs))
@@ -559,6 +754,7 @@ func (result depsolveResult) toRPMMD(rhsm map[string]bool) ([]rpmmd.PackageSpec,
 		rpmDependencies[i].RemoteLocation = dep.RemoteLocation
 		rpmDependencies[i].Checksum = dep.Checksum
 		rpmDependencies[i].CheckGPG = repo.GPGCheck
+		rpmDependencies[i].Proxy = proxies[dep.RepoID]
 		if verify := repo.SSLVerify; verify != nil {
 			rpmDependencies[i].IgnoreSSL = !*verify
 		}
@@ -595,6 +791,7 @@ func (result depsolveResult) toRPMMD(rhsm map[string]bool) ([]rpmmd.PackageSpec,
 			SSLCACert:      repo.SSLCACert,
 			SSLClientKey:   repo.SSLClientKey,
 			SSLClientCert:  repo.SSLClientCert,
+			Proxy:          proxies[repoID],
 		})
 	}
 	return rpmDependencies, repoConfigs
@@ -633,11 +830,24 @@ func (r *Request) Hash() string {
 	h.Write([]byte(r.Command))
 	h.Write([]byte(r.ModulePlatformID))
 	h.Write([]byte(r.Arch))
+	h.Write([]byte(r.Releasever))
+	h.Write([]byte(r.Proxy))
 	for _, repo := range r.Arguments.Repos {
 		h.Write([]byte(repo.Hash()))
 	}
 	h.Write([]byte(fmt.Sprintf("%T", r.Arguments.Search.Latest)))
 	h.Write([]byte(strings.Join(r.Arguments.Search.Packages, "")))
+	h.Write([]byte(r.Arguments.RootDir))
+	h.Write([]byte(strings.Join(r.Arguments.OptionalMetadata, ",")))
+	for _, t := range r.Arguments.Transactions {
+		// separate the fields so that different transactions cannot hash to
+		// the same value
+		h.Write([]byte(strings.Join(t.PackageSpecs, ",") + "\x00"))
+		h.Write([]byte(strings.Join(t.ExcludeSpecs, ",") + "\x00"))
+		h.Write([]byte(strings.Join(t.RepoIDs, ",") + "\x00"))
+		h.Write([]byte(fmt.Sprintf("%v\x00", t.InstallWeakDeps)))
+		h.Write([]byte(strings.Join(t.ModuleEnableSpecs, ",") + "\x00"))
+	}
 
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
@@ -683,6 +893,9 @@ type transactionArgs struct {
 
 	// If we want weak deps for this depsolve
 	InstallWeakDeps bool `json:"install_weak_deps"`
+
+	// Module streams to enable, in the form "name:stream[/profile]"
+	ModuleEnableSpecs []string `json:"module-enable-specs,omitempty"`
 }
 
 type packageSpecs []PackageSpec
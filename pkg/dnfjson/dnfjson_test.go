@@ -11,6 +11,7 @@ import (
 
 	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/internal/mocks/rpmrepo"
+	"github.com/osbuild/images/pkg/proxy"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -749,6 +750,48 @@ func TestRepoConfigHash(t *testing.T) {
 	assert.NotEqual(t, hash, rcs[1].Hash())
 }
 
+func TestReposFromRPMMDProxy(t *testing.T) {
+	external := &proxy.Config{URL: "http://proxy.example.com:3128", NoProxy: []string{"internal.example.com"}, CACert: "/etc/pki/proxy-ca.pem"}
+	repos := []rpmmd.RepoConfig{
+		{Name: "baseos", BaseURLs: []string{"https://cdn.example.com/baseos/"}, Proxy: external},
+		{Name: "internal", BaseURLs: []string{"https://internal.example.com/repo/"}, Proxy: external},
+		{Name: "direct", Metalink: "https://mirrors.example.com/metalink", Proxy: &proxy.Config{}},
+		{Name: "default", BaseURLs: []string{"https://cdn.example.com/appstream/"}},
+		{Name: "invalid", BaseURLs: []string{"https://cdn.example.com/appstream/"}, Proxy: &proxy.Config{URL: "ftp://proxy.example.com"}},
+	}
+
+	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", "/tmp/cache")
+	rcs, err := solver.reposFromRPMMD(repos[:4])
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", rcs[0].Proxy)
+	assert.Equal(t, "/etc/pki/proxy-ca.pem", rcs[0].ProxySSLCACert)
+	assert.Equal(t, "_none_", rcs[1].Proxy)
+	assert.Equal(t, "_none_", rcs[2].Proxy)
+	assert.Equal(t, "", rcs[3].Proxy)
+	// the proxy does not change the repository
+	proxied := repos[3]
+	proxied.Proxy = external
+	assert.Equal(t, repos[3].Hash(), proxied.Hash())
+
+	_, err = solver.reposFromRPMMD(repos[4:])
+	assert.EqualError(t, err, `repository "invalid": proxy URL "ftp://proxy.example.com" has an unsupported scheme "ftp"`)
+
+	result := depsolveResult{
+		Packages: []PackageSpec{{Name: "tmux", RepoID: "r1"}, {Name: "glibc", RepoID: "r2"}},
+		Repos:    map[string]repoConfig{"r1": {ID: "r1"}, "r2": {ID: "r2"}},
+	}
+	pkgs, rpmRepos := result.toRPMMD(nil, map[string]*proxy.Config{"r1": external})
+	assert.Equal(t, external, pkgs[0].Proxy)
+	assert.Nil(t, pkgs[1].Proxy)
+	for _, repo := range rpmRepos {
+		if repo.Id == "r1" {
+			assert.Equal(t, external, repo.Proxy)
+		} else {
+			assert.Nil(t, repo.Proxy)
+		}
+	}
+}
+
 func TestRequestHash(t *testing.T) {
 	solver := NewSolver("platform:f38", "38", "x86_64", "fedora-38", "/tmp/cache")
 	repos := []rpmmd.RepoConfig{
@@ -814,3 +857,30 @@ echo '{"solver": "zypper"}'
 	assert.Equal(t, 0, len(pkgSpec))
 	assert.Equal(t, 0, len(repoCfg))
 }
+
+func TestMakeDepsolveRequestModules(t *testing.T) {
+	repo := rpmmd.RepoConfig{Name: "appstream", BaseURLs: []string{"https://example.com/appstream"}}
+	pkgSets := []rpmmd.PackageSet{
+		{
+			Include:        []string{"nodejs"},
+			Repositories:   []rpmmd.RepoConfig{repo},
+			EnabledModules: []rpmmd.ModuleStream{{Name: "nodejs", Stream: "18"}, {Name: "postgresql", Stream: "15", Profile: "server"}},
+		},
+		{
+			Include:      []string{"tmux"},
+			Repositories: []rpmmd.RepoConfig{repo},
+		},
+	}
+
+	solver := NewSolver("platform:el9", "9", "x86_64", "rhel9.0", "/tmp/cache")
+	req, _, err := solver.makeDepsolveRequest(pkgSets)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nodejs:18", "postgresql:15/server"}, req.Arguments.Transactions[0].ModuleEnableSpecs)
+	assert.Nil(t, req.Arguments.Transactions[1].ModuleEnableSpecs)
+
+	// the module streams are part of the request hash
+	pkgSets[0].EnabledModules[0].Stream = "20"
+	req2, _, err := solver.makeDepsolveRequest(pkgSets)
+	require.NoError(t, err)
+	assert.NotEqual(t, req.Hash(), req2.Hash())
+}
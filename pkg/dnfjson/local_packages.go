@@ -0,0 +1,164 @@
+package dnfjson
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// localRepo is a temporary repository created from the local RPM files of a
+// depsolve request.
+type localRepo struct {
+	dir    string
+	config rpmmd.RepoConfig
+
+	// original files of the packages in the repository, keyed by file name
+	pkgs map[string]rpmmd.LocalPackage
+}
+
+func (r *localRepo) cleanup() {
+	os.RemoveAll(r.dir)
+}
+
+// verifyLocalPackage returns an error if the content of the local package
+// does not match its checksum.
+func verifyLocalPackage(pkg rpmmd.LocalPackage) error {
+	f, err := os.Open(pkg.Path)
+	if err != nil {
+		return fmt.Errorf("cannot open local package: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("cannot read local package %q: %w", pkg.Path, err)
+	}
+	if actual := fmt.Sprintf("sha256:%x", h.Sum(nil)); actual != pkg.Checksum {
+		return fmt.Errorf("checksum mismatch for local package %q: expected %s, got %s", pkg.Path, pkg.Checksum, actual)
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// makeLocalRepo verifies the local packages of all package sets and creates
+// a temporary repository containing them with createrepo_c. Returns nil if
+// none of the package sets has local packages.
+func makeLocalRepo(pkgSets []rpmmd.PackageSet) (*localRepo, error) {
+	var pkgs []rpmmd.LocalPackage
+	for _, ps := range pkgSets {
+		pkgs = append(pkgs, ps.LocalPackages...)
+	}
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "local-packages-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create local package repository: %w", err)
+	}
+	repo := &localRepo{
+		dir:  dir,
+		pkgs: make(map[string]rpmmd.LocalPackage),
+		config: rpmmd.RepoConfig{
+			Id:       "local-packages",
+			Name:     "Local packages",
+			BaseURLs: []string{"file://" + dir},
+			CheckGPG: common.ToPtr(false),
+		},
+	}
+
+	for _, pkg := range pkgs {
+		name := filepath.Base(pkg.Path)
+		if _, ok := repo.pkgs[name]; ok {
+			repo.cleanup()
+			return nil, fmt.Errorf("local package file name %q is used more than once", name)
+		}
+		if err := verifyLocalPackage(pkg); err != nil {
+			repo.cleanup()
+			return nil, err
+		}
+		if err := copyFile(pkg.Path, filepath.Join(dir, name)); err != nil {
+			repo.cleanup()
+			return nil, fmt.Errorf("cannot copy local package %q: %w", pkg.Path, err)
+		}
+		repo.pkgs[name] = pkg
+	}
+
+	if output, err := exec.Command("createrepo_c", dir).CombinedOutput(); err != nil {
+		repo.cleanup()
+		return nil, fmt.Errorf("creating local package repository failed: %w\n%s", err, output)
+	}
+
+	return repo, nil
+}
+
+// withLocalRepo returns a copy of the package sets that depsolve the local
+// packages from the given repository. The repository is added first to every
+// package set to keep the repositories of chained package sets consistent.
+func withLocalRepo(pkgSets []rpmmd.PackageSet, repo *localRepo) []rpmmd.PackageSet {
+	result := make([]rpmmd.PackageSet, len(pkgSets))
+	for idx, ps := range pkgSets {
+		ps.Repositories = append([]rpmmd.RepoConfig{repo.config}, ps.Repositories...)
+		ps.Include = append([]string{}, ps.Include...)
+		for _, pkg := range ps.LocalPackages {
+			ps.Include = append(ps.Include, strings.TrimSuffix(filepath.Base(pkg.Path), ".rpm"))
+		}
+		result[idx] = ps
+	}
+	return result
+}
+
+// resolveLocalPackages points the packages of the depsolve result that come
+// from the temporary local repository to the original files.
+func (result *depsolveResult) resolveLocalPackages(repo *localRepo) error {
+	repoID := repo.config.Hash()
+	for idx, pkg := range result.Packages {
+		if pkg.RepoID != repoID {
+			continue
+		}
+		local, ok := repo.pkgs[filepath.Base(pkg.RemoteLocation)]
+		if !ok {
+			return fmt.Errorf("depsolve returned unknown local package %q", pkg.RemoteLocation)
+		}
+		result.Packages[idx].RemoteLocation = "file://" + local.Path
+		result.Packages[idx].Checksum = local.Checksum
+	}
+	return nil
+}
+
+// withoutLocalRepo removes the temporary local repository from the given
+// repositories.
+func withoutLocalRepo(repos []rpmmd.RepoConfig, repo *localRepo) []rpmmd.RepoConfig {
+	repoID := repo.config.Hash()
+	result := make([]rpmmd.RepoConfig, 0, len(repos))
+	for _, r := range repos {
+		if r.Id != repoID {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package dnfjson
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func writeLocalPackage(t *testing.T, name string) rpmmd.LocalPackage {
+	path := filepath.Join(t.TempDir(), name)
+	data := []byte("not really an rpm")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return rpmmd.LocalPackage{Path: path, Checksum: fmt.Sprintf("sha256:%x", sha256.Sum256(data))}
+}
+
+func TestMakeLocalRepoNone(t *testing.T) {
+	repo, err := makeLocalRepo([]rpmmd.PackageSet{{Include: []string{"kernel"}}})
+	assert.NoError(t, err)
+	assert.Nil(t, repo)
+}
+
+func TestMakeLocalRepoChecksumMismatch(t *testing.T) {
+	pkg := writeLocalPackage(t, "hello-1.0-1.x86_64.rpm")
+	expected := pkg.Checksum
+	pkg.Checksum = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+	_, err := makeLocalRepo([]rpmmd.PackageSet{{LocalPackages: []rpmmd.LocalPackage{pkg}}})
+	assert.EqualError(t, err, fmt.Sprintf("checksum mismatch for local package %q: expected %s, got %s", pkg.Path, pkg.Checksum, expected))
+}
+
+func TestMakeLocalRepoDuplicate(t *testing.T) {
+	pkg1 := writeLocalPackage(t, "hello-1.0-1.x86_64.rpm")
+	pkg2 := writeLocalPackage(t, "hello-1.0-1.x86_64.rpm")
+
+	_, err := makeLocalRepo([]rpmmd.PackageSet{
+		{LocalPackages: []rpmmd.LocalPackage{pkg1}},
+		{LocalPackages: []rpmmd.LocalPackage{pkg2}},
+	})
+	assert.EqualError(t, err, `local package file name "hello-1.0-1.x86_64.rpm" is used more than once`)
+}
+
+func TestLocalRepoRequestAndResult(t *testing.T) {
+	pkg := rpmmd.LocalPackage{
+		Path:     "/srv/rpms/hello-1.0-1.x86_64.rpm",
+		Checksum: "sha256:" + fmt.Sprintf("%064x", 1),
+	}
+	repo := &localRepo{
+		dir:  "/tmp/local-packages-test",
+		pkgs: map[string]rpmmd.LocalPackage{"hello-1.0-1.x86_64.rpm": pkg},
+		config: rpmmd.RepoConfig{
+			Id:       "local-packages",
+			BaseURLs: []string{"file:///tmp/local-packages-test"},
+		},
+	}
+	baseos := rpmmd.RepoConfig{Id: "baseos", BaseURLs: []string{"https://example.com/baseos"}}
+
+	pkgSets := withLocalRepo([]rpmmd.PackageSet{
+		{Include: []string{"kernel"}, Repositories: []rpmmd.RepoConfig{baseos}},
+		{Include: []string{"tmux"}, Repositories: []rpmmd.RepoConfig{baseos}, LocalPackages: []rpmmd.LocalPackage{pkg}},
+	}, repo)
+	assert.Equal(t, []string{"kernel"}, pkgSets[0].Include)
+	assert.Equal(t, []string{"tmux", "hello-1.0-1.x86_64"}, pkgSets[1].Include)
+	for _, ps := range pkgSets {
+		assert.Equal(t, []rpmmd.RepoConfig{repo.config, baseos}, ps.Repositories)
+	}
+
+	// the chain must still be valid
+	solver := NewSolver("platform:el9", "9", "x86_64", "rhel9.0", "/tmp/cache")
+	_, _, err := solver.makeDepsolveRequest(pkgSets)
+	assert.NoError(t, err)
+
+	result := depsolveResult{
+		Packages: packageSpecs{
+			{Name: "hello", Version: "1.0", Release: "1", Arch: "x86_64", RepoID: repo.config.Hash(), RemoteLocation: "file:///tmp/local-packages-test/hello-1.0-1.x86_64.rpm", Checksum: "sha256:abcd"},
+			{Name: "tmux", Version: "3.2", Release: "1", Arch: "x86_64", RepoID: baseos.Hash(), RemoteLocation: "https://example.com/baseos/tmux-3.2-1.x86_64.rpm", Checksum: "sha256:1234"},
+		},
+		Repos: map[string]repoConfig{
+			repo.config.Hash(): {ID: repo.config.Hash()},
+			baseos.Hash():      {ID: baseos.Hash()},
+		},
+	}
+	require.NoError(t, result.resolveLocalPackages(repo))
+	packages, repos := result.toRPMMD(nil, nil)
+	repos = withoutLocalRepo(repos, repo)
+
+	assert.Equal(t, "file:///srv/rpms/hello-1.0-1.x86_64.rpm", packages[0].RemoteLocation)
+	assert.Equal(t, pkg.Checksum, packages[0].Checksum)
+	assert.Equal(t, "https://example.com/baseos/tmux-3.2-1.x86_64.rpm", packages[1].RemoteLocation)
+	require.Len(t, repos, 1)
+	assert.Equal(t, baseos.Hash(), repos[0].Id)
+}
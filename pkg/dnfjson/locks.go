@@ -0,0 +1,163 @@
+package dnfjson
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// LockConflictError is returned by Depsolve when the locked version of a
+// package cannot be installed.
+type LockConflictError struct {
+	Lock rpmmd.PackageLock
+	// Reason why the lock is unsatisfiable
+	Reason string
+	// Error of the depsolver if it failed because of the lock
+	Err error
+}
+
+func (e LockConflictError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("package %q is locked to %s: %s:\n%s", e.Lock.Name, e.Lock.EVR, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("package %q is locked to %s: %s", e.Lock.Name, e.Lock.EVR, e.Reason)
+}
+
+func (e LockConflictError) Unwrap() error {
+	return e.Err
+}
+
+// lockConflict returns the error for a failed depsolve of package sets with
+// locks. The package sets are depsolved again without the locks. If that
+// fails too, the locks are not the cause of the error. Otherwise a
+// LockConflictError wrapping the error is returned for the first lock of a
+// package that is resolved to another version without the locks.
+func (s *Solver) lockConflict(pkgSets []rpmmd.PackageSet, locks []rpmmd.PackageLock, cache bool, depsolveErr error) error {
+	req, _, err := s.makeDepsolveRequest(pkgSets)
+	if err != nil {
+		return fmt.Errorf("makeDepsolveRequest failed: %w", err)
+	}
+	result, err := s.solve(req, cache)
+	if err != nil {
+		return fmt.Errorf("running osbuild-depsolve-dnf failed:\n%w", depsolveErr)
+	}
+	for _, lock := range locks {
+		for _, pkg := range result.Packages {
+			if pkg.Name == lock.Name && pkg.evr() != normalizeEVR(lock.EVR) {
+				return LockConflictError{Lock: lock, Reason: fmt.Sprintf("the packages can only be resolved with %s", pkg.evr()), Err: depsolveErr}
+			}
+		}
+	}
+	return fmt.Errorf("running osbuild-depsolve-dnf failed, the package locks %v are unsatisfiable:\n%w", locks, depsolveErr)
+}
+
+// lockExcludes returns exclude specs for all the versions of the locked
+// packages in the repositories of the package sets except the locked ones,
+// so that the depsolver can only pick the locked versions. A
+// LockConflictError is returned if the locked version of a package is not
+// available.
+func (s *Solver) lockExcludes(pkgSets []rpmmd.PackageSet, locks []rpmmd.PackageLock) ([]string, error) {
+	// chained package sets use all the repositories of their predecessors
+	repos := pkgSets[len(pkgSets)-1].Repositories
+	names := make([]string, len(locks))
+	for idx, lock := range locks {
+		names[idx] = lock.Name
+	}
+	pkgs, err := s.SearchMetadata(repos, names)
+	if err != nil {
+		return nil, fmt.Errorf("searching the locked packages failed: %w", err)
+	}
+
+	var excludes []string
+	for _, lock := range locks {
+		available := false
+		for _, pkg := range pkgs {
+			if pkg.Name != lock.Name {
+				continue
+			}
+			evr := formatEVR(pkg.Epoch, pkg.Version, pkg.Release)
+			if evr == normalizeEVR(lock.EVR) {
+				available = true
+				continue
+			}
+			if spec := pkg.Name + "-" + evr; !slices.Contains(excludes, spec) {
+				excludes = append(excludes, spec)
+			}
+		}
+		if !available {
+			return nil, LockConflictError{Lock: lock, Reason: "the version is not available in the repositories"}
+		}
+	}
+	return excludes, nil
+}
+
+// withExcludes returns copies of the package sets with the excludes added.
+func withExcludes(pkgSets []rpmmd.PackageSet, excludes []string) []rpmmd.PackageSet {
+	result := make([]rpmmd.PackageSet, len(pkgSets))
+	for idx, ps := range pkgSets {
+		ps.Exclude = append(append([]string{}, ps.Exclude...), excludes...)
+		result[idx] = ps
+	}
+	return result
+}
+
+// collectLocks returns the locks of all package sets, or an error if they
+// conflict with each other or with the excluded packages.
+func collectLocks(pkgSets []rpmmd.PackageSet) ([]rpmmd.PackageLock, error) {
+	var locks []rpmmd.PackageLock
+	evrs := make(map[string]string)
+	for _, ps := range pkgSets {
+		for _, lock := range ps.Locks {
+			if evr, ok := evrs[lock.Name]; ok {
+				if evr != lock.EVR {
+					return nil, LockConflictError{Lock: lock, Reason: fmt.Sprintf("also locked to %s", evr)}
+				}
+				continue
+			}
+			evrs[lock.Name] = lock.EVR
+			locks = append(locks, lock)
+		}
+	}
+	for _, ps := range pkgSets {
+		for _, lock := range locks {
+			if slices.Contains(ps.Exclude, lock.Name) {
+				return nil, LockConflictError{Lock: lock, Reason: "the package is excluded"}
+			}
+		}
+	}
+	return locks, nil
+}
+
+// normalizeEVR drops a zero epoch from an [epoch:]version-release.
+func normalizeEVR(evr string) string {
+	return strings.TrimPrefix(evr, "0:")
+}
+
+// formatEVR returns the [epoch:]version-release of a package, without a
+// zero epoch.
+func formatEVR(epoch uint, version, release string) string {
+	if epoch == 0 {
+		return fmt.Sprintf("%s-%s", version, release)
+	}
+	return fmt.Sprintf("%d:%s-%s", epoch, version, release)
+}
+
+func (pkg PackageSpec) evr() string {
+	return formatEVR(pkg.Epoch, pkg.Version, pkg.Release)
+}
+
+// checkLocks returns an error if a locked package of the depsolve result was
+// resolved to another version. Locked packages that are not installed are
+// not an error.
+func (result depsolveResult) checkLocks(locks []rpmmd.PackageLock) error {
+	for _, lock := range locks {
+		for _, pkg := range result.Packages {
+			if pkg.Name == lock.Name && pkg.evr() != normalizeEVR(lock.EVR) {
+				return LockConflictError{Lock: lock, Reason: fmt.Sprintf("resolved to %s instead", pkg.evr())}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package dnfjson
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestCollectLocks(t *testing.T) {
+	openssl := rpmmd.PackageLock{Name: "openssl", EVR: "1:3.0.7-27.el9"}
+	httpd := rpmmd.PackageLock{Name: "httpd", EVR: "2.4.57-5.el9"}
+
+	locks, err := collectLocks([]rpmmd.PackageSet{
+		{Locks: []rpmmd.PackageLock{openssl}},
+		{Locks: []rpmmd.PackageLock{openssl, httpd}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []rpmmd.PackageLock{openssl, httpd}, locks)
+
+	_, err = collectLocks([]rpmmd.PackageSet{
+		{Locks: []rpmmd.PackageLock{openssl}},
+		{Locks: []rpmmd.PackageLock{{Name: "openssl", EVR: "1:3.0.7-28.el9"}}},
+	})
+	assert.EqualError(t, err, `package "openssl" is locked to 1:3.0.7-28.el9: also locked to 1:3.0.7-27.el9`)
+
+	_, err = collectLocks([]rpmmd.PackageSet{
+		{Locks: []rpmmd.PackageLock{openssl}},
+		{Exclude: []string{"openssl"}},
+	})
+	assert.EqualError(t, err, `package "openssl" is locked to 1:3.0.7-27.el9: the package is excluded`)
+}
+
+func TestCheckLocks(t *testing.T) {
+	result := depsolveResult{
+		Packages: packageSpecs{
+			{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "27.el9"},
+			{Name: "httpd", Version: "2.4.57", Release: "8.el9"},
+		},
+	}
+
+	assert.NoError(t, result.checkLocks([]rpmmd.PackageLock{{Name: "openssl", EVR: "1:3.0.7-27.el9"}}))
+	assert.NoError(t, result.checkLocks([]rpmmd.PackageLock{{Name: "httpd", EVR: "0:2.4.57-8.el9"}}))
+	// locks of packages that are not installed are not an error
+	assert.NoError(t, result.checkLocks([]rpmmd.PackageLock{{Name: "tmux", EVR: "3.2a-5.el9"}}))
+
+	err := result.checkLocks([]rpmmd.PackageLock{{Name: "httpd", EVR: "2.4.57-5.el9"}})
+	assert.EqualError(t, err, `package "httpd" is locked to 2.4.57-5.el9: resolved to 2.4.57-8.el9 instead`)
+	assert.IsType(t, LockConflictError{}, err)
+}
+
+// fakeLockSolver returns the path of a depsolver that finds two versions of
+// httpd and resolves httpd 2.4.57-8.el9 as a dependency of mod_ssl, or fails
+// if that version is excluded.
+func fakeLockSolver(t *testing.T, repoID string) string {
+	fakeSolver := fmt.Sprintf(`#!/bin/sh -e
+req=$(cat -)
+case "$req" in
+*'"command":"search"'*)
+	cat <<'END'
+[
+  {"name": "httpd", "version": "2.4.57", "release": "5.el9", "arch": "x86_64"},
+  {"name": "httpd", "version": "2.4.57", "release": "8.el9", "arch": "x86_64"},
+  {"name": "openssl", "epoch": 1, "version": "3.0.7", "release": "27.el9", "arch": "x86_64"}
+]
+END
+	;;
+*'httpd-2.4.57-8.el9'*)
+	cat <<'END'
+{"kind": "DepsolveError", "reason": "package mod_ssl-1:2.4.57-8.el9.x86_64 requires httpd = 2.4.57-8.el9, but none of the providers can be installed"}
+END
+	exit 1
+	;;
+*)
+	cat <<'END'
+{
+  "packages": [
+    {"name": "mod_ssl", "epoch": 1, "version": "2.4.57", "release": "8.el9", "arch": "x86_64", "repo_id": "%[1]s"},
+    {"name": "httpd", "version": "2.4.57", "release": "8.el9", "arch": "x86_64", "repo_id": "%[1]s"}
+  ],
+  "repos": {"%[1]s": {"id": "%[1]s", "name": "appstream"}}
+}
+END
+	;;
+esac
+`, repoID)
+	fakeSolverPath := filepath.Join(t.TempDir(), "fake-solver")
+	require.NoError(t, os.WriteFile(fakeSolverPath, []byte(fakeSolver), 0755)) //nolint:gosec
+	return fakeSolverPath
+}
+
+func TestSolverDepsolveLocks(t *testing.T) {
+	repo := rpmmd.RepoConfig{Name: "appstream", BaseURLs: []string{"https://example.com/appstream"}}
+	solver := NewSolver("platform:el9", "9", "x86_64", "rhel9.0", t.TempDir())
+	solver.SetDNFJSONPath(fakeLockSolver(t, repo.Hash()))
+
+	// the lock of the package that is not installed does not add it
+	pkgs, _, err := solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"mod_ssl"},
+			Repositories: []rpmmd.RepoConfig{repo},
+			Locks:        []rpmmd.PackageLock{{Name: "openssl", EVR: "1:3.0.7-27.el9"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Len(t, pkgs, 2)
+
+	// the other versions of a locked package are excluded, so the depsolve
+	// fails and is repeated without the locks to find the conflict
+	_, _, err = solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"mod_ssl"},
+			Repositories: []rpmmd.RepoConfig{repo},
+			Locks:        []rpmmd.PackageLock{{Name: "httpd", EVR: "2.4.57-5.el9"}},
+		},
+	})
+	assert.EqualError(t, err, `package "httpd" is locked to 2.4.57-5.el9: the packages can only be resolved with 2.4.57-8.el9:
+DNF error occurred: DepsolveError: package mod_ssl-1:2.4.57-8.el9.x86_64 requires httpd = 2.4.57-8.el9, but none of the providers can be installed`)
+	var lockErr LockConflictError
+	require.ErrorAs(t, err, &lockErr)
+	var dnfErr Error
+	assert.ErrorAs(t, err, &dnfErr)
+
+	_, _, err = solver.Depsolve([]rpmmd.PackageSet{
+		{
+			Include:      []string{"mod_ssl"},
+			Repositories: []rpmmd.RepoConfig{repo},
+			Locks:        []rpmmd.PackageLock{{Name: "httpd", EVR: "2.4.57-9.el9"}},
+		},
+	})
+	assert.EqualError(t, err, `package "httpd" is locked to 2.4.57-9.el9: the version is not available in the repositories`)
+}
+
+func TestLockExcludes(t *testing.T) {
+	repo := rpmmd.RepoConfig{Name: "appstream", BaseURLs: []string{"https://example.com/appstream"}}
+	solver := NewSolver("platform:el9", "9", "x86_64", "rhel9.0", t.TempDir())
+	solver.SetDNFJSONPath(fakeLockSolver(t, repo.Hash()))
+
+	pkgSets := []rpmmd.PackageSet{{Include: []string{"mod_ssl"}, Repositories: []rpmmd.RepoConfig{repo}}}
+	excludes, err := solver.lockExcludes(pkgSets, []rpmmd.PackageLock{
+		{Name: "httpd", EVR: "0:2.4.57-5.el9"},
+		{Name: "openssl", EVR: "1:3.0.7-27.el9"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"httpd-2.4.57-8.el9"}, excludes)
+
+	locked := withExcludes(pkgSets, excludes)
+	assert.Equal(t, []string{"httpd-2.4.57-8.el9"}, locked[0].Exclude)
+	// the package sets must not be modified
+	assert.Nil(t, pkgSets[0].Exclude)
+}
@@ -0,0 +1,135 @@
+package dnfjson
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+type updateinfoXML struct {
+	Updates []struct {
+		Type     string `xml:"type,attr"`
+		ID       string `xml:"id"`
+		Title    string `xml:"title"`
+		Severity string `xml:"severity"`
+		Issued   struct {
+			Date string `xml:"date,attr"`
+		} `xml:"issued"`
+		References []struct {
+			Type string `xml:"type,attr"`
+			ID   string `xml:"id,attr"`
+		} `xml:"references>reference"`
+		Packages []struct {
+			Name    string `xml:"name,attr"`
+			Epoch   string `xml:"epoch,attr"`
+			Version string `xml:"version,attr"`
+			Release string `xml:"release,attr"`
+			Arch    string `xml:"arch,attr"`
+		} `xml:"pkglist>collection>package"`
+	} `xml:"update"`
+}
+
+// parseUpdateInfo parses the advisories of an updateinfo.xml file.
+func parseUpdateInfo(r io.Reader) ([]rpmmd.Advisory, error) {
+	var updateinfo updateinfoXML
+	if err := xml.NewDecoder(r).Decode(&updateinfo); err != nil {
+		return nil, err
+	}
+
+	advisories := make([]rpmmd.Advisory, 0, len(updateinfo.Updates))
+	for _, update := range updateinfo.Updates {
+		advisory := rpmmd.Advisory{
+			ID:       update.ID,
+			Type:     update.Type,
+			Title:    update.Title,
+			Severity: update.Severity,
+			Issued:   update.Issued.Date,
+		}
+		for _, ref := range update.References {
+			if ref.Type == "cve" {
+				advisory.CVEs = append(advisory.CVEs, ref.ID)
+			}
+		}
+		for _, pkg := range update.Packages {
+			var epoch uint64
+			if pkg.Epoch != "" {
+				var err error
+				if epoch, err = strconv.ParseUint(pkg.Epoch, 10, 32); err != nil {
+					return nil, fmt.Errorf("advisory %s: package %s has an invalid epoch %q", update.ID, pkg.Name, pkg.Epoch)
+				}
+			}
+			advisory.Packages = append(advisory.Packages, rpmmd.AdvisoryPackage{
+				Name:    pkg.Name,
+				Epoch:   uint(epoch),
+				Version: pkg.Version,
+				Release: pkg.Release,
+				Arch:    pkg.Arch,
+			})
+		}
+		advisories = append(advisories, advisory)
+	}
+	return advisories, nil
+}
+
+// fetchUpdateInfo returns the advisories of the repository, or nil if the
+// repository has no updateinfo metadata.
+func fetchUpdateInfo(repo repoConfig, proxy string) ([]rpmmd.Advisory, error) {
+	client, err := repodata.NewClient(repo.repodata(proxy))
+	if err != nil {
+		return nil, err
+	}
+	repomd, err := client.Repomd()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := repomd.Find("updateinfo")
+	if !ok {
+		return nil, nil
+	}
+	f, err := client.OpenData(data)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	advisories, err := parseUpdateInfo(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", data.Location.Href, err)
+	}
+	return advisories, nil
+}
+
+// FetchUpdateInfo returns the advisories from the updateinfo metadata of the
+// repositories. Repositories without updateinfo metadata are skipped and
+// advisories that appear in more than one repository are only returned once.
+// The metadata is fetched from the first base URL of each repository or, for
+// repositories with only a metalink or mirrorlist, from the preferred mirror.
+// Only file, http and https URLs are supported.
+//
+// The advisories can be checked against the packages of a depsolve result
+// with rpmmd.CheckAdvisories().
+func (s *Solver) FetchUpdateInfo(repos []rpmmd.RepoConfig) ([]rpmmd.Advisory, error) {
+	dnfRepos, err := s.reposFromRPMMD(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []rpmmd.Advisory
+	seen := make(map[string]bool)
+	for _, repo := range dnfRepos {
+		advisories, err := fetchUpdateInfo(repo, s.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("fetching updateinfo of repository %q failed: %w", repo.Name, err)
+		}
+		for _, advisory := range advisories {
+			if !seen[advisory.ID] {
+				seen[advisory.ID] = true
+				result = append(result, advisory)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package dnfjson
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+const testUpdateInfo = `<?xml version="1.0" encoding="UTF-8"?>
+<updates>
+  <update from="release-engineering@redhat.com" status="final" type="security" version="2">
+    <id>RHSA-2024:0001</id>
+    <title>Critical: openssl security update</title>
+    <issued date="2024-01-02 00:00:00"/>
+    <severity>Critical</severity>
+    <references>
+      <reference href="https://access.redhat.com/errata/RHSA-2024:0001" id="RHSA-2024:0001" type="self"/>
+      <reference href="https://access.redhat.com/security/cve/CVE-2024-0001" id="CVE-2024-0001" type="cve"/>
+      <reference href="https://access.redhat.com/security/cve/CVE-2024-0002" id="CVE-2024-0002" type="cve"/>
+    </references>
+    <pkglist>
+      <collection short="rhel-9">
+        <name>rhel-9</name>
+        <package name="openssl" epoch="1" version="3.0.7" release="28.el9" arch="x86_64" src="openssl-3.0.7-28.el9.src.rpm">
+          <filename>openssl-3.0.7-28.el9.x86_64.rpm</filename>
+        </package>
+      </collection>
+    </pkglist>
+  </update>
+  <update status="final" type="bugfix" version="2">
+    <id>RHBA-2024:0002</id>
+    <title>tmux bug fix update</title>
+    <issued date="2024-01-03 00:00:00"/>
+    <pkglist>
+      <collection>
+        <package name="tmux" version="3.2a" release="5.el9" arch="x86_64"/>
+      </collection>
+    </pkglist>
+  </update>
+</updates>
+`
+
+// makeUpdateInfoRepo creates the metadata of a repository with the given
+// updateinfo.xml, compressed with gzip or zstd depending on the extension,
+// and returns its base URL.
+func makeUpdateInfoRepo(t *testing.T, updateinfo string, ext string) string {
+	repoDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "repodata"), 0755))
+
+	repomd := `<repomd><revision>1</revision>`
+	if updateinfo != "" {
+		href := "repodata/updateinfo.xml" + ext
+		repomd += `<data type="updateinfo"><location href="` + href + `"/></data>`
+		f, err := os.Create(filepath.Join(repoDir, href))
+		require.NoError(t, err)
+		var w io.WriteCloser
+		switch ext {
+		case ".gz":
+			w = gzip.NewWriter(f)
+		case ".zst":
+			w, err = zstd.NewWriter(f)
+			require.NoError(t, err)
+		default:
+			t.Fatalf("unsupported extension %s", ext)
+		}
+		_, err = w.Write([]byte(updateinfo))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+	}
+	repomd += `</repomd>`
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "repodata", "repomd.xml"), []byte(repomd), 0644))
+	return "file://" + repoDir
+}
+
+func TestFetchUpdateInfo(t *testing.T) {
+	// repositories with only a metalink are fetched from its first mirror
+	metalink := filepath.Join(t.TempDir(), "metalink.xml")
+	require.NoError(t, os.WriteFile(metalink, []byte(`<metalink><files><file name="repomd.xml"><resources>
+<url protocol="file" preference="100">`+makeUpdateInfoRepo(t, testUpdateInfo, ".zst")+`/repodata/repomd.xml</url>
+</resources></file></files></metalink>`), 0644))
+
+	repos := []rpmmd.RepoConfig{
+		{Name: "baseos", BaseURLs: []string{makeUpdateInfoRepo(t, testUpdateInfo, ".gz")}},
+		// advisories are deduplicated
+		{Name: "baseos-copy", Metalink: "file://" + metalink},
+		// repositories without updateinfo are skipped
+		{Name: "extras", BaseURLs: []string{makeUpdateInfoRepo(t, "", "")}},
+	}
+
+	solver := NewSolver("platform:el9", "9", "x86_64", "rhel9.0", t.TempDir())
+	advisories, err := solver.FetchUpdateInfo(repos)
+	require.NoError(t, err)
+	assert.Equal(t, []rpmmd.Advisory{
+		{
+			ID:       "RHSA-2024:0001",
+			Type:     "security",
+			Title:    "Critical: openssl security update",
+			Severity: "Critical",
+			Issued:   "2024-01-02 00:00:00",
+			CVEs:     []string{"CVE-2024-0001", "CVE-2024-0002"},
+			Packages: []rpmmd.AdvisoryPackage{{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "28.el9", Arch: "x86_64"}},
+		},
+		{
+			ID:       "RHBA-2024:0002",
+			Type:     "bugfix",
+			Title:    "tmux bug fix update",
+			Issued:   "2024-01-03 00:00:00",
+			Packages: []rpmmd.AdvisoryPackage{{Name: "tmux", Version: "3.2a", Release: "5.el9", Arch: "x86_64"}},
+		},
+	}, advisories)
+
+	// the outstanding critical advisories of a depsolve result
+	packages := []rpmmd.PackageSpec{
+		{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "27.el9", Arch: "x86_64"},
+		{Name: "tmux", Version: "3.2a", Release: "5.el9", Arch: "x86_64"},
+	}
+	outstanding := rpmmd.OutstandingAdvisories(rpmmd.CheckAdvisories(packages, advisories), "Critical")
+	require.Len(t, outstanding, 1)
+	assert.Equal(t, "RHSA-2024:0001", outstanding[0].Advisory.ID)
+
+	_, err = solver.FetchUpdateInfo([]rpmmd.RepoConfig{{Name: "broken", BaseURLs: []string{makeUpdateInfoRepo(t, "<updates", ".gz")}}})
+	assert.ErrorContains(t, err, `fetching updateinfo of repository "broken" failed: cannot parse repodata/updateinfo.xml.gz: XML syntax error`)
+}
@@ -0,0 +1,420 @@
+// Package lockfile persists the resolved content of a manifest: the depsolved
+// packages and their repositories, the container digests and the ostree commit
+// checksums of every pipeline. A manifest generated from a lockfile is
+// identical to the one generated from the original resolution, which makes it
+// possible to rebuild an image long after the repositories moved on.
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/mirror"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/proxy"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Version of the lockfile format
+const Version = 1
+
+type Lockfile struct {
+	Version int `json:"version"`
+
+	// Resolved content, keyed by pipeline name
+	Pipelines map[string]Pipeline `json:"pipelines"`
+}
+
+// Pipeline is the resolved content of a single manifest pipeline.
+type Pipeline struct {
+	// Hash of the package set chain the packages were depsolved from, see
+	// hashPackageSetChain()
+	PackageSetChain string `json:"package_set_chain,omitempty"`
+	// Depsolved packages of the package set chain of the pipeline
+	Packages []Package `json:"packages,omitempty"`
+	// Repositories the packages were depsolved from
+	Repositories []Repository `json:"repositories,omitempty"`
+	Containers   []Container  `json:"containers,omitempty"`
+	Commits      []Commit     `json:"ostree_commits,omitempty"`
+}
+
+type Package struct {
+	Name           string `json:"name"`
+	Epoch          uint   `json:"epoch,omitempty"`
+	Version        string `json:"version"`
+	Release        string `json:"release"`
+	Arch           string `json:"arch"`
+	RemoteLocation string `json:"remote_location"`
+	Checksum       string `json:"checksum"`
+	Secrets        string `json:"secrets,omitempty"`
+	CheckGPG       bool   `json:"check_gpg,omitempty"`
+	IgnoreSSL      bool   `json:"ignore_ssl,omitempty"`
+
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+
+	OriginalRemoteLocation string `json:"original_remote_location,omitempty"`
+}
+
+type Repository struct {
+	// Hash of the repository configuration, see rpmmd.RepoConfig.Hash()
+	Hash string `json:"hash"`
+
+	ID             string   `json:"id"`
+	Name           string   `json:"name,omitempty"`
+	BaseURLs       []string `json:"baseurls,omitempty"`
+	Metalink       string   `json:"metalink,omitempty"`
+	MirrorList     string   `json:"mirrorlist,omitempty"`
+	GPGKeys        []string `json:"gpgkeys,omitempty"`
+	CheckGPG       *bool    `json:"check_gpg,omitempty"`
+	CheckRepoGPG   *bool    `json:"check_repo_gpg,omitempty"`
+	IgnoreSSL      *bool    `json:"ignore_ssl,omitempty"`
+	MetadataExpire string   `json:"metadata_expire,omitempty"`
+	ModuleHotfixes *bool    `json:"module_hotfixes,omitempty"`
+	RHSM           bool     `json:"rhsm,omitempty"`
+	SSLCACert      string   `json:"sslcacert,omitempty"`
+	SSLClientKey   string   `json:"sslclientkey,omitempty"`
+	SSLClientCert  string   `json:"sslclientcert,omitempty"`
+
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+}
+
+type Container struct {
+	Source       string `json:"source"`
+	Digest       string `json:"digest"`
+	ImageID      string `json:"image_id"`
+	LocalName    string `json:"local_name"`
+	ListDigest   string `json:"list_digest,omitempty"`
+	TLSVerify    *bool  `json:"tls_verify,omitempty"`
+	LocalStorage bool   `json:"local_storage,omitempty"`
+	Arch         string `json:"arch"`
+
+	PolicyRequirements []container.SignatureRequirement `json:"policy_requirements,omitempty"`
+	Mirror             string                           `json:"mirror,omitempty"`
+	OriginalSource     string                           `json:"original_source,omitempty"`
+}
+
+type Commit struct {
+	Ref        string `json:"ref,omitempty"`
+	URL        string `json:"url,omitempty"`
+	ContentURL string `json:"content_url,omitempty"`
+	Secrets    string `json:"secrets,omitempty"`
+	Checksum   string `json:"checksum"`
+
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+
+	OriginalURL        string `json:"original_url,omitempty"`
+	OriginalContentURL string `json:"original_content_url,omitempty"`
+}
+
+// New creates a lockfile from the resolved content of a manifest, as passed
+// to manifest.Manifest.Serialize(), and the package set chains of the
+// manifest the packages were depsolved from.
+func New(packageSets map[string][]rpmmd.PackageSpec, containerSpecs map[string][]container.Spec, ostreeCommits map[string][]ostree.CommitSpec, rpmRepos map[string][]rpmmd.RepoConfig, packageSetChains map[string][]rpmmd.PackageSet) *Lockfile {
+	lf := &Lockfile{
+		Version:   Version,
+		Pipelines: make(map[string]Pipeline),
+	}
+	get := func(name string) Pipeline {
+		return lf.Pipelines[name]
+	}
+
+	for name, pkgs := range packageSets {
+		pl := get(name)
+		for _, pkg := range pkgs {
+			pl.Packages = append(pl.Packages, Package(pkg))
+		}
+		lf.Pipelines[name] = pl
+	}
+
+	for name, chain := range packageSetChains {
+		pl := get(name)
+		pl.PackageSetChain = hashPackageSetChain(chain)
+		lf.Pipelines[name] = pl
+	}
+
+	for name, repos := range rpmRepos {
+		pl := get(name)
+		for _, repo := range repos {
+			pl.Repositories = append(pl.Repositories, Repository{
+				Hash:           repo.Hash(),
+				ID:             repo.Id,
+				Name:           repo.Name,
+				BaseURLs:       repo.BaseURLs,
+				Metalink:       repo.Metalink,
+				MirrorList:     repo.MirrorList,
+				GPGKeys:        repo.GPGKeys,
+				CheckGPG:       repo.CheckGPG,
+				CheckRepoGPG:   repo.CheckRepoGPG,
+				IgnoreSSL:      repo.IgnoreSSL,
+				MetadataExpire: repo.MetadataExpire,
+				ModuleHotfixes: repo.ModuleHotfixes,
+				RHSM:           repo.RHSM,
+				SSLCACert:      repo.SSLCACert,
+				SSLClientKey:   repo.SSLClientKey,
+				SSLClientCert:  repo.SSLClientCert,
+				Proxy:          repo.Proxy,
+			})
+		}
+		// the depsolver returns the repositories in random order
+		sort.Slice(pl.Repositories, func(i, j int) bool {
+			return pl.Repositories[i].ID < pl.Repositories[j].ID
+		})
+		lf.Pipelines[name] = pl
+	}
+
+	for name, specs := range containerSpecs {
+		pl := get(name)
+		for _, spec := range specs {
+			pl.Containers = append(pl.Containers, Container{
+				Source:             spec.Source,
+				Digest:             spec.Digest,
+				ImageID:            spec.ImageID,
+				LocalName:          spec.LocalName,
+				ListDigest:         spec.ListDigest,
+				TLSVerify:          spec.TLSVerify,
+				LocalStorage:       spec.LocalStorage,
+				Arch:               spec.Arch.String(),
+				PolicyRequirements: spec.PolicyRequirements,
+				Mirror:             spec.Mirror,
+				OriginalSource:     spec.OriginalSource,
+			})
+		}
+		lf.Pipelines[name] = pl
+	}
+
+	for name, commits := range ostreeCommits {
+		pl := get(name)
+		for _, commit := range commits {
+			pl.Commits = append(pl.Commits, Commit(commit))
+		}
+		lf.Pipelines[name] = pl
+	}
+
+	return lf
+}
+
+// Read parses a lockfile and checks its version and integrity.
+func Read(r io.Reader) (*Lockfile, error) {
+	var lf Lockfile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&lf); err != nil {
+		return nil, fmt.Errorf("cannot decode lockfile: %w", err)
+	}
+	if lf.Version != Version {
+		return nil, fmt.Errorf("unsupported lockfile version %d, expected %d", lf.Version, Version)
+	}
+	for name, pl := range lf.Pipelines {
+		for _, c := range pl.Containers {
+			if _, err := parseArch(c.Arch); err != nil {
+				return nil, fmt.Errorf("container %q of pipeline %q: %w", c.Source, name, err)
+			}
+		}
+		for _, repo := range pl.Repositories {
+			rpmRepo := repo.toRPMMD()
+			if rpmRepo.Hash() != repo.Hash {
+				return nil, fmt.Errorf("repository %q of pipeline %q does not match its hash %s", repo.ID, name, repo.Hash)
+			}
+		}
+	}
+	return &lf, nil
+}
+
+// Load reads the lockfile at the given path.
+func Load(path string) (*Lockfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write writes the lockfile as indented JSON.
+func (lf *Lockfile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lf)
+}
+
+// Save writes the lockfile to the given path.
+func (lf *Lockfile) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := lf.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write lockfile %q: %w", path, err)
+	}
+	return f.Close()
+}
+
+func parseArch(name string) (arch.Arch, error) {
+	switch name {
+	case arch.ARCH_UNSET.String():
+		return arch.ARCH_UNSET, nil
+	case "x86_64", "aarch64", "ppc64le", "s390x":
+		return arch.FromString(name), nil
+	default:
+		return arch.ARCH_UNSET, fmt.Errorf("unsupported architecture %q", name)
+	}
+}
+
+func (repo Repository) toRPMMD() rpmmd.RepoConfig {
+	return rpmmd.RepoConfig{
+		Id:             repo.ID,
+		Name:           repo.Name,
+		BaseURLs:       repo.BaseURLs,
+		Metalink:       repo.Metalink,
+		MirrorList:     repo.MirrorList,
+		GPGKeys:        repo.GPGKeys,
+		CheckGPG:       repo.CheckGPG,
+		CheckRepoGPG:   repo.CheckRepoGPG,
+		IgnoreSSL:      repo.IgnoreSSL,
+		MetadataExpire: repo.MetadataExpire,
+		ModuleHotfixes: repo.ModuleHotfixes,
+		RHSM:           repo.RHSM,
+		SSLCACert:      repo.SSLCACert,
+		SSLClientKey:   repo.SSLClientKey,
+		SSLClientCert:  repo.SSLClientCert,
+		Proxy:          repo.Proxy,
+	}
+}
+
+// hashPackageSetChain returns a hash of a package set chain, which changes if
+// the packages, repositories or options of any of its package sets change.
+//
+//nolint:errcheck
+func hashPackageSetChain(chain []rpmmd.PackageSet) string {
+	h := sha256.New()
+	for _, ps := range chain {
+		// separate the fields so that different chains cannot hash to the
+		// same value
+		h.Write([]byte(strings.Join(ps.Include, ",") + "\x00"))
+		h.Write([]byte(strings.Join(ps.Exclude, ",") + "\x00"))
+		for _, repo := range ps.Repositories {
+			h.Write([]byte(repo.Hash() + ","))
+		}
+		h.Write([]byte(fmt.Sprintf("\x00%v\x00", ps.InstallWeakDeps)))
+		for _, pkg := range ps.LocalPackages {
+			h.Write([]byte(pkg.Path + ":" + pkg.Checksum + ","))
+		}
+		h.Write([]byte("\x00"))
+		for _, lock := range ps.Locks {
+			h.Write([]byte(lock.Spec() + ","))
+		}
+		h.Write([]byte("\x00"))
+		for _, module := range ps.EnabledModules {
+			h.Write([]byte(module.Spec() + ","))
+		}
+		h.Write([]byte("\x01"))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Resolve returns the content of the lockfile in the form expected by
+// manifest.Manifest.Serialize(). It returns an error if the lockfile does not
+// contain the content for every pipeline of the manifest that needs to be
+// resolved, i.e. if the manifest changed since the lockfile was created: the
+// package set chains, container sources and ostree sources of the manifest
+// must be the ones the content was resolved from. The content and the
+// container and ostree sources are rewritten with the rewriter, which can be
+// nil, before they are compared, so the lockfile matches whether or not it
+// was created with the same rewrite rules.
+func (lf *Lockfile) Resolve(m *manifest.Manifest, rewriter *mirror.Rewriter) (map[string][]rpmmd.PackageSpec, map[string][]container.Spec, map[string][]ostree.CommitSpec, map[string][]rpmmd.RepoConfig, error) {
+	packageSets := make(map[string][]rpmmd.PackageSpec)
+	containerSpecs := make(map[string][]container.Spec)
+	ostreeCommits := make(map[string][]ostree.CommitSpec)
+	rpmRepos := make(map[string][]rpmmd.RepoConfig)
+
+	for name, chain := range m.GetPackageSetChains() {
+		pl, ok := lf.Pipelines[name]
+		if !ok || len(pl.Packages) == 0 {
+			return nil, nil, nil, nil, fmt.Errorf("lockfile has no packages for pipeline %q", name)
+		}
+		if pl.PackageSetChain != hashPackageSetChain(chain) {
+			return nil, nil, nil, nil, fmt.Errorf("lockfile packages of pipeline %q were depsolved from a different package set chain", name)
+		}
+		for _, pkg := range pl.Packages {
+			packageSets[name] = append(packageSets[name], rpmmd.PackageSpec(pkg))
+		}
+		for _, repo := range pl.Repositories {
+			rpmRepos[name] = append(rpmRepos[name], repo.toRPMMD())
+		}
+	}
+
+	for name, sources := range rewriter.RewriteContainerSources(m.GetContainerSourceSpecs()) {
+		pl := lf.Pipelines[name]
+		if len(pl.Containers) != len(sources) {
+			return nil, nil, nil, nil, fmt.Errorf("lockfile has %d containers for pipeline %q, the manifest requires %d", len(pl.Containers), name, len(sources))
+		}
+		for _, c := range pl.Containers {
+			containerArch, err := parseArch(c.Arch)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			containerSpecs[name] = append(containerSpecs[name], container.Spec{
+				Source:             c.Source,
+				Digest:             c.Digest,
+				ImageID:            c.ImageID,
+				LocalName:          c.LocalName,
+				ListDigest:         c.ListDigest,
+				TLSVerify:          c.TLSVerify,
+				LocalStorage:       c.LocalStorage,
+				Arch:               containerArch,
+				PolicyRequirements: c.PolicyRequirements,
+				Mirror:             c.Mirror,
+				OriginalSource:     c.OriginalSource,
+			})
+		}
+		containerSpecs[name] = rewriter.RewriteContainerSpecs(map[string][]container.Spec{name: containerSpecs[name]})[name]
+
+		// the resolved containers are sorted by digest, so they are matched
+		// to the sources by their names rather than by their position
+		matched := make([]bool, len(containerSpecs[name]))
+		for _, src := range sources {
+			source, localName, err := src.SpecNames()
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			found := false
+			for idx, c := range containerSpecs[name] {
+				if !matched[idx] && c.Source == source && c.LocalName == localName {
+					matched[idx] = true
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, nil, nil, nil, fmt.Errorf("lockfile has no container %s (%s) for pipeline %q", source, localName, name)
+			}
+		}
+	}
+
+	for name, sources := range rewriter.RewriteOSTreeSources(m.GetOSTreeSourceSpecs()) {
+		pl := lf.Pipelines[name]
+		if len(pl.Commits) != len(sources) {
+			return nil, nil, nil, nil, fmt.Errorf("lockfile has %d ostree commits for pipeline %q, the manifest requires %d", len(pl.Commits), name, len(sources))
+		}
+		for _, c := range pl.Commits {
+			ostreeCommits[name] = append(ostreeCommits[name], ostree.CommitSpec(c))
+		}
+		ostreeCommits[name] = rewriter.RewriteCommitSpecs(map[string][]ostree.CommitSpec{name: ostreeCommits[name]})[name]
+		for idx, c := range ostreeCommits[name] {
+			if c.URL != sources[idx].URL || c.Ref != sources[idx].Ref {
+				return nil, nil, nil, nil, fmt.Errorf("lockfile ostree commit %s (%s) of pipeline %q does not match the manifest source %s (%s)", c.URL, c.Ref, name, sources[idx].URL, sources[idx].Ref)
+			}
+		}
+	}
+
+	return rewriter.RewritePackageSpecs(packageSets), containerSpecs, ostreeCommits, rewriter.RewriteRepoConfigs(rpmRepos), nil
+}
@@ -0,0 +1,186 @@
+package lockfile
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/arch"
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/manifest"
+	"github.com/osbuild/images/pkg/mirror"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/platform"
+	"github.com/osbuild/images/pkg/rpmmd"
+	"github.com/osbuild/images/pkg/runner"
+)
+
+func testManifest(ostreeParent *ostree.SourceSpec) *manifest.Manifest {
+	return testManifestWith(ostreeParent, "registry.example.com/app:latest", "https://example.com/baseos")
+}
+
+func testManifestWith(ostreeParent *ostree.SourceSpec, containerSource string, baseURL string) *manifest.Manifest {
+	repos := []rpmmd.RepoConfig{{Name: "baseos", BaseURLs: []string{baseURL}}}
+	m := manifest.New()
+	build := manifest.NewBuild(&m, &runner.Fedora{Version: 40}, repos, nil)
+	os := manifest.NewOS(build, &platform.X86{BIOS: true}, repos)
+	os.OSCustomizations.Containers = []container.SourceSpec{{Source: containerSource}}
+	os.OSTreeParent = ostreeParent
+	return &m
+}
+
+func testContent() (map[string][]rpmmd.PackageSpec, map[string][]container.Spec, map[string][]ostree.CommitSpec, map[string][]rpmmd.RepoConfig) {
+	repo := rpmmd.RepoConfig{
+		Name:     "baseos",
+		BaseURLs: []string{"https://example.com/baseos"},
+		CheckGPG: common.ToPtr(true),
+		GPGKeys:  []string{"-----BEGIN PGP PUBLIC KEY BLOCK-----"},
+	}
+	repo.Id = repo.Hash()
+	appstream := rpmmd.RepoConfig{Name: "appstream", BaseURLs: []string{"https://example.com/appstream"}}
+	appstream.Id = appstream.Hash()
+
+	pkg := func(name string) rpmmd.PackageSpec {
+		return rpmmd.PackageSpec{
+			Name:           name,
+			Version:        "1.0",
+			Release:        "1.fc40",
+			Arch:           "x86_64",
+			RemoteLocation: "https://example.com/baseos/" + name + "-1.0-1.fc40.x86_64.rpm",
+			Checksum:       "sha256:" + strings.Repeat("a", 64),
+			CheckGPG:       true,
+		}
+	}
+
+	packages := map[string][]rpmmd.PackageSpec{
+		"build": {pkg("rpm"), pkg("coreutils")},
+		"os":    {pkg("kernel"), pkg("systemd")},
+	}
+	containers := map[string][]container.Spec{
+		"os": {{
+			Source:    "registry.example.com/app",
+			Digest:    "sha256:" + strings.Repeat("b", 64),
+			ImageID:   "sha256:" + strings.Repeat("c", 64),
+			LocalName: "registry.example.com/app:latest",
+			Arch:      arch.ARCH_X86_64,
+		}},
+	}
+	commits := map[string][]ostree.CommitSpec{
+		"os": {{Ref: "fedora/40/x86_64/iot", URL: "https://example.com/ostree", Checksum: strings.Repeat("d", 64)}},
+	}
+	repos := map[string][]rpmmd.RepoConfig{
+		"build": {repo},
+		"os":    {repo, appstream},
+	}
+	return packages, containers, commits, repos
+}
+
+func TestRoundTrip(t *testing.T) {
+	packages, containers, commits, repos := testContent()
+	m := testManifest(&ostree.SourceSpec{URL: "https://example.com/ostree", Ref: "fedora/40/x86_64/iot"})
+	lf := New(packages, containers, commits, repos, m.GetPackageSetChains())
+	assert.NotEmpty(t, lf.Pipelines["os"].PackageSetChain)
+	assert.NotEqual(t, lf.Pipelines["os"].PackageSetChain, lf.Pipelines["build"].PackageSetChain)
+
+	var buf bytes.Buffer
+	require.NoError(t, lf.Write(&buf))
+	loaded, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, lf, loaded)
+
+	// repositories are sorted by ID for a stable file
+	ids := []string{loaded.Pipelines["os"].Repositories[0].ID, loaded.Pipelines["os"].Repositories[1].ID}
+	assert.IsIncreasing(t, ids)
+
+	resPackages, resContainers, resCommits, resRepos, err := loaded.Resolve(m, nil)
+	require.NoError(t, err)
+	assert.Equal(t, packages, resPackages)
+	assert.Equal(t, containers, resContainers)
+	assert.Equal(t, commits, resCommits)
+	assert.ElementsMatch(t, repos["os"], resRepos["os"])
+	assert.Equal(t, repos["build"], resRepos["build"])
+}
+
+func TestResolveMismatch(t *testing.T) {
+	packages, containers, commits, repos := testContent()
+	chains := testManifest(nil).GetPackageSetChains()
+
+	lf := New(map[string][]rpmmd.PackageSpec{"build": packages["build"]}, containers, commits, repos, chains)
+	_, _, _, _, err := lf.Resolve(testManifest(nil), nil)
+	assert.EqualError(t, err, `lockfile has no packages for pipeline "os"`)
+
+	// the packages were depsolved with other repositories
+	lf = New(packages, containers, commits, repos, chains)
+	_, _, _, _, err = lf.Resolve(testManifestWith(nil, "registry.example.com/app:latest", "https://mirror.example.com/baseos"), nil)
+	assert.Regexp(t, `^lockfile packages of pipeline "(build|os)" were depsolved from a different package set chain$`, err.Error())
+
+	// lockfiles without the hashes of the package set chains are rejected
+	lf = New(packages, containers, commits, repos, nil)
+	_, _, _, _, err = lf.Resolve(testManifest(nil), nil)
+	assert.ErrorContains(t, err, "were depsolved from a different package set chain")
+
+	lf = New(packages, nil, commits, repos, chains)
+	_, _, _, _, err = lf.Resolve(testManifest(nil), nil)
+	assert.EqualError(t, err, `lockfile has 0 containers for pipeline "os", the manifest requires 1`)
+
+	lf = New(packages, containers, commits, repos, chains)
+	_, _, _, _, err = lf.Resolve(testManifestWith(nil, "registry.example.com/app:v2", "https://example.com/baseos"), nil)
+	assert.EqualError(t, err, `lockfile has no container registry.example.com/app (registry.example.com/app:v2) for pipeline "os"`)
+	_, _, _, _, err = lf.Resolve(testManifestWith(nil, "registry.example.com/other:latest", "https://example.com/baseos"), nil)
+	assert.EqualError(t, err, `lockfile has no container registry.example.com/other (registry.example.com/other:latest) for pipeline "os"`)
+
+	_, _, _, _, err = lf.Resolve(testManifest(&ostree.SourceSpec{URL: "https://example.com/ostree", Ref: "fedora/41/x86_64/iot"}), nil)
+	assert.EqualError(t, err, `lockfile ostree commit https://example.com/ostree (fedora/40/x86_64/iot) of pipeline "os" does not match the manifest source https://example.com/ostree (fedora/41/x86_64/iot)`)
+}
+
+func TestResolveRewrite(t *testing.T) {
+	rewriter, err := mirror.NewRewriter([]mirror.Rule{
+		{Prefix: "https://example.com/", Replace: "https://mirror.lab/"},
+		{Prefix: "registry.example.com/", Replace: "registry.lab/"},
+	})
+	require.NoError(t, err)
+
+	packages, containers, commits, repos := testContent()
+	m := testManifest(&ostree.SourceSpec{URL: "https://example.com/ostree", Ref: "fedora/40/x86_64/iot"})
+
+	// the content was resolved without the rules, or from the sources
+	// rewritten with them
+	for name, lf := range map[string]*Lockfile{
+		"original":  New(packages, containers, commits, repos, m.GetPackageSetChains()),
+		"rewritten": New(rewriter.RewritePackageSpecs(packages), rewriter.RewriteContainerSpecs(containers), rewriter.RewriteCommitSpecs(commits), rewriter.RewriteRepoConfigs(repos), m.GetPackageSetChains()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			resPackages, resContainers, resCommits, resRepos, err := lf.Resolve(m, rewriter)
+			require.NoError(t, err)
+			assert.Equal(t, "https://mirror.lab/baseos/kernel-1.0-1.fc40.x86_64.rpm", resPackages["os"][0].RemoteLocation)
+			assert.Equal(t, "registry.lab/app", resContainers["os"][0].Source)
+			assert.Equal(t, "registry.example.com/app:latest", resContainers["os"][0].LocalName)
+			assert.Equal(t, "https://mirror.lab/ostree", resCommits["os"][0].URL)
+			assert.Equal(t, []string{"https://mirror.lab/baseos"}, resRepos["build"][0].BaseURLs)
+		})
+	}
+
+	// content resolved from the rewritten sources does not match the
+	// manifest without the rules
+	lf := New(packages, rewriter.RewriteContainerSpecs(containers), commits, repos, m.GetPackageSetChains())
+	_, _, _, _, err = lf.Resolve(m, nil)
+	assert.EqualError(t, err, `lockfile has no container registry.example.com/app (registry.example.com/app:latest) for pipeline "os"`)
+}
+
+func TestReadErrors(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"version": 2, "pipelines": {}}`))
+	assert.EqualError(t, err, "unsupported lockfile version 2, expected 1")
+
+	_, err = Read(strings.NewReader(`{"version": 1, "pipelines": {}, "extra": true}`))
+	assert.ErrorContains(t, err, "cannot decode lockfile")
+
+	_, err = Read(strings.NewReader(`{"version": 1, "pipelines": {"os": {"repositories": [{"hash": "abc", "id": "baseos", "baseurls": ["https://example.com"]}]}}}`))
+	assert.EqualError(t, err, `repository "baseos" of pipeline "os" does not match its hash abc`)
+
+	_, err = Read(strings.NewReader(`{"version": 1, "pipelines": {"os": {"containers": [{"source": "app", "arch": "mips"}]}}}`))
+	assert.EqualError(t, err, `container "app" of pipeline "os": unsupported architecture "mips"`)
+}
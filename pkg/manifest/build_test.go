@@ -1,12 +1,14 @@
 package manifest
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/mirror"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/osbuild/images/pkg/runner"
@@ -127,6 +129,56 @@ func TestNewBuildFromContainerSpecs(t *testing.T) {
 	require.Nil(t, build.getContainerSpecs())
 }
 
+func TestNewBuildFromContainerOCI(t *testing.T) {
+	mf := New()
+	containers := []container.SourceSpec{{Source: "oci-archive:/srv/ci/buildroot.tar"}}
+	NewBuildFromContainer(&mf, &runner.Fedora{Version: 39}, containers, nil)
+
+	imageID := "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f"
+	osbuildManifest, err := mf.Serialize(nil, map[string][]container.Spec{
+		"build": {
+			{
+				Source:    "oci-archive:/srv/ci/buildroot.tar",
+				Digest:    "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+				ImageID:   imageID,
+				LocalName: "localhost/buildroot:latest",
+			},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	var m struct {
+		Pipelines []struct {
+			Name   string `json:"name"`
+			Stages []struct {
+				Type   string `json:"type"`
+				Inputs struct {
+					Images struct {
+						References map[string]json.RawMessage `json:"references"`
+					} `json:"images"`
+				} `json:"inputs"`
+			} `json:"stages"`
+		} `json:"pipelines"`
+		Sources map[string]struct {
+			Items map[string]osbuild.SkopeoSourceItem `json:"items"`
+		} `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(osbuildManifest, &m))
+
+	// the buildroot is deployed from the image copied from the archive
+	require.Len(t, m.Pipelines, 1)
+	assert.Equal(t, "build", m.Pipelines[0].Name)
+	assert.Equal(t, "org.osbuild.container-deploy", m.Pipelines[0].Stages[0].Type)
+	assert.Contains(t, m.Pipelines[0].Stages[0].Inputs.Images.References, imageID)
+
+	assert.Len(t, m.Sources, 1)
+	assert.Equal(t, osbuild.SkopeopSourceImage{
+		Name:                "/srv/ci/buildroot.tar",
+		Digest:              "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		ContainersTransport: "oci-archive",
+	}, m.Sources["org.osbuild.skopeo"].Items[imageID].Image)
+}
+
 func TestBuildFromContainerSpecsGetSelinuxLabelsNotBuildable(t *testing.T) {
 	build := &BuildrootFromContainer{}
 
@@ -146,3 +198,38 @@ func TestBuildFromContainerSpecsGetSelinuxLabelsWithContainerBuildable(t *testin
 		"/usr/bin/umount": "system_u:object_r:install_exec_t:s0",
 	})
 }
+
+func TestNewBuildFromContainerRewriter(t *testing.T) {
+	mf := New()
+	rewriter, err := mirror.NewRewriter([]mirror.Rule{{Prefix: "quay.io/", Replace: "registry.lab/"}})
+	require.NoError(t, err)
+	mf.Rewriter = rewriter
+	containers := []container.SourceSpec{{Source: "quay.io/centos-bootc/centos-bootc:stream9"}}
+	NewBuildFromContainer(&mf, &runner.Fedora{Version: 39}, containers, nil)
+
+	imageID := "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f"
+	osbuildManifest, err := mf.Serialize(nil, map[string][]container.Spec{
+		"build": {
+			{
+				Source:    "quay.io/centos-bootc/centos-bootc",
+				Digest:    "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+				ImageID:   imageID,
+				LocalName: "quay.io/centos-bootc/centos-bootc:stream9",
+			},
+		},
+	}, nil, nil)
+	require.NoError(t, err)
+
+	var m struct {
+		Sources map[string]struct {
+			Items map[string]osbuild.SkopeoSourceItem `json:"items"`
+		} `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(osbuildManifest, &m))
+
+	// the image is fetched from the mirror
+	assert.Equal(t, "registry.lab/centos-bootc/centos-bootc", m.Sources["org.osbuild.skopeo"].Items[imageID].Image.Name)
+	assert.Equal(t, []mirror.Rewrite{
+		{Kind: mirror.KindContainer, Original: "quay.io/centos-bootc/centos-bootc", Rewritten: "registry.lab/centos-bootc/centos-bootc"},
+	}, rewriter.Rewrites())
+}
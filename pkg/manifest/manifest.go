@@ -16,6 +16,8 @@ import (
 	"encoding/json"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/mirror"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -72,6 +74,12 @@ type Manifest struct {
 	// generate. It is used for determining package names that differ between
 	// different distributions and version.
 	Distro Distro
+
+	// Rewriter, if set, rewrites the URLs of the sources of the serialized
+	// manifest to fetch the content from mirrors. The content of the
+	// pipelines, e.g. the repositories configured in the image, is not
+	// rewritten.
+	Rewriter *mirror.Rewriter
 }
 
 func New() Manifest {
@@ -125,6 +133,18 @@ func (m Manifest) GetContainerSourceSpecs() map[string][]container.SourceSpec {
 	return containerSpecs
 }
 
+// GetRemoteFiles returns the files of every pipeline whose content is
+// downloaded from a URL when building the image.
+func (m Manifest) GetRemoteFiles() map[string][]*fsnode.File {
+	remoteFiles := make(map[string][]*fsnode.File)
+	for _, pipeline := range m.pipelines {
+		if files := pipeline.getRemoteFiles(); len(files) > 0 {
+			remoteFiles[pipeline.Name()] = files
+		}
+	}
+	return remoteFiles
+}
+
 func (m Manifest) GetOSTreeSourceSpecs() map[string][]ostree.SourceSpec {
 	// OSTree commits should only appear in one pipeline.
 	// Let's iterate over all pipelines to avoid assuming pipeline names, but
@@ -144,6 +164,7 @@ func (m Manifest) Serialize(packageSets map[string][]rpmmd.PackageSpec, containe
 	commits := make([]ostree.CommitSpec, 0)
 	inline := make([]string, 0)
 	containers := make([]container.Spec, 0)
+	var remoteFiles []*fsnode.File
 	for _, pipeline := range m.pipelines {
 		pipeline.serializeStart(packageSets[pipeline.Name()], containerSpecs[pipeline.Name()], ostreeCommits[pipeline.Name()], rpmRepos[pipeline.Name()])
 	}
@@ -153,12 +174,13 @@ func (m Manifest) Serialize(packageSets map[string][]rpmmd.PackageSpec, containe
 		packages = append(packages, packageSets[pipeline.Name()]...)
 		inline = append(inline, pipeline.getInline()...)
 		containers = append(containers, pipeline.getContainerSpecs()...)
+		remoteFiles = append(remoteFiles, pipeline.getRemoteFiles()...)
 	}
 	for _, pipeline := range m.pipelines {
 		pipeline.serializeEnd()
 	}
 
-	sources, err := osbuild.GenSources(packages, commits, inline, containers)
+	sources, err := osbuild.GenRewrittenSources(m.Rewriter, packages, commits, inline, containers, remoteFiles)
 	if err != nil {
 		return nil, err
 	}
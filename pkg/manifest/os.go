@@ -12,11 +12,15 @@ import (
 	"github.com/osbuild/images/internal/workload"
 	"github.com/osbuild/images/pkg/arch"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/audit"
 	"github.com/osbuild/images/pkg/customizations/bootc"
 	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/journald"
 	"github.com/osbuild/images/pkg/customizations/oscap"
+	"github.com/osbuild/images/pkg/customizations/selinux"
 	"github.com/osbuild/images/pkg/customizations/shell"
 	"github.com/osbuild/images/pkg/customizations/subscription"
+	"github.com/osbuild/images/pkg/customizations/sudoers"
 	"github.com/osbuild/images/pkg/customizations/users"
 	"github.com/osbuild/images/pkg/disk"
 	"github.com/osbuild/images/pkg/osbuild"
@@ -90,6 +94,10 @@ type OSCustomizations struct {
 
 	SELinuxForceRelabel *bool
 
+	// Local modifications of the SELinux policy and custom policy modules,
+	// applied right before the tree is labeled. Requires SElinux to be set.
+	SELinuxPolicy *selinux.Options
+
 	// Do not install documentation
 	ExcludeDocs bool
 
@@ -141,8 +149,24 @@ type OSCustomizations struct {
 	Directories []*fsnode.Directory
 	Files       []*fsnode.File
 
+	// Journald settings written to a drop-in in /etc/systemd/journald.conf.d
+	Journald *journald.Options
+
+	// Audit rules files to install in /etc/audit/rules.d
+	AuditRules []audit.RulesFile
+
+	// PEM encoded certificate bundles to add to the system trust store
+	CACerts []string
+
+	// Drop-in files to create in /etc/sudoers.d
+	Sudoers []sudoers.DropIn
+
 	FIPS bool
 
+	// System-wide crypto policy with optional subpolicies, e.g.
+	// "DEFAULT:NO-SHA1". When FIPS is enabled, the policy must be FIPS-based.
+	CryptoPolicy string
+
 	// NoBLS configures the image bootloader with traditional menu entries
 	// instead of BLS. Required for legacy systems like RHEL 7.
 	NoBLS bool
@@ -261,23 +285,56 @@ func (p *OS) getPackageSetChain(Distro) []rpmmd.PackageSet {
 
 	}
 
+	if len(p.AuditRules) > 0 {
+		packages = append(packages, "audit")
+	}
+
+	if p.CryptoPolicy != "" {
+		packages = append(packages, "crypto-policies-scripts")
+	}
+
+	if len(p.CACerts) > 0 {
+		packages = append(packages, "ca-certificates")
+	}
+
+	if len(p.Sudoers) > 0 {
+		packages = append(packages, "sudo")
+	}
+
 	osRepos := append(p.repos, p.ExtraBaseRepos...)
 
+	// packages excluded and locked by the workload and its module streams
+	// apply to the whole chain, so they are set on the base package set
+	var excludes []string
+	var locks []rpmmd.PackageLock
+	var modules []rpmmd.ModuleStream
+	if p.Workload != nil {
+		excludes = p.Workload.GetExcludePackages()
+		locks = p.Workload.GetPackageLocks()
+		modules = p.Workload.GetEnabledModules()
+	}
+
 	chain := []rpmmd.PackageSet{
 		{
 			Include:         append(packages, p.ExtraBasePackages...),
-			Exclude:         p.ExcludeBasePackages,
+			Exclude:         append(append([]string{}, p.ExcludeBasePackages...), excludes...),
 			Repositories:    osRepos,
 			InstallWeakDeps: p.InstallWeakDeps,
+			Locks:           locks,
+			EnabledModules:  modules,
 		},
 	}
 
 	if p.Workload != nil {
 		workloadPackages := p.Workload.GetPackages()
-		if len(workloadPackages) > 0 {
+		localPackages := p.Workload.GetLocalPackages()
+		if len(workloadPackages) > 0 || len(localPackages) > 0 {
 			chain = append(chain, rpmmd.PackageSet{
-				Include:      workloadPackages,
-				Repositories: append(osRepos, p.Workload.GetRepos()...),
+				Include:        workloadPackages,
+				Exclude:        excludes,
+				Repositories:   append(osRepos, p.Workload.GetRepos()...),
+				LocalPackages:  localPackages,
+				EnabledModules: modules,
 			})
 		}
 	}
@@ -300,6 +357,9 @@ func (p *OS) getBuildPackages(distro Distro) []string {
 	}
 	if p.SElinux != "" {
 		packages = append(packages, "policycoreutils", fmt.Sprintf("selinux-policy-%s", p.SElinux))
+		if p.SELinuxPolicy != nil {
+			packages = append(packages, "policycoreutils-python-utils")
+		}
 	}
 	if len(p.CloudInit) > 0 {
 		switch distro {
@@ -561,6 +621,12 @@ func (p *OS) serialize() osbuild.Pipeline {
 		pipeline.AddStage(osbuild.NewDNFConfigStage(dnfConfig))
 	}
 
+	if p.Workload != nil {
+		for _, module := range p.Workload.GetEnabledModules() {
+			pipeline.AddStage(osbuild.NewDNFModuleConfigStage(osbuild.NewDNFModuleConfigStageOptions(module)))
+		}
+	}
+
 	if p.DNFAutomaticConfig != nil {
 		pipeline.AddStage(osbuild.NewDNFAutomaticConfigStage(p.DNFAutomaticConfig))
 	}
@@ -720,6 +786,39 @@ func (p *OS) serialize() osbuild.Pipeline {
 			}))
 	}
 
+	if p.Journald != nil {
+		dir, file, err := p.Journald.Nodes()
+		if err != nil {
+			panic(err)
+		}
+		p.Directories = append(p.Directories, dir)
+		p.Files = append(p.Files, file)
+	}
+
+	for _, rulesFile := range p.AuditRules {
+		file, err := rulesFile.File()
+		if err != nil {
+			panic(err)
+		}
+		p.Files = append(p.Files, file)
+	}
+
+	for _, dropin := range p.Sudoers {
+		file, err := dropin.File()
+		if err != nil {
+			panic(err)
+		}
+		p.Files = append(p.Files, file)
+	}
+
+	for _, bundle := range p.CACerts {
+		files, err := osbuild.NewCAFileNodes(bundle)
+		if err != nil {
+			panic(err)
+		}
+		p.Files = append(p.Files, files...)
+	}
+
 	// First create custom directories, because some of the custom files may depend on them
 	if len(p.Directories) > 0 {
 		pipeline.AddStages(osbuild.GenDirectoryNodesStages(p.Directories)...)
@@ -729,6 +828,10 @@ func (p *OS) serialize() osbuild.Pipeline {
 		pipeline.AddStages(osbuild.GenFileNodesStages(p.Files)...)
 	}
 
+	if len(p.CACerts) > 0 {
+		pipeline.AddStage(osbuild.NewUpdateCATrustStage())
+	}
+
 	enabledServices := []string{}
 	disabledServices := []string{}
 	maskedServices := []string{}
@@ -767,6 +870,14 @@ func (p *OS) serialize() osbuild.Pipeline {
 		}
 	}
 
+	// NOTE: the crypto policy is set after the FIPS stages so that FIPS
+	// subpolicies (e.g. FIPS:OSPP) replace the plain FIPS policy
+	if p.CryptoPolicy != "" && !(p.FIPS && p.CryptoPolicy == "FIPS") {
+		pipeline.AddStage(osbuild.NewUpdateCryptoPoliciesStage(&osbuild.UpdateCryptoPoliciesStageOptions{
+			Policy: p.CryptoPolicy,
+		}))
+	}
+
 	// NOTE: We need to run the OpenSCAP stages as the last stage before SELinux
 	// since the remediation may change file permissions and other aspects of the
 	// hardened image
@@ -785,6 +896,16 @@ func (p *OS) serialize() osbuild.Pipeline {
 		}))
 	}
 
+	// NOTE: custom modules and policy modifications must be in the policy
+	// store before the tree is labeled, otherwise the file contexts and types
+	// they define are not applied
+	if p.SELinuxPolicy != nil {
+		if p.SElinux == "" {
+			panic("SELinux policy customizations require an SELinux policy, this is a programming error")
+		}
+		pipeline.AddStages(osbuild.GenSELinuxPolicyStages(p.SELinuxPolicy)...)
+	}
+
 	if p.SElinux != "" {
 		pipeline.AddStage(osbuild.NewSELinuxStage(&osbuild.SELinuxStageOptions{
 			FileContexts:     fmt.Sprintf("etc/selinux/%s/contexts/files/file_contexts", p.SElinux),
@@ -838,7 +959,7 @@ func usersFirstBootOptions(users []users.User) *osbuild.FirstBootStageOptions {
 	roothome := filepath.Join("/var", "roothome")
 
 	for _, user := range users {
-		if user.Key != nil {
+		if keys := user.AuthorizedKeys(); len(keys) > 0 {
 			var home string
 
 			if user.Name == "root" {
@@ -850,7 +971,9 @@ func usersFirstBootOptions(users []users.User) *osbuild.FirstBootStageOptions {
 			sshdir := filepath.Join(home, ".ssh")
 
 			cmds = append(cmds, fmt.Sprintf("mkdir -p %s", sshdir))
-			cmds = append(cmds, fmt.Sprintf("sh -c 'echo %q >> %q'", *user.Key, filepath.Join(sshdir, "authorized_keys")))
+			for _, key := range keys {
+				cmds = append(cmds, fmt.Sprintf("sh -c 'echo %q >> %q'", key, filepath.Join(sshdir, "authorized_keys")))
+			}
 			cmds = append(cmds, fmt.Sprintf("chown %s:%s -Rc %s", user.Name, user.Name, sshdir))
 		}
 	}
@@ -874,8 +997,21 @@ func (p *OS) getInline() []string {
 
 	// inline data for custom files
 	for _, file := range p.Files {
+		if file.URL() != "" {
+			continue
+		}
 		inlineData = append(inlineData, string(file.Data()))
 	}
 
 	return inlineData
 }
+
+func (p *OS) getRemoteFiles() []*fsnode.File {
+	var remoteFiles []*fsnode.File
+	for _, file := range p.Files {
+		if file.URL() != "" {
+			remoteFiles = append(remoteFiles, file)
+		}
+	}
+	return remoteFiles
+}
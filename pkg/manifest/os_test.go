@@ -4,7 +4,14 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/internal/workload"
+	"github.com/osbuild/images/pkg/customizations/audit"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/customizations/journald"
+	"github.com/osbuild/images/pkg/customizations/selinux"
 	"github.com/osbuild/images/pkg/customizations/subscription"
+	"github.com/osbuild/images/pkg/customizations/sudoers"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/platform"
 	"github.com/osbuild/images/pkg/rpmmd"
@@ -181,3 +188,187 @@ func TestBootupdStage(t *testing.T) {
 	st := findStage("org.osbuild.bootupd.gen-metadata", pipeline.Stages)
 	require.NotNil(t, st)
 }
+
+func findStageIndex(name string, stages []*osbuild.Stage) int {
+	for idx, s := range stages {
+		if s.Type == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+func TestSELinuxPolicyStagesBeforeRelabel(t *testing.T) {
+	os := NewTestOS()
+	os.SElinux = "targeted"
+	os.SELinuxPolicy = &selinux.Options{
+		Booleans: []selinux.Boolean{{Name: "httpd_can_network_connect", Value: true}},
+		Modules:  []string{"/etc/selinux/modules/myapp.cil"},
+	}
+	pipeline := os.serialize()
+
+	moduleIdx := findStageIndex("org.osbuild.selinux.module", pipeline.Stages)
+	semanageIdx := findStageIndex("org.osbuild.selinux.semanage", pipeline.Stages)
+	relabelIdx := findStageIndex("org.osbuild.selinux", pipeline.Stages)
+	require.NotEqual(t, -1, moduleIdx)
+	require.NotEqual(t, -1, semanageIdx)
+	require.NotEqual(t, -1, relabelIdx)
+	assert.Less(t, moduleIdx, semanageIdx)
+	assert.Less(t, semanageIdx, relabelIdx)
+
+	assert.Contains(t, os.getBuildPackages(DISTRO_NULL), "policycoreutils-python-utils")
+}
+
+func TestSELinuxPolicyStagesWithoutPolicy(t *testing.T) {
+	os := NewTestOS()
+	os.SELinuxPolicy = &selinux.Options{
+		Booleans: []selinux.Boolean{{Name: "httpd_can_network_connect", Value: true}},
+	}
+	assert.Panics(t, func() { os.serialize() })
+}
+
+func TestAuditRules(t *testing.T) {
+	os := NewTestOS()
+	os.AuditRules = []audit.RulesFile{
+		{Filename: "50-identity.rules", Rules: []string{"-w /etc/passwd -p wa -k identity"}},
+	}
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"audit"})
+
+	pipeline := os.serialize()
+	st := findStage("org.osbuild.copy", pipeline.Stages)
+	require.NotNil(t, st)
+	assert.Contains(t, os.getInline(), "-w /etc/passwd -p wa -k identity\n")
+}
+
+func TestJournald(t *testing.T) {
+	os := NewTestOS()
+	os.Journald = &journald.Options{Storage: "persistent", SystemMaxUse: "1G", ForwardToSyslog: common.ToPtr(false)}
+	pipeline := os.serialize()
+	st := findStage("org.osbuild.mkdir", pipeline.Stages)
+	require.NotNil(t, st)
+	assert.Contains(t, os.getInline(), "[Journal]\nStorage=persistent\nSystemMaxUse=1G\nForwardToSyslog=no\n")
+}
+
+func TestCryptoPolicy(t *testing.T) {
+	os := NewTestOS()
+	os.CryptoPolicy = "FUTURE"
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"crypto-policies-scripts"})
+	pipeline := os.serialize()
+	st := findStage("org.osbuild.update-crypto-policies", pipeline.Stages)
+	require.NotNil(t, st)
+	assert.Equal(t, &osbuild.UpdateCryptoPoliciesStageOptions{Policy: "FUTURE"}, st.Options)
+}
+
+func TestCryptoPolicyFIPS(t *testing.T) {
+	os := NewTestOS()
+	os.FIPS = true
+	os.CryptoPolicy = "FIPS:OSPP"
+	pipeline := os.serialize()
+
+	var policies []string
+	for _, st := range pipeline.Stages {
+		if st.Type == "org.osbuild.update-crypto-policies" {
+			policies = append(policies, st.Options.(*osbuild.UpdateCryptoPoliciesStageOptions).Policy)
+		}
+	}
+	assert.Equal(t, []string{"FIPS", "FIPS:OSPP"}, policies)
+}
+
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIBgzCCASmgAwIBAgIUSDm4/9TNoy/pAInNMj4klsS/TWYwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLVGVzdCBDQSBUd28wIBcNMjYxMDE4MjE1NjMwWhgPMjEyNjA5
+MjQyMTU2MzBaMBYxFDASBgNVBAMMC1Rlc3QgQ0EgVHdvMFkwEwYHKoZIzj0CAQYI
+KoZIzj0DAQcDQgAEun2KePq54xx1tbj30AU1v1u1UVqV1UT330hcxGwzZ81mhv8a
+gD0OFuaYVt+WTp0DBKn7h/Cpp2BkT700NRAQfaNTMFEwHQYDVR0OBBYEFIto22Qt
+NHw2Aa6IGLfow5bQvYDmMB8GA1UdIwQYMBaAFIto22QtNHw2Aa6IGLfow5bQvYDm
+MA8GA1UdEwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSAAwRQIgaJCpDX515kf76Tzi
+fZhwB4hRdpGswI2Q7eRQ9ogMVU0CIQC92RWghBhpcWQUidbHoy1BDIal/EFTcK3y
+OHFwwvTqTg==
+-----END CERTIFICATE-----
+`
+
+func TestCACerts(t *testing.T) {
+	os := NewTestOS()
+	os.CACerts = []string{testCACert}
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"ca-certificates"})
+
+	pipeline := os.serialize()
+	copyIdx := findStageIndex("org.osbuild.copy", pipeline.Stages)
+	trustIdx := findStageIndex("org.osbuild.pki.update-ca-trust", pipeline.Stages)
+	require.NotEqual(t, -1, copyIdx)
+	require.NotEqual(t, -1, trustIdx)
+	assert.Less(t, copyIdx, trustIdx)
+	assert.Contains(t, os.getInline(), testCACert)
+}
+
+func TestSudoers(t *testing.T) {
+	os := NewTestOS()
+	os.Sudoers = []sudoers.DropIn{
+		{Filename: "wheel", Rules: []sudoers.Rule{{Group: "wheel", NoPasswd: true}}},
+	}
+	CheckPkgSetInclude(t, os.getPackageSetChain(DISTRO_NULL), []string{"sudo"})
+
+	pipeline := os.serialize()
+	require.NotNil(t, findStage("org.osbuild.copy", pipeline.Stages))
+	assert.Contains(t, os.getInline(), "%wheel\tALL=(ALL)\tNOPASSWD: ALL\n")
+}
+
+func TestRemoteFiles(t *testing.T) {
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+	remote, err := fsnode.NewRemoteFile("/etc/bundle.tar", nil, nil, nil, "https://example.com/bundle.tar", checksum)
+	require.NoError(t, err)
+	inline, err := fsnode.NewFile("/etc/inline", nil, nil, nil, []byte("inline data"))
+	require.NoError(t, err)
+
+	os := NewTestOS()
+	os.Files = []*fsnode.File{remote, inline}
+
+	pipeline := os.serialize()
+	copyStage := findStage("org.osbuild.copy", pipeline.Stages)
+	require.NotNil(t, copyStage)
+	inputs := copyStage.Inputs.(*osbuild.CopyStageFilesInputs)
+	assert.Contains(t, *inputs, "file-"+checksum)
+
+	// the remote file content is downloaded by the curl source, not inlined
+	assert.Equal(t, []string{"inline data"}, os.getInline())
+	assert.Equal(t, []*fsnode.File{remote}, os.getRemoteFiles())
+	assert.Equal(t, map[string][]*fsnode.File{"os": {remote}}, os.Manifest().GetRemoteFiles())
+}
+
+func TestWorkloadExcludesAndLocks(t *testing.T) {
+	os := NewTestOS()
+	os.ExcludeBasePackages = []string{"dracut-config-rescue"}
+	lock := rpmmd.PackageLock{Name: "openssl", EVR: "1:3.0.7-27.el9"}
+	os.Workload = &workload.Custom{
+		Packages:        []string{"httpd"},
+		ExcludePackages: []string{"abrt"},
+		PackageLocks:    []rpmmd.PackageLock{lock},
+	}
+
+	chain := os.getPackageSetChain(DISTRO_NULL)
+	require.Len(t, chain, 2)
+	assert.Equal(t, []string{"dracut-config-rescue", "abrt"}, chain[0].Exclude)
+	assert.Equal(t, []rpmmd.PackageLock{lock}, chain[0].Locks)
+	assert.Equal(t, []string{"abrt"}, chain[1].Exclude)
+	assert.Nil(t, chain[1].Locks)
+	assert.Equal(t, []string{"dracut-config-rescue"}, os.ExcludeBasePackages)
+}
+
+func TestWorkloadEnabledModules(t *testing.T) {
+	os := NewTestOS()
+	module := rpmmd.ModuleStream{Name: "nodejs", Stream: "18", Profile: "common"}
+	os.Workload = &workload.Custom{
+		Packages:       []string{"nodejs"},
+		EnabledModules: []rpmmd.ModuleStream{module},
+	}
+
+	chain := os.getPackageSetChain(DISTRO_NULL)
+	require.Len(t, chain, 2)
+	assert.Equal(t, []rpmmd.ModuleStream{module}, chain[0].EnabledModules)
+	assert.Equal(t, []rpmmd.ModuleStream{module}, chain[1].EnabledModules)
+
+	pipeline := os.serialize()
+	st := findStage("org.osbuild.dnf.module-config", pipeline.Stages)
+	require.NotNil(t, st)
+	assert.Equal(t, osbuild.NewDNFModuleConfigStageOptions(module), st.Options)
+}
@@ -502,12 +502,25 @@ func (p *OSTreeDeployment) getInline() []string {
 
 	// inline data for custom files
 	for _, file := range p.Files {
+		if file.URL() != "" {
+			continue
+		}
 		inlineData = append(inlineData, string(file.Data()))
 	}
 
 	return inlineData
 }
 
+func (p *OSTreeDeployment) getRemoteFiles() []*fsnode.File {
+	var remoteFiles []*fsnode.File
+	for _, file := range p.Files {
+		if file.URL() != "" {
+			remoteFiles = append(remoteFiles, file)
+		}
+	}
+	return remoteFiles
+}
+
 // Creates systemd unit stage by ingesting the servicename and mount-points
 func createMountpointService(serviceName string, mountpoints []string) *osbuild.SystemdUnitCreateStageOptions {
 	var conditionPathIsDirectory []string
@@ -3,6 +3,7 @@ package manifest
 import (
 	"github.com/osbuild/images/pkg/artifact"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/osbuild"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/platform"
@@ -69,6 +70,9 @@ type Pipeline interface {
 	// getInline returns the list of inlined data content that will be used to
 	// embed files in the pipeline tree.
 	getInline() []string
+	// getRemoteFiles returns the list of files in the pipeline tree with
+	// content that is downloaded when building the image.
+	getRemoteFiles() []*fsnode.File
 }
 
 // A Base represents the core functionality shared between each of the pipeline
@@ -148,6 +152,10 @@ func (p Base) getInline() []string {
 	return []string{}
 }
 
+func (p Base) getRemoteFiles() []*fsnode.File {
+	return nil
+}
+
 // NewBase returns a generic Pipeline object. The name is mandatory, immutable and must
 // be unique among all the pipelines used in a manifest, which is currently not enforced.
 // The build argument is a pipeline representing a build root in which the rest of the
@@ -0,0 +1,517 @@
+// Package mirror rewrites the URLs of content sources, i.e. RPM repositories
+// and packages, container images, ostree repositories and remote files, to
+// redirect them to mirrors. The sources of a manifest are rewritten before
+// they are resolved, and content that was resolved elsewhere, e.g. the content
+// of a lockfile, is rewritten when the manifest is serialized. Rewriting is
+// idempotent, so content that was resolved from rewritten sources is left
+// as it is. Every rewrite is recorded, and the resolved content keeps its
+// original URLs next to the rewritten ones, so that they remain known.
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// Kind is the kind of content source a URL belongs to.
+type Kind string
+
+const (
+	// RPM repositories and packages
+	KindRPM Kind = "rpm"
+	// Container image references, e.g. registry.example.com/org/image:tag
+	KindContainer Kind = "container"
+	// OSTree repositories
+	KindOSTree Kind = "ostree"
+	// Remote files of file customizations
+	KindFile Kind = "file"
+)
+
+var kinds = map[Kind]bool{
+	KindRPM:       true,
+	KindContainer: true,
+	KindOSTree:    true,
+	KindFile:      true,
+}
+
+// Rule is a rewrite rule. Exactly one of Prefix and Regex must be set.
+type Rule struct {
+	// URLs starting with the prefix have it replaced with Replace
+	Prefix string `json:"prefix,omitempty"`
+	// URLs matching the regular expression have the matches replaced with
+	// Replace, which can refer to the groups of the expression with $1 or
+	// ${name}
+	Regex string `json:"regex,omitempty"`
+
+	Replace string `json:"replace"`
+
+	// Kinds of sources the rule applies to, all if empty
+	Sources []Kind `json:"sources,omitempty"`
+}
+
+// Config is the content of a rewrite rule configuration file.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rewrite is the record of a rewritten URL.
+type Rewrite struct {
+	Kind      Kind   `json:"kind"`
+	Original  string `json:"original"`
+	Rewritten string `json:"rewritten"`
+}
+
+type rule struct {
+	Rule
+	regex *regexp.Regexp
+	kinds map[Kind]bool
+
+	// the fixed part of the replacement, which every URL rewritten by the
+	// rule starts with
+	replacePrefix string
+}
+
+func (r rule) appliesTo(kind Kind) bool {
+	return len(r.kinds) == 0 || r.kinds[kind]
+}
+
+// rewrite returns the rewritten URL and true if the rule matches the URL.
+// URLs that start with the fixed part of the replacement were already
+// rewritten by the rule and are returned unchanged.
+func (r rule) rewrite(url string) (string, bool) {
+	if r.replacePrefix != "" && strings.HasPrefix(url, r.replacePrefix) {
+		return url, true
+	}
+	if r.regex != nil {
+		if !r.regex.MatchString(url) {
+			return "", false
+		}
+		return r.regex.ReplaceAllString(url, r.Replace), true
+	}
+	if !strings.HasPrefix(url, r.Prefix) {
+		return "", false
+	}
+	return r.Replace + strings.TrimPrefix(url, r.Prefix), true
+}
+
+// Rewriter applies rewrite rules to URLs. The first rule that matches a URL
+// is applied. A nil Rewriter returns all URLs unchanged.
+type Rewriter struct {
+	rules []rule
+
+	mu sync.Mutex
+	// record of the rewritten URLs by kind and original URL
+	rewrites map[Kind]map[string]string
+}
+
+// NewRewriter returns a rewriter for the rules.
+func NewRewriter(rules []Rule) (*Rewriter, error) {
+	rw := &Rewriter{
+		rewrites: make(map[Kind]map[string]string),
+	}
+	for idx, r := range rules {
+		compiled := rule{Rule: r}
+		switch {
+		case r.Prefix != "" && r.Regex != "":
+			return nil, fmt.Errorf("rewrite rule %d has both a prefix and a regex", idx)
+		case r.Prefix == "" && r.Regex == "":
+			return nil, fmt.Errorf("rewrite rule %d has neither a prefix nor a regex", idx)
+		case r.Regex != "":
+			re, err := regexp.Compile(r.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("rewrite rule %d has an invalid regex: %w", idx, err)
+			}
+			compiled.regex = re
+			compiled.replacePrefix, _, _ = strings.Cut(r.Replace, "$")
+		default:
+			// the URLs the rule matches would look rewritten already
+			if strings.HasPrefix(r.Prefix, r.Replace) {
+				return nil, fmt.Errorf("rewrite rule %d replaces the prefix %q with a prefix of it", idx, r.Prefix)
+			}
+			compiled.replacePrefix = r.Replace
+		}
+		for _, kind := range r.Sources {
+			if !kinds[kind] {
+				return nil, fmt.Errorf("rewrite rule %d has an unknown source kind %q", idx, kind)
+			}
+			if compiled.kinds == nil {
+				compiled.kinds = make(map[Kind]bool)
+			}
+			compiled.kinds[kind] = true
+		}
+		rw.rules = append(rw.rules, compiled)
+	}
+	return rw, nil
+}
+
+// LoadRewriter returns a rewriter for the rules of a JSON configuration file.
+func LoadRewriter(path string) (*Rewriter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("cannot parse rewrite rules from %s: %w", path, err)
+	}
+	rw, err := NewRewriter(config.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite rules in %s: %w", path, err)
+	}
+	return rw, nil
+}
+
+// Rewrite returns the URL rewritten by the first matching rule for the kind
+// of source, or the URL itself if no rule matches. The result only depends on
+// the rules: a URL that starts with the fixed part of the replacement of a
+// rule, i.e. the part before the first reference to a group of its regex, is
+// considered rewritten by the rule and returned unchanged, so rewriting a
+// rewritten URL, e.g. the location of a package in a rewritten repository,
+// has no effect.
+func (rw *Rewriter) Rewrite(kind Kind, url string) string {
+	if rw == nil || url == "" {
+		return url
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	for _, r := range rw.rules {
+		if !r.appliesTo(kind) {
+			continue
+		}
+		if rewritten, ok := r.rewrite(url); ok {
+			if rewritten == url {
+				return url
+			}
+			if rw.rewrites[kind] == nil {
+				rw.rewrites[kind] = make(map[string]string)
+			}
+			rw.rewrites[kind][url] = rewritten
+			return rewritten
+		}
+	}
+	return url
+}
+
+// Original returns the original URL of a URL that was rewritten by the
+// rewriter, or that is located below such a URL, e.g. a package in a
+// rewritten repository or the name of a rewritten container reference
+// without its tag. It returns an empty string if the original URL is not
+// known.
+func (rw *Rewriter) Original(kind Kind, url string) string {
+	if rw == nil || url == "" {
+		return ""
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	var original, match string
+	for from, to := range rw.rewrites[kind] {
+		// the longest rewritten URL matches best
+		if len(to) <= len(match) {
+			continue
+		}
+		if o, ok := originalOf(from, to, url); ok {
+			original, match = o, to
+		}
+	}
+	return original
+}
+
+// originalOf returns the original URL of url, if url is the URL from was
+// rewritten to, is located below it or, for container references, is its
+// name without the tag or digest.
+func originalOf(from, to, url string) (string, bool) {
+	switch {
+	case url == to:
+		return from, true
+	case strings.HasPrefix(url, to) && (strings.HasSuffix(to, "/") || url[len(to)] == '/'):
+		return from + strings.TrimPrefix(url, to), true
+	}
+	if tail := strings.TrimPrefix(to, url); tail != to && (tail[0] == ':' || tail[0] == '@') && strings.HasSuffix(from, tail) {
+		return strings.TrimSuffix(from, tail), true
+	}
+	return "", false
+}
+
+// rewriteRecorded returns the rewritten URL and sets original to the
+// original URL of the result, unless it is set already, e.g. because the URL
+// was rewritten before.
+func (rw *Rewriter) rewriteRecorded(kind Kind, url string, original *string) string {
+	rewritten := rw.Rewrite(kind, url)
+	if *original == "" {
+		if rewritten != url {
+			*original = url
+		} else {
+			*original = rw.Original(kind, url)
+		}
+	}
+	return rewritten
+}
+
+// Rewrites returns the record of all rewritten URLs, sorted by kind and
+// original URL.
+func (rw *Rewriter) Rewrites() []Rewrite {
+	if rw == nil {
+		return nil
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	var result []Rewrite
+	for kind, rewrites := range rw.rewrites {
+		for original, rewritten := range rewrites {
+			result = append(result, Rewrite{Kind: kind, Original: original, Rewritten: rewritten})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Original < result[j].Original
+	})
+	return result
+}
+
+// RewriteRepo returns a copy of the repository with its base URLs, metalink,
+// mirrorlist, GPG key URLs and snapshot URL template rewritten. Inline GPG
+// keys are kept as they are.
+func (rw *Rewriter) RewriteRepo(repo rpmmd.RepoConfig) rpmmd.RepoConfig {
+	if rw == nil {
+		return repo
+	}
+	rewriteAll := func(urls []string) []string {
+		if urls == nil {
+			return nil
+		}
+		result := make([]string, len(urls))
+		for idx, url := range urls {
+			if strings.HasPrefix(strings.TrimSpace(url), "-----BEGIN") {
+				result[idx] = url
+				continue
+			}
+			result[idx] = rw.Rewrite(KindRPM, url)
+		}
+		return result
+	}
+	repo.BaseURLs = rewriteAll(repo.BaseURLs)
+	repo.GPGKeys = rewriteAll(repo.GPGKeys)
+	repo.Metalink = rw.Rewrite(KindRPM, repo.Metalink)
+	repo.MirrorList = rw.Rewrite(KindRPM, repo.MirrorList)
+	repo.SnapshotURL = rw.Rewrite(KindRPM, repo.SnapshotURL)
+	return repo
+}
+
+// RewriteRepos returns copies of the repositories rewritten with
+// RewriteRepo().
+func (rw *Rewriter) RewriteRepos(repos []rpmmd.RepoConfig) []rpmmd.RepoConfig {
+	if rw == nil || repos == nil {
+		return repos
+	}
+	result := make([]rpmmd.RepoConfig, len(repos))
+	for idx, repo := range repos {
+		result[idx] = rw.RewriteRepo(repo)
+	}
+	return result
+}
+
+// RewritePackageSetChains returns copies of the package set chains, as
+// returned by manifest.Manifest.GetPackageSetChains(), with their
+// repositories rewritten, to be depsolved.
+func (rw *Rewriter) RewritePackageSetChains(chains map[string][]rpmmd.PackageSet) map[string][]rpmmd.PackageSet {
+	if rw == nil || chains == nil {
+		return chains
+	}
+	result := make(map[string][]rpmmd.PackageSet, len(chains))
+	for name, chain := range chains {
+		sets := make([]rpmmd.PackageSet, len(chain))
+		for idx, set := range chain {
+			set.Repositories = rw.RewriteRepos(set.Repositories)
+			sets[idx] = set
+		}
+		result[name] = sets
+	}
+	return result
+}
+
+// RewriteContainerSources returns copies of the container sources, as
+// returned by manifest.Manifest.GetContainerSourceSpecs(), with their source
+// references rewritten, to be resolved. The local names of the containers
+// are kept, so the images keep the names they would have without the rules
+// in the image. OCI archives and layout directories are not rewritten.
+func (rw *Rewriter) RewriteContainerSources(sources map[string][]container.SourceSpec) map[string][]container.SourceSpec {
+	if rw == nil || sources == nil {
+		return sources
+	}
+	result := make(map[string][]container.SourceSpec, len(sources))
+	for name, specs := range sources {
+		rewritten := make([]container.SourceSpec, len(specs))
+		for idx, spec := range specs {
+			if _, _, oci := container.SplitOCISource(spec.Source); !spec.Local && !oci {
+				if spec.Name == "" {
+					// invalid references fail to resolve either way
+					if _, localName, err := spec.SpecNames(); err == nil {
+						spec.Name = localName
+					}
+				}
+				spec.Source = rw.Rewrite(KindContainer, spec.Source)
+			}
+			rewritten[idx] = spec
+		}
+		result[name] = rewritten
+	}
+	return result
+}
+
+// RewriteOSTreeSources returns copies of the ostree sources, as returned by
+// manifest.Manifest.GetOSTreeSourceSpecs(), with their URLs rewritten, to be
+// resolved.
+func (rw *Rewriter) RewriteOSTreeSources(sources map[string][]ostree.SourceSpec) map[string][]ostree.SourceSpec {
+	if rw == nil || sources == nil {
+		return sources
+	}
+	result := make(map[string][]ostree.SourceSpec, len(sources))
+	for name, specs := range sources {
+		rewritten := make([]ostree.SourceSpec, len(specs))
+		for idx, spec := range specs {
+			spec.URL = rw.Rewrite(KindOSTree, spec.URL)
+			rewritten[idx] = spec
+		}
+		result[name] = rewritten
+	}
+	return result
+}
+
+// RewritePackageSpec returns a copy of the depsolved package with its
+// location rewritten and its original location, if it differs, in
+// OriginalRemoteLocation.
+func (rw *Rewriter) RewritePackageSpec(spec rpmmd.PackageSpec) rpmmd.PackageSpec {
+	if rw == nil {
+		return spec
+	}
+	spec.RemoteLocation = rw.rewriteRecorded(KindRPM, spec.RemoteLocation, &spec.OriginalRemoteLocation)
+	return spec
+}
+
+// RewritePackageSpecs returns copies of the depsolved packages of the
+// pipelines rewritten with RewritePackageSpec().
+func (rw *Rewriter) RewritePackageSpecs(packageSpecs map[string][]rpmmd.PackageSpec) map[string][]rpmmd.PackageSpec {
+	if rw == nil || packageSpecs == nil {
+		return packageSpecs
+	}
+	result := make(map[string][]rpmmd.PackageSpec, len(packageSpecs))
+	for name, specs := range packageSpecs {
+		rewritten := make([]rpmmd.PackageSpec, len(specs))
+		for idx, spec := range specs {
+			rewritten[idx] = rw.RewritePackageSpec(spec)
+		}
+		result[name] = rewritten
+	}
+	return result
+}
+
+// RewriteRepoConfigs returns copies of the repositories of the pipelines
+// rewritten with RewriteRepo().
+func (rw *Rewriter) RewriteRepoConfigs(repoConfigs map[string][]rpmmd.RepoConfig) map[string][]rpmmd.RepoConfig {
+	if rw == nil || repoConfigs == nil {
+		return repoConfigs
+	}
+	result := make(map[string][]rpmmd.RepoConfig, len(repoConfigs))
+	for name, repos := range repoConfigs {
+		result[name] = rw.RewriteRepos(repos)
+	}
+	return result
+}
+
+// RewriteContainerSpec returns a copy of the resolved container with its
+// source rewritten and its original source, if it differs, in
+// OriginalSource. Containers from the local storage and OCI archives and
+// layout directories are not rewritten.
+func (rw *Rewriter) RewriteContainerSpec(spec container.Spec) container.Spec {
+	if rw == nil {
+		return spec
+	}
+	if _, _, oci := container.SplitOCISource(spec.Source); !spec.LocalStorage && !oci {
+		spec.Source = rw.rewriteRecorded(KindContainer, spec.Source, &spec.OriginalSource)
+	}
+	return spec
+}
+
+// RewriteContainerSpecs returns copies of the resolved containers of the
+// pipelines rewritten with RewriteContainerSpec().
+func (rw *Rewriter) RewriteContainerSpecs(containerSpecs map[string][]container.Spec) map[string][]container.Spec {
+	if rw == nil || containerSpecs == nil {
+		return containerSpecs
+	}
+	result := make(map[string][]container.Spec, len(containerSpecs))
+	for name, specs := range containerSpecs {
+		rewritten := make([]container.Spec, len(specs))
+		for idx, spec := range specs {
+			rewritten[idx] = rw.RewriteContainerSpec(spec)
+		}
+		result[name] = rewritten
+	}
+	return result
+}
+
+// RewriteCommitSpec returns a copy of the resolved ostree commit with its
+// URLs rewritten and its original URLs, if they differ, in OriginalURL and
+// OriginalContentURL.
+func (rw *Rewriter) RewriteCommitSpec(spec ostree.CommitSpec) ostree.CommitSpec {
+	if rw == nil {
+		return spec
+	}
+	spec.URL = rw.rewriteRecorded(KindOSTree, spec.URL, &spec.OriginalURL)
+	spec.ContentURL = rw.rewriteRecorded(KindOSTree, spec.ContentURL, &spec.OriginalContentURL)
+	return spec
+}
+
+// RewriteCommitSpecs returns copies of the resolved ostree commits of the
+// pipelines rewritten with RewriteCommitSpec().
+func (rw *Rewriter) RewriteCommitSpecs(commitSpecs map[string][]ostree.CommitSpec) map[string][]ostree.CommitSpec {
+	if rw == nil || commitSpecs == nil {
+		return commitSpecs
+	}
+	result := make(map[string][]ostree.CommitSpec, len(commitSpecs))
+	for name, specs := range commitSpecs {
+		rewritten := make([]ostree.CommitSpec, len(specs))
+		for idx, spec := range specs {
+			rewritten[idx] = rw.RewriteCommitSpec(spec)
+		}
+		result[name] = rewritten
+	}
+	return result
+}
+
+// RewriteRemoteFiles returns the remote files with their URLs rewritten.
+// Files whose URLs are rewritten are replaced with copies, the original files
+// keep their original URLs.
+func (rw *Rewriter) RewriteRemoteFiles(files []*fsnode.File) ([]*fsnode.File, error) {
+	if rw == nil || files == nil {
+		return files, nil
+	}
+	result := make([]*fsnode.File, len(files))
+	for idx, file := range files {
+		url := rw.Rewrite(KindFile, file.URL())
+		if url == file.URL() {
+			result[idx] = file
+			continue
+		}
+		rewritten, err := fsnode.NewRemoteFile(file.Path(), file.Mode(), file.User(), file.Group(), url, file.SHA256())
+		if err != nil {
+			return nil, fmt.Errorf("cannot rewrite the URL of remote file %q: %w", file.Path(), err)
+		}
+		result[idx] = rewritten
+	}
+	return result, nil
+}
@@ -0,0 +1,272 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestNewRewriterErrors(t *testing.T) {
+	testCases := []struct {
+		rule Rule
+		err  string
+	}{
+		{Rule{Prefix: "https://a/", Regex: "^https://a/", Replace: "https://b/"}, "rewrite rule 0 has both a prefix and a regex"},
+		{Rule{Replace: "https://b/"}, "rewrite rule 0 has neither a prefix nor a regex"},
+		{Rule{Regex: "(", Replace: "https://b/"}, "rewrite rule 0 has an invalid regex: error parsing regexp: missing closing ): `(`"},
+		{Rule{Prefix: "https://a/", Replace: "https://b/", Sources: []Kind{"git"}}, `rewrite rule 0 has an unknown source kind "git"`},
+		{Rule{Prefix: "https://a/mirror/", Replace: "https://a/"}, `rewrite rule 0 replaces the prefix "https://a/mirror/" with a prefix of it`},
+	}
+	for _, tc := range testCases {
+		_, err := NewRewriter([]Rule{tc.rule})
+		assert.EqualError(t, err, tc.err)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	rw, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/cdn/"},
+		{Regex: `^quay\.io/(?P<path>.*)$`, Replace: "registry.lab/quay/${path}", Sources: []Kind{KindContainer}},
+		{Prefix: "https://", Replace: "https://mirror.lab/", Sources: []Kind{KindOSTree}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://mirror.lab/cdn/baseos/", rw.Rewrite(KindRPM, "https://cdn.example.com/baseos/"))
+	assert.Equal(t, "https://mirror.lab/cdn/ostree/repo", rw.Rewrite(KindOSTree, "https://cdn.example.com/ostree/repo"))
+	assert.Equal(t, "https://mirror.lab/ostree.example.com/repo", rw.Rewrite(KindOSTree, "https://ostree.example.com/repo"))
+	assert.Equal(t, "registry.lab/quay/centos/centos:stream9", rw.Rewrite(KindContainer, "quay.io/centos/centos:stream9"))
+	// the container rule only applies to containers
+	assert.Equal(t, "quay.io/centos/centos:stream9", rw.Rewrite(KindRPM, "quay.io/centos/centos:stream9"))
+	assert.Equal(t, "https://other.example.com/", rw.Rewrite(KindRPM, "https://other.example.com/"))
+	assert.Equal(t, "", rw.Rewrite(KindRPM, ""))
+
+	// rewritten URLs and URLs below them are not rewritten again, by this
+	// or any other rewriter with the same rules
+	other, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/cdn/"},
+		{Regex: `^quay\.io/(?P<path>.*)$`, Replace: "registry.lab/quay/${path}", Sources: []Kind{KindContainer}},
+		{Prefix: "https://", Replace: "https://mirror.lab/", Sources: []Kind{KindOSTree}},
+	})
+	require.NoError(t, err)
+	for _, rewriter := range []*Rewriter{rw, other} {
+		assert.Equal(t, "https://mirror.lab/ostree.example.com/repo", rewriter.Rewrite(KindOSTree, "https://mirror.lab/ostree.example.com/repo"))
+		assert.Equal(t, "https://mirror.lab/ostree.example.com/repo/objects", rewriter.Rewrite(KindOSTree, "https://mirror.lab/ostree.example.com/repo/objects"))
+		assert.Equal(t, "https://mirror.lab/cdn/baseos/Packages/tmux.rpm", rewriter.Rewrite(KindRPM, "https://mirror.lab/cdn/baseos/Packages/tmux.rpm"))
+		assert.Equal(t, "registry.lab/quay/centos/centos:stream9", rewriter.Rewrite(KindContainer, "registry.lab/quay/centos/centos:stream9"))
+	}
+	assert.Empty(t, other.Rewrites())
+
+	assert.Equal(t, []Rewrite{
+		{Kind: KindContainer, Original: "quay.io/centos/centos:stream9", Rewritten: "registry.lab/quay/centos/centos:stream9"},
+		{Kind: KindOSTree, Original: "https://cdn.example.com/ostree/repo", Rewritten: "https://mirror.lab/cdn/ostree/repo"},
+		{Kind: KindOSTree, Original: "https://ostree.example.com/repo", Rewritten: "https://mirror.lab/ostree.example.com/repo"},
+		{Kind: KindRPM, Original: "https://cdn.example.com/baseos/", Rewritten: "https://mirror.lab/cdn/baseos/"},
+	}, rw.Rewrites())
+
+	var nilRewriter *Rewriter
+	assert.Equal(t, "https://cdn.example.com/baseos/", nilRewriter.Rewrite(KindRPM, "https://cdn.example.com/baseos/"))
+	assert.Nil(t, nilRewriter.Rewrites())
+}
+
+func TestRewriteSources(t *testing.T) {
+	rw, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/"},
+		{Prefix: "quay.io/", Replace: "registry.lab/"},
+	})
+	require.NoError(t, err)
+
+	inlineKey := "-----BEGIN PGP PUBLIC KEY BLOCK-----\n...\n-----END PGP PUBLIC KEY BLOCK-----"
+	repo := rpmmd.RepoConfig{
+		Name:        "baseos",
+		BaseURLs:    []string{"https://cdn.example.com/baseos"},
+		Metalink:    "https://cdn.example.com/metalink",
+		GPGKeys:     []string{inlineKey, "https://cdn.example.com/RPM-GPG-KEY"},
+		SnapshotURL: "https://cdn.example.com/snapshots/$snapshot/baseos",
+	}
+	chains := map[string][]rpmmd.PackageSet{
+		"os": {{Include: []string{"tmux"}, Repositories: []rpmmd.RepoConfig{repo}}},
+	}
+	rewrittenChains := rw.RewritePackageSetChains(chains)
+	assert.Equal(t, rpmmd.RepoConfig{
+		Name:        "baseos",
+		BaseURLs:    []string{"https://mirror.lab/baseos"},
+		Metalink:    "https://mirror.lab/metalink",
+		GPGKeys:     []string{inlineKey, "https://mirror.lab/RPM-GPG-KEY"},
+		SnapshotURL: "https://mirror.lab/snapshots/$snapshot/baseos",
+	}, rewrittenChains["os"][0].Repositories[0])
+	assert.Equal(t, []string{"tmux"}, rewrittenChains["os"][0].Include)
+	// the original package sets are not modified
+	assert.Equal(t, repo, chains["os"][0].Repositories[0])
+
+	containers := rw.RewriteContainerSources(map[string][]container.SourceSpec{
+		"os": {
+			{Source: "quay.io/centos/centos:stream9"},
+			{Source: "quay.io/centos/centos"},
+			{Source: "quay.io/fedora/fedora:40", Name: "localhost/fedora"},
+			{Source: "quay.io/local/image", Local: true},
+			{Source: "oci-archive:/srv/ci/image.tar"},
+		},
+	})
+	// the images are named as they would be without the rules
+	assert.Equal(t, []container.SourceSpec{
+		{Source: "registry.lab/centos/centos:stream9", Name: "quay.io/centos/centos:stream9"},
+		{Source: "registry.lab/centos/centos", Name: "quay.io/centos/centos:latest"},
+		{Source: "registry.lab/fedora/fedora:40", Name: "localhost/fedora"},
+		{Source: "quay.io/local/image", Local: true},
+		{Source: "oci-archive:/srv/ci/image.tar"},
+	}, containers["os"])
+
+	commits := rw.RewriteOSTreeSources(map[string][]ostree.SourceSpec{
+		"ostree-deployment": {{URL: "https://cdn.example.com/ostree/repo", Ref: "edge"}},
+	})
+	assert.Equal(t, []ostree.SourceSpec{{URL: "https://mirror.lab/ostree/repo", Ref: "edge"}}, commits["ostree-deployment"])
+}
+
+func TestLoadRewriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mirrors.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"prefix": "https://cdn.example.com/", "replace": "https://mirror.lab/", "sources": ["rpm"]}]}`), 0600))
+	rw, err := LoadRewriter(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://mirror.lab/baseos", rw.Rewrite(KindRPM, "https://cdn.example.com/baseos"))
+	assert.Equal(t, "https://cdn.example.com/repo", rw.Rewrite(KindOSTree, "https://cdn.example.com/repo"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"rules": [{"replace": "https://mirror.lab/"}]}`), 0600))
+	_, err = LoadRewriter(path)
+	assert.EqualError(t, err, "invalid rewrite rules in "+path+": rewrite rule 0 has neither a prefix nor a regex")
+}
+
+func TestRewriteSpecs(t *testing.T) {
+	rw, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/"},
+		{Prefix: "quay.io/", Replace: "registry.lab/"},
+	})
+	require.NoError(t, err)
+
+	repos := rw.RewriteRepoConfigs(map[string][]rpmmd.RepoConfig{
+		"os": {{Name: "baseos", BaseURLs: []string{"https://cdn.example.com/baseos"}}},
+	})
+	assert.Equal(t, []string{"https://mirror.lab/baseos"}, repos["os"][0].BaseURLs)
+
+	packages := rw.RewritePackageSpecs(map[string][]rpmmd.PackageSpec{
+		"os": {
+			{Name: "tmux", RemoteLocation: "https://cdn.example.com/baseos/Packages/tmux.rpm"},
+			// resolved from the rewritten repository
+			{Name: "vim", RemoteLocation: "https://mirror.lab/baseos/Packages/vim.rpm"},
+			{Name: "git", RemoteLocation: "https://other.example.com/Packages/git.rpm"},
+		},
+	})
+	assert.Equal(t, []rpmmd.PackageSpec{
+		{Name: "tmux", RemoteLocation: "https://mirror.lab/baseos/Packages/tmux.rpm", OriginalRemoteLocation: "https://cdn.example.com/baseos/Packages/tmux.rpm"},
+		{Name: "vim", RemoteLocation: "https://mirror.lab/baseos/Packages/vim.rpm", OriginalRemoteLocation: "https://cdn.example.com/baseos/Packages/vim.rpm"},
+		{Name: "git", RemoteLocation: "https://other.example.com/Packages/git.rpm"},
+	}, packages["os"])
+	// rewriting again keeps the original locations
+	assert.Equal(t, packages, rw.RewritePackageSpecs(packages))
+
+	containers := rw.RewriteContainerSpecs(map[string][]container.Spec{
+		"os": {
+			{Source: "quay.io/centos/centos", LocalName: "quay.io/centos/centos:stream9"},
+			{Source: "quay.io/local/image", LocalStorage: true},
+			{Source: "oci-archive:/srv/ci/image.tar"},
+		},
+	})
+	assert.Equal(t, []container.Spec{
+		{Source: "registry.lab/centos/centos", LocalName: "quay.io/centos/centos:stream9", OriginalSource: "quay.io/centos/centos"},
+		{Source: "quay.io/local/image", LocalStorage: true},
+		{Source: "oci-archive:/srv/ci/image.tar"},
+	}, containers["os"])
+
+	commits := rw.RewriteCommitSpecs(map[string][]ostree.CommitSpec{
+		"os": {{Ref: "edge", URL: "https://cdn.example.com/ostree/repo", ContentURL: "https://cdn.example.com/ostree/content"}},
+	})
+	assert.Equal(t, []ostree.CommitSpec{
+		{
+			Ref:                "edge",
+			URL:                "https://mirror.lab/ostree/repo",
+			ContentURL:         "https://mirror.lab/ostree/content",
+			OriginalURL:        "https://cdn.example.com/ostree/repo",
+			OriginalContentURL: "https://cdn.example.com/ostree/content",
+		},
+	}, commits["os"])
+
+	var nilRewriter *Rewriter
+	assert.Nil(t, nilRewriter.RewritePackageSpecs(nil))
+	assert.Equal(t, packages, nilRewriter.RewritePackageSpecs(packages))
+}
+
+func TestRewriteRemoteFiles(t *testing.T) {
+	rw, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/", Sources: []Kind{KindFile}},
+	})
+	require.NoError(t, err)
+
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+	mode := os.FileMode(0600)
+	bundle, err := fsnode.NewRemoteFile("/etc/bundle.tar", &mode, "root", int64(10), "https://cdn.example.com/bundle.tar", checksum)
+	require.NoError(t, err)
+	other, err := fsnode.NewRemoteFile("/etc/other.tar", nil, nil, nil, "https://other.example.com/other.tar", checksum)
+	require.NoError(t, err)
+
+	files, err := rw.RewriteRemoteFiles([]*fsnode.File{bundle, other})
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "https://mirror.lab/bundle.tar", files[0].URL())
+	assert.Equal(t, "/etc/bundle.tar", files[0].Path())
+	assert.Equal(t, &mode, files[0].Mode())
+	assert.Equal(t, "root", files[0].User())
+	assert.Equal(t, int64(10), files[0].Group())
+	assert.Equal(t, checksum, files[0].SHA256())
+	assert.Same(t, other, files[1])
+	// the original file keeps its original URL
+	assert.Equal(t, "https://cdn.example.com/bundle.tar", bundle.URL())
+
+	var nilRewriter *Rewriter
+	files, err = nilRewriter.RewriteRemoteFiles([]*fsnode.File{bundle})
+	require.NoError(t, err)
+	assert.Equal(t, []*fsnode.File{bundle}, files)
+}
+
+func TestOriginal(t *testing.T) {
+	rw, err := NewRewriter([]Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/"},
+		{Prefix: "quay.io/", Replace: "registry.lab/"},
+	})
+	require.NoError(t, err)
+
+	rw.RewriteRepo(rpmmd.RepoConfig{BaseURLs: []string{"https://cdn.example.com/baseos/"}})
+	rw.RewriteContainerSources(map[string][]container.SourceSpec{
+		"os": {{Source: "quay.io/centos/centos:stream9"}, {Source: "quay.io/fedora/fedora@sha256:aabbcc"}},
+	})
+
+	testCases := []struct {
+		kind     Kind
+		url      string
+		original string
+	}{
+		{KindRPM, "https://mirror.lab/baseos/", "https://cdn.example.com/baseos/"},
+		{KindRPM, "https://mirror.lab/baseos/Packages/tmux.rpm", "https://cdn.example.com/baseos/Packages/tmux.rpm"},
+		{KindRPM, "https://mirror.lab/appstream/Packages/vim.rpm", ""},
+		{KindRPM, "https://other.example.com/baseos/", ""},
+		// resolved containers are named without their tag or digest
+		{KindContainer, "registry.lab/centos/centos", "quay.io/centos/centos"},
+		{KindContainer, "registry.lab/centos/centos:stream9", "quay.io/centos/centos:stream9"},
+		{KindContainer, "registry.lab/fedora/fedora", "quay.io/fedora/fedora"},
+		{KindContainer, "registry.lab/centos", ""},
+		// the original URLs are recorded by kind
+		{KindOSTree, "https://mirror.lab/baseos/", ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.original, rw.Original(tc.kind, tc.url), tc.url)
+	}
+
+	var nilRewriter *Rewriter
+	assert.Equal(t, "", nilRewriter.Original(KindRPM, "https://mirror.lab/baseos/"))
+}
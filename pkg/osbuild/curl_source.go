@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/osbuild/images/pkg/customizations/fsnode"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
 
@@ -59,6 +60,13 @@ func (source *CurlSource) AddPackage(pkg rpmmd.PackageSpec) error {
 	return nil
 }
 
+// AddRemoteFile adds the content of a remote file to the curl source to
+// download. osbuild verifies the downloaded content against the checksum of
+// the file.
+func (source *CurlSource) AddRemoteFile(file *fsnode.File) {
+	source.Items["sha256:"+file.SHA256()] = URL(file.URL())
+}
+
 type URL string
 
 func (URL) isCurlSourceItem() {}
@@ -76,34 +84,33 @@ type URLSecrets struct {
 }
 
 // Unmarshal method for CurlSource for handling the CurlSourceItem interface:
-// Tries each of the implementations until it finds the one that works.
-func (cs *CurlSource) UnmarshalJSON(data []byte) (err error) {
-	cs.Items = make(map[string]CurlSourceItem)
-	type csSimple struct {
-		Items map[string]URL `json:"items"`
+// Tries each of the implementations for every item until it finds the one
+// that works, so that sources with both kinds of items can be unmarshalled.
+func (cs *CurlSource) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Items map[string]json.RawMessage `json:"items"`
 	}
-	simple := new(csSimple)
-	b := bytes.NewReader(data)
-	dec := json.NewDecoder(b)
+	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.DisallowUnknownFields()
-	if err = dec.Decode(simple); err == nil {
-		for k, v := range simple.Items {
-			cs.Items[k] = v
-		}
-		return
+	if err := dec.Decode(&raw); err != nil {
+		return err
 	}
 
-	type csWithSecrets struct {
-		Items map[string]CurlSourceOptions `json:"items"`
-	}
-	withSecrets := new(csWithSecrets)
-	b.Reset(data)
-	if err = dec.Decode(withSecrets); err == nil {
-		for k, v := range withSecrets.Items {
-			cs.Items[k] = v
+	cs.Items = make(map[string]CurlSourceItem, len(raw.Items))
+	for k, rawItem := range raw.Items {
+		var url URL
+		if err := json.Unmarshal(rawItem, &url); err == nil {
+			cs.Items[k] = url
+			continue
 		}
-		return
-	}
 
-	return
+		var options CurlSourceOptions
+		dec := json.NewDecoder(bytes.NewReader(rawItem))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&options); err != nil {
+			return err
+		}
+		cs.Items[k] = options
+	}
+	return nil
 }
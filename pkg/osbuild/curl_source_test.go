@@ -1,10 +1,12 @@
 package osbuild
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/osbuild/images/pkg/rpmmd"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPackageSourceValidation(t *testing.T) {
@@ -117,3 +119,20 @@ func TestPackageSourceValidation(t *testing.T) {
 		}
 	}
 }
+
+func TestCurlSourceUnmarshalMixedItems(t *testing.T) {
+	data := `{
+  "items": {
+    "sha256:aa": "https://example.com/bundle.tar",
+    "sha256:bb": {"url": "https://example.com/tmux.rpm", "secrets": {"name": "org.osbuild.rhsm"}}
+  }
+}`
+	var source CurlSource
+	require.NoError(t, json.Unmarshal([]byte(data), &source))
+	assert.Equal(t, map[string]CurlSourceItem{
+		"sha256:aa": URL("https://example.com/bundle.tar"),
+		"sha256:bb": CurlSourceOptions{URL: "https://example.com/tmux.rpm", Secrets: &URLSecrets{Name: "org.osbuild.rhsm"}},
+	}, source.Items)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"items": {"sha256:aa": {"uri": "https://example.com"}}}`), &source))
+}
@@ -0,0 +1,45 @@
+package osbuild
+
+import (
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// DNFModuleConfigStageOptions represents the state of a DNF module, written
+// to /etc/dnf/modules.d/<name>.module.
+type DNFModuleConfigStageOptions struct {
+	Conf *DNFModuleConfig `json:"conf,omitempty"`
+}
+
+func (DNFModuleConfigStageOptions) isStageOptions() {}
+
+type DNFModuleConfig struct {
+	Name     string   `json:"name"`
+	Stream   string   `json:"stream"`
+	State    string   `json:"state"`
+	Profiles []string `json:"profiles"`
+}
+
+// NewDNFModuleConfigStageOptions creates the options of a module config stage
+// that enables the given module stream.
+func NewDNFModuleConfigStageOptions(module rpmmd.ModuleStream) *DNFModuleConfigStageOptions {
+	profiles := []string{}
+	if module.Profile != "" {
+		profiles = append(profiles, module.Profile)
+	}
+	return &DNFModuleConfigStageOptions{
+		Conf: &DNFModuleConfig{
+			Name:     module.Name,
+			Stream:   module.Stream,
+			State:    "enabled",
+			Profiles: profiles,
+		},
+	}
+}
+
+// NewDNFModuleConfigStage creates a new DNF module config stage.
+func NewDNFModuleConfigStage(options *DNFModuleConfigStageOptions) *Stage {
+	return &Stage{
+		Type:    "org.osbuild.dnf.module-config",
+		Options: options,
+	}
+}
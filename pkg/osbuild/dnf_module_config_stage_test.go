@@ -0,0 +1,25 @@
+package osbuild
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestNewDNFModuleConfigStage(t *testing.T) {
+	stage := NewDNFModuleConfigStage(NewDNFModuleConfigStageOptions(rpmmd.ModuleStream{Name: "postgresql", Stream: "15", Profile: "server"}))
+	data, err := json.Marshal(stage)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "org.osbuild.dnf.module-config",
+		"options": {"conf": {"name": "postgresql", "stream": "15", "state": "enabled", "profiles": ["server"]}}
+	}`, string(data))
+
+	// modules without a profile have an empty list of profiles
+	options := NewDNFModuleConfigStageOptions(rpmmd.ModuleStream{Name: "nodejs", Stream: "18"})
+	assert.Equal(t, []string{}, options.Conf.Profiles)
+}
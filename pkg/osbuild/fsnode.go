@@ -1,7 +1,6 @@
 package osbuild
 
 import (
-	"crypto/sha256"
 	"fmt"
 
 	"github.com/osbuild/images/pkg/customizations/fsnode"
@@ -22,7 +21,7 @@ func GenFileNodesStages(files []*fsnode.File) []*Stage {
 	chownPaths := make(map[string]ChownStagePathOptions)
 
 	for _, file := range files {
-		fileDataChecksum := fmt.Sprintf("%x", sha256.Sum256(file.Data()))
+		fileDataChecksum := file.SHA256()
 		copyStageInputKey := fmt.Sprintf("file-%s", fileDataChecksum)
 		copyStagePaths = append(copyStagePaths, CopyStagePath{
 			From: fmt.Sprintf("input://%s/sha256:%s", copyStageInputKey, fileDataChecksum),
@@ -0,0 +1,48 @@
+package osbuild
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/cert"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+)
+
+// CAAnchorsDir is the directory for additional trust anchors that are
+// consolidated into the system trust store by update-ca-trust.
+const CAAnchorsDir = "/etc/pki/ca-trust/source/anchors"
+
+// NewUpdateCATrustStage creates a new org.osbuild.pki.update-ca-trust stage
+// which regenerates the system trust store from the anchors in the tree.
+func NewUpdateCATrustStage() *Stage {
+	return &Stage{
+		Type: "org.osbuild.pki.update-ca-trust",
+	}
+}
+
+// NewCAFileNodes returns a file node in CAAnchorsDir for each certificate in
+// the PEM encoded bundle. Files are named by the SHA-256 fingerprint of the
+// certificate.
+func NewCAFileNodes(bundle string) ([]*fsnode.File, error) {
+	certs, err := cert.ParseCerts(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*fsnode.File, 0, len(certs))
+	for _, c := range certs {
+		fingerprint := sha256.Sum256(c.Raw)
+		path := filepath.Join(CAAnchorsDir, hex.EncodeToString(fingerprint[:])+".pem")
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+		file, err := fsnode.NewFile(path, common.ToPtr(os.FileMode(0644)), "root", "root", data)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,39 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCACert = `-----BEGIN CERTIFICATE-----
+MIIBgzCCASmgAwIBAgIUSDm4/9TNoy/pAInNMj4klsS/TWYwCgYIKoZIzj0EAwIw
+FjEUMBIGA1UEAwwLVGVzdCBDQSBUd28wIBcNMjYxMDE4MjE1NjMwWhgPMjEyNjA5
+MjQyMTU2MzBaMBYxFDASBgNVBAMMC1Rlc3QgQ0EgVHdvMFkwEwYHKoZIzj0CAQYI
+KoZIzj0DAQcDQgAEun2KePq54xx1tbj30AU1v1u1UVqV1UT330hcxGwzZ81mhv8a
+gD0OFuaYVt+WTp0DBKn7h/Cpp2BkT700NRAQfaNTMFEwHQYDVR0OBBYEFIto22Qt
+NHw2Aa6IGLfow5bQvYDmMB8GA1UdIwQYMBaAFIto22QtNHw2Aa6IGLfow5bQvYDm
+MA8GA1UdEwEB/wQFMAMBAf8wCgYIKoZIzj0EAwIDSAAwRQIgaJCpDX515kf76Tzi
+fZhwB4hRdpGswI2Q7eRQ9ogMVU0CIQC92RWghBhpcWQUidbHoy1BDIal/EFTcK3y
+OHFwwvTqTg==
+-----END CERTIFICATE-----
+`
+
+func TestNewUpdateCATrustStage(t *testing.T) {
+	expectedStage := &Stage{
+		Type: "org.osbuild.pki.update-ca-trust",
+	}
+	assert.Equal(t, expectedStage, NewUpdateCATrustStage())
+}
+
+func TestNewCAFileNodes(t *testing.T) {
+	files, err := NewCAFileNodes(testCACert)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "/etc/pki/ca-trust/source/anchors/597df91dc373470031b32caaf4cd01d81c8224ce34e729ed8197e8a278ec170b.pem", files[0].Path())
+	assert.Equal(t, testCACert, string(files[0].Data()))
+
+	_, err = NewCAFileNodes("garbage")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package osbuild
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/proxy"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// SourcesProxyEnv returns the environment variables that configure the proxy
+// of the osbuild sources for the packages and commits, to be passed to
+// RunOSBuild().
+//
+// The sources of osbuild read the proxy from the environment, so only a
+// single proxy is supported for all the content: an error is returned if the
+// content requires more than one. The hosts of content that is accessed
+// directly are added to the no-proxy list of the environment. Content without
+// a proxy configuration uses the proxy of the environment, which is replaced
+// if other content requires a proxy. The CA certificate of the proxy must be
+// trusted by the host running osbuild.
+//
+// Containers have no proxy configuration: containers/image only supports the
+// proxy of the environment, which is used to resolve and to fetch them.
+func SourcesProxyEnv(packages []rpmmd.PackageSpec, commits []ostree.CommitSpec) ([]string, error) {
+	type source struct {
+		url    string
+		config *proxy.Config
+	}
+	var sources []source
+	for _, pkg := range packages {
+		if pkg.Proxy != nil {
+			sources = append(sources, source{pkg.RemoteLocation, pkg.Proxy})
+		}
+	}
+	for _, commit := range commits {
+		if commit.Proxy != nil {
+			sources = append(sources, source{commit.URL, commit.Proxy})
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+
+	var proxyURL string
+	noProxy := make(map[string]bool)
+	for _, src := range sources {
+		u, err := url.Parse(src.url)
+		if err != nil {
+			return nil, err
+		}
+		srcProxy, err := src.config.ForURL(src.url)
+		if err != nil {
+			return nil, err
+		}
+		if srcProxy == "" {
+			noProxy[u.Hostname()] = true
+			continue
+		}
+		if proxyURL != "" && proxyURL != srcProxy {
+			return nil, fmt.Errorf("osbuild sources support a single proxy, but the content requires %s and %s", proxyURL, srcProxy)
+		}
+		proxyURL = srcProxy
+	}
+
+	for _, env := range []string{"no_proxy", "NO_PROXY"} {
+		for _, host := range strings.Split(os.Getenv(env), ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				noProxy[host] = true
+			}
+		}
+	}
+	hosts := make([]string, 0, len(noProxy))
+	for host := range noProxy {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	if proxyURL == "" {
+		// keep the proxy of the environment for the other content
+		return []string{
+			"no_proxy=" + strings.Join(hosts, ","),
+			"NO_PROXY=" + strings.Join(hosts, ","),
+		}, nil
+	}
+	config := proxy.Config{URL: proxyURL, NoProxy: hosts}
+	return config.Env(), nil
+}
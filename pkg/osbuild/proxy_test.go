@@ -0,0 +1,52 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/proxy"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestSourcesProxyEnv(t *testing.T) {
+	t.Setenv("no_proxy", "localhost")
+	t.Setenv("NO_PROXY", "")
+
+	external := &proxy.Config{URL: "http://proxy.example.com:3128", NoProxy: []string{"internal.example.com"}}
+	direct := &proxy.Config{}
+
+	env, err := SourcesProxyEnv([]rpmmd.PackageSpec{{RemoteLocation: "https://cdn.example.com/tmux.rpm"}}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, env)
+
+	env, err = SourcesProxyEnv(
+		[]rpmmd.PackageSpec{
+			{RemoteLocation: "https://cdn.example.com/tmux.rpm", Proxy: external},
+			{RemoteLocation: "https://rpm.internal.example.com/tool.rpm", Proxy: external},
+		},
+		[]ostree.CommitSpec{{URL: "https://ostree.lab/repo", Proxy: direct}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"http_proxy=http://proxy.example.com:3128",
+		"https_proxy=http://proxy.example.com:3128",
+		"no_proxy=localhost,ostree.lab,rpm.internal.example.com",
+		"HTTP_PROXY=http://proxy.example.com:3128",
+		"HTTPS_PROXY=http://proxy.example.com:3128",
+		"NO_PROXY=localhost,ostree.lab,rpm.internal.example.com",
+	}, env)
+
+	// direct access only extends the no-proxy list of the environment
+	env, err = SourcesProxyEnv(nil, []ostree.CommitSpec{{URL: "https://ostree.lab/repo", Proxy: direct}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"no_proxy=localhost,ostree.lab", "NO_PROXY=localhost,ostree.lab"}, env)
+
+	_, err = SourcesProxyEnv(
+		[]rpmmd.PackageSpec{{RemoteLocation: "https://cdn.example.com/tmux.rpm", Proxy: external}},
+		[]ostree.CommitSpec{{URL: "https://ostree.example.com/repo", Proxy: &proxy.Config{URL: "http://other-proxy.example.com"}}},
+	)
+	assert.EqualError(t, err, "osbuild sources support a single proxy, but the content requires http://proxy.example.com:3128 and http://other-proxy.example.com")
+}
@@ -0,0 +1,131 @@
+package osbuild
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/pkg/customizations/selinux"
+)
+
+// SELinuxSemanageStageOptions describes local modifications to the SELinux
+// policy of the tree. The modifications are stored persistently in the
+// policy store and must be applied before the tree is labeled by the
+// org.osbuild.selinux stage.
+type SELinuxSemanageStageOptions struct {
+	Booleans     []SELinuxBoolean     `json:"booleans,omitempty"`
+	Ports        []SELinuxPort        `json:"ports,omitempty"`
+	FileContexts []SELinuxFileContext `json:"fcontexts,omitempty"`
+}
+
+func (SELinuxSemanageStageOptions) isStageOptions() {}
+
+// SELinuxBoolean sets the persistent value of a policy boolean.
+type SELinuxBoolean struct {
+	Name  string `json:"name"`
+	Value bool   `json:"value"`
+}
+
+// SELinuxPort assigns a port type to a port or a range of ports ("low-high").
+type SELinuxPort struct {
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
+	Port     string `json:"port"`
+}
+
+// SELinuxFileContext assigns a file type to the paths matching the target
+// regular expression.
+type SELinuxFileContext struct {
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+func (o SELinuxSemanageStageOptions) validate() error {
+	if len(o.Booleans) == 0 && len(o.Ports) == 0 && len(o.FileContexts) == 0 {
+		return fmt.Errorf("at least one of booleans, ports or file contexts is required")
+	}
+	for _, p := range o.Ports {
+		if p.Type == "" || p.Protocol == "" || p.Port == "" {
+			return fmt.Errorf("SELinux port definitions require a type, protocol and port")
+		}
+	}
+	for _, fc := range o.FileContexts {
+		if fc.Target == "" || fc.Type == "" {
+			return fmt.Errorf("SELinux file context definitions require a target and type")
+		}
+	}
+	return nil
+}
+
+// NewSELinuxSemanageStage creates a new org.osbuild.selinux.semanage stage.
+func NewSELinuxSemanageStage(options *SELinuxSemanageStageOptions) *Stage {
+	if err := options.validate(); err != nil {
+		panic(err)
+	}
+	return &Stage{
+		Type:    "org.osbuild.selinux.semanage",
+		Options: options,
+	}
+}
+
+// SELinuxModuleStageOptions describes custom SELinux policy modules (.pp or
+// .cil) to install into the policy store of the tree. The module files must
+// already exist in the tree.
+type SELinuxModuleStageOptions struct {
+	Modules []string `json:"modules"`
+}
+
+func (SELinuxModuleStageOptions) isStageOptions() {}
+
+func (o SELinuxModuleStageOptions) validate() error {
+	if len(o.Modules) == 0 {
+		return fmt.Errorf("at least one module is required")
+	}
+	for _, m := range o.Modules {
+		if len(m) == 0 || m[0] != '/' {
+			return fmt.Errorf("module path %q must be absolute", m)
+		}
+	}
+	return nil
+}
+
+// NewSELinuxModuleStage creates a new org.osbuild.selinux.module stage.
+func NewSELinuxModuleStage(options *SELinuxModuleStageOptions) *Stage {
+	if err := options.validate(); err != nil {
+		panic(err)
+	}
+	return &Stage{
+		Type:    "org.osbuild.selinux.module",
+		Options: options,
+	}
+}
+
+// GenSELinuxPolicyStages returns the stages that install the custom policy
+// modules and apply the local policy modifications from the options. The
+// stages must be added to the pipeline before the tree is labeled.
+func GenSELinuxPolicyStages(options *selinux.Options) []*Stage {
+	if options == nil {
+		return nil
+	}
+
+	var stages []*Stage
+	if len(options.Modules) > 0 {
+		stages = append(stages, NewSELinuxModuleStage(&SELinuxModuleStageOptions{
+			Modules: options.Modules,
+		}))
+	}
+
+	if len(options.Booleans) == 0 && len(options.Ports) == 0 && len(options.FileContexts) == 0 {
+		return stages
+	}
+
+	semanageOptions := &SELinuxSemanageStageOptions{}
+	for _, b := range options.Booleans {
+		semanageOptions.Booleans = append(semanageOptions.Booleans, SELinuxBoolean(b))
+	}
+	for _, p := range options.Ports {
+		semanageOptions.Ports = append(semanageOptions.Ports, SELinuxPort(p))
+	}
+	for _, fc := range options.FileContexts {
+		semanageOptions.FileContexts = append(semanageOptions.FileContexts, SELinuxFileContext(fc))
+	}
+	return append(stages, NewSELinuxSemanageStage(semanageOptions))
+}
@@ -0,0 +1,75 @@
+package osbuild
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/osbuild/images/pkg/customizations/selinux"
+)
+
+func TestNewSELinuxSemanageStage(t *testing.T) {
+	options := &SELinuxSemanageStageOptions{
+		Booleans: []SELinuxBoolean{{Name: "httpd_can_network_connect", Value: true}},
+		Ports:    []SELinuxPort{{Type: "http_port_t", Protocol: "tcp", Port: "8443"}},
+		FileContexts: []SELinuxFileContext{
+			{Target: "/srv/www(/.*)?", Type: "httpd_sys_content_t"},
+		},
+	}
+	expectedStage := &Stage{
+		Type:    "org.osbuild.selinux.semanage",
+		Options: options,
+	}
+	assert.Equal(t, expectedStage, NewSELinuxSemanageStage(options))
+}
+
+func TestNewSELinuxSemanageStageInvalid(t *testing.T) {
+	tests := map[string]*SELinuxSemanageStageOptions{
+		"empty":         {},
+		"port-type":     {Ports: []SELinuxPort{{Protocol: "tcp", Port: "80"}}},
+		"fcontext-type": {FileContexts: []SELinuxFileContext{{Target: "/srv"}}},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Panics(t, func() { NewSELinuxSemanageStage(options) })
+		})
+	}
+}
+
+func TestNewSELinuxModuleStage(t *testing.T) {
+	options := &SELinuxModuleStageOptions{
+		Modules: []string{"/etc/selinux/modules/myapp.pp"},
+	}
+	expectedStage := &Stage{
+		Type:    "org.osbuild.selinux.module",
+		Options: options,
+	}
+	assert.Equal(t, expectedStage, NewSELinuxModuleStage(options))
+
+	assert.Panics(t, func() { NewSELinuxModuleStage(&SELinuxModuleStageOptions{}) })
+	assert.Panics(t, func() {
+		NewSELinuxModuleStage(&SELinuxModuleStageOptions{Modules: []string{"myapp.pp"}})
+	})
+}
+
+func TestGenSELinuxPolicyStages(t *testing.T) {
+	assert.Nil(t, GenSELinuxPolicyStages(nil))
+
+	stages := GenSELinuxPolicyStages(&selinux.Options{
+		Modules: []string{"/etc/selinux/modules/myapp.pp"},
+	})
+	assert.Len(t, stages, 1)
+	assert.Equal(t, "org.osbuild.selinux.module", stages[0].Type)
+
+	stages = GenSELinuxPolicyStages(&selinux.Options{
+		Booleans: []selinux.Boolean{{Name: "httpd_can_network_connect", Value: true}},
+		Ports:    []selinux.Port{{Type: "http_port_t", Protocol: "tcp", Port: "8080-8090"}},
+		Modules:  []string{"/etc/selinux/modules/myapp.pp"},
+	})
+	assert.Len(t, stages, 2)
+	assert.Equal(t, "org.osbuild.selinux.module", stages[0].Type)
+	assert.Equal(t, &SELinuxSemanageStageOptions{
+		Booleans: []SELinuxBoolean{{Name: "httpd_can_network_connect", Value: true}},
+		Ports:    []SELinuxPort{{Type: "http_port_t", Protocol: "tcp", Port: "8080-8090"}},
+	}, stages[1].Options)
+}
@@ -20,6 +20,16 @@ type SkopeopSourceImage struct {
 	Name      string `json:"name,omitempty"`
 	Digest    string `json:"digest,omitempty"`
 	TLSVerify *bool  `json:"tls-verify,omitempty"`
+
+	// Transport to fetch the image with, DockerTransport if empty. With the
+	// container.OCIArchiveTransport and container.OCILayoutTransport
+	// transports, which the source passes on to skopeo like the others, the
+	// name is the path of the archive or layout directory, optionally
+	// followed by ":" and a reference within it. Unlike this source, the
+	// skopeo-index source only fetches from registries.
+	ContainersTransport string `json:"containers-transport,omitempty"`
+	// Location of the containers storage with ContainersStorageTransport
+	StorageLocation string `json:"storage-location,omitempty"`
 }
 
 type SkopeoSourceItem struct {
@@ -63,7 +73,15 @@ func NewSkopeoSource() *SkopeoSource {
 // AddItem adds a source item to the source; will panic
 // if any of the supplied options are invalid or missing
 func (source *SkopeoSource) AddItem(name, digest, image string, tlsVerify *bool) {
+	source.AddTransportItem("", name, digest, image, tlsVerify)
+}
+
+// AddTransportItem adds a source item that is fetched with the given
+// containers transport to the source; will panic if any of the supplied
+// options are invalid or missing
+func (source *SkopeoSource) AddTransportItem(transport, name, digest, image string, tlsVerify *bool) {
 	item := NewSkopeoSourceItem(name, digest, tlsVerify)
+	item.Image.ContainersTransport = transport
 	if !skopeoDigestPattern.MatchString(image) {
 		panic(fmt.Errorf("item %#v has invalid image id", image))
 	}
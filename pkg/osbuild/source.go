@@ -3,8 +3,11 @@ package osbuild
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/mirror"
 	"github.com/osbuild/images/pkg/ostree"
 	"github.com/osbuild/images/pkg/rpmmd"
 )
@@ -41,6 +44,12 @@ func (sources *Sources) UnmarshalJSON(data []byte) error {
 			source = new(InlineSource)
 		case "org.osbuild.ostree":
 			source = new(OSTreeSource)
+		case "org.osbuild.skopeo":
+			source = new(SkopeoSource)
+		case "org.osbuild.skopeo-index":
+			source = new(SkopeoIndexSource)
+		case "org.osbuild.containers-storage":
+			source = new(ContainersStorageSource)
 		default:
 			return errors.New("unexpected source name: " + name)
 		}
@@ -54,18 +63,33 @@ func (sources *Sources) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec, inlineData []string, containers []container.Spec) (Sources, error) {
+func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec, inlineData []string, containers []container.Spec, remoteFiles []*fsnode.File) (Sources, error) {
+	return GenRewrittenSources(nil, packages, ostreeCommits, inlineData, containers, remoteFiles)
+}
+
+// GenRewrittenSources generates the sources like GenSources, with the URLs of
+// the packages, ostree commits, containers and remote files rewritten by the
+// rewriter to fetch them from mirrors. The rewriter records the original URLs.
+func GenRewrittenSources(rewriter *mirror.Rewriter, packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec, inlineData []string, containers []container.Spec, remoteFiles []*fsnode.File) (Sources, error) {
 	sources := Sources{}
 
-	// collect rpm package sources
-	if len(packages) > 0 {
+	remoteFiles, err := rewriter.RewriteRemoteFiles(remoteFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect rpm package and remote file sources
+	if len(packages) > 0 || len(remoteFiles) > 0 {
 		curl := NewCurlSource()
 		for _, pkg := range packages {
-			err := curl.AddPackage(pkg)
+			err := curl.AddPackage(rewriter.RewritePackageSpec(pkg))
 			if err != nil {
 				return nil, err
 			}
 		}
+		for _, file := range remoteFiles {
+			curl.AddRemoteFile(file)
+		}
 		sources["org.osbuild.curl"] = curl
 	}
 
@@ -73,7 +97,7 @@ func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec,
 	if len(ostreeCommits) > 0 {
 		ostree := NewOSTreeSource()
 		for _, commit := range ostreeCommits {
-			ostree.AddItem(commit)
+			ostree.AddItem(rewriter.RewriteCommitSpec(commit))
 		}
 		if len(ostree.Items) > 0 {
 			sources["org.osbuild.ostree"] = ostree
@@ -96,13 +120,27 @@ func GenSources(packages []rpmmd.PackageSpec, ostreeCommits []ostree.CommitSpec,
 		skopeoIndex := NewSkopeoIndexSource()
 		localContainers := NewContainersStorageSource()
 		for _, c := range containers {
+			c = rewriter.RewriteContainerSpec(c)
 			if c.LocalStorage {
 				localContainers.AddItem(c.ImageID)
+			} else if transport, ref, ok := container.SplitOCISource(c.Source); ok {
+				// archives and layout directories are copied from the
+				// build host as they are; the skopeo-index source only
+				// fetches manifest lists from registries
+				if c.ListDigest != "" {
+					return nil, fmt.Errorf("the manifest list of %s cannot be fetched, only registry sources can have a list digest", c.Source)
+				}
+				skopeo.AddTransportItem(transport, ref, c.Digest, c.ImageID, nil)
 			} else {
-				skopeo.AddItem(c.Source, c.Digest, c.ImageID, c.TLSVerify)
+				// fetch the image from the registry mirror it was resolved from
+				source := c.Source
+				if c.Mirror != "" {
+					source = c.Mirror
+				}
+				skopeo.AddItem(source, c.Digest, c.ImageID, c.TLSVerify)
 				// if we have a list digest, add a skopeo-index source as well
 				if c.ListDigest != "" {
-					skopeoIndex.AddItem(c.Source, c.ListDigest, c.TLSVerify)
+					skopeoIndex.AddItem(source, c.ListDigest, c.TLSVerify)
 				}
 			}
 		}
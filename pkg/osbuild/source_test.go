@@ -7,8 +7,14 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/osbuild/images/internal/common"
 	"github.com/osbuild/images/pkg/container"
+	"github.com/osbuild/images/pkg/customizations/fsnode"
+	"github.com/osbuild/images/pkg/mirror"
+	"github.com/osbuild/images/pkg/ostree"
+	"github.com/osbuild/images/pkg/rpmmd"
 )
 
 func TestSource_UnmarshalJSON(t *testing.T) {
@@ -119,7 +125,7 @@ func TestSource_UnmarshalJSON(t *testing.T) {
 }
 
 func TestGenSourcesTrivial(t *testing.T) {
-	sources, err := GenSources(nil, nil, nil, nil)
+	sources, err := GenSources(nil, nil, nil, nil, nil)
 	assert.NoError(t, err)
 
 	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
@@ -135,7 +141,7 @@ func TestGenSourcesContainerStorage(t *testing.T) {
 			LocalStorage: true,
 		},
 	}
-	sources, err := GenSources(nil, nil, nil, containers)
+	sources, err := GenSources(nil, nil, nil, containers, nil)
 	assert.NoError(t, err)
 
 	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
@@ -159,7 +165,7 @@ func TestGenSourcesSkopeo(t *testing.T) {
 			ImageID: imageID,
 		},
 	}
-	sources, err := GenSources(nil, nil, nil, containers)
+	sources, err := GenSources(nil, nil, nil, containers, nil)
 	assert.NoError(t, err)
 
 	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
@@ -190,7 +196,7 @@ func TestGenSourcesWithSkopeoIndex(t *testing.T) {
 			ImageID:    imageID,
 		},
 	}
-	sources, err := GenSources(nil, nil, nil, containers)
+	sources, err := GenSources(nil, nil, nil, containers, nil)
 	assert.NoError(t, err)
 
 	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
@@ -217,3 +223,133 @@ func TestGenSourcesWithSkopeoIndex(t *testing.T) {
   }
 }`)
 }
+
+func TestGenSourcesRegistryMirror(t *testing.T) {
+	containers := []container.Spec{
+		{
+			Source:    "docker.io/library/fedora",
+			Mirror:    "registry.lab:5000/dockerhub/library/fedora",
+			Digest:    "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			ImageID:   "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			TLSVerify: common.ToPtr(false),
+		},
+	}
+	sources, err := GenSources(nil, nil, nil, containers, nil)
+	require.NoError(t, err)
+
+	skopeo := sources["org.osbuild.skopeo"].(*SkopeoSource)
+	assert.Equal(t, SkopeopSourceImage{
+		Name:      "registry.lab:5000/dockerhub/library/fedora",
+		Digest:    containers[0].Digest,
+		TLSVerify: common.ToPtr(false),
+	}, skopeo.Items[containers[0].ImageID].Image)
+}
+
+func TestGenSourcesOCI(t *testing.T) {
+	containers := []container.Spec{
+		{
+			Source:    "oci-archive:/srv/ci/image.tar",
+			Digest:    "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			ImageID:   "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			TLSVerify: common.ToPtr(false),
+		},
+		{
+			Source:  "oci:/srv/ci/layout:v2",
+			Digest:  "sha256:1122335cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			ImageID: "sha256:4455665cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		},
+	}
+	sources, err := GenSources(nil, nil, nil, containers, nil)
+	assert.NoError(t, err)
+
+	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonOutput), `{
+  "org.osbuild.skopeo": {
+    "items": {
+      "sha256:4455665cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f": {
+        "image": {
+          "name": "/srv/ci/layout:v2",
+          "digest": "sha256:1122335cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+          "containers-transport": "oci"
+        }
+      },
+      "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f": {
+        "image": {
+          "name": "/srv/ci/image.tar",
+          "digest": "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+          "containers-transport": "oci-archive"
+        }
+      }
+    }
+  }
+}`)
+
+	// the skopeo-index source cannot fetch manifest lists from archives
+	containers[0].ListDigest = "sha256:ffeeaabbcc90e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f"
+	_, err = GenSources(nil, nil, nil, containers, nil)
+	assert.EqualError(t, err, "the manifest list of oci-archive:/srv/ci/image.tar cannot be fetched, only registry sources can have a list digest")
+}
+
+func TestGenSourcesRemoteFiles(t *testing.T) {
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+	file, err := fsnode.NewRemoteFile("/etc/bundle.tar", nil, nil, nil, "https://example.com/bundle.tar", checksum)
+	require.NoError(t, err)
+
+	sources, err := GenSources(nil, nil, nil, nil, []*fsnode.File{file})
+	assert.NoError(t, err)
+
+	jsonOutput, err := json.MarshalIndent(sources, "", "  ")
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "org.osbuild.curl": {
+    "items": {
+      "sha256:4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865": "https://example.com/bundle.tar"
+    }
+  }
+}`, string(jsonOutput))
+}
+
+func TestGenRewrittenSources(t *testing.T) {
+	rw, err := mirror.NewRewriter([]mirror.Rule{
+		{Prefix: "https://cdn.example.com/", Replace: "https://mirror.lab/"},
+		{Prefix: "quay.io/", Replace: "registry.lab/"},
+	})
+	require.NoError(t, err)
+
+	packages := []rpmmd.PackageSpec{
+		{Name: "tmux", RemoteLocation: "https://cdn.example.com/baseos/tmux.rpm", Checksum: "sha256:8f1c1d1d84a9a4cfa0b2b9d76d4b5f5b4d5c6ce1d2ba0f9c2a6c0a0e2ec5a0c1"},
+	}
+	commits := []ostree.CommitSpec{
+		{Ref: "edge", URL: "https://cdn.example.com/ostree/repo", Checksum: "8f1c1d1d84a9a4cfa0b2b9d76d4b5f5b4d5c6ce1d2ba0f9c2a6c0a0e2ec5a0c1"},
+	}
+	containers := []container.Spec{
+		{
+			Source:  "quay.io/centos/centos",
+			Digest:  "sha256:aabbcc5cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+			ImageID: "sha256:c2ecf25cf190e76b12b07436ad5140d4ba53d8a136d498705e57a006837a720f",
+		},
+	}
+	checksum := "4355a46b19d348dc2f57c046f8ef63d4538ebb936000f3c9ee954a27460dd865"
+	file, err := fsnode.NewRemoteFile("/etc/bundle.tar", nil, nil, nil, "https://cdn.example.com/bundle.tar", checksum)
+	require.NoError(t, err)
+
+	sources, err := GenRewrittenSources(rw, packages, commits, nil, containers, []*fsnode.File{file})
+	require.NoError(t, err)
+
+	curl := sources["org.osbuild.curl"].(*CurlSource)
+	assert.Equal(t, "https://mirror.lab/baseos/tmux.rpm", curl.Items[packages[0].Checksum].(*CurlSourceOptions).URL)
+	assert.Equal(t, URL("https://mirror.lab/bundle.tar"), curl.Items["sha256:"+checksum])
+	assert.Equal(t, "https://mirror.lab/ostree/repo", sources["org.osbuild.ostree"].(*OSTreeSource).Items[commits[0].Checksum].Remote.URL)
+	assert.Equal(t, "registry.lab/centos/centos", sources["org.osbuild.skopeo"].(*SkopeoSource).Items[containers[0].ImageID].Image.Name)
+
+	// the content passed in keeps its original URLs
+	assert.Equal(t, "https://cdn.example.com/baseos/tmux.rpm", packages[0].RemoteLocation)
+	assert.Equal(t, "https://cdn.example.com/bundle.tar", file.URL())
+	assert.Equal(t, []mirror.Rewrite{
+		{Kind: mirror.KindContainer, Original: "quay.io/centos/centos", Rewritten: "registry.lab/centos/centos"},
+		{Kind: mirror.KindFile, Original: "https://cdn.example.com/bundle.tar", Rewritten: "https://mirror.lab/bundle.tar"},
+		{Kind: mirror.KindOSTree, Original: "https://cdn.example.com/ostree/repo", Rewritten: "https://mirror.lab/ostree/repo"},
+		{Kind: mirror.KindRPM, Original: "https://cdn.example.com/baseos/tmux.rpm", Rewritten: "https://mirror.lab/baseos/tmux.rpm"},
+	}, rw.Rewrites())
+}
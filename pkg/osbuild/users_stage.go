@@ -22,6 +22,25 @@ type UsersStageOptionsUser struct {
 	Key                *string  `json:"key,omitempty"`
 	ExpireDate         *int     `json:"expiredate,omitempty"`
 	ForcePasswordReset *bool    `json:"force_password_reset,omitempty"`
+
+	// Additional lines for the authorized_keys file of the user
+	Keys          []string                        `json:"keys,omitempty"`
+	PasswordAging *UsersStageOptionsPasswordAging `json:"password_aging,omitempty"`
+	Locked        *bool                           `json:"locked,omitempty"`
+	SubUID        *UsersStageOptionsSubIDRange    `json:"subuid,omitempty"`
+	SubGID        *UsersStageOptionsSubIDRange    `json:"subgid,omitempty"`
+}
+
+type UsersStageOptionsPasswordAging struct {
+	MinDays      *int `json:"min_days,omitempty"`
+	MaxDays      *int `json:"max_days,omitempty"`
+	WarnDays     *int `json:"warn_days,omitempty"`
+	InactiveDays *int `json:"inactive_days,omitempty"`
+}
+
+type UsersStageOptionsSubIDRange struct {
+	Start int `json:"start"`
+	Count int `json:"count"`
 }
 
 func NewUsersStage(options *UsersStageOptions) *Stage {
@@ -64,9 +83,27 @@ func NewUsersStageOptions(userCustomizations []users.User, omitKey bool) (*Users
 			Key:                nil,
 			ExpireDate:         uc.ExpireDate,
 			ForcePasswordReset: uc.ForcePasswordReset,
+			Locked:             uc.Locked,
 		}
 		if !omitKey {
 			user.Key = uc.Key
+			for _, key := range uc.Keys {
+				user.Keys = append(user.Keys, key.String())
+			}
+		}
+		if pa := uc.PasswordAging; pa != nil {
+			user.PasswordAging = &UsersStageOptionsPasswordAging{
+				MinDays:      pa.MinDays,
+				MaxDays:      pa.MaxDays,
+				WarnDays:     pa.WarnDays,
+				InactiveDays: pa.InactiveDays,
+			}
+		}
+		if uc.SubUID != nil {
+			user.SubUID = &UsersStageOptionsSubIDRange{Start: uc.SubUID.Start, Count: uc.SubUID.Count}
+		}
+		if uc.SubGID != nil {
+			user.SubGID = &UsersStageOptionsSubIDRange{Start: uc.SubGID.Start, Count: uc.SubGID.Count}
 		}
 		users[uc.Name] = user
 	}
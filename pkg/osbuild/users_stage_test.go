@@ -99,3 +99,46 @@ func TestGenUsersStageSameAsNewUsersStageOptions(t *testing.T) {
 	// the same
 	assert.Equal(t, usrStageOptions, opts)
 }
+
+func TestNewUsersStageOptionsAccountPolicies(t *testing.T) {
+	users := []users.User{
+		{
+			Name: "builder",
+			Key:  common.ToPtr("ssh-rsa AAAA legacy"),
+			Keys: []users.SSHKey{
+				{Key: "ssh-ed25519 AAAA one"},
+				{Key: "ssh-ed25519 AAAA two", Options: []string{"no-pty"}},
+			},
+			PasswordAging: &users.PasswordAging{
+				MinDays: common.ToPtr(1),
+				MaxDays: common.ToPtr(90),
+			},
+			Locked: common.ToPtr(true),
+			SubUID: &users.SubIDRange{Start: 100000, Count: 65536},
+			SubGID: &users.SubIDRange{Start: 200000, Count: 1000},
+		},
+	}
+
+	expected := UsersStageOptionsUser{
+		Key:  common.ToPtr("ssh-rsa AAAA legacy"),
+		Keys: []string{"ssh-ed25519 AAAA one", "no-pty ssh-ed25519 AAAA two"},
+		PasswordAging: &UsersStageOptionsPasswordAging{
+			MinDays: common.ToPtr(1),
+			MaxDays: common.ToPtr(90),
+		},
+		Locked: common.ToPtr(true),
+		SubUID: &UsersStageOptionsSubIDRange{Start: 100000, Count: 65536},
+		SubGID: &UsersStageOptionsSubIDRange{Start: 200000, Count: 1000},
+	}
+
+	opts, err := NewUsersStageOptions(users, false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, opts.Users["builder"])
+
+	// keys are omitted together, everything else is kept
+	expected.Key = nil
+	expected.Keys = nil
+	opts, err = NewUsersStageOptions(users, true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, opts.Users["builder"])
+}
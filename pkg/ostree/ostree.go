@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/osbuild/images/pkg/proxy"
 	"github.com/osbuild/images/pkg/rhsm"
 )
 
@@ -28,6 +29,10 @@ type SourceSpec struct {
 	URL  string
 	Ref  string
 	RHSM bool
+
+	// Proxy of the repository. The proxy of the environment is used if it
+	// is nil.
+	Proxy *proxy.Config
 }
 
 // CommitSpec specifies an ostree commit using any combination of Ref (branch), URL (source), and Checksum (commit ID).
@@ -45,6 +50,14 @@ type CommitSpec struct {
 
 	// Checksum of the commit.
 	Checksum string
+
+	// Proxy of the repository, if any.
+	Proxy *proxy.Config
+
+	// URLs the URL and ContentURL were rewritten from to fetch the commit
+	// from a mirror, if any.
+	OriginalURL        string
+	OriginalContentURL string
 }
 
 // ImageOptions specify an ostree ref, checksum, URL, ContentURL, and RHSM. The
@@ -72,6 +85,10 @@ type ImageOptions struct {
 	// Indicate if the 'org.osbuild.rhsm.consumer' secret should be added when pulling from the
 	// remote.
 	RHSM bool `json:"rhsm"`
+
+	// Proxy of the URL. The proxy of the environment is used if it is not
+	// specified.
+	Proxy *proxy.Config `json:"proxy,omitempty"`
 }
 
 // Validate the image options. This doesn't verify the existence of any remote
@@ -84,6 +101,7 @@ type ImageOptions struct {
 // - The ParentRef, if specified, must be a valid ref or a checksum.
 // - If the ParentRef is specified, the URL must also be specified.
 // - URLs must be valid.
+// - The proxy URL, if specified, must be valid.
 func (options ImageOptions) Validate() error {
 	if ref := options.ImageRef; ref != "" {
 		// image ref must not look like a checksum
@@ -119,6 +137,10 @@ func (options ImageOptions) Validate() error {
 		}
 	}
 
+	if err := options.Proxy.Validate(); err != nil {
+		return fmt.Errorf("ostree proxy is invalid: %w", err)
+	}
+
 	return nil
 }
 
@@ -142,6 +164,16 @@ func verifyChecksum(commit string) bool {
 // (location+"refs/heads/"+ref) and returns the commit ID for the named ref. If
 // there is an error, it will be of type ResolveRefError.
 func ResolveRef(location, ref string, consumerCerts bool, subs *rhsm.Subscriptions, ca *string) (string, error) {
+	return resolveRef(location, ref, consumerCerts, subs, ca, nil)
+}
+
+// ResolveRefWithProxy is ResolveRef() with the proxy configuration of the
+// repository.
+func ResolveRefWithProxy(location, ref string, consumerCerts bool, subs *rhsm.Subscriptions, ca *string, proxyConfig *proxy.Config) (string, error) {
+	return resolveRef(location, ref, consumerCerts, subs, ca, proxyConfig)
+}
+
+func resolveRef(location, ref string, consumerCerts bool, subs *rhsm.Subscriptions, ca *string, proxyConfig *proxy.Config) (string, error) {
 	u, err := url.Parse(location)
 	if err != nil {
 		return "", NewResolveRefError(fmt.Sprintf("error parsing ostree repository location: %v", err))
@@ -180,12 +212,22 @@ func ResolveRef(location, ref string, consumerCerts bool, subs *rhsm.Subscriptio
 		}
 		tlsConf.Certificates = []tls.Certificate{cert}
 
+		transport := &http.Transport{
+			TLSClientConfig: tlsConf,
+		}
+		if err := proxyConfig.Apply(transport); err != nil {
+			return "", NewResolveRefError("error configuring proxy when resolving ref: %s", err)
+		}
 		client = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsConf,
-			},
-			Timeout: 300 * time.Second,
+			Transport: transport,
+			Timeout:   300 * time.Second,
+		}
+	} else if proxyConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if err := proxyConfig.Apply(transport); err != nil {
+			return "", NewResolveRefError("error configuring proxy when resolving ref: %s", err)
 		}
+		client = &http.Client{Transport: transport}
 	} else {
 		client = &http.Client{}
 	}
@@ -227,8 +269,9 @@ func ResolveRef(location, ref string, consumerCerts bool, subs *rhsm.Subscriptio
 // If the ref is malformed, the function returns with a RefError.
 func Resolve(source SourceSpec) (CommitSpec, error) {
 	commit := CommitSpec{
-		Ref: source.Ref,
-		URL: source.URL,
+		Ref:   source.Ref,
+		URL:   source.URL,
+		Proxy: source.Proxy,
 	}
 
 	if source.RHSM {
@@ -249,7 +292,7 @@ func Resolve(source SourceSpec) (CommitSpec, error) {
 	// URL set: Resolve checksum
 	if source.URL != "" {
 		// If a URL is specified, we need to fetch the commit at the URL.
-		checksum, err := ResolveRef(source.URL, source.Ref, source.RHSM, nil, nil)
+		checksum, err := resolveRef(source.URL, source.Ref, source.RHSM, nil, nil, source.Proxy)
 		if err != nil {
 			return CommitSpec{}, err // ResolveRefError
 		}
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/osbuild/images/pkg/ostree/test_mtls_server"
+	"github.com/osbuild/images/pkg/proxy"
 	"github.com/osbuild/images/pkg/rhsm"
 )
 
@@ -97,6 +98,29 @@ func TestOstreeResolveRef(t *testing.T) {
 	}
 }
 
+func TestOstreeResolveRefWithProxy(t *testing.T) {
+	goodRef := "5330bb1b8820944567f519de66ad6354c729b6b490dea1c5a7ba320c9f147c58"
+	var proxiedURLs []string
+	proxySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURLs = append(proxiedURLs, r.URL.String())
+		fmt.Fprint(w, goodRef)
+	}))
+	defer proxySrv.Close()
+
+	proxyConfig := &proxy.Config{URL: proxySrv.URL, NoProxy: []string{"internal.example.com"}}
+	out, err := ResolveRefWithProxy("http://ostree.example.com/repo", "valid/ostree/ref", false, nil, nil, proxyConfig)
+	require.NoError(t, err)
+	assert.Equal(t, goodRef, out)
+	assert.Equal(t, []string{"http://ostree.example.com/repo/refs/heads/valid/ostree/ref"}, proxiedURLs)
+
+	commit, err := Resolve(SourceSpec{URL: "http://ostree.example.com/repo", Ref: "edge", Proxy: proxyConfig})
+	require.NoError(t, err)
+	assert.Equal(t, CommitSpec{Ref: "edge", URL: "http://ostree.example.com/repo", Checksum: goodRef, Proxy: proxyConfig}, commit)
+
+	_, err = ResolveRefWithProxy("http://ostree.example.com/repo", "edge", false, nil, nil, &proxy.Config{URL: "ftp://proxy.example.com"})
+	assert.EqualError(t, err, `error configuring proxy when resolving ref: proxy URL "ftp://proxy.example.com" has an unsupported scheme "ftp"`)
+}
+
 func TestVerifyRef(t *testing.T) {
 	cases := map[string]bool{
 		"a_perfectly_valid_ref": true,
@@ -205,6 +229,20 @@ func TestValidate(t *testing.T) {
 			},
 			valid: false,
 		},
+		"proxy-valid": {
+			options: ImageOptions{
+				URL:   "https://repo.example.com",
+				Proxy: &proxy.Config{URL: "http://proxy.example.com:3128"},
+			},
+			valid: true,
+		},
+		"bad-proxy": {
+			options: ImageOptions{
+				URL:   "https://repo.example.com",
+				Proxy: &proxy.Config{URL: "ftp://proxy.example.com"},
+			},
+			valid: false,
+		},
 	}
 
 	for name, testCase := range cases {
@@ -0,0 +1,141 @@
+// Package proxy defines the proxy configuration of content sources, i.e. RPM
+// repositories and ostree sources, and applies it to the HTTP clients that
+// resolve them.
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Config is the proxy configuration of a content source. A nil Config means
+// that the default of the tool fetching the source applies, usually the
+// proxy of the environment. A Config without a URL means that the source is
+// accessed directly.
+type Config struct {
+	// URL of the proxy, e.g. http://proxy.example.com:3128
+	URL string `json:"url,omitempty"`
+
+	// Hosts that are accessed directly, with the same syntax as the entries
+	// of the NO_PROXY environment variable: host names, which also match
+	// their subdomains, IP addresses, CIDR ranges and host:port pairs
+	NoProxy []string `json:"no_proxy,omitempty"`
+
+	// Path of a CA certificate to verify an HTTPS proxy with
+	CACert string `json:"ca_cert,omitempty"`
+}
+
+// Validate returns an error if the proxy URL is invalid.
+func (c *Config) Validate() error {
+	if c == nil || c.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("proxy URL %q is invalid", c.URL)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy URL %q has an unsupported scheme %q", c.URL, u.Scheme)
+	}
+	return nil
+}
+
+// For returns the URL of the proxy to use for the URL, or nil if the URL is
+// accessed directly.
+func (c *Config) For(u *url.URL) (*url.URL, error) {
+	if c == nil || c.URL == "" {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := httpproxy.Config{
+		HTTPProxy:  c.URL,
+		HTTPSProxy: c.URL,
+		NoProxy:    strings.Join(c.NoProxy, ","),
+	}
+	return cfg.ProxyFunc()(u)
+}
+
+// ForURL is For() for a URL string. An empty string is returned if the URL
+// is accessed directly.
+func (c *Config) ForURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	proxyURL, err := c.For(u)
+	if err != nil || proxyURL == nil {
+		return "", err
+	}
+	return proxyURL.String(), nil
+}
+
+// Apply configures the transport to use the proxy. The proxy of the
+// transport is kept if the Config is nil. The CA certificate of the proxy is
+// added to the root CAs of the transport, or to the system root CAs if the
+// transport has none.
+func (c *Config) Apply(transport *http.Transport) error {
+	if c == nil {
+		return nil
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return c.For(req.URL)
+	}
+
+	if c.CACert == "" {
+		return nil
+	}
+	caCert, err := os.ReadFile(c.CACert)
+	if err != nil {
+		return fmt.Errorf("cannot read proxy CA certificate: %w", err)
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	roots := transport.TLSClientConfig.RootCAs
+	if roots == nil {
+		roots, err = x509.SystemCertPool()
+		if err != nil {
+			return fmt.Errorf("cannot load system root CAs: %w", err)
+		}
+	} else {
+		roots = roots.Clone()
+	}
+	if !roots.AppendCertsFromPEM(caCert) {
+		return fmt.Errorf("no certificates found in proxy CA certificate %s", c.CACert)
+	}
+	transport.TLSClientConfig.RootCAs = roots
+	return nil
+}
+
+// Env returns the proxy environment variables of the configuration for
+// tools that read the proxy from the environment, e.g. curl and skopeo.
+func (c *Config) Env() []string {
+	if c == nil {
+		return nil
+	}
+	noProxy := strings.Join(c.NoProxy, ",")
+	return []string{
+		"http_proxy=" + c.URL,
+		"https_proxy=" + c.URL,
+		"no_proxy=" + noProxy,
+		"HTTP_PROXY=" + c.URL,
+		"HTTPS_PROXY=" + c.URL,
+		"NO_PROXY=" + noProxy,
+	}
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		config *Config
+		err    string
+	}{
+		{nil, ""},
+		{&Config{}, ""},
+		{&Config{URL: "http://proxy.example.com:3128"}, ""},
+		{&Config{URL: "socks5://proxy.example.com"}, ""},
+		{&Config{URL: "proxy.example.com:3128"}, `proxy URL "proxy.example.com:3128" is invalid`},
+		{&Config{URL: "http://"}, `proxy URL "http://" is invalid`},
+		{&Config{URL: "ftp://proxy.example.com"}, `proxy URL "ftp://proxy.example.com" has an unsupported scheme "ftp"`},
+	}
+	for _, tc := range testCases {
+		err := tc.config.Validate()
+		if tc.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tc.err)
+		}
+	}
+}
+
+func TestForURL(t *testing.T) {
+	config := &Config{
+		URL:     "http://proxy.example.com:3128",
+		NoProxy: []string{"internal.example.com", "10.0.0.0/8", "registry.lab:5000"},
+	}
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://cdn.example.com/baseos/", "http://proxy.example.com:3128"},
+		{"https://internal.example.com/repo/", ""},
+		{"https://mirror.internal.example.com/repo/", ""},
+		{"http://10.1.2.3/repo/", ""},
+		{"https://registry.lab:5000/v2/", ""},
+		{"https://registry.lab/v2/", "http://proxy.example.com:3128"},
+	}
+	for _, tc := range testCases {
+		proxyURL, err := config.ForURL(tc.url)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, proxyURL, tc.url)
+	}
+
+	var nilConfig *Config
+	proxyURL, err := nilConfig.ForURL("https://cdn.example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "", proxyURL)
+
+	proxyURL, err = (&Config{NoProxy: []string{"cdn.example.com"}}).ForURL("https://other.example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "", proxyURL)
+}
+
+func TestApply(t *testing.T) {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	require.NoError(t, (*Config)(nil).Apply(transport))
+	assert.NotNil(t, transport.Proxy)
+	assert.Nil(t, transport.TLSClientConfig)
+
+	config := &Config{URL: "http://proxy.example.com:3128", NoProxy: []string{"internal.example.com"}}
+	require.NoError(t, config.Apply(transport))
+	proxyURL, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "cdn.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxyURL.String())
+	proxyURL, err = transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "internal.example.com"}})
+	require.NoError(t, err)
+	assert.Nil(t, proxyURL)
+
+	// a direct configuration overrides the proxy of the environment
+	require.NoError(t, (&Config{}).Apply(transport))
+	proxyURL, err = transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "cdn.example.com"}})
+	require.NoError(t, err)
+	assert.Nil(t, proxyURL)
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caPath, []byte("not a certificate"), 0600))
+	err = (&Config{URL: "https://proxy.example.com", CACert: caPath}).Apply(transport)
+	assert.EqualError(t, err, "no certificates found in proxy CA certificate "+caPath)
+
+	err = (&Config{URL: "https://proxy.example.com", CACert: "/nonexistent/ca.pem"}).Apply(transport)
+	assert.EqualError(t, err, "cannot read proxy CA certificate: open /nonexistent/ca.pem: no such file or directory")
+}
+
+func TestEnv(t *testing.T) {
+	assert.Nil(t, (*Config)(nil).Env())
+	assert.Equal(t, []string{
+		"http_proxy=http://proxy.example.com:3128",
+		"https_proxy=http://proxy.example.com:3128",
+		"no_proxy=internal.example.com,10.0.0.0/8",
+		"HTTP_PROXY=http://proxy.example.com:3128",
+		"HTTPS_PROXY=http://proxy.example.com:3128",
+		"NO_PROXY=internal.example.com,10.0.0.0/8",
+	}, (&Config{URL: "http://proxy.example.com:3128", NoProxy: []string{"internal.example.com", "10.0.0.0/8"}}).Env())
+}
@@ -0,0 +1,115 @@
+package reporegistry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// OpenPGP packet tag of public keys, see RFC 4880, section 4.3
+const publicKeyPacketTag = 6
+
+// dearmorPublicKeys returns the binary content of the ASCII-armored public
+// key blocks in data, which is the keyring format read by gpgv(1). The
+// checksum lines of the blocks are ignored, corrupted keys are rejected by
+// gpgv.
+func dearmorPublicKeys(data []byte) ([]byte, error) {
+	var keys []byte
+	var body strings.Builder
+	inBlock, inHeaders := false, false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "-----BEGIN PGP PUBLIC KEY BLOCK-----":
+			inBlock, inHeaders = true, true
+			body.Reset()
+		case !inBlock:
+			continue
+		case line == "-----END PGP PUBLIC KEY BLOCK-----":
+			key, err := base64.StdEncoding.DecodeString(body.String())
+			if err != nil {
+				return nil, err
+			}
+			if !isPublicKeyPacket(key) {
+				return nil, fmt.Errorf("armored block is not a public key")
+			}
+			keys = append(keys, key...)
+			inBlock = false
+		case inHeaders && strings.Contains(line, ": "):
+			// armor header, e.g. "Version: GnuPG v2"
+		case line == "":
+			inHeaders = false
+		case strings.HasPrefix(line, "="):
+			// checksum
+		default:
+			inHeaders = false
+			body.WriteString(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inBlock {
+		return nil, fmt.Errorf("unterminated public key block")
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key block found")
+	}
+	return keys, nil
+}
+
+// isPublicKeyPacket returns true if the data starts with a public key packet
+// in either the old or the new packet format.
+func isPublicKeyPacket(data []byte) bool {
+	if len(data) == 0 || data[0]&0x80 == 0 {
+		return false
+	}
+	if data[0]&0x40 != 0 {
+		return data[0]&0x3f == publicKeyPacketTag
+	}
+	return (data[0]>>2)&0x0f == publicKeyPacketTag
+}
+
+// verifySignature verifies the detached armored signature of the data with
+// gpgv(1) and the binary keyring.
+func verifySignature(keyring, data, signature []byte) error {
+	dir, err := os.MkdirTemp("", "repo-signature-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	keyringPath := filepath.Join(dir, "keyring.gpg")
+	dataPath := filepath.Join(dir, "data")
+	signaturePath := filepath.Join(dir, "data.asc")
+	for path, content := range map[string][]byte{
+		keyringPath:   keyring,
+		dataPath:      data,
+		signaturePath: signature,
+	} {
+		if err := os.WriteFile(path, content, 0600); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command("gpgv", "--keyring", keyringPath, signaturePath, dataPath)
+	// keep gpgv away from the keyrings of the user and make the messages
+	// predictable
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+dir, "LC_ALL=C")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*exec.ExitError); !ok {
+		return fmt.Errorf("cannot run gpgv: %w", err)
+	}
+	// the last line of the output is the reason of the failure
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return fmt.Errorf("%s", lines[len(lines)-1])
+}
@@ -0,0 +1,251 @@
+package reporegistry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// client certificates that expire within this period are reported as a
+// warning
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// timeNow is replaced in tests
+var timeNow = time.Now
+
+// HealthCheckOptions configures the checks of CheckRepoHealth().
+type HealthCheckOptions struct {
+	// Metadata older than this is reported as a warning, the age is not
+	// checked if zero
+	MaxMetadataAge time.Duration
+}
+
+// RepoHealth is the result of the checks of a repository.
+type RepoHealth struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// The base URL that was checked: the first base URL of the repository or
+	// the mirror found through its metalink or mirrorlist
+	BaseURL string `json:"baseurl,omitempty"`
+
+	// Time of the newest metadata listed in the repomd.xml, nil if unknown
+	MetadataTimestamp *time.Time `json:"metadata_timestamp,omitempty"`
+	// Age of the metadata at the time of the check, zero if unknown
+	MetadataAge time.Duration `json:"-"`
+	// True if the signature of the repomd.xml was verified with the GPG keys
+	// of the repository
+	SignatureVerified bool `json:"signature_verified"`
+
+	// Problems that make the repository unusable
+	Errors []string `json:"errors,omitempty"`
+	// Problems that do not prevent the repository from being used
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Healthy returns true if no errors were found in the repository.
+func (h RepoHealth) Healthy() bool {
+	return len(h.Errors) == 0
+}
+
+func (h *RepoHealth) errorf(format string, a ...interface{}) {
+	h.Errors = append(h.Errors, fmt.Sprintf(format, a...))
+}
+
+func (h *RepoHealth) warnf(format string, a ...interface{}) {
+	h.Warnings = append(h.Warnings, fmt.Sprintf(format, a...))
+}
+
+// HealthReport is the result of the checks of the repositories of a
+// distribution and architecture.
+type HealthReport struct {
+	Distro string       `json:"distro"`
+	Arch   string       `json:"arch"`
+	Repos  []RepoHealth `json:"repos"`
+}
+
+// Healthy returns true if no errors were found in any of the repositories.
+func (r HealthReport) Healthy() bool {
+	for _, repo := range r.Repos {
+		if !repo.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckHealth checks all the repositories of the distribution and
+// architecture, including the ones with image type tags, with
+// CheckRepoHealth(). An error is only returned if the registry has no
+// repositories for the distribution and architecture; the problems of the
+// repositories themselves are part of the report.
+func (r *RepoRegistry) CheckHealth(distro, arch string, options HealthCheckOptions) (*HealthReport, error) {
+	repos, err := r.ReposByArchName(distro, arch, true)
+	if err != nil {
+		return nil, err
+	}
+	report := &HealthReport{
+		Distro: distro,
+		Arch:   arch,
+		Repos:  make([]RepoHealth, 0, len(repos)),
+	}
+	for _, repo := range repos {
+		report.Repos = append(report.Repos, CheckRepoHealth(repo, options))
+	}
+	return report, nil
+}
+
+// CheckRepoHealth checks that the repository can be used for a build:
+//   - the SSL client certificate and CA certificate can be loaded and the
+//     client certificate has not expired,
+//   - the repomd.xml can be fetched from the first base URL or, for
+//     repositories with only a metalink or mirrorlist, from the preferred
+//     mirror,
+//   - the GPG keys can be loaded, and are set if GPG checks are enabled,
+//   - the signature of the repomd.xml is valid if check_repo_gpg is set,
+//     which is verified with gpgv(1).
+//
+// The age of the metadata is reported as well.
+func CheckRepoHealth(repo rpmmd.RepoConfig, options HealthCheckOptions) RepoHealth {
+	health := RepoHealth{
+		ID:   repo.Id,
+		Name: repo.Name,
+	}
+
+	client, err := repodata.NewClient(repodata.FromRepoConfig(repo))
+	if err != nil {
+		health.errorf("invalid SSL configuration: %v", err)
+		return health
+	}
+	checkClientCert(repo, &health)
+
+	keyring := loadGPGKeys(repo, client, &health)
+
+	health.BaseURL, err = client.BaseURL()
+	if err != nil {
+		health.errorf("cannot find a mirror of the repository: %v", err)
+		return health
+	}
+
+	raw, err := client.Fetch("repodata/repomd.xml")
+	if err != nil {
+		health.errorf("cannot fetch repomd.xml: %v", err)
+		return health
+	}
+	repomd, err := repodata.ParseRepomd(raw)
+	if err != nil {
+		health.errorf("cannot parse repomd.xml: %v", err)
+		return health
+	}
+	checkMetadataAge(repomd, options, &health)
+
+	if repo.CheckRepoGPG != nil && *repo.CheckRepoGPG && keyring != nil {
+		checkRepomdSignature(client, repomd, keyring, &health)
+	}
+	return health
+}
+
+func checkClientCert(repo rpmmd.RepoConfig, health *RepoHealth) {
+	if repo.SSLClientCert == "" {
+		return
+	}
+	// the key pair was already loaded successfully by repodata.NewClient()
+	cert, _ := tls.LoadX509KeyPair(repo.SSLClientCert, repo.SSLClientKey)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		health.errorf("cannot parse SSL client certificate %s: %v", repo.SSLClientCert, err)
+		return
+	}
+	now := timeNow()
+	switch {
+	case now.After(leaf.NotAfter):
+		health.errorf("SSL client certificate %s expired on %s", repo.SSLClientCert, leaf.NotAfter.Format(time.RFC3339))
+	case now.Before(leaf.NotBefore):
+		health.errorf("SSL client certificate %s is not valid before %s", repo.SSLClientCert, leaf.NotBefore.Format(time.RFC3339))
+	case now.Add(certExpiryWarning).After(leaf.NotAfter):
+		health.warnf("SSL client certificate %s expires on %s", repo.SSLClientCert, leaf.NotAfter.Format(time.RFC3339))
+	}
+}
+
+// loadGPGKeys returns the keyring with the GPG keys of the repository, which
+// are either inline armored keys or URLs, in the binary format read by
+// gpgv(1), or nil if the repository has no keys or one of them cannot be
+// loaded.
+func loadGPGKeys(repo rpmmd.RepoConfig, client *repodata.Client, health *RepoHealth) []byte {
+	if len(repo.GPGKeys) == 0 {
+		if (repo.CheckGPG != nil && *repo.CheckGPG) || (repo.CheckRepoGPG != nil && *repo.CheckRepoGPG) {
+			health.errorf("GPG checks are enabled but the repository has no GPG keys")
+		}
+		return nil
+	}
+
+	var keyring []byte
+	for idx, key := range repo.GPGKeys {
+		name := fmt.Sprintf("GPG key %d", idx)
+		data := []byte(key)
+		if !strings.HasPrefix(strings.TrimSpace(key), "-----BEGIN PGP PUBLIC KEY BLOCK-----") {
+			name = fmt.Sprintf("GPG key %s", key)
+			var err error
+			data, err = client.FetchURL(key)
+			if err != nil {
+				health.errorf("cannot fetch %s: %v", name, err)
+				return nil
+			}
+		}
+		keys, err := dearmorPublicKeys(data)
+		if err != nil {
+			health.errorf("cannot parse %s: %v", name, err)
+			return nil
+		}
+		keyring = append(keyring, keys...)
+	}
+	return keyring
+}
+
+// checkMetadataAge sets the timestamp and age of the newest metadata listed
+// in the repomd.xml, falling back to the revision, which is usually a
+// timestamp as well.
+func checkMetadataAge(repomd *repodata.Repomd, options HealthCheckOptions, health *RepoHealth) {
+	var newest int64
+	for _, data := range repomd.Data {
+		// some generators write fractional timestamps
+		ts, err := strconv.ParseFloat(data.Timestamp, 64)
+		if err == nil && int64(ts) > newest {
+			newest = int64(ts)
+		}
+	}
+	if newest == 0 {
+		if rev, err := strconv.ParseInt(repomd.Revision, 10, 64); err == nil {
+			newest = rev
+		}
+	}
+	if newest == 0 {
+		health.warnf("repomd.xml has no timestamps, the age of the metadata is unknown")
+		return
+	}
+
+	ts := time.Unix(newest, 0).UTC()
+	health.MetadataTimestamp = &ts
+	health.MetadataAge = timeNow().Sub(ts)
+	if options.MaxMetadataAge > 0 && health.MetadataAge > options.MaxMetadataAge {
+		health.warnf("metadata is %s old, more than %s", health.MetadataAge.Round(time.Second), options.MaxMetadataAge)
+	}
+}
+
+func checkRepomdSignature(client *repodata.Client, repomd *repodata.Repomd, keyring []byte, health *RepoHealth) {
+	signature, err := client.Fetch("repodata/repomd.xml.asc")
+	if err != nil {
+		health.errorf("cannot fetch repomd.xml.asc: %v", err)
+		return
+	}
+	if err := verifySignature(keyring, repomd.Raw, signature); err != nil {
+		health.errorf("invalid signature of repomd.xml: %v", err)
+		return
+	}
+	health.SignatureVerified = true
+}
@@ -0,0 +1,427 @@
+package reporegistry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// readHealthFixture returns the content of a file of test/health
+func readHealthFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("test", "health", name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+// writeClientCert writes a self-signed client certificate and its key that
+// expire at the given time and returns their paths.
+func writeClientCert(t *testing.T, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certPath, keyPath
+}
+
+func TestCheckRepoHealth(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	_, err := exec.LookPath("gpgv")
+	haveGPGV := err == nil
+
+	// the metadata files are dated 5 and 2 hours before now
+	publicKey := readHealthFixture(t, "key.asc")
+	repomd := readHealthFixture(t, "repomd.xml")
+	var srvURL string
+	files := map[string]string{
+		"/key.asc":                         publicKey,
+		"/good/repodata/repomd.xml":        repomd,
+		"/good/repodata/repomd.xml.asc":    readHealthFixture(t, "repomd.xml.asc"),
+		"/badsig/repodata/repomd.xml":      repomd,
+		"/badsig/repodata/repomd.xml.asc":  readHealthFixture(t, "repomd.xml.other.asc"),
+		"/unsigned/repodata/repomd.xml":    repomd,
+		"/revision/repodata/repomd.xml":    fmt.Sprintf(`<repomd><revision>%d</revision></repomd>`, now.Add(-time.Hour).Unix()),
+		"/notimestamp/repodata/repomd.xml": `<repomd><data type="primary"/></repomd>`,
+		"/notxml/repodata/repomd.xml":      `not xml`,
+		"/badkey/repodata/repomd.xml":      repomd,
+		"/badkey/repodata/repomd.xml.asc":  readHealthFixture(t, "repomd.xml.asc"),
+		"/metalink": `<?xml version="1.0" encoding="utf-8"?>
+<metalink version="3.0" xmlns="http://www.metalinker.org/">
+  <files>
+    <file name="repomd.xml">
+      <resources maxconnections="1">
+        <url protocol="rsync" type="rsync" preference="100">rsync://mirror.example.com/good/repodata/repomd.xml</url>
+        <url protocol="http" type="http" preference="90">{{srv}}/good/repodata/repomd.xml</url>
+        <url protocol="http" type="http" preference="80">{{srv}}/missing/repodata/repomd.xml</url>
+      </resources>
+    </file>
+  </files>
+</metalink>`,
+		"/mirrorlist": "# mirrors\n\n{{srv}}/revision\n{{srv}}/missing\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(content, "{{srv}}", srvURL))) //nolint:errcheck
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	expiredCert, expiredKey := writeClientCert(t, now.Add(-24*time.Hour))
+	expiringCert, expiringKey := writeClientCert(t, now.Add(7*24*time.Hour))
+
+	testCases := []struct {
+		name     string
+		repo     rpmmd.RepoConfig
+		options  HealthCheckOptions
+		expected RepoHealth
+		// the signature is verified with gpgv
+		needsGPGV bool
+	}{
+		{
+			name: "signed",
+			repo: rpmmd.RepoConfig{
+				Name:         "good",
+				BaseURLs:     []string{srv.URL + "/good/"},
+				GPGKeys:      []string{publicKey},
+				CheckRepoGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "good",
+				BaseURL:           srv.URL + "/good/",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				SignatureVerified: true,
+			},
+			needsGPGV: true,
+		},
+		{
+			name: "key-url-and-old-metadata",
+			repo: rpmmd.RepoConfig{
+				Id:           "good",
+				Name:         "Good",
+				BaseURLs:     []string{srv.URL + "/good"},
+				GPGKeys:      []string{srv.URL + "/key.asc"},
+				CheckRepoGPG: common.ToPtr(true),
+			},
+			options: HealthCheckOptions{MaxMetadataAge: time.Hour},
+			expected: RepoHealth{
+				ID:                "good",
+				Name:              "Good",
+				BaseURL:           srv.URL + "/good",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				SignatureVerified: true,
+				Warnings:          []string{"metadata is 2h0m0s old, more than 1h0m0s"},
+			},
+			needsGPGV: true,
+		},
+		{
+			name: "bad-signature",
+			repo: rpmmd.RepoConfig{
+				Name:         "badsig",
+				BaseURLs:     []string{srv.URL + "/badsig"},
+				GPGKeys:      []string{publicKey},
+				CheckRepoGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "badsig",
+				BaseURL:           srv.URL + "/badsig",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				Errors:            []string{"invalid signature of repomd.xml: gpgv: Can't check signature: No public key"},
+			},
+			needsGPGV: true,
+		},
+		{
+			name: "missing-signature",
+			repo: rpmmd.RepoConfig{
+				Name:         "unsigned",
+				BaseURLs:     []string{srv.URL + "/unsigned"},
+				GPGKeys:      []string{publicKey},
+				CheckRepoGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "unsigned",
+				BaseURL:           srv.URL + "/unsigned",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				Errors:            []string{fmt.Sprintf(`cannot fetch repomd.xml.asc: unexpected status "404 Not Found" from %s/unsigned/repodata/repomd.xml.asc`, srv.URL)},
+			},
+		},
+		{
+			name: "signature-not-checked",
+			repo: rpmmd.RepoConfig{
+				Name:     "unsigned",
+				BaseURLs: []string{srv.URL + "/unsigned"},
+				GPGKeys:  []string{publicKey},
+				CheckGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "unsigned",
+				BaseURL:           srv.URL + "/unsigned",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+			},
+		},
+		{
+			name: "revision",
+			repo: rpmmd.RepoConfig{
+				Name:     "revision",
+				BaseURLs: []string{srv.URL + "/revision"},
+			},
+			expected: RepoHealth{
+				Name:              "revision",
+				BaseURL:           srv.URL + "/revision",
+				MetadataTimestamp: common.ToPtr(now.Add(-time.Hour)),
+				MetadataAge:       time.Hour,
+			},
+		},
+		{
+			name: "no-timestamp",
+			repo: rpmmd.RepoConfig{
+				Name:     "notimestamp",
+				BaseURLs: []string{srv.URL + "/notimestamp"},
+			},
+			expected: RepoHealth{
+				Name:     "notimestamp",
+				BaseURL:  srv.URL + "/notimestamp",
+				Warnings: []string{"repomd.xml has no timestamps, the age of the metadata is unknown"},
+			},
+		},
+		{
+			name: "not-xml",
+			repo: rpmmd.RepoConfig{
+				Name:     "notxml",
+				BaseURLs: []string{srv.URL + "/notxml"},
+			},
+			expected: RepoHealth{
+				Name:    "notxml",
+				BaseURL: srv.URL + "/notxml",
+				Errors:  []string{"cannot parse repomd.xml: EOF"},
+			},
+		},
+		{
+			name: "missing-repomd",
+			repo: rpmmd.RepoConfig{
+				Name:     "missing",
+				BaseURLs: []string{srv.URL + "/missing"},
+			},
+			expected: RepoHealth{
+				Name:    "missing",
+				BaseURL: srv.URL + "/missing",
+				Errors:  []string{fmt.Sprintf(`cannot fetch repomd.xml: unexpected status "404 Not Found" from %s/missing/repodata/repomd.xml`, srv.URL)},
+			},
+		},
+		{
+			name: "missing-key",
+			repo: rpmmd.RepoConfig{
+				Name:         "badkey",
+				BaseURLs:     []string{srv.URL + "/badkey"},
+				GPGKeys:      []string{srv.URL + "/missing.asc"},
+				CheckRepoGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "badkey",
+				BaseURL:           srv.URL + "/badkey",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				Errors:            []string{fmt.Sprintf(`cannot fetch GPG key %[1]s/missing.asc: unexpected status "404 Not Found" from %[1]s/missing.asc`, srv.URL)},
+			},
+		},
+		{
+			name: "invalid-key",
+			repo: rpmmd.RepoConfig{
+				Name:     "badkey",
+				BaseURLs: []string{srv.URL + "/badkey"},
+				GPGKeys:  []string{"-----BEGIN PGP PUBLIC KEY BLOCK-----\n\ngarbage\n-----END PGP PUBLIC KEY BLOCK-----\n"},
+			},
+			expected: RepoHealth{
+				Name:              "badkey",
+				BaseURL:           srv.URL + "/badkey",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				Errors:            []string{"cannot parse GPG key 0: illegal base64 data at input byte 4"},
+			},
+		},
+		{
+			name: "gpg-check-without-keys",
+			repo: rpmmd.RepoConfig{
+				Name:     "nokeys",
+				BaseURLs: []string{srv.URL + "/good"},
+				CheckGPG: common.ToPtr(true),
+			},
+			expected: RepoHealth{
+				Name:              "nokeys",
+				BaseURL:           srv.URL + "/good",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+				Errors:            []string{"GPG checks are enabled but the repository has no GPG keys"},
+			},
+		},
+		{
+			name: "metalink",
+			repo: rpmmd.RepoConfig{
+				Name:     "metalink",
+				Metalink: srv.URL + "/metalink",
+			},
+			expected: RepoHealth{
+				Name:              "metalink",
+				BaseURL:           srv.URL + "/good/",
+				MetadataTimestamp: common.ToPtr(now.Add(-2 * time.Hour)),
+				MetadataAge:       2 * time.Hour,
+			},
+		},
+		{
+			name: "mirrorlist",
+			repo: rpmmd.RepoConfig{
+				Name:       "mirrorlist",
+				MirrorList: srv.URL + "/mirrorlist",
+			},
+			expected: RepoHealth{
+				Name:              "mirrorlist",
+				BaseURL:           srv.URL + "/revision",
+				MetadataTimestamp: common.ToPtr(now.Add(-time.Hour)),
+				MetadataAge:       time.Hour,
+			},
+		},
+		{
+			name: "missing-metalink",
+			repo: rpmmd.RepoConfig{
+				Name:     "metalink",
+				Metalink: srv.URL + "/missing-metalink",
+			},
+			expected: RepoHealth{
+				Name:   "metalink",
+				Errors: []string{fmt.Sprintf(`cannot find a mirror of the repository: cannot fetch metalink: unexpected status "404 Not Found" from %s/missing-metalink`, srv.URL)},
+			},
+		},
+		{
+			name: "expired-client-cert",
+			repo: rpmmd.RepoConfig{
+				Name:          "expired",
+				BaseURLs:      []string{srv.URL + "/notimestamp"},
+				SSLClientCert: expiredCert,
+				SSLClientKey:  expiredKey,
+			},
+			expected: RepoHealth{
+				Name:    "expired",
+				BaseURL: srv.URL + "/notimestamp",
+				Errors:  []string{fmt.Sprintf("SSL client certificate %s expired on 2024-04-30T12:00:00Z", expiredCert)},
+				Warnings: []string{
+					"repomd.xml has no timestamps, the age of the metadata is unknown",
+				},
+			},
+		},
+		{
+			name: "expiring-client-cert",
+			repo: rpmmd.RepoConfig{
+				Name:          "expiring",
+				BaseURLs:      []string{srv.URL + "/revision"},
+				SSLClientCert: expiringCert,
+				SSLClientKey:  expiringKey,
+			},
+			expected: RepoHealth{
+				Name:              "expiring",
+				BaseURL:           srv.URL + "/revision",
+				MetadataTimestamp: common.ToPtr(now.Add(-time.Hour)),
+				MetadataAge:       time.Hour,
+				Warnings:          []string{fmt.Sprintf("SSL client certificate %s expires on 2024-05-08T12:00:00Z", expiringCert)},
+			},
+		},
+		{
+			name: "client-cert-without-key",
+			repo: rpmmd.RepoConfig{
+				Name:          "nokey",
+				BaseURLs:      []string{srv.URL + "/good"},
+				SSLClientCert: expiredCert,
+			},
+			expected: RepoHealth{
+				Name:   "nokey",
+				Errors: []string{"invalid SSL configuration: both an SSL client certificate and key are required"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.needsGPGV && !haveGPGV {
+				t.Skip("gpgv is not installed")
+			}
+			health := CheckRepoHealth(tc.repo, tc.options)
+			assert.Equal(t, tc.expected, health)
+			assert.Equal(t, len(tc.expected.Errors) == 0, health.Healthy())
+		})
+	}
+}
+
+func TestRepoRegistryCheckHealth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/baseos/repodata/repomd.xml" {
+			w.Write([]byte("<repomd><revision>1714564800</revision></repomd>")) //nolint:errcheck
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	registry := NewFromDistrosRepoConfigs(rpmmd.DistrosRepoConfigs{
+		"rhel-9.4": {
+			"x86_64": {
+				{Name: "baseos", BaseURLs: []string{srv.URL + "/baseos"}},
+				{Name: "extras", BaseURLs: []string{srv.URL + "/extras"}, ImageTypeTags: []string{"ec2"}},
+			},
+		},
+	})
+
+	report, err := registry.CheckHealth("rhel-9.4", "x86_64", HealthCheckOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "rhel-9.4", report.Distro)
+	assert.Equal(t, "x86_64", report.Arch)
+	require.Len(t, report.Repos, 2)
+	assert.True(t, report.Repos[0].Healthy())
+	assert.Equal(t, "extras", report.Repos[1].Name)
+	assert.False(t, report.Repos[1].Healthy())
+	assert.False(t, report.Healthy())
+
+	_, err = registry.CheckHealth("rhel-9.4", "aarch64", HealthCheckOptions{})
+	assert.EqualError(t, err, "Failed to get repositories for distribution 'rhel-9.4' and architecture 'aarch64': there are no repositories for distribution 'rhel-9.4' and architecture 'aarch64'")
+}
@@ -0,0 +1,81 @@
+package reporegistry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// LoadRepoFiles loads the enabled repositories from yum/dnf .repo files.
+// Each path is either a .repo file or a directory, such as /etc/yum.repos.d,
+// whose .repo files are loaded in alphabetical order. The variables of the
+// dnf vars directories in varsDirs, e.g. /etc/dnf/vars, are loaded in order,
+// later directories overriding earlier ones. The $releasever, $basearch and
+// $arch variables are always set from the given values. A variable that is
+// used in a .repo file but has no value is an error. The repositories are
+// returned for the architecture, in the same form as LoadRepositories()
+// returns them.
+func LoadRepoFiles(paths, varsDirs []string, arch, releasever string) (map[string][]rpmmd.RepoConfig, error) {
+	vars := make(map[string]string)
+	for _, dir := range varsDirs {
+		dirVars, err := rpmmd.LoadRepoVars(dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load repository variables from %s: %w", dir, err)
+		}
+		for name, value := range dirVars {
+			vars[name] = value
+		}
+	}
+	vars["releasever"] = releasever
+	vars["basearch"] = arch
+	vars["arch"] = arch
+
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var dirFiles []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".repo") {
+				dirFiles = append(dirFiles, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+
+	var repos []rpmmd.RepoConfig
+	ids := make(map[string]string)
+	for _, file := range files {
+		fileRepos, err := rpmmd.LoadRepositoriesFromRepoFile(file, vars)
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range fileRepos {
+			if other, ok := ids[repo.Id]; ok {
+				return nil, fmt.Errorf("repository %q is defined in both %s and %s", repo.Id, other, file)
+			}
+			ids[repo.Id] = file
+			if repo.Enabled != nil && !*repo.Enabled {
+				continue
+			}
+			repos = append(repos, repo)
+		}
+	}
+
+	return map[string][]rpmmd.RepoConfig{arch: repos}, nil
+}
@@ -0,0 +1,76 @@
+package reporegistry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestLoadRepoFiles(t *testing.T) {
+	repoConfigs, err := LoadRepoFiles([]string{"./test/repofiles"}, []string{"./test/repovars"}, "x86_64", "9")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]rpmmd.RepoConfig{
+		"x86_64": {
+			{
+				Id:             "baseos",
+				Name:           "CentOS Stream 9 - BaseOS",
+				BaseURLs:       []string{"https://mirror.example.com/centos-stream/9-stream/BaseOS/x86_64/os/"},
+				GPGKeys:        []string{"file:///etc/pki/rpm-gpg/RPM-GPG-KEY-centosofficial"},
+				CheckGPG:       common.ToPtr(true),
+				CheckRepoGPG:   common.ToPtr(false),
+				MetadataExpire: "6h",
+				Enabled:        common.ToPtr(true),
+			},
+			{
+				Id:   "internal-apps",
+				Name: "Internal applications for EL9",
+				BaseURLs: []string{
+					"https://apps.example.com/el9/x86_64/",
+					"https://apps-backup.example.com/el9/x86_64/",
+				},
+				GPGKeys: []string{
+					"https://apps.example.com/RPM-GPG-KEY-apps",
+					"https://apps.example.com/RPM-GPG-KEY-apps-2024",
+				},
+				CheckGPG:       common.ToPtr(true),
+				IgnoreSSL:      common.ToPtr(true),
+				SSLCACert:      "/etc/pki/tls/certs/internal-ca.pem",
+				SSLClientCert:  "/etc/pki/entitlement/client.pem",
+				SSLClientKey:   "/etc/pki/entitlement/client-key.pem",
+				Priority:       common.ToPtr(10),
+				ModuleHotfixes: common.ToPtr(true),
+				Enabled:        common.ToPtr(true),
+			},
+		},
+	}, repoConfigs)
+}
+
+func TestLoadRepoFilesDuplicate(t *testing.T) {
+	dup := filepath.Join(t.TempDir(), "dup.repo")
+	require.NoError(t, os.WriteFile(dup, []byte("[baseos]\nbaseurl=https://example.com/baseos\n"), 0644))
+
+	_, err := LoadRepoFiles([]string{"./test/repofiles/centos.repo", dup}, []string{"./test/repovars"}, "x86_64", "9")
+	assert.EqualError(t, err, `repository "baseos" is defined in both ./test/repofiles/centos.repo and `+dup)
+
+	_, err = LoadRepoFiles([]string{"./test/repofiles/missing.repo"}, nil, "x86_64", "9")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadRepoFilesUnknownVars(t *testing.T) {
+	// without the vars directory, $contentdir and $stream have no value
+	_, err := LoadRepoFiles([]string{"./test/repofiles/centos.repo"}, nil, "x86_64", "9")
+	assert.EqualError(t, err, `cannot load repositories from ./test/repofiles/centos.repo: repository "baseos": invalid value of baseurl: unknown variables $contentdir, $stream`)
+
+	// the vars directory cannot override the variables of the distribution
+	varsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(varsDir, "releasever"), []byte("8\n"), 0644))
+	repoConfigs, err := LoadRepoFiles([]string{"./test/repofiles/centos.repo"}, []string{"./test/repovars", varsDir}, "x86_64", "9")
+	require.NoError(t, err)
+	assert.Equal(t, "CentOS Stream 9 - BaseOS", repoConfigs["x86_64"][0].Name)
+}
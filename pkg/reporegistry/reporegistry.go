@@ -12,6 +12,9 @@ import (
 // if the loaded repository definition contains any ImageTypeTags.
 type RepoRegistry struct {
 	repos rpmmd.DistrosRepoConfigs
+
+	// ID of the snapshot the repositories are pinned to, see AtSnapshot()
+	snapshot string
 }
 
 // New returns a new RepoRegistry instance with the data
@@ -22,7 +25,7 @@ func New(repoConfigPaths []string) (*RepoRegistry, error) {
 		return nil, err
 	}
 
-	return &RepoRegistry{repositories}, nil
+	return &RepoRegistry{repos: repositories}, nil
 }
 
 // NewTestedDefault returns a new RepoRegistry instance with the data
@@ -33,7 +36,7 @@ func NewTestedDefault() (*RepoRegistry, error) {
 }
 
 func NewFromDistrosRepoConfigs(distrosRepoConfigs rpmmd.DistrosRepoConfigs) *RepoRegistry {
-	return &RepoRegistry{distrosRepoConfigs}
+	return &RepoRegistry{repos: distrosRepoConfigs}
 }
 
 // ReposByImageTypeName returns a slice of rpmmd.RepoConfig instances, which should be used for building the specific
@@ -44,7 +47,7 @@ func NewFromDistrosRepoConfigs(distrosRepoConfigs rpmmd.DistrosRepoConfigs) *Rep
 func (r *RepoRegistry) ReposByImageTypeName(distro, arch, imageType string) ([]rpmmd.RepoConfig, error) {
 	repositories := []rpmmd.RepoConfig{}
 
-	archRepos, err := r.ReposByArchName(distro, arch, true)
+	archRepos, err := r.reposByArchName(distro, arch, true)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +68,7 @@ func (r *RepoRegistry) ReposByImageTypeName(distro, arch, imageType string) ([]r
 		}
 	}
 
-	return repositories, nil
+	return r.pinRepos(repositories)
 }
 
 // reposByArchName returns a slice of rpmmd.RepoConfig instances, which should be used for building image types for the
@@ -75,9 +78,17 @@ func (r *RepoRegistry) ReposByImageTypeName(distro, arch, imageType string) ([]r
 //
 // The method does not verify if the given architecture name is actually part of the specific distribution definition.
 func (r *RepoRegistry) ReposByArchName(distro, arch string, includeTagged bool) ([]rpmmd.RepoConfig, error) {
+	repositories, err := r.reposByArchName(distro, arch, includeTagged)
+	if err != nil {
+		return nil, err
+	}
+	return r.pinRepos(repositories)
+}
+
+func (r *RepoRegistry) reposByArchName(distro, arch string, includeTagged bool) ([]rpmmd.RepoConfig, error) {
 	repositories := []rpmmd.RepoConfig{}
 
-	archRepos, err := r.DistroHasRepos(distro, arch)
+	archRepos, err := r.distroRepos(distro, arch)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get repositories for distribution '%s' and architecture '%s': %v", distro, arch, err)
 	}
@@ -96,6 +107,14 @@ func (r *RepoRegistry) ReposByArchName(distro, arch string, includeTagged bool)
 
 // DistroHasRepos returns the repositories for the distro+arch, and a found flag
 func (r *RepoRegistry) DistroHasRepos(distro, arch string) ([]rpmmd.RepoConfig, error) {
+	repos, err := r.distroRepos(distro, arch)
+	if err != nil {
+		return nil, err
+	}
+	return r.pinRepos(repos)
+}
+
+func (r *RepoRegistry) distroRepos(distro, arch string) ([]rpmmd.RepoConfig, error) {
 	// compatibility layer to support old repository definition filenames
 	// without a dot to separate major and minor release versions
 	stdDistroName, err := distroidparser.DefaultParser.Standardize(distro)
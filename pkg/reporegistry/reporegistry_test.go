@@ -13,7 +13,7 @@ import (
 func getTestingRepoRegistry() *RepoRegistry {
 	testDistro := test_distro.DistroFactory(test_distro.TestDistro1Name)
 	return &RepoRegistry{
-		map[string]map[string][]rpmmd.RepoConfig{
+		repos: map[string]map[string][]rpmmd.RepoConfig{
 			testDistro.Name(): {
 				test_distro.TestArchName: {
 					{
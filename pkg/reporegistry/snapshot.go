@@ -0,0 +1,72 @@
+package reporegistry
+
+import (
+	"fmt"
+
+	"github.com/osbuild/images/internal/repodata"
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+// AtSnapshot returns a registry with the same repositories pinned to the
+// snapshot with the given ID, e.g. the date at which the content was frozen.
+// The repositories returned by the new registry use the base URL of their
+// snapshot URL template (see rpmmd.RepoConfig.AtSnapshot()), and an error is
+// returned for repositories without a snapshot URL template. Whether the
+// snapshots exist is not checked by the getters of the registry, use
+// ValidateSnapshot() for that.
+func (r *RepoRegistry) AtSnapshot(id string) (*RepoRegistry, error) {
+	if err := rpmmd.CheckSnapshotID(id); err != nil {
+		return nil, err
+	}
+	return &RepoRegistry{
+		repos:    r.repos,
+		snapshot: id,
+	}, nil
+}
+
+// Snapshot returns the ID of the snapshot the repositories of the registry
+// are pinned to, or an empty string if they are not pinned.
+func (r *RepoRegistry) Snapshot() string {
+	return r.snapshot
+}
+
+// ValidateSnapshot checks that the snapshots of all the repositories of the
+// distribution and architecture, including the ones with image type tags,
+// exist by fetching their repomd.xml. Nothing is checked if the registry is
+// not pinned to a snapshot.
+func (r *RepoRegistry) ValidateSnapshot(distro, arch string) error {
+	if r.snapshot == "" {
+		return nil
+	}
+	repos, err := r.ReposByArchName(distro, arch, true)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		client, err := repodata.NewClient(repodata.FromRepoConfig(repo))
+		if err == nil {
+			_, err = client.Fetch("repodata/repomd.xml")
+		}
+		if err != nil {
+			return fmt.Errorf("snapshot %q of repository %q does not exist: %w", r.snapshot, repo.Name, err)
+		}
+	}
+	return nil
+}
+
+// pinRepos returns the repositories pinned to the snapshot of the registry,
+// or the repositories as they are if the registry is not pinned.
+func (r *RepoRegistry) pinRepos(repos []rpmmd.RepoConfig) ([]rpmmd.RepoConfig, error) {
+	if r.snapshot == "" {
+		return repos, nil
+	}
+	pinned := make([]rpmmd.RepoConfig, 0, len(repos))
+	for _, repo := range repos {
+		pinnedRepo, err := repo.AtSnapshot(r.snapshot)
+		if err != nil {
+			return nil, err
+		}
+		pinned = append(pinned, pinnedRepo)
+	}
+	return pinned, nil
+}
@@ -0,0 +1,77 @@
+package reporegistry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/pkg/rpmmd"
+)
+
+func TestRepoRegistryAtSnapshot(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/baseos-20240501/repodata/repomd.xml", "/appstream-20240501/repodata/repomd.xml":
+			w.Write([]byte("<repomd/>")) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	registry := NewFromDistrosRepoConfigs(rpmmd.DistrosRepoConfigs{
+		"rhel-9.4": {
+			"x86_64": {
+				{Name: "baseos", BaseURLs: []string{"https://cdn.example.com/baseos"}, SnapshotURL: srv.URL + "/baseos-$snapshot"},
+				{Name: "appstream", BaseURLs: []string{"https://cdn.example.com/appstream"}, SnapshotURL: srv.URL + "/appstream-${snapshot}/"},
+				{Name: "extras", BaseURLs: []string{"https://cdn.example.com/extras"}, SnapshotURL: srv.URL + "/extras-$snapshot", ImageTypeTags: []string{"ec2"}},
+			},
+			"aarch64": {
+				{Name: "baseos", BaseURLs: []string{"https://cdn.example.com/baseos"}},
+			},
+		},
+	})
+
+	pinned, err := registry.AtSnapshot("20240501")
+	require.NoError(t, err)
+	assert.Equal(t, "20240501", pinned.Snapshot())
+	assert.Equal(t, "", registry.Snapshot())
+
+	repos, err := pinned.ReposByImageTypeName("rhel-9.4", "x86_64", "qcow2")
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	assert.Equal(t, []string{srv.URL + "/baseos-20240501"}, repos[0].BaseURLs)
+	assert.Equal(t, []string{srv.URL + "/appstream-20240501/"}, repos[1].BaseURLs)
+
+	// the getters do not check that the snapshots exist
+	repos, err = pinned.ReposByImageTypeName("rhel-9.4", "x86_64", "ec2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{srv.URL + "/extras-20240501"}, repos[2].BaseURLs)
+	_, err = pinned.ReposByArchName("rhel-9.4", "x86_64", false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	// the unpinned registry is not affected
+	repos, err = registry.ReposByImageTypeName("rhel-9.4", "x86_64", "qcow2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://cdn.example.com/baseos"}, repos[0].BaseURLs)
+
+	// ValidateSnapshot() checks all the repositories, including the tagged
+	// ones
+	err = pinned.ValidateSnapshot("rhel-9.4", "x86_64")
+	assert.ErrorContains(t, err, `snapshot "20240501" of repository "extras" does not exist: unexpected status "404 Not Found"`)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.NoError(t, registry.ValidateSnapshot("rhel-9.4", "x86_64"))
+
+	_, err = pinned.ReposByArchName("rhel-9.4", "aarch64", true)
+	assert.ErrorContains(t, err, `repository "baseos" has no snapshot URL template`)
+
+	_, err = registry.AtSnapshot("2024 05 01")
+	assert.EqualError(t, err, `snapshot ID "2024 05 01" is invalid`)
+}
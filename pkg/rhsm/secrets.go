@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -188,3 +189,28 @@ func (s *Subscriptions) GetSecretsForBaseurl(baseurls []string, arch, releasever
 	}
 	return nil, fmt.Errorf("no such baseurl in the available subscriptions")
 }
+
+// GetSecretsForURL queries the Subscriptions structure for the RHSMSecrets of
+// a URL in a repository, e.g. of a package, the way osbuild finds the secrets
+// of its org.osbuild.rhsm sources: the URL has to start with the baseurl of a
+// subscription, where $releasever and $basearch match any value.
+func (s *Subscriptions) GetSecretsForURL(url string) (*RHSMSecrets, error) {
+	for _, subs := range s.available {
+		pattern := regexp.QuoteMeta(subs.baseurl)
+		for _, variable := range []string{"$releasever", "$basearch"} {
+			pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta(variable), "[^/]+")
+		}
+		if matched, _ := regexp.MatchString("^"+pattern, url); matched {
+			return &RHSMSecrets{
+				SSLCACert:     subs.sslCACert,
+				SSLClientKey:  subs.sslClientKey,
+				SSLClientCert: subs.sslClientCert,
+			}, nil
+		}
+	}
+	// If there is no matching URL, fall back to the global secrets
+	if s.secrets != nil {
+		return s.secrets, nil
+	}
+	return nil, fmt.Errorf("no subscription for %s in the available subscriptions", url)
+}
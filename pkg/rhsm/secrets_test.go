@@ -47,3 +47,25 @@ func TestParseRepoFile(t *testing.T) {
 	assert.Equal(t, secrets.SSLClientCert, "/etc/pki/entitlement/456.pem", "Unexpected path to the client cert")
 	assert.Equal(t, secrets.SSLClientKey, "/etc/pki/entitlement/123-key.pem", "Unexpected path to the client key")
 }
+
+func TestGetSecretsForURL(t *testing.T) {
+	repoFileContent, err := parseRepoFile([]byte(VALID_REPO))
+	require.NoError(t, err)
+	subscriptions := Subscriptions{
+		available: repoFileContent,
+	}
+
+	secrets, err := subscriptions.GetSecretsForURL("https://cdn.redhat.com/content/dist/rhel/atomic/7/7Server/x86_64/os/Packages/t/tmux-1.8-4.el7.x86_64.rpm")
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/pki/entitlement/789-key.pem", secrets.SSLClientKey)
+	assert.Equal(t, "/etc/pki/entitlement/101112.pem", secrets.SSLClientCert)
+
+	_, err = subscriptions.GetSecretsForURL("https://cdn.redhat.com/content/dist/rhel9/9/x86_64/baseos/os/Packages/t/tmux.rpm")
+	assert.EqualError(t, err, "no subscription for https://cdn.redhat.com/content/dist/rhel9/9/x86_64/baseos/os/Packages/t/tmux.rpm in the available subscriptions")
+
+	// the global secrets are used for other URLs
+	subscriptions.secrets = &RHSMSecrets{SSLCACert: "/etc/rhsm/ca/redhat-uep.pem", SSLClientKey: "/etc/pki/entitlement/1-key.pem", SSLClientCert: "/etc/pki/entitlement/1.pem"}
+	secrets, err = subscriptions.GetSecretsForURL("https://cdn.redhat.com/content/dist/rhel9/9/x86_64/baseos/os/Packages/t/tmux.rpm")
+	require.NoError(t, err)
+	assert.Equal(t, subscriptions.secrets, secrets)
+}
@@ -0,0 +1,103 @@
+package rpmmd
+
+import (
+	"sort"
+	"strings"
+)
+
+// An Advisory is an erratum from the updateinfo metadata of a repository.
+type Advisory struct {
+	ID string `json:"id"`
+	// "security", "bugfix", "enhancement" or "newpackage"
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// e.g. "Critical", "Important", "Moderate" or "Low", empty if unknown
+	Severity string `json:"severity,omitempty"`
+	// Date when the advisory was issued, as given in the metadata
+	Issued string   `json:"issued,omitempty"`
+	CVEs   []string `json:"cves,omitempty"`
+	// The package versions that fix the advisory
+	Packages []AdvisoryPackage `json:"packages"`
+}
+
+// An AdvisoryPackage is a package version that fixes an advisory.
+type AdvisoryPackage struct {
+	Name    string `json:"name"`
+	Epoch   uint   `json:"epoch"`
+	Version string `json:"version"`
+	Release string `json:"release"`
+	Arch    string `json:"arch"`
+}
+
+// AdvisoryStatus is the state of an advisory for a set of packages.
+type AdvisoryStatus struct {
+	Advisory Advisory `json:"advisory"`
+	// True if all the packages of the set that are affected by the advisory
+	// are at least at the fixed version
+	Fixed bool `json:"fixed"`
+	// The packages of the set that are older than the fixed versions
+	Outstanding []PackageSpec `json:"outstanding,omitempty"`
+}
+
+// CheckAdvisories returns the status of the advisories that apply to the
+// packages, i.e. the advisories that fix a version of one of the packages
+// with the same name and architecture. The result is sorted by advisory ID.
+func CheckAdvisories(packages []PackageSpec, advisories []Advisory) []AdvisoryStatus {
+	type nameArch struct {
+		name, arch string
+	}
+	installed := make(map[nameArch]PackageSpec, len(packages))
+	for _, pkg := range packages {
+		installed[nameArch{pkg.Name, pkg.Arch}] = pkg
+	}
+
+	var result []AdvisoryStatus
+	for _, advisory := range advisories {
+		applies := false
+		status := AdvisoryStatus{Advisory: advisory, Fixed: true}
+		outstanding := make(map[string]bool)
+		for _, fix := range advisory.Packages {
+			pkg, ok := installed[nameArch{fix.Name, fix.Arch}]
+			if !ok {
+				continue
+			}
+			applies = true
+			if CompareEVR(pkg.Epoch, pkg.Version, pkg.Release, fix.Epoch, fix.Version, fix.Release) < 0 && !outstanding[pkg.Name+"."+pkg.Arch] {
+				outstanding[pkg.Name+"."+pkg.Arch] = true
+				status.Fixed = false
+				status.Outstanding = append(status.Outstanding, pkg)
+			}
+		}
+		if applies {
+			result = append(result, status)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Advisory.ID < result[j].Advisory.ID
+	})
+	return result
+}
+
+// OutstandingAdvisories returns the advisories that are not fixed and have one
+// of the severities, compared case-insensitively. All outstanding advisories are
+// returned if no severity is given.
+func OutstandingAdvisories(statuses []AdvisoryStatus, severities ...string) []AdvisoryStatus {
+	var result []AdvisoryStatus
+	for _, status := range statuses {
+		if status.Fixed {
+			continue
+		}
+		if len(severities) == 0 {
+			result = append(result, status)
+			continue
+		}
+		for _, severity := range severities {
+			if strings.EqualFold(status.Advisory.Severity, severity) {
+				result = append(result, status)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package rpmmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAdvisories(t *testing.T) {
+	openssl := PackageSpec{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "27.el9", Arch: "x86_64"}
+	tmux := PackageSpec{Name: "tmux", Version: "3.2a", Release: "5.el9", Arch: "x86_64"}
+	packages := []PackageSpec{openssl, tmux}
+
+	advisories := []Advisory{
+		{
+			ID:       "RHSA-2024:0002",
+			Type:     "security",
+			Severity: "Critical",
+			CVEs:     []string{"CVE-2024-0001"},
+			Packages: []AdvisoryPackage{
+				{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "28.el9", Arch: "x86_64"},
+				{Name: "openssl", Epoch: 1, Version: "3.0.7", Release: "28.el9", Arch: "i686"},
+			},
+		},
+		{
+			ID:       "RHSA-2024:0001",
+			Type:     "security",
+			Severity: "Moderate",
+			Packages: []AdvisoryPackage{{Name: "tmux", Version: "3.2a", Release: "4.el9", Arch: "x86_64"}},
+		},
+		{
+			// not installed
+			ID:       "RHSA-2024:0003",
+			Type:     "security",
+			Severity: "Critical",
+			Packages: []AdvisoryPackage{{Name: "httpd", Version: "2.4.57", Release: "5.el9", Arch: "x86_64"}},
+		},
+		{
+			// other architecture
+			ID:       "RHSA-2024:0004",
+			Type:     "security",
+			Severity: "Important",
+			Packages: []AdvisoryPackage{{Name: "tmux", Version: "3.3", Release: "1.el9", Arch: "aarch64"}},
+		},
+	}
+
+	statuses := CheckAdvisories(packages, advisories)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, AdvisoryStatus{Advisory: advisories[1], Fixed: true}, statuses[0])
+	assert.Equal(t, AdvisoryStatus{Advisory: advisories[0], Fixed: false, Outstanding: []PackageSpec{openssl}}, statuses[1])
+
+	assert.Equal(t, []AdvisoryStatus{statuses[1]}, OutstandingAdvisories(statuses))
+	assert.Equal(t, []AdvisoryStatus{statuses[1]}, OutstandingAdvisories(statuses, "critical"))
+	assert.Empty(t, OutstandingAdvisories(statuses, "Low", "Moderate"))
+}
@@ -0,0 +1,141 @@
+package rpmmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DependencyType describes why a package was added to a transaction.
+type DependencyType string
+
+const (
+	// The package or group was requested by the package set.
+	DependencyRequested DependencyType = "requested"
+
+	// The package provides a requirement of another package.
+	DependencyRequires DependencyType = "requires"
+
+	// The package provides a weak dependency (Recommends or Supplements) of
+	// another package.
+	DependencyWeak DependencyType = "weak"
+
+	// The package is a member of a requested group.
+	DependencyGroup DependencyType = "group"
+)
+
+// A PackageDependency is an edge of the dependency graph of a depsolved
+// package set chain.
+type PackageDependency struct {
+	// Name of the package or group ("@" followed by the group ID) that pulled
+	// in To. Empty for packages and groups requested by the package set.
+	From string `json:"from,omitempty"`
+
+	// Name of the package or group that was added to the transaction.
+	To string `json:"to"`
+
+	Type DependencyType `json:"type"`
+
+	// The requirement of From that is provided by To, e.g.
+	// "libc.so.6()(64bit)", for requires and weak dependencies. For weak
+	// dependencies from Supplements, it is the supplements of To that is
+	// provided by From.
+	Requirement string `json:"requirement,omitempty"`
+}
+
+func (d PackageDependency) String() string {
+	switch d.Type {
+	case DependencyRequested:
+		return fmt.Sprintf("%s is requested", d.To)
+	case DependencyGroup:
+		return fmt.Sprintf("%s is a member of %s", d.To, d.From)
+	case DependencyWeak:
+		return fmt.Sprintf("%s is recommended by %s (%s)", d.To, d.From, d.Requirement)
+	default:
+		return fmt.Sprintf("%s is required by %s (%s)", d.To, d.From, d.Requirement)
+	}
+}
+
+// DependencyGraph is the list of dependencies of a depsolved package set
+// chain. It may be derived from the metadata of the packages rather than
+// returned by the solver, see dnfjson.Solver.DepsolveWithDependencies(), in
+// which case it approximates the dependencies the solver followed.
+type DependencyGraph []PackageDependency
+
+// order in which the dependencies are followed when looking for the shortest
+// chain, so that hard requirements are preferred
+var dependencyTypeOrder = map[DependencyType]int{
+	DependencyRequested: 0,
+	DependencyGroup:     1,
+	DependencyRequires:  2,
+	DependencyWeak:      3,
+}
+
+// Why returns the shortest chain of dependencies that pulled the named package
+// into the transaction, starting with the requested package or group.
+func (g DependencyGraph) Why(name string) ([]PackageDependency, error) {
+	edges := make(map[string][]PackageDependency)
+	var queue []string
+	reason := make(map[string]PackageDependency)
+	for _, dep := range g {
+		if dep.From == "" {
+			if _, ok := reason[dep.To]; !ok {
+				reason[dep.To] = dep
+				queue = append(queue, dep.To)
+			}
+			continue
+		}
+		edges[dep.From] = append(edges[dep.From], dep)
+	}
+	for from := range edges {
+		deps := edges[from]
+		sort.SliceStable(deps, func(i, j int) bool {
+			return dependencyTypeOrder[deps[i].Type] < dependencyTypeOrder[deps[j].Type]
+		})
+	}
+
+	// breadth-first search from the requested packages
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == name {
+			break
+		}
+		for _, dep := range edges[current] {
+			if _, ok := reason[dep.To]; !ok {
+				reason[dep.To] = dep
+				queue = append(queue, dep.To)
+			}
+		}
+	}
+
+	if _, ok := reason[name]; !ok {
+		return nil, fmt.Errorf("package %q is not part of the dependency graph", name)
+	}
+
+	var chain []PackageDependency
+	for current := name; ; {
+		dep := reason[current]
+		chain = append([]PackageDependency{dep}, chain...)
+		if dep.From == "" {
+			break
+		}
+		current = dep.From
+	}
+	return chain, nil
+}
+
+// PrintWhy writes the chain of dependencies that pulled the named package into
+// the transaction to w, one dependency per line.
+func (g DependencyGraph) PrintWhy(w io.Writer, name string) error {
+	chain, err := g.Why(name)
+	if err != nil {
+		return err
+	}
+	for _, dep := range chain {
+		if _, err := fmt.Fprintln(w, dep.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package rpmmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependencyGraphWhy(t *testing.T) {
+	graph := DependencyGraph{
+		{To: "@core", Type: DependencyRequested},
+		{To: "tmux", Type: DependencyRequested},
+		{From: "@core", To: "bash", Type: DependencyGroup},
+		{From: "tmux", To: "libevent", Type: DependencyWeak, Requirement: "libevent"},
+		{From: "tmux", To: "glibc", Type: DependencyRequires, Requirement: "libc.so.6()(64bit)"},
+		{From: "bash", To: "glibc", Type: DependencyRequires, Requirement: "libc.so.6()(64bit)"},
+		{From: "glibc", To: "glibc-common", Type: DependencyRequires, Requirement: "glibc-common"},
+		{From: "bash", To: "libevent", Type: DependencyRequires, Requirement: "libevent-2.1.so()(64bit)"},
+	}
+
+	chain, err := graph.Why("tmux")
+	require.NoError(t, err)
+	assert.Equal(t, []PackageDependency{graph[1]}, chain)
+
+	// the first requested package with the shortest chain wins
+	chain, err = graph.Why("glibc-common")
+	require.NoError(t, err)
+	assert.Equal(t, []PackageDependency{graph[1], graph[4], graph[6]}, chain)
+
+	// the shortest chain wins, even if it contains a weak dependency
+	chain, err = graph.Why("libevent")
+	require.NoError(t, err)
+	assert.Equal(t, []PackageDependency{graph[1], graph[3]}, chain)
+
+	_, err = graph.Why("nano")
+	assert.EqualError(t, err, `package "nano" is not part of the dependency graph`)
+
+	var buf bytes.Buffer
+	require.NoError(t, graph.PrintWhy(&buf, "glibc-common"))
+	assert.Equal(t, `tmux is requested
+glibc is required by tmux (libc.so.6()(64bit))
+glibc-common is required by glibc (glibc-common)
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, graph.PrintWhy(&buf, "bash"))
+	assert.Equal(t, "@core is requested\nbash is a member of @core\n", buf.String())
+}
@@ -0,0 +1,196 @@
+package rpmmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/proxy"
+)
+
+var repoVarRegex = regexp.MustCompile(`\$(\{[A-Za-z0-9_]+\}|[A-Za-z0-9_]+)`)
+
+var repoVarNameRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// substituteRepoVars replaces the variables of the form $name and ${name} in
+// s with their values. An error is returned for variables without a value,
+// because leaving them in the URLs of a repository would only fail later,
+// when the repository is used.
+func substituteRepoVars(s string, vars map[string]string) (string, error) {
+	var unknown []string
+	result := repoVarRegex.ReplaceAllStringFunc(s, func(match string) string {
+		name := strings.Trim(match[1:], "{}")
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		unknown = append(unknown, match)
+		return match
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown variables %s", strings.Join(unknown, ", "))
+	}
+	return result, nil
+}
+
+// LoadRepoVars loads the variables of a dnf vars directory, such as
+// /etc/dnf/vars. The name of each file is the name of a variable and its
+// first line is the value, as dnf reads them. A missing directory yields no
+// variables.
+func LoadRepoVars(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !repoVarNameRegex.MatchString(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		value, _, _ := strings.Cut(string(data), "\n")
+		vars[entry.Name()] = strings.TrimSpace(value)
+	}
+	return vars, nil
+}
+
+// splitRepoList splits a list option of a .repo file, whose items are
+// separated by whitespace, commas or new lines.
+func splitRepoList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+func parseRepoBool(value string) (*bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "true", "on":
+		return common.ToPtr(true), nil
+	case "0", "no", "false", "off":
+		return common.ToPtr(false), nil
+	}
+	return nil, fmt.Errorf("%q is not a boolean", value)
+}
+
+// LoadRepositoriesFromRepoFile loads the repositories of a yum/dnf .repo
+// file. The variables in the values of the options, e.g. $releasever and
+// $basearch, are replaced with the given values; a variable without a value
+// is an error. Disabled repositories are returned as well, with Enabled set
+// to false.
+func LoadRepositoriesFromRepoFile(filename string, vars map[string]string) ([]RepoConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := parseRepoFile(data, vars)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load repositories from %s: %w", filename, err)
+	}
+	return repos, nil
+}
+
+func parseRepoFile(data []byte, vars map[string]string) ([]RepoConfig, error) {
+	cfg, err := ini.LoadSources(ini.LoadOptions{
+		AllowPythonMultilineValues: true,
+		SpaceBeforeInlineComment:   true,
+	}, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []RepoConfig
+	for _, section := range cfg.Sections() {
+		id := section.Name()
+		// the main section only appears in dnf.conf and yum.conf
+		if id == ini.DefaultSection || id == "main" {
+			continue
+		}
+
+		repo := RepoConfig{
+			Id:   id,
+			Name: id,
+		}
+		var proxyCACert string
+		for _, key := range section.Keys() {
+			value, err := substituteRepoVars(strings.TrimSpace(key.Value()), vars)
+			if err != nil {
+				return nil, fmt.Errorf("repository %q: invalid value of %s: %w", id, key.Name(), err)
+			}
+			switch key.Name() {
+			case "name":
+				repo.Name = value
+			case "baseurl":
+				repo.BaseURLs = splitRepoList(value)
+			case "metalink":
+				repo.Metalink = value
+			case "mirrorlist":
+				repo.MirrorList = value
+			case "gpgkey":
+				repo.GPGKeys = splitRepoList(value)
+			case "gpgcheck":
+				repo.CheckGPG, err = parseRepoBool(value)
+			case "repo_gpgcheck":
+				repo.CheckRepoGPG, err = parseRepoBool(value)
+			case "enabled":
+				repo.Enabled, err = parseRepoBool(value)
+			case "module_hotfixes":
+				repo.ModuleHotfixes, err = parseRepoBool(value)
+			case "sslverify":
+				var verify *bool
+				verify, err = parseRepoBool(value)
+				if err == nil {
+					ignore := !*verify
+					repo.IgnoreSSL = &ignore
+				}
+			case "priority":
+				priority, convErr := strconv.Atoi(value)
+				if convErr != nil {
+					err = fmt.Errorf("%q is not a number", value)
+				}
+				repo.Priority = &priority
+			case "metadata_expire":
+				repo.MetadataExpire = value
+			case "sslcacert":
+				repo.SSLCACert = value
+			case "sslclientkey":
+				repo.SSLClientKey = value
+			case "sslclientcert":
+				repo.SSLClientCert = value
+			case "proxy":
+				repo.Proxy = &proxy.Config{}
+				// "_none_" and an empty value disable the proxy of dnf.conf
+				if value != "_none_" {
+					repo.Proxy.URL = value
+				}
+				err = repo.Proxy.Validate()
+			case "proxy_sslcacert":
+				proxyCACert = value
+			}
+			if err != nil {
+				return nil, fmt.Errorf("repository %q: invalid value of %s: %w", id, key.Name(), err)
+			}
+		}
+		if len(repo.BaseURLs) == 0 && repo.Metalink == "" && repo.MirrorList == "" {
+			return nil, fmt.Errorf("repository %q has no baseurl, metalink or mirrorlist", id)
+		}
+		if repo.Enabled == nil {
+			repo.Enabled = common.ToPtr(true)
+		}
+		if repo.Proxy != nil {
+			repo.Proxy.CACert = proxyCACert
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
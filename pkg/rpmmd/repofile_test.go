@@ -0,0 +1,118 @@
+package rpmmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osbuild/images/internal/common"
+	"github.com/osbuild/images/pkg/proxy"
+)
+
+func TestLoadRepositoriesFromRepoFile(t *testing.T) {
+	repoFile := `[main]
+gpgcheck=1
+
+[fedora]
+name=Fedora $releasever - $basearch
+metalink=https://mirrors.fedoraproject.org/metalink?repo=fedora-$releasever&arch=${basearch}
+enabled=1
+gpgcheck=1
+gpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-fedora-$releasever-$basearch
+
+[updates-testing]
+baseurl=https://example.com/$releasever/$contentdir/ https://mirror.example.com/$releasever/
+enabled=0
+sslverify=false
+priority=20
+proxy_sslcacert=/etc/pki/proxy-ca.pem
+proxy=http://proxy.example.com:3128
+
+[internal]
+baseurl=https://internal.example.com/
+proxy=_none_
+`
+	path := filepath.Join(t.TempDir(), "fedora.repo")
+	require.NoError(t, os.WriteFile(path, []byte(repoFile), 0644))
+
+	repos, err := LoadRepositoriesFromRepoFile(path, map[string]string{"releasever": "40", "basearch": "x86_64", "contentdir": "pub"})
+	require.NoError(t, err)
+	assert.Equal(t, []RepoConfig{
+		{
+			Id:       "fedora",
+			Name:     "Fedora 40 - x86_64",
+			Metalink: "https://mirrors.fedoraproject.org/metalink?repo=fedora-40&arch=x86_64",
+			Enabled:  common.ToPtr(true),
+			CheckGPG: common.ToPtr(true),
+			GPGKeys:  []string{"file:///etc/pki/rpm-gpg/RPM-GPG-KEY-fedora-40-x86_64"},
+		},
+		{
+			Id:        "updates-testing",
+			Name:      "updates-testing",
+			BaseURLs:  []string{"https://example.com/40/pub/", "https://mirror.example.com/40/"},
+			Enabled:   common.ToPtr(false),
+			IgnoreSSL: common.ToPtr(true),
+			Priority:  common.ToPtr(20),
+			Proxy:     &proxy.Config{URL: "http://proxy.example.com:3128", CACert: "/etc/pki/proxy-ca.pem"},
+		},
+		{
+			Id:       "internal",
+			Name:     "internal",
+			BaseURLs: []string{"https://internal.example.com/"},
+			Enabled:  common.ToPtr(true),
+			Proxy:    &proxy.Config{},
+		},
+	}, repos)
+}
+
+func TestLoadRepoVars(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "contentdir"), []byte("centos\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stream"), []byte(" 9-stream \nignored\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "not-a-var.rpmsave"), []byte("x"), 0644))
+
+	vars, err := LoadRepoVars(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"contentdir": "centos", "stream": "9-stream"}, vars)
+
+	vars, err = LoadRepoVars(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, vars)
+}
+
+func TestParseRepoFileErrors(t *testing.T) {
+	tests := map[string]struct {
+		repoFile string
+		expErr   string
+	}{
+		"no-url": {
+			repoFile: "[empty]\nname=Empty\n",
+			expErr:   `repository "empty" has no baseurl, metalink or mirrorlist`,
+		},
+		"bad-bool": {
+			repoFile: "[repo]\nbaseurl=https://example.com\ngpgcheck=maybe\n",
+			expErr:   `repository "repo": invalid value of gpgcheck: "maybe" is not a boolean`,
+		},
+		"bad-priority": {
+			repoFile: "[repo]\nbaseurl=https://example.com\npriority=high\n",
+			expErr:   `repository "repo": invalid value of priority: "high" is not a number`,
+		},
+		"unknown-var": {
+			repoFile: "[repo]\nmetalink=https://example.com/metalink?repo=$stream&arch=${basearch}\n",
+			expErr:   `repository "repo": invalid value of metalink: unknown variables $stream, ${basearch}`,
+		},
+		"bad-proxy": {
+			repoFile: "[repo]\nbaseurl=https://example.com\nproxy=ftp://proxy.example.com\n",
+			expErr:   `repository "repo": invalid value of proxy: proxy URL "ftp://proxy.example.com" has an unsupported scheme "ftp"`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseRepoFile([]byte(tc.repoFile), nil)
+			assert.EqualError(t, err, tc.expErr)
+		})
+	}
+}
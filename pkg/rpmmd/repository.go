@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/gobwas/glob"
+
+	"github.com/osbuild/images/pkg/proxy"
 )
 
 type repository struct {
@@ -26,6 +29,9 @@ type repository struct {
 	MetadataExpire string   `json:"metadata_expire,omitempty"`
 	ImageTypeTags  []string `json:"image_type_tags,omitempty"`
 	PackageSets    []string `json:"package_sets,omitempty"`
+	SnapshotURL    string   `json:"snapshot_baseurl,omitempty"`
+
+	Proxy *proxy.Config `json:"proxy,omitempty"`
 }
 
 type RepoConfig struct {
@@ -49,6 +55,14 @@ type RepoConfig struct {
 	ImageTypeTags  []string `json:"image_type_tags,omitempty"`
 	PackageSets    []string `json:"package_sets,omitempty"`
 
+	// Template of the base URL of date-pinned snapshots of the repository,
+	// with $snapshot in place of the snapshot ID, see AtSnapshot()
+	SnapshotURL string `json:"snapshot_baseurl,omitempty"`
+
+	// Proxy of the repository. The solver-wide proxy is used if it is nil
+	// and the repository is accessed directly if its URL is empty.
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+
 	// These fields are only filled out by the worker during the
 	// depsolve job for certain baseurls.
 	SSLCACert     string `json:"sslcacert,omitempty"`
@@ -57,8 +71,8 @@ type RepoConfig struct {
 }
 
 // Hash calculates an ID string that uniquely represents a repository
-// configuration.  The Name and ImageTypeTags fields are not considered in the
-// calculation.
+// configuration.  The Name, ImageTypeTags and Proxy fields are not considered
+// in the calculation.
 func (r *RepoConfig) Hash() string {
 	bts := func(b bool) string {
 		return fmt.Sprintf("%T", b)
@@ -87,6 +101,38 @@ func (r *RepoConfig) Hash() string {
 		r.SSLClientCert)))
 }
 
+var snapshotIDRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// CheckSnapshotID returns an error if the ID cannot be used in the URL of a
+// repository snapshot.
+func CheckSnapshotID(id string) error {
+	if !snapshotIDRegex.MatchString(id) {
+		return fmt.Errorf("snapshot ID %q is invalid", id)
+	}
+	return nil
+}
+
+// AtSnapshot returns a copy of the repository pinned to the snapshot with the
+// given ID, e.g. a date such as "20240501". The base URL of the copy is the
+// SnapshotURL template with $snapshot replaced by the ID.
+func (r RepoConfig) AtSnapshot(id string) (RepoConfig, error) {
+	if err := CheckSnapshotID(id); err != nil {
+		return RepoConfig{}, err
+	}
+	if !strings.Contains(r.SnapshotURL, "$snapshot") && !strings.Contains(r.SnapshotURL, "${snapshot}") {
+		return RepoConfig{}, fmt.Errorf("repository %q has no snapshot URL template", r.Name)
+	}
+	baseURL, err := substituteRepoVars(r.SnapshotURL, map[string]string{"snapshot": id})
+	if err != nil {
+		return RepoConfig{}, fmt.Errorf("repository %q: invalid snapshot URL template: %w", r.Name, err)
+	}
+	r.BaseURLs = []string{baseURL}
+	r.Metalink = ""
+	r.MirrorList = ""
+	r.SnapshotURL = ""
+	return r, nil
+}
+
 type DistrosRepoConfigs map[string]map[string][]RepoConfig
 
 type PackageList []Package
@@ -142,16 +188,65 @@ type PackageSet struct {
 	Exclude         []string
 	Repositories    []RepoConfig
 	InstallWeakDeps bool
+	// RPM files on the host to include in addition to the packages from
+	// the repositories
+	LocalPackages []LocalPackage
+	// Exact versions of packages. Locks restrict the versions the packages
+	// can be resolved to, they do not add the packages to the package set.
+	Locks []PackageLock
+	// Module streams to enable before resolving the packages
+	EnabledModules []ModuleStream
 }
 
-// Append the Include and Exclude package list from another PackageSet and
-// return the result.
+// Append the Include, Exclude, local package, lock and module lists from
+// another PackageSet and return the result.
 func (ps PackageSet) Append(other PackageSet) PackageSet {
 	ps.Include = append(ps.Include, other.Include...)
 	ps.Exclude = append(ps.Exclude, other.Exclude...)
+	ps.LocalPackages = append(ps.LocalPackages, other.LocalPackages...)
+	ps.Locks = append(ps.Locks, other.Locks...)
+	ps.EnabledModules = append(ps.EnabledModules, other.EnabledModules...)
 	return ps
 }
 
+// A ModuleStream is a stream of a DNF module, optionally with a profile to
+// install.
+type ModuleStream struct {
+	Name    string
+	Stream  string
+	Profile string
+}
+
+// Spec returns the module stream as a dnf module spec in the form
+// "name:stream" or "name:stream/profile".
+func (m ModuleStream) Spec() string {
+	spec := m.Name + ":" + m.Stream
+	if m.Profile != "" {
+		spec += "/" + m.Profile
+	}
+	return spec
+}
+
+// A PackageLock pins a package to an exact [epoch:]version-release.
+type PackageLock struct {
+	Name string
+	EVR  string
+}
+
+// Spec returns the lock as a dnf package spec.
+func (l PackageLock) Spec() string {
+	return l.Name + "-" + l.EVR
+}
+
+// A LocalPackage is an RPM file on the host. The file name must be the NEVRA
+// of the package followed by ".rpm".
+type LocalPackage struct {
+	// Absolute path of the RPM file
+	Path string
+	// Checksum of the RPM file in the form "sha256:<hex>"
+	Checksum string
+}
+
 // TODO: the public API of this package should not be reused for serialization.
 type PackageSpec struct {
 	Name           string `json:"name"`
@@ -164,6 +259,13 @@ type PackageSpec struct {
 	Secrets        string `json:"secrets,omitempty"`
 	CheckGPG       bool   `json:"check_gpg,omitempty"`
 	IgnoreSSL      bool   `json:"ignore_ssl,omitempty"`
+
+	// Proxy of the repository of the package
+	Proxy *proxy.Config `json:"proxy,omitempty"`
+
+	// Location the RemoteLocation was rewritten from to fetch the package
+	// from a mirror, if any
+	OriginalRemoteLocation string `json:"original_remote_location,omitempty"`
 }
 
 type PackageSource struct {
@@ -266,6 +368,8 @@ func LoadRepositoriesFromFile(filename string) (map[string][]RepoConfig, error)
 				ModuleHotfixes: repo.ModuleHotfixes,
 				ImageTypeTags:  repo.ImageTypeTags,
 				PackageSets:    repo.PackageSets,
+				SnapshotURL:    repo.SnapshotURL,
+				Proxy:          repo.Proxy,
 			}
 
 			repoConfigs[arch] = append(repoConfigs[arch], config)
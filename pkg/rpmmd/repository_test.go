@@ -2,9 +2,12 @@ package rpmmd
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPackageSpecGetEVRA(t *testing.T) {
@@ -56,3 +59,33 @@ func TestRepoConfigMarshalEmpty(t *testing.T) {
 	js, _ := json.Marshal(repoCfg)
 	assert.Equal(t, string(js), `{}`)
 }
+
+func TestRepoConfigAtSnapshot(t *testing.T) {
+	reposFile := filepath.Join(t.TempDir(), "repos.json")
+	require.NoError(t, os.WriteFile(reposFile, []byte(`{
+  "x86_64": [
+    {
+      "name": "baseos",
+      "metalink": "https://mirrors.example.com/metalink?repo=baseos",
+      "snapshot_baseurl": "https://snapshots.example.com/baseos-$snapshot/x86_64/"
+    }
+  ]
+}`), 0644))
+	repos, err := LoadRepositoriesFromFile(reposFile)
+	require.NoError(t, err)
+	repo := repos["x86_64"][0]
+
+	pinned, err := repo.AtSnapshot("20240501")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://snapshots.example.com/baseos-20240501/x86_64/"}, pinned.BaseURLs)
+	assert.Empty(t, pinned.Metalink)
+	assert.Empty(t, pinned.SnapshotURL)
+	// the original repository is not modified
+	assert.Equal(t, "https://mirrors.example.com/metalink?repo=baseos", repo.Metalink)
+
+	_, err = repo.AtSnapshot("../20240501")
+	assert.EqualError(t, err, `snapshot ID "../20240501" is invalid`)
+
+	_, err = RepoConfig{Name: "appstream", BaseURLs: []string{"https://example.com/appstream"}}.AtSnapshot("20240501")
+	assert.EqualError(t, err, `repository "appstream" has no snapshot URL template`)
+}
@@ -0,0 +1,123 @@
+package rpmmd
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares two version or release strings with the rules of
+// rpm. It returns -1, 0 or 1 if a is older, equal or newer than b.
+func CompareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	isAlnum := func(r rune) bool {
+		return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+	isDigit := func(r rune) bool {
+		return r >= '0' && r <= '9'
+	}
+	isAlpha := func(r rune) bool {
+		return r < unicode.MaxASCII && unicode.IsLetter(r)
+	}
+	isSep := func(r rune) bool {
+		return !isAlnum(r) && r != '~' && r != '^'
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		a = strings.TrimLeftFunc(a, isSep)
+		b = strings.TrimLeftFunc(b, isSep)
+
+		// a tilde sorts before everything, even the end of the string
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// a caret sorts after the end of the string, but before everything
+		// else
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		split := isAlpha
+		numeric := isDigit(rune(a[0]))
+		if numeric {
+			split = isDigit
+		}
+		segA := a[:len(a)-len(strings.TrimLeftFunc(a, split))]
+		segB := b[:len(b)-len(strings.TrimLeftFunc(b, split))]
+		a, b = a[len(segA):], b[len(segB):]
+
+		if segB == "" {
+			// numeric segments are newer than alpha segments
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		if numeric {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) != len(segB) {
+				if len(segA) > len(segB) {
+					return 1
+				}
+				return -1
+			}
+		}
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// CompareEVR compares the epoch, version and release of two packages with the
+// rules of rpm. It returns -1, 0 or 1 if the first package is older, equal or
+// newer than the second.
+func CompareEVR(epochA uint, versionA, releaseA string, epochB uint, versionB, releaseB string) int {
+	if epochA != epochB {
+		if epochA > epochB {
+			return 1
+		}
+		return -1
+	}
+	if c := CompareVersions(versionA, versionB); c != 0 {
+		return c
+	}
+	return CompareVersions(releaseA, releaseB)
+}
@@ -0,0 +1,37 @@
+package rpmmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "2.0", -1},
+		{"2.0", "1.0", 1},
+		{"1.10", "1.9", 1},
+		{"1.01", "1.1", 0},
+		{"1.0a", "1.0", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.a", "1.1", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1_0", "1.0", 0},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, CompareVersions(tc.a, tc.b), "%s <=> %s", tc.a, tc.b)
+	}
+}
+
+func TestCompareEVR(t *testing.T) {
+	assert.Equal(t, 1, CompareEVR(1, "1.0", "1", 0, "2.0", "1"))
+	assert.Equal(t, -1, CompareEVR(0, "1.0", "1.el9", 0, "1.0", "2.el9"))
+	assert.Equal(t, 0, CompareEVR(2, "3.0.7", "27.el9", 2, "3.0.7", "27.el9"))
+}